@@ -0,0 +1,190 @@
+// Command dbbench benchmark the read and decode path of a configured MongoDB backend, to compare the CPU time,
+// the allocations and the garbage collections of list endpoints between versions.
+//
+//	dbbench -config storage.yaml -documents 1000 -limit 100
+//	dbbench -env STORAGE -database bench -test.benchtime 5s
+//
+// The documents are inserted in a scratch collection which is emptied at the end.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/golang-common-packages/storage"
+)
+
+// benchDocument is the data model of the documents read by the benchmarks
+type benchDocument struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Name      string             `bson:"name"`
+	Email     string             `bson:"email"`
+	Age       int                `bson:"age"`
+	Tags      []string           `bson:"tags"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
+
+func main() {
+	// Register the -test.benchtime flag of testing.Benchmark
+	testing.Init()
+	configFile := flag.String("config", "", "JSON, YAML or TOML config file")
+	envPrefix := flag.String("env", "", "prefix of the environment variables to read the config from")
+	databaseName := flag.String("database", "bench", "database of the scratch collection")
+	collectionName := flag.String("collection", "dbbench", "scratch collection, emptied at the end")
+	documents := flag.Int("documents", 1000, "number of documents inserted")
+	limit := flag.Int64("limit", 100, "limit of the reads, 0 means every document")
+	flag.Parse()
+
+	var config *storage.Config
+	var err error
+	switch {
+	case *configFile != "":
+		config, err = storage.LoadConfigFromFile(*configFile)
+	case *envPrefix != "":
+		config, err = storage.LoadConfigFromEnv(*envPrefix)
+	default:
+		log.Fatalln("One of -config or -env is required")
+	}
+	if err != nil {
+		log.Fatalln("Unable to load config: ", err)
+	}
+	if *documents <= 0 {
+		log.Fatalln("-documents must be positive")
+	}
+
+	db := storage.New(context.Background(), storage.NOSQLDOCUMENT)(storage.MONGODB, config).(storage.INoSQLDocument)
+
+	if err := seed(db, *databaseName, *collectionName, *documents); err != nil {
+		log.Fatalln("Unable to insert documents: ", err)
+	}
+	defer func() {
+		if _, err := db.Delete(*databaseName, *collectionName, bson.M{}); err != nil {
+			log.Println("Unable to empty scratch collection: ", err)
+		}
+	}()
+
+	dataModel := reflect.TypeOf(benchDocument{})
+	benchmarks := []struct {
+		name string
+		fn   func() error
+	}{
+		{"Read", func() error {
+			_, err := db.Read(*databaseName, *collectionName, bson.M{}, *limit, dataModel)
+			return err
+		}},
+		{"ReadFiltered", func() error {
+			_, err := db.Read(*databaseName, *collectionName, bson.M{"age": bson.M{"$gte": 40}}, *limit, dataModel)
+			return err
+		}},
+		{"ReadBSON", func() error {
+			_, err := db.Read(*databaseName, *collectionName, bson.M{}, *limit, reflect.TypeOf(bson.M{}))
+			return err
+		}},
+	}
+	// Cache hits marshal their keys in the pooled buffers under sustained load
+	cache := storage.New(context.Background(), storage.NOSQLKEYVALUE)(storage.CUSTOM, &storage.Config{
+		CustomKeyValue: storage.CustomKeyValue{MemorySize: 64 * 1024 * 1024},
+	}).(storage.INoSQLKeyValue)
+	cached := storage.NewCachedDatabase(db, cache, time.Minute)
+	firstID, err := getFirstID(db, *databaseName, *collectionName)
+	if err != nil {
+		log.Fatalln("Unable to read first document: ", err)
+	}
+	benchmarks = append(benchmarks, struct {
+		name string
+		fn   func() error
+	}{"CachedRead", func() error {
+		_, err := cached.Read(*databaseName, *collectionName, bson.M{"_id": firstID}, 1, dataModel)
+		return err
+	}})
+	if reader, ok := db.(storage.IReadInto); ok {
+		var results []benchDocument
+		benchmarks = append(benchmarks, struct {
+			name string
+			fn   func() error
+		}{"ReadInto", func() error {
+			// The slice is reused by every iteration
+			return reader.ReadInto(*databaseName, *collectionName, bson.M{}, *limit, &results)
+		}})
+	}
+	if aggregator, ok := db.(storage.IAggregate); ok {
+		pipeline := bson.A{bson.M{"$sort": bson.M{"_id": 1}}}
+		if *limit > 0 {
+			pipeline = append(pipeline, bson.M{"$limit": *limit})
+		}
+		benchmarks = append(benchmarks, struct {
+			name string
+			fn   func() error
+		}{"Aggregate", func() error {
+			_, err := aggregator.Aggregate(*databaseName, *collectionName, pipeline, dataModel)
+			return err
+		}})
+	}
+
+	for _, benchmark := range benchmarks {
+		var benchErr error
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		result := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := benchmark.fn(); err != nil {
+					benchErr = err
+					b.FailNow()
+				}
+			}
+		})
+		runtime.ReadMemStats(&after)
+		if benchErr != nil {
+			log.Fatalf("%s failed: %v\n", benchmark.name, benchErr)
+		}
+		fmt.Printf("%-14s %s %s %d GC\n", benchmark.name, result.String(), result.MemString(), after.NumGC-before.NumGC)
+	}
+}
+
+// seed empty the collection and insert the documents
+func seed(db storage.INoSQLDocument, databaseName, collectionName string, count int) error {
+	if _, err := db.Delete(databaseName, collectionName, bson.M{}); err != nil {
+		return err
+	}
+
+	documents := make([]interface{}, count)
+	for i := range documents {
+		documents[i] = benchDocument{
+			ID:        primitive.NewObjectID(),
+			Name:      fmt.Sprintf("User %d", i),
+			Email:     fmt.Sprintf("user%d@example.com", i),
+			Age:       18 + i%60,
+			Tags:      []string{"bench", fmt.Sprintf("group%d", i%10)},
+			CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+		}
+	}
+
+	_, err := db.Create(databaseName, collectionName, documents)
+	return err
+}
+
+// getFirstID return the _id of the first document of the collection
+func getFirstID(db storage.INoSQLDocument, databaseName, collectionName string) (primitive.ObjectID, error) {
+	results, err := db.Read(databaseName, collectionName, bson.M{}, 1, reflect.TypeOf(benchDocument{}))
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	documents := *results.(*[]benchDocument)
+	if len(documents) == 0 {
+		return primitive.NilObjectID, fmt.Errorf("No document in %s.%s", databaseName, collectionName)
+	}
+
+	return documents[0].ID, nil
+}
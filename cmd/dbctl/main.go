@@ -0,0 +1,391 @@
+// Command dbctl run ad-hoc CRUD and maintenance actions against a configured backend.
+//
+//	dbctl -config storage.yaml list shop users -filter '{"active":true}' -limit 10
+//	dbctl -config storage.yaml get shop users 5f1d7f0c8e2b3a0001a1b2c3
+//	dbctl -config storage.yaml insert shop users users.json
+//	dbctl -config storage.yaml update shop users 5f1d7f0c8e2b3a0001a1b2c3 '{"active":false}'
+//	dbctl -config storage.yaml delete shop users 5f1d7f0c8e2b3a0001a1b2c3
+//	dbctl -config storage.yaml index shop users '{"email":1}' -unique
+//	dbctl -env STORAGE health
+//
+// Filters, updates, index keys and documents are MongoDB Extended JSON. The insert file hold one document,
+// an array of documents or one document per line.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/go-redis/redis"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"github.com/golang-common-packages/storage"
+)
+
+const usage = `Usage: dbctl [-config file | -env prefix] <command> [arguments]
+
+Commands:
+  list <database> <collection> [-filter json] [-limit n]
+  get <database> <collection> <id>
+  insert <database> <collection> <file>
+  update <database> <collection> <id> <json>
+  delete <database> <collection> <id>
+  index <database> <collection> <keys> [-name name] [-unique]
+  health
+`
+
+func main() {
+	configFile := flag.String("config", "", "JSON, YAML or TOML config file")
+	envPrefix := flag.String("env", "", "prefix of the environment variables to read the config from")
+	timeout := flag.Duration("timeout", 30*time.Second, "timeout of the command")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var config *storage.Config
+	var err error
+	switch {
+	case *configFile != "":
+		config, err = storage.LoadConfigFromFile(*configFile)
+	case *envPrefix != "":
+		config, err = storage.LoadConfigFromEnv(*envPrefix)
+	default:
+		log.Fatalln("One of -config or -env is required")
+	}
+	if err != nil {
+		log.Fatalln("Unable to load config: ", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	command, args := flag.Arg(0), flag.Args()[1:]
+	if command == "health" {
+		if err := health(ctx, config); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
+	if reflect.DeepEqual(config.MongoDB, storage.MongoDB{}) {
+		log.Fatalln("mongodb config is required for ", command)
+	}
+	if len(args) < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	db := storage.New(ctx, storage.NOSQLDOCUMENT)(storage.MONGODB, config).(storage.INoSQLDocument)
+
+	switch command {
+	case "list":
+		err = list(db, args)
+	case "get":
+		err = get(db, args)
+	case "insert":
+		err = insert(db, args)
+	case "update":
+		err = update(db, args)
+	case "delete":
+		err = remove(db, args)
+	case "index":
+		err = index(db, args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// list print the documents matching the filter
+func list(db storage.INoSQLDocument, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	filter := flags.String("filter", "{}", "filter of the documents")
+	limit := flags.Int64("limit", 20, "maximum number of documents, 0 means no limit")
+	flags.Parse(args[2:])
+
+	query, err := parseJSON(*filter)
+	if err != nil {
+		return fmt.Errorf("Invalid filter: %v", err)
+	}
+
+	return read(db, args[0], args[1], query, *limit)
+}
+
+// get print the document based on its ID
+func get(db storage.INoSQLDocument, args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("get require <database> <collection> <id>")
+	}
+
+	return read(db, args[0], args[1], storage.IDFilter(args[2]), 1)
+}
+
+// insert the documents of the file
+func insert(db storage.INoSQLDocument, args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("insert require <database> <collection> <file>")
+	}
+
+	documents, err := readDocuments(args[2])
+	if err != nil {
+		return fmt.Errorf("Unable to read documents: %v", err)
+	}
+	if len(documents) == 0 {
+		return fmt.Errorf("No document in %s", args[2])
+	}
+
+	result, err := db.Create(args[0], args[1], documents)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(result)
+}
+
+// update set the fields provided on the document based on its ID
+func update(db storage.INoSQLDocument, args []string) error {
+	if len(args) != 4 {
+		return fmt.Errorf("update require <database> <collection> <id> <json>")
+	}
+
+	fields, err := parseJSON(args[3])
+	if err != nil {
+		return fmt.Errorf("Invalid update: %v", err)
+	}
+
+	result, err := db.Update(args[0], args[1], storage.IDFilter(args[2]), bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(result)
+}
+
+// remove the document based on its ID
+func remove(db storage.INoSQLDocument, args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("delete require <database> <collection> <id>")
+	}
+
+	result, err := db.Delete(args[0], args[1], storage.IDFilter(args[2]))
+	if err != nil {
+		return err
+	}
+
+	return printJSON(result)
+}
+
+// index create an index on the keys provided
+func index(db storage.INoSQLDocument, args []string) error {
+	flags := flag.NewFlagSet("index", flag.ExitOnError)
+	name := flags.String("name", "", "name of the index")
+	unique := flags.Bool("unique", false, "create an unique index")
+	if len(args) < 3 {
+		return fmt.Errorf("index require <database> <collection> <keys>")
+	}
+	flags.Parse(args[3:])
+
+	indexer, ok := db.(storage.IIndex)
+	if !ok {
+		return fmt.Errorf("Backend does not support indexes")
+	}
+
+	keys, err := parseJSON(args[2])
+	if err != nil {
+		return fmt.Errorf("Invalid keys: %v", err)
+	}
+
+	indexOptions := options.Index().SetUnique(*unique)
+	if *name != "" {
+		indexOptions.SetName(*name)
+	}
+
+	indexName, err := indexer.CreateIndex(args[0], args[1], keys, indexOptions)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(indexName)
+	return nil
+}
+
+// health ping every backend configured, the connections are opened directly since the clients of storage.New exit
+// the process when they can not connect
+func health(ctx context.Context, config *storage.Config) error {
+	healthy := true
+	check := func(name string, ping func() error) {
+		if err := ping(); err != nil {
+			healthy = false
+			fmt.Printf("%s\tDOWN\t%v\n", name, err)
+			return
+		}
+		fmt.Printf("%s\tUP\n", name)
+	}
+
+	if !reflect.DeepEqual(config.MongoDB, storage.MongoDB{}) {
+		check("mongodb", func() error {
+			clientOptions, err := storage.GetMongoDBClientOptions(&config.MongoDB)
+			if err != nil {
+				return err
+			}
+			client, err := mongo.Connect(ctx, clientOptions)
+			if err != nil {
+				return err
+			}
+			defer client.Disconnect(ctx)
+
+			return client.Ping(ctx, readpref.Primary())
+		})
+	}
+	if config.Redis != (storage.Redis{}) {
+		check("redis", func() error {
+			client := redis.NewClient(&redis.Options{
+				Addr:     config.Redis.Host,
+				Password: config.Redis.Password,
+				DB:       config.Redis.DB,
+			})
+			defer client.Close()
+
+			return client.WithContext(ctx).Ping().Err()
+		})
+	}
+	if config.LIKE != (storage.LIKE{}) {
+		check("like", func() error {
+			if !containsDriver(config.LIKE.DriverName) {
+				return fmt.Errorf("driver %s is not linked in dbctl", config.LIKE.DriverName)
+			}
+			client, err := sql.Open(config.LIKE.DriverName, config.LIKE.DataSourceName)
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+
+			return client.PingContext(ctx)
+		})
+	}
+
+	if !healthy {
+		return fmt.Errorf("Unhealthy backend")
+	}
+
+	return nil
+}
+
+// read print the documents matching the filter
+func read(db storage.INoSQLDocument, databaseName, collectionName string, filter interface{}, limit int64) error {
+	results, err := db.Read(databaseName, collectionName, filter, limit, reflect.TypeOf(bson.M{}))
+	if err != nil {
+		return err
+	}
+
+	for _, document := range *results.(*[]bson.M) {
+		if err := printJSON(document); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readDocuments return the documents of a file holding one document, an array or one document per line
+func readDocuments(path string) ([]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+
+	if bytes.HasPrefix(data, []byte("[")) {
+		var wrapper struct {
+			Documents []bson.D `bson:"documents"`
+		}
+		if err := bson.UnmarshalExtJSON([]byte(`{"documents":`+string(data)+`}`), false, &wrapper); err != nil {
+			return nil, err
+		}
+
+		documents := make([]interface{}, 0, len(wrapper.Documents))
+		for _, document := range wrapper.Documents {
+			documents = append(documents, document)
+		}
+		return documents, nil
+	}
+
+	var documents []interface{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		document, err := parseJSON(string(line))
+		if err != nil {
+			// The file hold one document on several lines
+			if len(documents) == 0 {
+				document, err := parseJSON(string(data))
+				if err != nil {
+					return nil, err
+				}
+				return []interface{}{document}, nil
+			}
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+
+	return documents, scanner.Err()
+}
+
+// parseJSON return the document of the Extended JSON string
+func parseJSON(s string) (bson.D, error) {
+	var document bson.D
+	if err := bson.UnmarshalExtJSON([]byte(s), false, &document); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// printJSON print value as relaxed Extended JSON
+func printJSON(value interface{}) error {
+	b, err := bson.MarshalExtJSON(value, false, false)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(b))
+	return nil
+}
+
+// containsDriver return true when the SQL driver is registered
+func containsDriver(driverName string) bool {
+	for _, driver := range sql.Drivers() {
+		if driver == driverName {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,330 @@
+// Command repogen generate strongly typed repositories for annotated model structs.
+//
+// Annotate the struct and its fields, then add the go:generate directive in the model file:
+//
+//	//go:generate go run github.com/golang-common-packages/storage/cmd/repogen
+//
+//	//storage:repository database=shop collection=users
+//	type User struct {
+//		ID    primitive.ObjectID `bson:"_id,omitempty"`
+//		Email string             `bson:"email" storage:"unique,required"`
+//		Name  string             `bson:"name" storage:"index"`
+//	}
+//
+// Field options: index and unique create an index and a FindBy method, required is checked by Validate.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+const annotation = "storage:repository"
+
+// model of an annotated struct
+type model struct {
+	Name           string
+	DatabaseName   string
+	CollectionName string
+	Fields         []field
+}
+
+// HasIndex return true when a field of the model is indexed
+func (m model) HasIndex() bool {
+	for _, f := range m.Fields {
+		if f.Index || f.Unique {
+			return true
+		}
+	}
+
+	return false
+}
+
+// field of an annotated struct
+type field struct {
+	Name     string
+	Type     string
+	BSONName string
+	Index    bool
+	Unique   bool
+	Required bool
+}
+
+func main() {
+	file := flag.String("file", os.Getenv("GOFILE"), "model file to read, default is the file of the go:generate directive")
+	output := flag.String("output", "", "file to write, default is <file>_repository_gen.go")
+	flag.Parse()
+
+	if *file == "" {
+		log.Fatalln("Model file is required")
+	}
+	if *output == "" {
+		*output = strings.TrimSuffix(*file, ".go") + "_repository_gen.go"
+	}
+
+	fileSet := token.NewFileSet()
+	parsed, err := parser.ParseFile(fileSet, *file, nil, parser.ParseComments)
+	if err != nil {
+		log.Fatalln("Unable to parse model file: ", err)
+	}
+
+	models, err := getModels(parsed)
+	if err != nil {
+		log.Fatalln("Unable to read annotations: ", err)
+	}
+	if len(models) == 0 {
+		log.Fatalln("No struct annotated with ", annotation)
+	}
+
+	data := struct {
+		Package     string
+		Models      []model
+		HasIndex    bool
+		HasRequired bool
+	}{Package: parsed.Name.Name, Models: models}
+	for _, m := range models {
+		data.HasIndex = data.HasIndex || m.HasIndex()
+		for _, f := range m.Fields {
+			data.HasRequired = data.HasRequired || f.Required
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := repositoryTemplate.Execute(&buf, data); err != nil {
+		log.Fatalln("Unable to generate repositories: ", err)
+	}
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalln("Unable to format generated code: ", err)
+	}
+
+	if err := ioutil.WriteFile(*output, source, 0644); err != nil {
+		log.Fatalln("Unable to write generated code: ", err)
+	}
+}
+
+// getModels return the annotated structs of the file
+func getModels(file *ast.File) ([]model, error) {
+	var models []model
+
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			structType, ok := typeSpec.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+
+			doc := typeSpec.Doc
+			if doc == nil {
+				doc = genDecl.Doc
+			}
+			arguments, ok := getAnnotation(doc)
+			if !ok {
+				continue
+			}
+
+			m := model{Name: typeSpec.Name.Name, DatabaseName: arguments["database"], CollectionName: arguments["collection"]}
+			if m.DatabaseName == "" || m.CollectionName == "" {
+				return nil, fmt.Errorf("%v: database and collection are required", m.Name)
+			}
+
+			for _, structField := range structType.Fields.List {
+				for _, name := range structField.Names {
+					if name.IsExported() {
+						m.Fields = append(m.Fields, getField(name.Name, structField))
+					}
+				}
+			}
+
+			models = append(models, m)
+		}
+	}
+
+	return models, nil
+}
+
+// getAnnotation return the key=value arguments of the annotation comment
+func getAnnotation(doc *ast.CommentGroup) (map[string]string, bool) {
+	if doc == nil {
+		return nil, false
+	}
+
+	for _, comment := range doc.List {
+		text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+		if !strings.HasPrefix(text, annotation) {
+			continue
+		}
+
+		arguments := make(map[string]string)
+		for _, argument := range strings.Fields(strings.TrimPrefix(text, annotation)) {
+			if pair := strings.SplitN(argument, "=", 2); len(pair) == 2 {
+				arguments[pair[0]] = pair[1]
+			}
+		}
+
+		return arguments, true
+	}
+
+	return nil, false
+}
+
+// getField return the field model based on its bson and storage tags
+func getField(name string, structField *ast.Field) field {
+	f := field{Name: name, Type: types.ExprString(structField.Type), BSONName: strings.ToLower(name)}
+	if structField.Tag == nil {
+		return f
+	}
+
+	tag := reflect.StructTag(strings.Trim(structField.Tag.Value, "`"))
+	if bsonName := strings.Split(tag.Get("bson"), ",")[0]; bsonName != "" {
+		f.BSONName = bsonName
+	}
+
+	for _, option := range strings.Split(tag.Get("storage"), ",") {
+		switch strings.TrimSpace(option) {
+		case "index":
+			f.Index = true
+		case "unique":
+			f.Unique = true
+		case "required":
+			f.Required = true
+		}
+	}
+
+	return f
+}
+
+var repositoryTemplate = template.Must(template.New("repository").Parse(`// Code generated by repogen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"errors"{{if .HasRequired}}
+	"fmt"{{end}}
+	"reflect"
+
+	"github.com/golang-common-packages/storage"
+	"go.mongodb.org/mongo-driver/bson"{{if .HasIndex}}
+	"go.mongodb.org/mongo-driver/mongo/options"{{end}}
+)
+{{range $model := .Models}}
+// {{.Name}}Repository provide typed CRUD actions for {{.Name}} documents of {{.DatabaseName}}.{{.CollectionName}}
+type {{.Name}}Repository struct {
+	db storage.INoSQLDocument
+}
+
+// New{{.Name}}Repository init new instance
+func New{{.Name}}Repository(db storage.INoSQLDocument) *{{.Name}}Repository {
+	return &{{.Name}}Repository{db: db}
+}
+
+// EnsureIndexes create the indexes declared on {{.Name}}, db must implement storage.IIndex
+func (r *{{.Name}}Repository) EnsureIndexes() error {
+{{- if $model.HasIndex}}
+	indexer, ok := r.db.(storage.IIndex)
+	if !ok {
+		return errors.New("Database does not support index creation")
+	}
+{{range .Fields}}{{if or .Index .Unique}}
+	if _, err := indexer.CreateIndex("{{$model.DatabaseName}}", "{{$model.CollectionName}}", bson.D{{"{{"}}Key: "{{.BSONName}}", Value: 1{{"}}"}}, options.Index().SetUnique({{.Unique}})); err != nil {
+		return err
+	}
+{{end}}{{end}}{{end}}
+	return nil
+}
+
+// Validate check the required fields of document
+func (r *{{.Name}}Repository) Validate(document *{{.Name}}) error {
+{{- range .Fields}}{{if .Required}}
+	if reflect.ValueOf(document.{{.Name}}).IsZero() {
+		return fmt.Errorf("{{.BSONName}} is required")
+	}
+{{end}}{{end}}
+	return nil
+}
+
+// FindWhere return {{.Name}} documents based on filter, limit 0 means no limit
+func (r *{{.Name}}Repository) FindWhere(filter interface{}, limit int64) ([]{{.Name}}, error) {
+	results, err := r.db.Read("{{.DatabaseName}}", "{{.CollectionName}}", filter, limit, reflect.TypeOf({{.Name}}{}))
+	if err != nil {
+		return nil, err
+	}
+
+	documents, ok := results.(*[]{{.Name}})
+	if !ok {
+		return nil, errors.New("Unable to map results to {{.Name}}")
+	}
+
+	return *documents, nil
+}
+
+// List return {{.Name}} documents, limit 0 means no limit
+func (r *{{.Name}}Repository) List(limit int64) ([]{{.Name}}, error) {
+	return r.FindWhere(bson.M{}, limit)
+}
+
+// GetByID return the {{.Name}} document based on its ID
+func (r *{{.Name}}Repository) GetByID(id string) (*{{.Name}}, error) {
+	documents, err := r.FindWhere(storage.IDFilter(id), 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(documents) == 0 {
+		return nil, storage.ErrDocumentNotFound
+	}
+
+	return &documents[0], nil
+}
+{{range .Fields}}{{if or .Index .Unique}}
+// FindBy{{.Name}} return {{$model.Name}} documents based on {{.BSONName}}
+func (r *{{$model.Name}}Repository) FindBy{{.Name}}(value {{.Type}}, limit int64) ([]{{$model.Name}}, error) {
+	return r.FindWhere(bson.M{"{{.BSONName}}": value}, limit)
+}
+{{end}}{{end}}
+// Create validate and insert the {{.Name}} documents
+func (r *{{.Name}}Repository) Create(documents ...{{.Name}}) (interface{}, error) {
+	values := make([]interface{}, 0, len(documents))
+	for i := range documents {
+		if err := r.Validate(&documents[i]); err != nil {
+			return nil, err
+		}
+		values = append(values, documents[i])
+	}
+
+	return r.db.Create("{{.DatabaseName}}", "{{.CollectionName}}", values)
+}
+
+// Update validate document and set its fields on the {{.Name}} document based on its ID
+func (r *{{.Name}}Repository) Update(id string, document {{.Name}}) (interface{}, error) {
+	if err := r.Validate(&document); err != nil {
+		return nil, err
+	}
+
+	return r.db.Update("{{.DatabaseName}}", "{{.CollectionName}}", storage.IDFilter(id), bson.M{"$set": document})
+}
+
+// Delete remove the {{.Name}} document based on its ID
+func (r *{{.Name}}Repository) Delete(id string) (interface{}, error) {
+	return r.db.Delete("{{.DatabaseName}}", "{{.CollectionName}}", storage.IDFilter(id))
+}
+{{end}}`))
@@ -0,0 +1,639 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	multierror "github.com/hashicorp/go-multierror"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// LoadConfigFromFile load config from JSON, YAML or TOML file based on the file extension.
+// Keys are the json names of config fields and durations are either like "10s" or in nanosecond.
+func LoadConfigFromFile(path string) (*Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(content, &values)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &values)
+	case ".toml":
+		err = toml.Unmarshal(content, &values)
+	default:
+		return nil, fmt.Errorf("Unsupported config file format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode through JSON so every format relies on the json names of config fields
+	normalized, err := parseDurations("", values, reflect.TypeOf(Config{}))
+	if err != nil {
+		return nil, err
+	}
+	valuesAsJSON, err := json.Marshal(normalized)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err := json.Unmarshal(valuesAsJSON, config); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// LoadConfigFromEnv load config from environment variables named by prefix and json names of config fields,
+// like STORAGE_MONGODB_HOSTS=host1:27017,host2:27017 or STORAGE_MONGODB_TRANSACTION_READ_CONCERN=majority.
+// Lists are comma separated, maps are comma separated key=value pairs and durations are either like "10s" or in nanosecond.
+func LoadConfigFromEnv(prefix string) (*Config, error) {
+	config := &Config{}
+	if err := loadFromEnv(strings.ToUpper(prefix), reflect.ValueOf(config).Elem()); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// loadFromEnv set every exported field of the struct value from its environment variable
+func loadFromEnv(prefix string, value reflect.Value) error {
+	valueType := value.Type()
+
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		name := getFieldName(field)
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+
+		envName := toEnvName(name)
+		if prefix != "" {
+			envName = prefix + "_" + envName
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := loadFromEnv(envName, value.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := os.LookupEnv(envName)
+		if !ok {
+			continue
+		}
+
+		if err := setFromString(value.Field(i), raw); err != nil {
+			return fmt.Errorf("Invalid value of %v: %v", envName, err)
+		}
+	}
+
+	return nil
+}
+
+// parseDurations return the decoded file value at path with the durations like "10s" of the fields of valueType
+// converted to nanosecond, the unknown keys are left for the JSON decoding
+func parseDurations(path string, value interface{}, valueType reflect.Type) (interface{}, error) {
+	for valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+
+	if valueType == durationType {
+		raw, ok := value.(string)
+		if !ok {
+			return value, nil
+		}
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			nanosecond, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid value of %v: %v", path, err)
+			}
+			duration = time.Duration(nanosecond)
+		}
+		return int64(duration), nil
+	}
+
+	switch valueType.Kind() {
+	case reflect.Struct:
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+		fields := map[string]reflect.Type{}
+		getFieldTypes(valueType, fields)
+		for key, item := range values {
+			fieldType, ok := fields[strings.ToLower(key)]
+			if !ok {
+				continue
+			}
+			parsed, err := parseDurations(joinPath(path, key), item, fieldType)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = parsed
+		}
+	case reflect.Map:
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+		for key, item := range values {
+			parsed, err := parseDurations(joinPath(path, key), item, valueType.Elem())
+			if err != nil {
+				return nil, err
+			}
+			values[key] = parsed
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return value, nil
+		}
+		for i, item := range items {
+			parsed, err := parseDurations(fmt.Sprintf("%v[%d]", path, i), item, valueType.Elem())
+			if err != nil {
+				return nil, err
+			}
+			items[i] = parsed
+		}
+	}
+
+	return value, nil
+}
+
+// joinPath return the path of key in the value at path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// getFieldTypes add the types of the exported fields of structType by lower case json name, the fields of embedded
+// structs are promoted like encoding/json does
+func getFieldTypes(structType reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name := getFieldName(field)
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+
+		if field.Anonymous && field.Tag.Get("json") == "" && field.Type.Kind() == reflect.Struct {
+			getFieldTypes(field.Type, fields)
+			continue
+		}
+		fields[strings.ToLower(name)] = field.Type
+	}
+}
+
+// getFieldName return the json name of struct field
+func getFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
+
+// toEnvName convert camel case name to upper snake case, like byHTTPClient to BY_HTTP_CLIENT
+func toEnvName(name string) string {
+	runes := []rune(name)
+
+	var builder strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				builder.WriteRune('_')
+			}
+		}
+		builder.WriteRune(unicode.ToUpper(r))
+	}
+
+	return builder.String()
+}
+
+// setFromString parse raw based on the kind of value
+func setFromString(value reflect.Value, raw string) error {
+	if value.Type() == durationType {
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			nanosecond, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return err
+			}
+			duration = time.Duration(nanosecond)
+		}
+		value.SetInt(int64(duration))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %v", value.Type())
+		}
+
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map type %v", value.Type())
+		}
+
+		items := reflect.MakeMap(value.Type())
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+
+			pair := strings.SplitN(item, "=", 2)
+			if len(pair) != 2 {
+				return fmt.Errorf("invalid map item %q, expected key=value", item)
+			}
+
+			element := reflect.New(value.Type().Elem()).Elem()
+			if err := setFromString(element, strings.TrimSpace(pair[1])); err != nil {
+				return err
+			}
+			items.SetMapIndex(reflect.ValueOf(strings.TrimSpace(pair[0])), element)
+		}
+		value.Set(items)
+	default:
+		return fmt.Errorf("unsupported type %v", value.Type())
+	}
+
+	return nil
+}
+
+// Validate check the values of the config sections in use and set their defaults
+func (c *Config) Validate() error {
+	var errs *multierror.Error
+
+	if !reflect.DeepEqual(c.LIKE, LIKE{}) {
+		errs = multierror.Append(errs, c.LIKE.validate())
+	}
+	if !reflect.DeepEqual(c.ClickHouse, ClickHouse{}) {
+		errs = multierror.Append(errs, c.ClickHouse.validate())
+	}
+	if !reflect.DeepEqual(c.MongoDB, MongoDB{}) {
+		errs = multierror.Append(errs, c.MongoDB.validate())
+	}
+	if !reflect.DeepEqual(c.Elasticsearch, Elasticsearch{}) {
+		errs = multierror.Append(errs, c.Elasticsearch.validate())
+	}
+	if !reflect.DeepEqual(c.DynamoDB, DynamoDB{}) {
+		errs = multierror.Append(errs, c.DynamoDB.validate())
+	}
+	if !reflect.DeepEqual(c.Couchbase, Couchbase{}) {
+		errs = multierror.Append(errs, c.Couchbase.validate())
+	}
+	if !reflect.DeepEqual(c.Embedded, Embedded{}) {
+		errs = multierror.Append(errs, c.Embedded.validate())
+	}
+	if c.Redis != (Redis{}) {
+		errs = multierror.Append(errs, c.Redis.validate())
+	}
+	if c.CustomKeyValue != (CustomKeyValue{}) {
+		errs = multierror.Append(errs, c.CustomKeyValue.validate())
+	}
+	if c.GoogleDrive != (GoogleDrive{}) {
+		errs = multierror.Append(errs, c.GoogleDrive.validate())
+	}
+	if c.CustomFile != (CustomFile{}) {
+		errs = multierror.Append(errs, c.CustomFile.validate())
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// validate SQL-LIKE config
+func (c *LIKE) validate() error {
+	var errs *multierror.Error
+
+	if c.DriverName == "" || c.DataSourceName == "" {
+		errs = multierror.Append(errs, errors.New("like: driverName and dataSourceName are required"))
+	}
+	switch c.Dialect {
+	case "", PostgresDialect, CockroachDBDialect:
+	default:
+		errs = multierror.Append(errs, fmt.Errorf("like: unknown dialect %q", c.Dialect))
+	}
+	if c.MaxTransactionRetries < 0 {
+		errs = multierror.Append(errs, errors.New("like: maxTransactionRetries must be positive"))
+	}
+	if c.MaxTransactionRetries == 0 {
+		c.MaxTransactionRetries = 10
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// validate ClickHouse config and set defaults
+func (c *ClickHouse) validate() error {
+	var errs *multierror.Error
+
+	if len(c.Addresses) == 0 {
+		errs = multierror.Append(errs, errors.New("clickhouse: addresses is required"))
+	}
+	if c.BatchSize < 0 || c.Timeout < 0 {
+		errs = multierror.Append(errs, errors.New("clickhouse: batchSize and timeout must be positive"))
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = 10000
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 60 * time.Second
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// validate MongoDB config and set defaults
+func (c *MongoDB) validate() error {
+	var errs *multierror.Error
+
+	if len(c.Hosts) == 0 && !c.Discovery.enabled() {
+		errs = multierror.Append(errs, errors.New("mongodb: hosts or discovery is required"))
+	}
+	if c.Discovery.SRV != "" && c.Discovery.Service != "" {
+		errs = multierror.Append(errs, errors.New("mongodb: discovery srv and service can not be used together"))
+	}
+	if c.Discovery.Service != "" && c.Discovery.Port == 0 {
+		c.Discovery.Port = 27017
+	}
+
+	switch c.Compatibility {
+	case "", DocumentDBCompatibility, CosmosDBCompatibility:
+	default:
+		errs = multierror.Append(errs, fmt.Errorf("mongodb: unknown compatibility %q", c.Compatibility))
+	}
+
+	if _, err := getTransactionOptions(&c.Transaction); err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("mongodb: %v", err))
+	}
+
+	if c.ReplicationLag.MaxLag > 0 && c.ReplicationLag.CheckInterval == 0 {
+		c.ReplicationLag.CheckInterval = 10 * time.Second
+	}
+
+	if c.RateLimit.Global < 0 || c.RateLimit.Burst < 0 {
+		errs = multierror.Append(errs, errors.New("mongodb: rateLimit global and burst must be positive"))
+	}
+	for collection, limit := range c.RateLimit.Collections {
+		if limit <= 0 {
+			errs = multierror.Append(errs, fmt.Errorf("mongodb: rateLimit of %s must be positive", collection))
+		}
+	}
+
+	if c.Bulkhead.MaxConcurrent < 0 || c.Bulkhead.QueueTimeout < 0 {
+		errs = multierror.Append(errs, errors.New("mongodb: bulkhead maxConcurrent and queueTimeout must be positive"))
+	}
+
+	if c.MaxDocumentSize < 0 || c.MaxDocumentSize > maxBSONDocumentSize {
+		errs = multierror.Append(errs, fmt.Errorf("mongodb: maxDocumentSize must be between 0 and %d", maxBSONDocumentSize))
+	}
+
+	if c.MaxPageSize < 0 {
+		errs = multierror.Append(errs, errors.New("mongodb: maxPageSize must be positive"))
+	}
+
+	if c.WarmUp.Connections < 0 || c.WarmUp.Timeout < 0 {
+		errs = multierror.Append(errs, errors.New("mongodb: warmUp connections and timeout must be positive"))
+	}
+
+	if c.Timeouts.Read < 0 || c.Timeouts.Write < 0 || c.Timeouts.Aggregate < 0 {
+		errs = multierror.Append(errs, errors.New("mongodb: timeouts must be positive"))
+	}
+	for collection, timeout := range c.Timeouts.Collections {
+		if timeout.Read < 0 || timeout.Write < 0 || timeout.Aggregate < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("mongodb: timeouts of %s must be positive", collection))
+		}
+	}
+
+	if c.ServerSelection.HeartbeatInterval < 0 || c.ServerSelection.ServerSelectionTimeout < 0 || c.ServerSelection.LocalThreshold < 0 {
+		errs = multierror.Append(errs, errors.New("mongodb: serverSelection durations must be positive"))
+	}
+	if c.ServerSelection.HeartbeatInterval > 0 && c.ServerSelection.HeartbeatInterval < minHeartbeatInterval {
+		errs = multierror.Append(errs, fmt.Errorf("mongodb: serverSelection heartbeatInterval can not be less than %v", minHeartbeatInterval))
+	}
+
+	if appName := getAppName(c.AppName, c.Metadata); len(appName) > maxAppNameSize {
+		errs = multierror.Append(errs, fmt.Errorf("mongodb: appName with metadata can not exceed %d bytes", maxAppNameSize))
+	}
+	for key := range c.Metadata {
+		if key == "" || strings.ContainsAny(key, "=,()") {
+			errs = multierror.Append(errs, fmt.Errorf("mongodb: invalid metadata key %q", key))
+		}
+	}
+
+	if c.Proxy.SSHHost != "" && (c.Proxy.SSHUser == "" || (c.Proxy.SSHPassword == "" && c.Proxy.SSHPrivateKey == "")) {
+		errs = multierror.Append(errs, errors.New("mongodb: proxy sshUser and sshPassword or sshPrivateKey are required"))
+	}
+	if c.Proxy.SSHHost != "" && c.Proxy.SSHKnownHosts == "" && !c.Proxy.SSHInsecureIgnoreHostKey {
+		errs = multierror.Append(errs, errors.New("mongodb: proxy sshKnownHosts is required unless sshInsecureIgnoreHostKey is set"))
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// validate Elasticsearch config and set defaults
+func (c *Elasticsearch) validate() error {
+	var errs *multierror.Error
+
+	if len(c.Addresses) == 0 {
+		errs = multierror.Append(errs, errors.New("elasticsearch: addresses is required"))
+	}
+	if c.Timeout < 0 {
+		errs = multierror.Append(errs, errors.New("elasticsearch: timeout must be positive"))
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 30 * time.Second
+	}
+	switch c.Refresh {
+	case "", "true", "false", "wait_for":
+	default:
+		errs = multierror.Append(errs, fmt.Errorf("elasticsearch: unknown refresh %q", c.Refresh))
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// validate DynamoDB config
+func (c *DynamoDB) validate() error {
+	var errs *multierror.Error
+
+	if c.Region == "" {
+		errs = multierror.Append(errs, errors.New("dynamodb: region is required"))
+	}
+	if (c.AccessKeyID == "") != (c.SecretAccessKey == "") {
+		errs = multierror.Append(errs, errors.New("dynamodb: accessKeyID and secretAccessKey must be set together"))
+	}
+	for collectionName, table := range c.Tables {
+		if table.HashKey == "" {
+			errs = multierror.Append(errs, fmt.Errorf("dynamodb: hashKey of table %q is required", collectionName))
+		}
+		for _, index := range table.Indexes {
+			if index.Name == "" || index.HashKey == "" {
+				errs = multierror.Append(errs, fmt.Errorf("dynamodb: name and hashKey of the indexes of table %q are required", collectionName))
+			}
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// validate Couchbase config and set defaults
+func (c *Couchbase) validate() error {
+	var errs *multierror.Error
+
+	if len(c.Addresses) == 0 {
+		errs = multierror.Append(errs, errors.New("couchbase: addresses is required"))
+	}
+	if err := checkCouchbaseDurability(c.Durability); err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("couchbase: %w", err))
+	}
+	switch c.ScanConsistency {
+	case "", "request_plus", "not_bounded":
+	default:
+		errs = multierror.Append(errs, fmt.Errorf("couchbase: unknown scanConsistency %q", c.ScanConsistency))
+	}
+	if c.Timeout < 0 {
+		errs = multierror.Append(errs, errors.New("couchbase: timeout must be positive"))
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 30 * time.Second
+	}
+	if c.Scope == "" {
+		c.Scope = "_default"
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// validate embedded store config
+func (c *Embedded) validate() error {
+	var errs *multierror.Error
+
+	if c.Path == "" {
+		errs = multierror.Append(errs, errors.New("embedded: path is required"))
+	}
+	for collectionName, fields := range c.Indexes {
+		for _, field := range fields {
+			if err := checkFieldPath(field); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("embedded: invalid index %q of collection %q", field, collectionName))
+			}
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// validate Redis config
+func (c *Redis) validate() error {
+	if c.Host == "" {
+		return errors.New("redis: host is required")
+	}
+
+	return nil
+}
+
+// validate custom key-value config and set defaults
+func (c *CustomKeyValue) validate() error {
+	if c.MemorySize <= 0 {
+		return errors.New("customKeyValue: memorySize must be positive")
+	}
+	if c.CleaningInterval <= 0 {
+		c.CleaningInterval = time.Minute
+	}
+
+	return nil
+}
+
+// validate Google Drive config and set defaults
+func (c *GoogleDrive) validate() error {
+	if c.Credential == "" {
+		return errors.New("googleDrive: credential is required")
+	}
+	if c.PoolSize <= 0 {
+		c.PoolSize = 1
+	}
+
+	return nil
+}
+
+// validate custom file config and set defaults
+func (c *CustomFile) validate() error {
+	if c.RootServiceDirectory == "" {
+		return errors.New("customFile: rootDirectory is required")
+	}
+	if c.PoolSize <= 0 {
+		c.PoolSize = 1
+	}
+
+	return nil
+}
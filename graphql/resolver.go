@@ -0,0 +1,222 @@
+// Package graphql map GraphQL list, get, create, update and delete resolvers (gqlgen compatible) onto
+// storage.INoSQLDocument, with Relay cursor pagination over the _id order. The resolvers are bound to the request
+// context with For.
+//
+//	type UserConnection = graphql.Connection[User]
+//
+//	func (r *queryResolver) Users(ctx context.Context, first *int, after *string) (*UserConnection, error) {
+//		return r.users.For(ctx).List(first, after)
+//	}
+package graphql
+
+import (
+	"context"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/golang-common-packages/storage"
+)
+
+const (
+	// DefaultPageSize is the number of nodes of a connection when first is not provided
+	DefaultPageSize = 20
+	// MaxPageSize is the maximum number of nodes of a connection, storage.ErrInvalidPageSize is returned above it
+	MaxPageSize = 100
+)
+
+// Connection model for Relay connection
+type Connection[T any] struct {
+	Edges    []*Edge[T] `json:"edges"`
+	PageInfo *PageInfo  `json:"pageInfo"`
+}
+
+// Edge model for Relay edge
+type Edge[T any] struct {
+	Cursor string `json:"cursor"`
+	Node   *T     `json:"node"`
+}
+
+// PageInfo model for Relay page info
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor"`
+	EndCursor       *string `json:"endCursor"`
+}
+
+// Resolver map GraphQL operations of T documents onto one collection
+type Resolver[T any] struct {
+	db             storage.INoSQLDocument
+	databaseName   string
+	collectionName string
+	repository     *storage.Repository[T]
+}
+
+// NewResolver init new resolver of T documents of databaseName.collectionName
+func NewResolver[T any](db storage.INoSQLDocument, databaseName, collectionName string) *Resolver[T] {
+	return &Resolver[T]{
+		db:             db,
+		databaseName:   databaseName,
+		collectionName: collectionName,
+		repository:     storage.NewRepository[T](db, databaseName, collectionName),
+	}
+}
+
+// For return a resolver running the operations with the resolver context ctx when the database implements
+// storage.IContextBinder, the resolver is returned as is otherwise
+func (r *Resolver[T]) For(ctx context.Context) *Resolver[T] {
+	binder, ok := r.db.(storage.IContextBinder)
+	if !ok {
+		return r
+	}
+
+	return NewResolver[T](binder.BindContext(ctx), r.databaseName, r.collectionName)
+}
+
+// List resolve the connection of first nodes after the cursor, ordered by _id
+func (r *Resolver[T]) List(first *int, after *string) (*Connection[T], error) {
+	return r.ListWhere(bson.M{}, first, after)
+}
+
+// ListWhere resolve the connection of first nodes matching filter after the cursor, ordered by _id. The page is read
+// with ReadPage when the database implements storage.IPage, the cursors are the keyset cursors of the _id order.
+func (r *Resolver[T]) ListWhere(filter bson.M, first *int, after *string) (*Connection[T], error) {
+	limit := DefaultPageSize
+	if first != nil {
+		if *first < 0 || *first > MaxPageSize {
+			return nil, storage.ErrInvalidPageSize
+		}
+		limit = *first
+	}
+
+	cursor := ""
+	if after != nil {
+		cursor = *after
+	}
+
+	connection := &Connection[T]{Edges: []*Edge[T]{}, PageInfo: &PageInfo{HasPreviousPage: cursor != ""}}
+	if limit == 0 {
+		return connection, nil
+	}
+
+	documents, hasNext, err := r.readPage(filter, limit, cursor)
+	if err != nil {
+		return nil, err
+	}
+	connection.PageInfo.HasNextPage = hasNext
+
+	for i := range documents {
+		id, err := storage.GetDocumentID(documents[i])
+		if err != nil {
+			return nil, err
+		}
+
+		cursor, err := storage.EncodeKeysetCursor(nil, id)
+		if err != nil {
+			return nil, err
+		}
+
+		connection.Edges = append(connection.Edges, &Edge[T]{Cursor: cursor, Node: &documents[i]})
+	}
+
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.StartCursor = &connection.Edges[0].Cursor
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+
+	return connection, nil
+}
+
+// readPage return limit documents matching filter after the cursor in _id order and true when there is a next page
+func (r *Resolver[T]) readPage(filter bson.M, limit int, cursor string) ([]T, bool, error) {
+	if pager, ok := r.db.(storage.IPage); ok {
+		var model T
+		page, err := pager.ReadPage(r.databaseName, r.collectionName, filter, &storage.PageOptions{
+			Limit: int64(limit),
+			After: cursor,
+		}, reflect.TypeOf(model))
+		if err != nil {
+			return nil, false, err
+		}
+
+		return *page.Data.(*[]T), page.Next != "", nil
+	}
+
+	pageFilter := bson.M{}
+	for key, value := range filter {
+		pageFilter[key] = value
+	}
+	if cursor != "" {
+		_, id, err := storage.DecodeKeysetCursor(cursor)
+		if err != nil {
+			return nil, false, err
+		}
+		pageFilter = bson.M{"$and": bson.A{pageFilter, bson.M{"_id": bson.M{"$gt": id}}}}
+	}
+
+	// Read one more node to know if there is a next page
+	documents, err := r.repository.FindWhere(pageFilter, int64(limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(documents) > limit {
+		return documents[:limit], true, nil
+	}
+
+	return documents, false, nil
+}
+
+// Get resolve the node based on its ID, nil when it does not exist
+func (r *Resolver[T]) Get(id string) (*T, error) {
+	document, err := r.repository.GetByID(id)
+	if err == storage.ErrDocumentNotFound {
+		return nil, nil
+	}
+
+	return document, err
+}
+
+// Create insert input and resolve the node created
+func (r *Resolver[T]) Create(input T) (*T, error) {
+	result, err := r.repository.Create(input)
+	if err != nil {
+		return nil, err
+	}
+
+	insertResult, ok := result.(*mongo.InsertManyResult)
+	if !ok || len(insertResult.InsertedIDs) == 0 {
+		return &input, nil
+	}
+
+	documents, err := r.repository.FindWhere(bson.M{"_id": insertResult.InsertedIDs[0]}, 1)
+	if err != nil || len(documents) == 0 {
+		return &input, err
+	}
+
+	return &documents[0], nil
+}
+
+// Update set the fields of input on the node based on its ID and resolve the node updated, nil when it does not exist
+func (r *Resolver[T]) Update(id string, input interface{}) (*T, error) {
+	if _, err := r.db.Update(r.databaseName, r.collectionName, storage.IDFilter(id), bson.M{"$set": input}); err != nil {
+		return nil, err
+	}
+
+	return r.Get(id)
+}
+
+// Delete remove the node based on its ID, false when it does not exist
+func (r *Resolver[T]) Delete(id string) (bool, error) {
+	result, err := r.repository.Delete(id)
+	if err != nil {
+		return false, err
+	}
+
+	if deleteResult, ok := result.(*mongo.DeleteResult); ok {
+		return deleteResult.DeletedCount > 0, nil
+	}
+
+	return true, nil
+}
@@ -0,0 +1,326 @@
+// Package grpc expose the CRUD and query operations of storage.INoSQLDocument over gRPC, see storage.proto.
+//
+//	server := googlegrpc.NewServer()
+//	grpc.Register(server, grpc.NewServer(db))
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	googlegrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/golang-common-packages/storage"
+)
+
+// DatabaseServer interface of the storage.v1.Database service
+type DatabaseServer interface {
+	Create(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error)
+	Read(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error)
+	Update(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error)
+	Delete(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error)
+	Aggregate(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error)
+}
+
+// Server implement the storage.v1.Database service
+type Server struct {
+	db storage.INoSQLDocument
+}
+
+// request private model for the fields shared by every request
+type request struct {
+	Database   string        `json:"database"`
+	Collection string        `json:"collection"`
+	Limit      int64         `json:"limit"`
+	Filter     interface{}   `json:"filter"`
+	Update     interface{}   `json:"update"`
+	Documents  []interface{} `json:"documents"`
+	Pipeline   []interface{} `json:"pipeline"`
+}
+
+var (
+	documentType = reflect.TypeOf(bson.M{})
+)
+
+// NewServer init new instance serving db
+func NewServer(db storage.INoSQLDocument) *Server {
+	return &Server{db: db}
+}
+
+// Register the storage.v1.Database service of server on grpcServer
+func Register(grpcServer *googlegrpc.Server, server DatabaseServer) {
+	grpcServer.RegisterService(&serviceDesc, server)
+}
+
+// Create insert documents
+func (s *Server) Create(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	req, err := decodeRequest(in)
+	if err != nil {
+		return nil, err
+	}
+
+	documents := make([]interface{}, 0, len(req.Documents))
+	for _, document := range req.Documents {
+		value, err := toBSON(document)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		documents = append(documents, value)
+	}
+
+	result, err := s.getDatabase(ctx).Create(req.Database, req.Collection, documents)
+	if err != nil {
+		return nil, getErrorStatus(err)
+	}
+
+	return toStruct(getResult(result))
+}
+
+// Read documents based on filter
+func (s *Server) Read(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	req, err := decodeRequest(in)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := toBSON(req.Filter)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	results, err := s.getDatabase(ctx).Read(req.Database, req.Collection, filter, req.Limit, documentType)
+	if err != nil {
+		return nil, getErrorStatus(err)
+	}
+
+	return toStruct(bson.M{"documents": results})
+}
+
+// Update documents based on filter
+func (s *Server) Update(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	req, err := decodeRequest(in)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := toRequiredBSON("filter", req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	update, err := toRequiredBSON("update", req.Update)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.getDatabase(ctx).Update(req.Database, req.Collection, filter, update)
+	if err != nil {
+		return nil, getErrorStatus(err)
+	}
+
+	return toStruct(getResult(result))
+}
+
+// Delete documents based on filter
+func (s *Server) Delete(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	req, err := decodeRequest(in)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := toRequiredBSON("filter", req.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.getDatabase(ctx).Delete(req.Database, req.Collection, filter)
+	if err != nil {
+		return nil, getErrorStatus(err)
+	}
+
+	return toStruct(getResult(result))
+}
+
+// Aggregate run the aggregation pipeline
+func (s *Server) Aggregate(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	aggregator, ok := s.getDatabase(ctx).(storage.IAggregate)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "Database does not support aggregation")
+	}
+
+	req, err := decodeRequest(in)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := make(bson.A, 0, len(req.Pipeline))
+	for _, stage := range req.Pipeline {
+		value, err := toBSON(stage)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		pipeline = append(pipeline, value)
+	}
+
+	results, err := aggregator.Aggregate(req.Database, req.Collection, pipeline, documentType)
+	if err != nil {
+		return nil, getErrorStatus(err)
+	}
+
+	return toStruct(bson.M{"documents": results})
+}
+
+// getDatabase return the database bound to the RPC context when it implements storage.IContextBinder, so the
+// deadline, the cancellation and the values of ctx, like the scope or the actor, reach the operations
+func (s *Server) getDatabase(ctx context.Context) storage.INoSQLDocument {
+	if binder, ok := s.db.(storage.IContextBinder); ok {
+		return binder.BindContext(ctx)
+	}
+
+	return s.db
+}
+
+// decodeRequest map the request struct and check the database and collection
+func decodeRequest(in *structpb.Struct) (*request, error) {
+	b, err := json.Marshal(in.AsMap())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	req := &request{}
+	if err := json.Unmarshal(b, req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if req.Database == "" || req.Collection == "" {
+		return nil, status.Error(codes.InvalidArgument, "database and collection are required")
+	}
+
+	return req, nil
+}
+
+// toBSON convert the Extended JSON value to BSON document, nil is the empty document
+func toBSON(value interface{}) (bson.M, error) {
+	if value == nil {
+		return bson.M{}, nil
+	}
+
+	if _, ok := value.(map[string]interface{}); !ok {
+		return nil, errors.New("Document must be an object")
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var document bson.M
+	if err := bson.UnmarshalExtJSON(b, false, &document); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// toRequiredBSON convert the Extended JSON value of the request field name like toBSON, a missing value is refused
+// so a request without filter can not update or delete the whole collection
+func toRequiredBSON(name string, value interface{}) (bson.M, error) {
+	if value == nil {
+		return nil, status.Error(codes.InvalidArgument, name+" is required")
+	}
+
+	document, err := toBSON(value)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return document, nil
+}
+
+// toStruct convert the BSON document to struct through Extended JSON
+func toStruct(document bson.M) (*structpb.Struct, error) {
+	b, err := bson.MarshalExtJSON(document, false, false)
+	if err != nil {
+		return nil, getErrorStatus(err)
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(b, &values); err != nil {
+		return nil, getErrorStatus(err)
+	}
+
+	out, err := structpb.NewStruct(values)
+	if err != nil {
+		return nil, getErrorStatus(err)
+	}
+
+	return out, nil
+}
+
+// getResult map the write result to response document
+func getResult(result interface{}) bson.M {
+	switch r := result.(type) {
+	case *mongo.InsertManyResult:
+		return bson.M{"insertedIds": r.InsertedIDs}
+	case *mongo.UpdateResult:
+		return bson.M{"matchedCount": r.MatchedCount, "modifiedCount": r.ModifiedCount, "upsertedCount": r.UpsertedCount, "upsertedId": r.UpsertedID}
+	case *mongo.DeleteResult:
+		return bson.M{"deletedCount": r.DeletedCount}
+	}
+
+	return bson.M{"result": result}
+}
+
+// getErrorStatus map the database error to a gRPC status error
+func getErrorStatus(err error) error {
+	var invalidArgument *storage.InvalidArgumentError
+	if errors.As(err, &invalidArgument) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
+
+// unaryHandler return the gRPC method handler calling method of DatabaseServer
+func unaryHandler(name string, method func(s DatabaseServer, ctx context.Context, in *structpb.Struct) (*structpb.Struct, error)) googlegrpc.MethodDesc {
+	return googlegrpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor googlegrpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(structpb.Struct)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+
+			if interceptor == nil {
+				return method(srv.(DatabaseServer), ctx, in)
+			}
+
+			info := &googlegrpc.UnaryServerInfo{Server: srv, FullMethod: "/storage.v1.Database/" + name}
+			return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return method(srv.(DatabaseServer), ctx, req.(*structpb.Struct))
+			})
+		},
+	}
+}
+
+// serviceDesc describe the storage.v1.Database service of storage.proto
+var serviceDesc = googlegrpc.ServiceDesc{
+	ServiceName: "storage.v1.Database",
+	HandlerType: (*DatabaseServer)(nil),
+	Methods: []googlegrpc.MethodDesc{
+		unaryHandler("Create", DatabaseServer.Create),
+		unaryHandler("Read", DatabaseServer.Read),
+		unaryHandler("Update", DatabaseServer.Update),
+		unaryHandler("Delete", DatabaseServer.Delete),
+		unaryHandler("Aggregate", DatabaseServer.Aggregate),
+	},
+	Streams:  []googlegrpc.StreamDesc{},
+	Metadata: "storage.proto",
+}
@@ -0,0 +1,338 @@
+// Package http mount standard CRUD endpoints of a model onto net/http, chi or any router accepting http.Handler.
+//
+//	handler := http.New("/users", db, "shop", "users", reflect.TypeOf(User{}))
+//
+//	// net/http
+//	handler.Mount(mux)
+//
+//	// chi
+//	router.Mount("/users", handler)
+//
+// Endpoints: GET /users?limit=20&after=<cursor>, GET /users/{id}, POST /users, PUT /users/{id}, DELETE /users/{id}
+// and PATCH /users/{id} with a JSON Patch or JSON Merge Patch body when db implements storage.IPatch.
+//
+// When db implements storage.IPage, lists are also sorted by any field with sort=createdAt and order=desc
+// and read backward with before=<previous>, the cursors are opaque. Otherwise lists are in _id order and the
+// after cursor is the last ID of the previous page.
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	stdhttp "net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/golang-common-packages/storage"
+)
+
+const (
+	// DefaultPageSize is the number of documents of a list page when limit is not provided
+	DefaultPageSize = 20
+	// MaxPageSize is the default maximum number of documents of a list page, see SetMaxPageSize
+	MaxPageSize = 100
+)
+
+// Handler serve CRUD endpoints for the documents of one collection
+type Handler struct {
+	prefix         string
+	db             storage.INoSQLDocument
+	databaseName   string
+	collectionName string
+	dataModel      reflect.Type
+	maxPageSize    int64
+}
+
+// Page model for list endpoint response
+type Page struct {
+	Data     interface{} `json:"data"`
+	Next     string      `json:"next,omitempty"`     // pass as after to get the next page
+	Previous string      `json:"previous,omitempty"` // pass as before to get the previous page
+}
+
+// errorResponse private model for error response
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// New init new handler serving dataModel documents of databaseName.collectionName under prefix
+func New(prefix string, db storage.INoSQLDocument, databaseName, collectionName string, dataModel reflect.Type) *Handler {
+	return &Handler{
+		prefix:         strings.TrimSuffix(prefix, "/"),
+		db:             db,
+		databaseName:   databaseName,
+		collectionName: collectionName,
+		dataModel:      dataModel,
+		maxPageSize:    MaxPageSize,
+	}
+}
+
+// SetMaxPageSize change the maximum limit of list requests, larger limits are rejected with 400 Bad Request
+func (h *Handler) SetMaxPageSize(size int64) *Handler {
+	if size > 0 {
+		h.maxPageSize = size
+	}
+
+	return h
+}
+
+// Mount register the handler on mux for the prefix and its sub paths
+func (h *Handler) Mount(mux *stdhttp.ServeMux) {
+	mux.Handle(h.prefix, h)
+	mux.Handle(h.prefix+"/", h)
+}
+
+// ServeHTTP route the request to the CRUD actions, the operations are bound to the request context when db
+// implements storage.IContextBinder
+func (h *Handler) ServeHTTP(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	h = h.bind(r.Context())
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, h.prefix), "/")
+
+	switch {
+	case id == "" && r.Method == stdhttp.MethodGet:
+		h.list(w, r)
+	case id == "" && r.Method == stdhttp.MethodPost:
+		h.create(w, r)
+	case id != "" && r.Method == stdhttp.MethodGet:
+		h.get(w, id)
+	case id != "" && r.Method == stdhttp.MethodPut:
+		h.update(w, r, id)
+	case id != "" && r.Method == stdhttp.MethodDelete:
+		h.delete(w, id)
+	case id != "" && r.Method == stdhttp.MethodPatch:
+		h.patch(w, r, id)
+	default:
+		writeError(w, stdhttp.StatusMethodNotAllowed, fmt.Errorf("Method %v is not allowed", r.Method))
+	}
+}
+
+// bind return a copy of the handler with db bound to ctx when it implements storage.IContextBinder
+func (h *Handler) bind(ctx context.Context) *Handler {
+	binder, ok := h.db.(storage.IContextBinder)
+	if !ok {
+		return h
+	}
+
+	bound := *h
+	bound.db = binder.BindContext(ctx)
+	return &bound
+}
+
+// list a page of documents after or before the cursor provided
+func (h *Handler) list(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	query := r.URL.Query()
+	limit := int64(DefaultPageSize)
+	if limit > h.maxPageSize {
+		limit = h.maxPageSize
+	}
+	if raw := query.Get("limit"); raw != "" {
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || value <= 0 || value > h.maxPageSize {
+			writeError(w, stdhttp.StatusBadRequest, fmt.Errorf("limit must be between 1 and %v", h.maxPageSize))
+			return
+		}
+		limit = value
+	}
+
+	order := query.Get("order")
+	if order != "" && order != "asc" && order != "desc" {
+		writeError(w, stdhttp.StatusBadRequest, errors.New("order must be asc or desc"))
+		return
+	}
+	if query.Get("after") != "" && query.Get("before") != "" {
+		writeError(w, stdhttp.StatusBadRequest, errors.New("after and before cannot be used together"))
+		return
+	}
+
+	if pager, ok := h.db.(storage.IPage); ok {
+		page, err := pager.ReadPage(h.databaseName, h.collectionName, bson.M{}, &storage.PageOptions{
+			SortField:  query.Get("sort"),
+			Descending: order == "desc",
+			Limit:      limit,
+			After:      query.Get("after"),
+			Before:     query.Get("before"),
+		}, h.dataModel)
+		if errors.Is(err, storage.ErrInvalidCursor) || errors.Is(err, storage.ErrInvalidPageSize) || errors.Is(err, storage.ErrOperatorInjection) {
+			writeError(w, stdhttp.StatusBadRequest, err)
+			return
+		}
+		if err != nil {
+			writeError(w, getStatus(err), err)
+			return
+		}
+
+		writeJSON(w, stdhttp.StatusOK, Page{Data: page.Data, Next: page.Next, Previous: page.Previous})
+		return
+	}
+
+	if query.Get("sort") != "" || query.Get("before") != "" || order == "desc" {
+		writeError(w, stdhttp.StatusBadRequest, errors.New("sort, order and before are not supported by the database"))
+		return
+	}
+
+	filter := bson.M{}
+	if after := query.Get("after"); after != "" {
+		filter["_id"] = bson.M{"$gt": storage.IDFilter(after)["_id"]}
+	}
+
+	results, err := h.db.Read(h.databaseName, h.collectionName, filter, limit, h.dataModel)
+	if err != nil {
+		writeError(w, getStatus(err), err)
+		return
+	}
+
+	page := Page{Data: results}
+	documents := reflect.ValueOf(results).Elem()
+	if int64(documents.Len()) == limit {
+		page.Next = getID(documents.Index(documents.Len() - 1).Interface())
+	}
+
+	writeJSON(w, stdhttp.StatusOK, page)
+}
+
+// get the document based on its ID
+func (h *Handler) get(w stdhttp.ResponseWriter, id string) {
+	results, err := h.db.Read(h.databaseName, h.collectionName, storage.IDFilter(id), 1, h.dataModel)
+	if err != nil {
+		writeError(w, getStatus(err), err)
+		return
+	}
+
+	documents := reflect.ValueOf(results).Elem()
+	if documents.Len() == 0 {
+		writeError(w, stdhttp.StatusNotFound, storage.ErrDocumentNotFound)
+		return
+	}
+
+	writeJSON(w, stdhttp.StatusOK, documents.Index(0).Interface())
+}
+
+// create the document from request body
+func (h *Handler) create(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+	document := reflect.New(h.dataModel).Interface()
+	if err := json.NewDecoder(r.Body).Decode(document); err != nil {
+		writeError(w, stdhttp.StatusBadRequest, err)
+		return
+	}
+
+	result, err := h.db.Create(h.databaseName, h.collectionName, []interface{}{document})
+	if err != nil {
+		writeError(w, getStatus(err), err)
+		return
+	}
+
+	writeJSON(w, stdhttp.StatusCreated, result)
+}
+
+// update the document fields from request body
+func (h *Handler) update(w stdhttp.ResponseWriter, r *stdhttp.Request, id string) {
+	document := reflect.New(h.dataModel).Interface()
+	if err := json.NewDecoder(r.Body).Decode(document); err != nil {
+		writeError(w, stdhttp.StatusBadRequest, err)
+		return
+	}
+
+	result, err := h.db.Update(h.databaseName, h.collectionName, storage.IDFilter(id), bson.M{"$set": document})
+	if err != nil {
+		writeError(w, getStatus(err), err)
+		return
+	}
+
+	if updateResult, ok := result.(*mongo.UpdateResult); ok && updateResult.MatchedCount == 0 {
+		writeError(w, stdhttp.StatusNotFound, storage.ErrDocumentNotFound)
+		return
+	}
+
+	writeJSON(w, stdhttp.StatusOK, result)
+}
+
+// delete the document based on its ID
+func (h *Handler) delete(w stdhttp.ResponseWriter, id string) {
+	result, err := h.db.Delete(h.databaseName, h.collectionName, storage.IDFilter(id))
+	if err != nil {
+		writeError(w, getStatus(err), err)
+		return
+	}
+
+	if deleteResult, ok := result.(*mongo.DeleteResult); ok && deleteResult.DeletedCount == 0 {
+		writeError(w, stdhttp.StatusNotFound, storage.ErrDocumentNotFound)
+		return
+	}
+
+	w.WriteHeader(stdhttp.StatusNoContent)
+}
+
+// patch apply the JSON Patch or JSON Merge Patch of request body and return the patched document
+func (h *Handler) patch(w stdhttp.ResponseWriter, r *stdhttp.Request, id string) {
+	patcher, ok := h.db.(storage.IPatch)
+	if !ok {
+		writeError(w, stdhttp.StatusMethodNotAllowed, fmt.Errorf("Method %v is not allowed", r.Method))
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, stdhttp.StatusBadRequest, err)
+		return
+	}
+
+	if err := patcher.ApplyPatch(h.databaseName, h.collectionName, storage.IDFilter(id)["_id"], body); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrDocumentNotFound):
+			writeError(w, stdhttp.StatusNotFound, err)
+		case errors.Is(err, storage.ErrPatchTestFailed):
+			writeError(w, stdhttp.StatusConflict, err)
+		case errors.Is(err, storage.ErrInvalidPatch), errors.Is(err, storage.ErrPatchPathNotFound):
+			writeError(w, stdhttp.StatusUnprocessableEntity, err)
+		default:
+			writeError(w, getStatus(err), err)
+		}
+		return
+	}
+
+	h.get(w, id)
+}
+
+// getID return the _id of document as string
+func getID(document interface{}) string {
+	id, err := storage.GetDocumentID(document)
+	if err != nil {
+		return ""
+	}
+
+	if objectID, ok := id.(primitive.ObjectID); ok {
+		return objectID.Hex()
+	}
+
+	return fmt.Sprint(id)
+}
+
+// getStatus return the HTTP status of the database error
+func getStatus(err error) int {
+	var invalidArgument *storage.InvalidArgumentError
+	if errors.As(err, &invalidArgument) {
+		return stdhttp.StatusBadRequest
+	}
+
+	return stdhttp.StatusInternalServerError
+}
+
+// writeJSON write value as JSON response
+func writeJSON(w stdhttp.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError write err as JSON response
+func writeError(w stdhttp.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
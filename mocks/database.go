@@ -0,0 +1,340 @@
+package mocks
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/golang-common-packages/storage"
+)
+
+const (
+	// Anything match any argument of an expectation, like testify mock.Anything
+	Anything = mock.Anything
+)
+
+// TestingT is the part of testing.T used by AssertExpectations
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// Database is a deterministic storage.INoSQLDocument and storage.IAggregate mock with an expectation API.
+// Calls match the first expectation declared with the same arguments and remaining calls, documents are
+// compared whatever the order of their keys, so bson.M arguments match.
+//
+//	db := mocks.NewDatabase()
+//	db.ExpectGetByField("shop", "users", "_id", "x").ReturnDocuments(bson.M{"_id": "x", "name": "Alice"})
+//	...
+//	db.AssertExpectations(t)
+type Database struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	unexpected   []string
+	capabilities storage.Capabilities
+}
+
+// Expectation of Database, returned by the Expect methods to set the results
+type Expectation struct {
+	operation      string
+	databaseName   string
+	collectionName string
+	arguments      []interface{}
+	result         interface{}
+	documents      []interface{}
+	err            error
+	times          int
+	calls          int
+}
+
+// NewDatabase init new mock without expectations, with aggregation as only capability
+func NewDatabase() *Database {
+	return &Database{capabilities: storage.Capabilities{Aggregation: true}}
+}
+
+// SetCapabilities set the capabilities returned by Capabilities, to test the feature detection of the code under test
+func (d *Database) SetCapabilities(capabilities storage.Capabilities) *Database {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.capabilities = capabilities
+	return d
+}
+
+// Capabilities implement storage.ICapabilities
+func (d *Database) Capabilities() storage.Capabilities {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.capabilities
+}
+
+// ExpectCreate expect Create of documents, Anything match every document list
+func (d *Database) ExpectCreate(databaseName, collectionName string, documents interface{}) *Expectation {
+	return d.expect("Create", databaseName, collectionName, documents)
+}
+
+// ExpectRead expect Read with filter, Anything match every filter
+func (d *Database) ExpectRead(databaseName, collectionName string, filter interface{}) *Expectation {
+	return d.expect("Read", databaseName, collectionName, filter)
+}
+
+// ExpectGetByField expect Read with the filter of field equal to value, like Repository GetByID with "_id"
+func (d *Database) ExpectGetByField(databaseName, collectionName, field string, value interface{}) *Expectation {
+	return d.expect("Read", databaseName, collectionName, bson.M{field: value})
+}
+
+// ExpectUpdate expect Update with filter and update, Anything match every value
+func (d *Database) ExpectUpdate(databaseName, collectionName string, filter, update interface{}) *Expectation {
+	return d.expect("Update", databaseName, collectionName, filter, update)
+}
+
+// ExpectDelete expect Delete with filter, Anything match every filter
+func (d *Database) ExpectDelete(databaseName, collectionName string, filter interface{}) *Expectation {
+	return d.expect("Delete", databaseName, collectionName, filter)
+}
+
+// ExpectAggregate expect Aggregate with pipeline, Anything match every pipeline
+func (d *Database) ExpectAggregate(databaseName, collectionName string, pipeline interface{}) *Expectation {
+	return d.expect("Aggregate", databaseName, collectionName, pipeline)
+}
+
+// Return set the result and the error of the expected call
+func (e *Expectation) Return(result interface{}, err error) *Expectation {
+	e.result, e.err = result, err
+	return e
+}
+
+// ReturnDocuments set the documents returned by the expected Read or Aggregate, they are converted to the data model of the call
+func (e *Expectation) ReturnDocuments(documents ...interface{}) *Expectation {
+	e.documents = documents
+	return e
+}
+
+// Times set the number of calls expected, default 1
+func (e *Expectation) Times(times int) *Expectation {
+	e.times = times
+	return e
+}
+
+// ExpectationsWereMet return an error listing the unexpected calls and the expectations with missing calls
+func (d *Database) ExpectationsWereMet() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	problems := append([]string(nil), d.unexpected...)
+	for _, expectation := range d.expectations {
+		if expectation.calls < expectation.times {
+			problems = append(problems, fmt.Sprintf("expected %d call(s) of %s, got %d", expectation.times, expectation, expectation.calls))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("Unmet expectations: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// AssertExpectations report ExpectationsWereMet error to t and return true when every expectation was met
+func (d *Database) AssertExpectations(t TestingT) bool {
+	if err := d.ExpectationsWereMet(); err != nil {
+		t.Errorf("%v", err)
+		return false
+	}
+
+	return true
+}
+
+// Create return the result of the matching expectation
+func (d *Database) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	expectation, err := d.called("Create", databaseName, collectionName, documents)
+	if err != nil {
+		return nil, err
+	}
+
+	return expectation.result, expectation.err
+}
+
+// Read return the documents or the result of the matching expectation
+func (d *Database) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	expectation, err := d.called("Read", databaseName, collectionName, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return expectation.getResult(dataModel)
+}
+
+// Update return the result of the matching expectation
+func (d *Database) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	expectation, err := d.called("Update", databaseName, collectionName, filter, update)
+	if err != nil {
+		return nil, err
+	}
+
+	return expectation.result, expectation.err
+}
+
+// Delete return the result of the matching expectation
+func (d *Database) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	expectation, err := d.called("Delete", databaseName, collectionName, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return expectation.result, expectation.err
+}
+
+// Aggregate return the documents or the result of the matching expectation
+func (d *Database) Aggregate(databaseName, collectionName string, pipeline interface{}, dataModel reflect.Type) (interface{}, error) {
+	expectation, err := d.called("Aggregate", databaseName, collectionName, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	return expectation.getResult(dataModel)
+}
+
+// expect add the expectation of one call
+func (d *Database) expect(operation, databaseName, collectionName string, arguments ...interface{}) *Expectation {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	expectation := &Expectation{
+		operation:      operation,
+		databaseName:   databaseName,
+		collectionName: collectionName,
+		arguments:      arguments,
+		times:          1,
+	}
+	d.expectations = append(d.expectations, expectation)
+
+	return expectation
+}
+
+// called return the first matching expectation with remaining calls, or an error recorded for ExpectationsWereMet
+func (d *Database) called(operation, databaseName, collectionName string, arguments ...interface{}) (*Expectation, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, expectation := range d.expectations {
+		if expectation.calls < expectation.times && expectation.matches(operation, databaseName, collectionName, arguments) {
+			expectation.calls++
+			return expectation, nil
+		}
+	}
+
+	call := fmt.Sprintf("%s(%s, %s, %s)", operation, databaseName, collectionName, formatArguments(arguments))
+	d.unexpected = append(d.unexpected, "unexpected call "+call)
+
+	return nil, fmt.Errorf("Unexpected call %s", call)
+}
+
+// matches return true when the call has the arguments of the expectation
+func (e *Expectation) matches(operation, databaseName, collectionName string, arguments []interface{}) bool {
+	if e.operation != operation || e.databaseName != databaseName || e.collectionName != collectionName {
+		return false
+	}
+
+	for i, expected := range e.arguments {
+		if expected == Anything {
+			continue
+		}
+		if normalize(expected) != normalize(arguments[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// getResult return the documents as a pointer to a slice of dataModel, like the database, or the result
+func (e *Expectation) getResult(dataModel reflect.Type) (interface{}, error) {
+	if e.documents == nil || dataModel == nil {
+		return e.result, e.err
+	}
+
+	results := reflect.New(reflect.SliceOf(dataModel))
+	for _, document := range e.documents {
+		raw, err := bson.Marshal(document)
+		if err != nil {
+			return nil, err
+		}
+
+		value := reflect.New(dataModel)
+		if err := bson.Unmarshal(raw, value.Interface()); err != nil {
+			return nil, err
+		}
+		results.Elem().Set(reflect.Append(results.Elem(), value.Elem()))
+	}
+
+	return results.Interface(), e.err
+}
+
+// String describe the expected call
+func (e *Expectation) String() string {
+	return fmt.Sprintf("%s(%s, %s, %s)", e.operation, e.databaseName, e.collectionName, formatArguments(e.arguments))
+}
+
+// formatArguments return the arguments as Extended JSON separated by comma
+func formatArguments(arguments []interface{}) string {
+	formatted := make([]string, 0, len(arguments))
+	for _, argument := range arguments {
+		if argument == Anything {
+			formatted = append(formatted, "Anything")
+			continue
+		}
+		formatted = append(formatted, normalize(argument))
+	}
+
+	return strings.Join(formatted, ", ")
+}
+
+// normalize return value as Extended JSON with the keys of documents sorted, fmt formatting when it is not BSON
+func normalize(value interface{}) string {
+	raw, err := bson.Marshal(bson.M{"v": value})
+	if err != nil {
+		return fmt.Sprintf("%#v", value)
+	}
+
+	var document bson.D
+	if err := bson.Unmarshal(raw, &document); err != nil {
+		return fmt.Sprintf("%#v", value)
+	}
+
+	b, err := bson.MarshalExtJSON(sortKeys(document[0].Value), false, false)
+	if err != nil {
+		// Values which are not documents are wrapped to be marshaled
+		b, err = bson.MarshalExtJSON(bson.D{{Key: "v", Value: sortKeys(document[0].Value)}}, false, false)
+		if err != nil {
+			return fmt.Sprintf("%#v", value)
+		}
+	}
+
+	return string(b)
+}
+
+// sortKeys return value with the keys of its documents sorted, arrays keep their order
+func sortKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		sorted := make(bson.D, 0, len(v))
+		for _, element := range v {
+			sorted = append(sorted, bson.E{Key: element.Key, Value: sortKeys(element.Value)})
+		}
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+		return sorted
+	case bson.A:
+		items := make(bson.A, 0, len(v))
+		for _, item := range v {
+			items = append(items, sortKeys(item))
+		}
+		return items
+	}
+
+	return value
+}
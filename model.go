@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/allegro/bigcache/v2"
+	"go.mongodb.org/mongo-driver/bson"
 	"google.golang.org/api/drive/v3"
 )
 
@@ -12,7 +13,12 @@ import (
 // Config model for database config
 type Config struct {
 	LIKE           LIKE            `json:"like,omitempty"`
+	ClickHouse     ClickHouse      `json:"clickhouse,omitempty"`
 	MongoDB        MongoDB         `json:"mongodb,omitempty"`
+	Elasticsearch  Elasticsearch   `json:"elasticsearch,omitempty"`
+	DynamoDB       DynamoDB        `json:"dynamodb,omitempty"`
+	Couchbase      Couchbase       `json:"couchbase,omitempty"`
+	Embedded       Embedded        `json:"embedded,omitempty"`
 	Redis          Redis           `json:"redis,omitempty"`
 	CustomKeyValue CustomKeyValue  `json:"customKeyValue,omitempty"`
 	BigCache       bigcache.Config `json:"bigCache,omitempty"`
@@ -27,15 +33,191 @@ type LIKE struct {
 	MaxConnectionLifetime time.Duration `json:"maxConnectionLifetime"`
 	MaxConnectionIdle     int           `json:"maxConnectionIdle"`
 	MaxConnectionOpen     int           `json:"maxConnectionOpen"`
+	Dialect               string        `json:"dialect"`               // empty for any database, postgres or cockroachdb
+	MaxTransactionRetries int           `json:"maxTransactionRetries"` // retries of WithTransaction on serialization failures, default 10
+}
+
+// ClickHouse model for ClickHouse connection config
+type ClickHouse struct {
+	Addresses []string      `json:"addresses"` // HTTP interface like http://localhost:8123, the next address is tried when one is unreachable
+	Username  string        `json:"username"`
+	Password  string        `json:"password"`
+	BatchSize int           `json:"batchSize"` // rows of each insert request, default 10000
+	Timeout   time.Duration `json:"timeout"`   // timeout of each request, default 60 seconds
+}
+
+// Capabilities model for the features of a database, see GetCapabilities
+type Capabilities struct {
+	Transactions    bool  `json:"transactions"`    // operations can run inside one transaction
+	ChangeStreams   bool  `json:"changeStreams"`   // changes can be watched, like by CDC and Sync
+	TextSearch      bool  `json:"textSearch"`      // full text queries are supported
+	Aggregation     bool  `json:"aggregation"`     // implement IAggregate
+	Pagination      bool  `json:"pagination"`      // implement IPage
+	MaxDocumentSize int64 `json:"maxDocumentSize"` // bytes, 0 means no limit or unknown
 }
 
 // MongoDB model for MongoDB connection config
 type MongoDB struct {
-	User     string   `json:"user"`
-	Password string   `json:"password"`
-	Hosts    []string `json:"hosts"`
-	DB       string   `json:"db"`
-	Options  []string `json:"options"`
+	User               string                 `json:"user"`
+	Password           string                 `json:"password"`
+	Hosts              []string               `json:"hosts"`
+	DB                 string                 `json:"db"`
+	Options            []string               `json:"options"`
+	DisableTransaction bool                   `json:"disableTransaction"` // transactions are always skipped on standalone deployment
+	Transaction        MongoDBTransaction     `json:"transaction"`
+	ReplicationLag     MongoDBReplicationLag  `json:"replicationLag"`
+	Discovery          MongoDBDiscovery       `json:"discovery"`
+	Proxy              MongoDBProxy           `json:"proxy"`
+	Compatibility      string                 `json:"compatibility"` // empty for MongoDB, documentdb or cosmosdb
+	CAFile             string                 `json:"caFile"`        // CA bundle file path, TLS is enabled when it is set
+	RateLimit          MongoDBRateLimit       `json:"rateLimit"`
+	Bulkhead           MongoDBBulkhead        `json:"bulkhead"`
+	MaxPageSize        int64                  `json:"maxPageSize"` // maximum limit of ReadPage, 0 means no maximum
+	Redaction          MongoDBRedaction       `json:"redaction"`
+	WarmUp             MongoDBWarmUp          `json:"warmUp"`
+	Timeouts           MongoDBTimeouts        `json:"timeouts"`
+	AppName            string                 `json:"appName"`  // name of the service in currentOp, server logs and Atlas metrics
+	Metadata           map[string]string      `json:"metadata"` // appended to AppName, like team or version
+	ServerSelection    MongoDBServerSelection `json:"serverSelection"`
+	MaxDocumentSize    int64                  `json:"maxDocumentSize"` // bytes, Create reject larger documents before sending them, 0 disable the check
+}
+
+// MongoDBTransaction model for MongoDB default transaction options
+type MongoDBTransaction struct {
+	ReadConcern    string        `json:"readConcern"`    // local, majority, snapshot...
+	WriteConcern   string        `json:"writeConcern"`   // majority, number of nodes or tag set name
+	ReadPreference string        `json:"readPreference"` // primary, primaryPreferred, secondary...
+	MaxCommitTime  time.Duration `json:"maxCommitTime"`
+}
+
+// MongoDBDiscovery model for MongoDB host discovery config, it replaces Hosts when SRV or Service is set
+type MongoDBDiscovery struct {
+	SRV             string        `json:"srv"`             // SRV record like _mongodb._tcp.mongo.default.svc.cluster.local
+	Service         string        `json:"service"`         // Kubernetes headless service like mongo.default.svc.cluster.local
+	Port            int           `json:"port"`            // port of Service endpoints, default 27017
+	RefreshInterval time.Duration `json:"refreshInterval"` // 0 disable the refresh
+}
+
+// MongoDBProxy model for dialing MongoDB through SSH bastion and/or SOCKS5 proxy
+type MongoDBProxy struct {
+	SSHHost                  string `json:"sshHost"` // bastion host:port
+	SSHUser                  string `json:"sshUser"`
+	SSHPassword              string `json:"sshPassword"`
+	SSHPrivateKey            string `json:"sshPrivateKey"`            // private key file path
+	SSHKnownHosts            string `json:"sshKnownHosts"`            // known_hosts file path, required unless SSHInsecureIgnoreHostKey is set
+	SSHInsecureIgnoreHostKey bool   `json:"sshInsecureIgnoreHostKey"` // accept any bastion host key when SSHKnownHosts is empty, for tests only
+	SOCKS5Address            string `json:"socks5Address"`            // proxy host:port
+	SOCKS5User               string `json:"socks5User"`
+	SOCKS5Password           string `json:"socks5Password"`
+}
+
+// MongoDBReplicationLag model for MongoDB replication lag monitor config
+type MongoDBReplicationLag struct {
+	MaxLag        time.Duration `json:"maxLag"`        // secondary reads fall back to primary above this lag, 0 disable the monitor
+	CheckInterval time.Duration `json:"checkInterval"` // nanosecond
+}
+
+// MongoDBRateLimit model for MongoDB client-side rate limits of operations, by token buckets
+type MongoDBRateLimit struct {
+	Global      float64            `json:"global"`      // operations per second of the client, 0 means no limit
+	Collections map[string]float64 `json:"collections"` // operations per second per database.collection
+	Burst       int                `json:"burst"`       // operations allowed at once, default is the rate rounded up
+	FailFast    bool               `json:"failFast"`    // return ErrRateLimited instead of waiting for a token
+}
+
+// MongoDBBulkhead model for MongoDB client concurrency limit
+type MongoDBBulkhead struct {
+	MaxConcurrent int           `json:"maxConcurrent"` // operations running at once, 0 means no limit
+	QueueTimeout  time.Duration `json:"queueTimeout"`  // maximum wait for a slot before ErrBulkheadFull, 0 means no timeout
+}
+
+// MongoDBRedaction model for masking sensitive fields in logs and error messages
+type MongoDBRedaction struct {
+	Fields []string `json:"fields"` // field names masked at any depth, case insensitive, like password or email
+	Mask   string   `json:"mask"`   // default is ***
+}
+
+// MongoDBWarmUp model for the connections established and checked on startup, before the first operations
+type MongoDBWarmUp struct {
+	Connections int           `json:"connections"` // pooled connections kept open, 0 disable the warm-up
+	Timeout     time.Duration `json:"timeout"`     // budget of the warm-up, default 10 seconds
+}
+
+// MongoDBServerSelection model for the monitoring of the servers and the selection of the server of each operation,
+// 0 keep the value of the connection string options or the default
+type MongoDBServerSelection struct {
+	HeartbeatInterval      time.Duration `json:"heartbeatInterval"`      // interval between two checks of each server, default 2 seconds, minimum 500ms
+	ServerSelectionTimeout time.Duration `json:"serverSelectionTimeout"` // wait for a suitable server, default 5 seconds
+	LocalThreshold         time.Duration `json:"localThreshold"`         // latency window of the eligible servers, default 15ms
+}
+
+// MongoDBTimeouts model for the default timeouts of the operations, enforced by context deadline and maxTimeMS
+// so an operation without caller-side deadline can not hang forever, the earlier deadline wins
+type MongoDBTimeouts struct {
+	Read        time.Duration             `json:"read"`        // Read, ReadInto, ReadPage, Distinct and each range of ParallelScan, 0 means no timeout
+	Write       time.Duration             `json:"write"`       // Create, Update, Delete, FindOrCreate, ApplyPatch and UpdateArrayElement, 0 means no timeout
+	Aggregate   time.Duration             `json:"aggregate"`   // Aggregate, AggregateInto and Facets, 0 means no timeout
+	Collections map[string]MongoDBTimeout `json:"collections"` // overrides per database.collection
+}
+
+// MongoDBTimeout model for the timeouts of a collection, 0 keeps the default timeout
+type MongoDBTimeout struct {
+	Read      time.Duration `json:"read"`
+	Write     time.Duration `json:"write"`
+	Aggregate time.Duration `json:"aggregate"`
+}
+
+// Elasticsearch model for Elasticsearch connection config
+type Elasticsearch struct {
+	Addresses []string      `json:"addresses"` // like https://localhost:9200, the next address is tried when one is unreachable
+	Username  string        `json:"username"`
+	Password  string        `json:"password"`
+	APIKey    string        `json:"apiKey"`  // base64 encoded id:key, used instead of username and password
+	Timeout   time.Duration `json:"timeout"` // timeout of each request, default 30 seconds
+	Refresh   string        `json:"refresh"` // refresh of the writes: empty, true or wait_for
+}
+
+// DynamoDB model for DynamoDB connection config
+type DynamoDB struct {
+	Region          string                   `json:"region"`
+	Endpoint        string                   `json:"endpoint"`    // empty for AWS, like http://localhost:8000 for DynamoDB Local
+	AccessKeyID     string                   `json:"accessKeyID"` // empty for the default credential chain of the SDK
+	SecretAccessKey string                   `json:"secretAccessKey"`
+	SessionToken    string                   `json:"sessionToken"`
+	Tables          map[string]DynamoDBTable `json:"tables"` // by collection name, the other collections are tables with _id hash key
+}
+
+// DynamoDBTable model for the keys and global secondary indexes of a DynamoDB table
+type DynamoDBTable struct {
+	Name     string          `json:"name"`     // table name, databaseName-collectionName when empty
+	HashKey  string          `json:"hashKey"`  // partition key attribute
+	RangeKey string          `json:"rangeKey"` // sort key attribute, empty when the table has none
+	Indexes  []DynamoDBIndex `json:"indexes"`  // global secondary indexes queried when a filter fix their hash key
+}
+
+// DynamoDBIndex model for a global secondary index of a DynamoDB table
+type DynamoDBIndex struct {
+	Name     string `json:"name"`
+	HashKey  string `json:"hashKey"`
+	RangeKey string `json:"rangeKey"`
+}
+
+// Couchbase model for Couchbase connection config, databaseName is the bucket and collectionName the collection of Scope
+type Couchbase struct {
+	Addresses       []string      `json:"addresses"` // query service like http://localhost:8093, the next address is tried when one is unreachable
+	Username        string        `json:"username"`
+	Password        string        `json:"password"`
+	Scope           string        `json:"scope"`           // scope of the collections, _default when empty
+	Durability      string        `json:"durability"`      // durability of the writes: empty, majority, majorityAndPersistActive or persistToMajority
+	ScanConsistency string        `json:"scanConsistency"` // consistency of the reads: request_plus when empty or not_bounded
+	Timeout         time.Duration `json:"timeout"`         // timeout of each request, default 30 seconds
+}
+
+// Embedded model for the embedded document store config
+type Embedded struct {
+	Path    string              `json:"path"`    // directory of the database files, created when it does not exist
+	Indexes map[string][]string `json:"indexes"` // field paths indexed in memory by collection name, used by equality filters
+	NoSync  bool                `json:"noSync"`  // skip the fsync of each write, faster but a power loss can corrupt the database file
 }
 
 // Redis model for redis config
@@ -67,6 +249,23 @@ type CustomFile struct {
 	RootServiceDirectory string `json:"rootDirectory"`
 }
 
+// S3 model for the bucket of S3ObjectStorage, S3 compatible services like MinIO are set by Endpoint
+type S3 struct {
+	Bucket          string `json:"bucket"`
+	Region          string `json:"region"`
+	Endpoint        string `json:"endpoint"`    // empty for AWS
+	AccessKeyID     string `json:"accessKeyID"` // empty for the default credential chain of the SDK
+	SecretAccessKey string `json:"secretAccessKey"`
+	SessionToken    string `json:"sessionToken"`
+	ForcePathStyle  bool   `json:"forcePathStyle"` // bucket in the path instead of the host name, for most compatible services
+}
+
+// GCS model for the bucket of GCSObjectStorage
+type GCS struct {
+	Bucket     string `json:"bucket"`
+	Credential string `json:"credential"` // service account key file, empty for the application default credentials
+}
+
 // End Database Connection Models //
 
 // -------------------------------------------------------------------------
@@ -83,6 +282,353 @@ type customKeyValueItem struct {
 
 // -------------------------------------------------------------------------
 
+// Begin Document Models //
+
+// ReplicationLagStats model for MongoDB replication lag metrics
+type ReplicationLagStats struct {
+	MaxLag          time.Duration            `json:"maxLag"`
+	Members         map[string]time.Duration `json:"members"`
+	CheckedAt       time.Time                `json:"checkedAt"`       // time of the last successful check
+	Failed          bool                     `json:"failed"`          // the last check failed, secondary reads are sent to primary
+	PrimaryFallback uint64                   `json:"primaryFallback"` // number of secondary reads sent to primary
+}
+
+// ImportReport model for Import result
+type ImportReport struct {
+	Inserted int64             `json:"inserted"`
+	Rejected []ImportRejection `json:"rejected"`
+}
+
+// ParquetReport model for ExportParquet result
+type ParquetReport struct {
+	Exported int64            `json:"exported"`
+	Rejected map[string]int64 `json:"rejected"` // values not matching the type of their inferred column, written as null, by field
+}
+
+// ImportRejection model for a row rejected by Import
+type ImportRejection struct {
+	Row   int64  `json:"row"` // 1-based position of the document in the input, CSV header excluded
+	Error string `json:"error"`
+}
+
+// BackupReport model for Backup and Restore result
+type BackupReport struct {
+	Database    string           `json:"database"`
+	Collections map[string]int64 `json:"collections"` // number of documents per collection
+}
+
+// BackupSchedulerStats model for BackupScheduler metrics
+type BackupSchedulerStats struct {
+	LastSuccess  time.Time     `json:"lastSuccess"`
+	LastBackup   string        `json:"lastBackup"`   // name of the last successful backup
+	LastDuration time.Duration `json:"lastDuration"` // duration of the last successful backup
+	LastError    string        `json:"lastError"`    // error of the last run, empty when it succeeded
+	Successes    uint64        `json:"successes"`
+	Failures     uint64        `json:"failures"`
+	Pruned       uint64        `json:"pruned"` // number of backups deleted by the retention rules
+}
+
+// ViewRefreshStats model for the metrics of a materialized view of ViewRefresher
+type ViewRefreshStats struct {
+	LastRefresh  time.Time     `json:"lastRefresh"`  // start of the last successful refresh, the data of the view are as of this time
+	LastDuration time.Duration `json:"lastDuration"` // duration of the last successful refresh
+	LastError    string        `json:"lastError"`    // error of the last refresh, empty when it succeeded
+	Refreshes    uint64        `json:"refreshes"`
+	Failures     uint64        `json:"failures"`
+	Staleness    time.Duration `json:"staleness"` // age of the data of the view, since the start if it was never refreshed
+	Stale        bool          `json:"stale"`     // staleness is above MaxStaleness of the view
+}
+
+// MaintenanceTaskStats model for the metrics of a task of MaintenanceScheduler
+type MaintenanceTaskStats struct {
+	LastRun      time.Time     `json:"lastRun"`      // start of the last run on this instance
+	LastDuration time.Duration `json:"lastDuration"` // duration of the last run on this instance
+	LastError    string        `json:"lastError"`    // error of the last run, empty when it succeeded
+	Runs         uint64        `json:"runs"`
+	Failures     uint64        `json:"failures"`
+	Skipped      uint64        `json:"skipped"`   // runs skipped because another instance held the lock
+	LostLocks    uint64        `json:"lostLocks"` // runs which lost their lock, so they may not have been exclusive
+}
+
+// SyncStats model for Sync metrics
+type SyncStats struct {
+	Copied           int64     `json:"copied"`  // documents written by the initial copy
+	Applied          int64     `json:"applied"` // change events written to the target
+	Skipped          int64     `json:"skipped"` // documents and events skipped by the conflict policy
+	LastEventAt      time.Time `json:"lastEventAt"`
+	LastCheckpointAt time.Time `json:"lastCheckpointAt"`
+}
+
+// QueryCacheStats model for QueryCache metrics
+type QueryCacheStats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Invalidations uint64 `json:"invalidations"` // number of writes that invalidated a collection
+}
+
+// BulkheadStats model for MongoDB client concurrency metrics
+type BulkheadStats struct {
+	MaxConcurrent int    `json:"maxConcurrent"`
+	InFlight      int64  `json:"inFlight"`
+	Queued        int64  `json:"queued"`   // operations waiting for a slot
+	Rejected      uint64 `json:"rejected"` // operations which waited longer than the queue timeout
+}
+
+// BatchUpdateProgress model for UpdateManyInBatches progress
+type BatchUpdateProgress struct {
+	Batches  int64       `json:"batches"`
+	Matched  int64       `json:"matched"`
+	Modified int64       `json:"modified"`
+	LastID   interface{} `json:"lastID"` // _id of the last updated document, to resume the update
+}
+
+// BackfillProgress model for Backfill progress, the counts include the batches of the previous runs
+type BackfillProgress struct {
+	Total     int64         `json:"total"` // documents matching the filter when the run started
+	Processed int64         `json:"processed"`
+	Updated   int64         `json:"updated"`
+	Skipped   int64         `json:"skipped"` // documents without update from the transform, or no longer matching the filter
+	Batches   int64         `json:"batches"`
+	LastID    interface{}   `json:"lastID"` // _id of the last processed document, to resume the backfill
+	StartedAt time.Time     `json:"startedAt"`
+	Rate      float64       `json:"rate"` // documents processed per second by this run
+	ETA       time.Duration `json:"eta"`  // estimated time left at the current rate
+}
+
+// DuplicateGroup model for a group of documents found by FindDuplicates
+type DuplicateGroup struct {
+	Key   map[string]interface{} `json:"key"` // values of the grouping keys
+	IDs   []interface{}          `json:"ids"` // ascending _id of the duplicates
+	Count int64                  `json:"count"`
+}
+
+// SchemaReport model for AnalyzeSchema result
+type SchemaReport struct {
+	Database   string        `json:"database"`
+	Collection string        `json:"collection"`
+	Sampled    int64         `json:"sampled"` // number of sampled documents
+	Fields     []SchemaField `json:"fields"`
+	Drift      []SchemaDrift `json:"drift"`
+}
+
+// SchemaField model for a field inferred by AnalyzeSchema
+type SchemaField struct {
+	Name     string           `json:"name"`  // dotted path of nested fields
+	Types    map[string]int64 `json:"types"` // number of values per $type alias
+	Count    int64            `json:"count"` // number of sampled documents with the field
+	Optional bool             `json:"optional"`
+}
+
+// SchemaDrift model for a difference between a collection and its Go struct
+type SchemaDrift struct {
+	Field    string `json:"field"`
+	Kind     string `json:"kind"`     // unknownField, typeMismatch or missingField
+	Expected string `json:"expected"` // $type aliases of the struct field
+	Actual   string `json:"actual"`   // $type aliases of the sampled values
+}
+
+// ValidationReport model for Validate result
+type ValidationReport struct {
+	Database   string                   `json:"database"`
+	Collection string                   `json:"collection"`
+	Violations map[string][]interface{} `json:"violations"` // _id of the violating documents per rule name
+	Counts     map[string]int64         `json:"counts"`     // number of violating documents per rule name
+}
+
+// CascadeReport model for DeleteCascade result
+type CascadeReport struct {
+	Database string                   `json:"database"`
+	DryRun   bool                     `json:"dryRun"`
+	IDs      map[string][]interface{} `json:"ids"`     // _id of the deleted documents per collection
+	Deleted  map[string]int64         `json:"deleted"` // number of deleted documents per collection
+}
+
+// OrphanReport model for the documents of a reference whose referenced document no longer exists, see FindOrphans
+type OrphanReport struct {
+	Database             string        `json:"database"`
+	Collection           string        `json:"collection"`
+	Field                string        `json:"field"`
+	ReferencedCollection string        `json:"referencedCollection"`
+	Checked              int64         `json:"checked"` // number of referencing documents checked
+	Orphans              int64         `json:"orphans"`
+	IDs                  []interface{} `json:"ids"`     // _id of the orphans, at most MaxIDs
+	Deleted              int64         `json:"deleted"` // number of orphans deleted by CleanOrphans
+}
+
+// AuditEntry model for an audit trail entry of AuditedDatabase, one per written document
+type AuditEntry struct {
+	ID         interface{}            `json:"id" bson:"_id,omitempty"`
+	Database   string                 `json:"database" bson:"database"`
+	Collection string                 `json:"collection" bson:"collection"`
+	Operation  string                 `json:"operation" bson:"operation"` // create, update or delete
+	DocumentID interface{}            `json:"documentId" bson:"documentId"`
+	Actor      string                 `json:"actor" bson:"actor"`   // actor bound with AuditedDatabase.For
+	Before     map[string]interface{} `json:"before" bson:"before"` // nil for create
+	After      map[string]interface{} `json:"after" bson:"after"`   // nil for delete
+	Timestamp  time.Time              `json:"timestamp" bson:"timestamp"`
+}
+
+// View model for a MongoDB view returned by ListViews
+type View struct {
+	Name     string        `json:"name"`
+	Source   string        `json:"source"` // collection or view the view is defined on
+	Pipeline []interface{} `json:"pipeline"`
+}
+
+// CollectionStats model for MongoDB collection statistics, sizes are in byte
+type CollectionStats struct {
+	Database       string           `json:"database"`
+	Collection     string           `json:"collection"`
+	Count          int64            `json:"count"`
+	Size           int64            `json:"size"` // uncompressed size of the documents
+	AvgObjSize     int64            `json:"avgObjSize"`
+	StorageSize    int64            `json:"storageSize"` // size allocated on disk
+	Indexes        int64            `json:"indexes"`
+	TotalIndexSize int64            `json:"totalIndexSize"`
+	IndexSizes     map[string]int64 `json:"indexSizes"` // size per index name
+}
+
+// DatabaseStats model for MongoDB database statistics, sizes are in byte
+type DatabaseStats struct {
+	Database    string `json:"database"`
+	Collections int64  `json:"collections"`
+	Views       int64  `json:"views"`
+	Objects     int64  `json:"objects"`
+	AvgObjSize  int64  `json:"avgObjSize"`
+	DataSize    int64  `json:"dataSize"`    // uncompressed size of the documents
+	StorageSize int64  `json:"storageSize"` // size allocated on disk
+	Indexes     int64  `json:"indexes"`
+	IndexSize   int64  `json:"indexSize"`
+}
+
+// StorageStats model for StatsCollector metrics
+type StorageStats struct {
+	Databases   map[string]DatabaseStats   `json:"databases"`
+	Collections map[string]CollectionStats `json:"collections"` // per database.collection
+	CollectedAt time.Time                  `json:"collectedAt"`
+}
+
+// IndexUsage model for the accesses of an index returned by IndexUsage
+type IndexUsage struct {
+	Name   string    `json:"name"`
+	Keys   bson.D    `json:"keys"`
+	Ops    int64     `json:"ops"`    // number of accesses since the counters reset
+	Since  time.Time `json:"since"`  // most recent reset of the counters of the members
+	Unused bool      `json:"unused"` // no access for the requested period
+}
+
+// IndexSuggestion model for an index suggested by SuggestIndexes
+type IndexSuggestion struct {
+	Keys   bson.D `json:"keys"`   // ready for CreateIndex
+	Shapes int    `json:"shapes"` // number of query shapes served by the index
+}
+
+// ProfilingStatus model for the profiler settings of a database
+type ProfilingStatus struct {
+	Level      int     `json:"level"`  // 0 off, 1 slow operations, 2 all operations
+	SlowMS     int64   `json:"slowMS"` // threshold of the slow operations
+	SampleRate float64 `json:"sampleRate"`
+}
+
+// ProfileEntry model for an operation recorded by the database profiler in system.profile
+type ProfileEntry struct {
+	Op           string                 `json:"op" bson:"op"`
+	Namespace    string                 `json:"namespace" bson:"ns"`
+	Command      map[string]interface{} `json:"command" bson:"command"` // like find with filter and sort
+	Millis       int64                  `json:"millis" bson:"millis"`
+	KeysExamined int64                  `json:"keysExamined" bson:"keysExamined"`
+	DocsExamined int64                  `json:"docsExamined" bson:"docsExamined"`
+	NReturned    int64                  `json:"nReturned" bson:"nreturned"`
+	PlanSummary  string                 `json:"planSummary" bson:"planSummary"` // like COLLSCAN or IXSCAN { email: 1 }
+	Client       string                 `json:"client" bson:"client"`
+	User         string                 `json:"user" bson:"user"`
+	Timestamp    time.Time              `json:"timestamp" bson:"ts"`
+}
+
+// RunningOperation model for an active operation returned by ListRunningOperations
+type RunningOperation struct {
+	OpID        interface{}            `json:"opId"` // number, or "shard:number" on sharded clusters
+	Op          string                 `json:"op"`   // query, getmore, insert, update, remove, command...
+	Namespace   string                 `json:"namespace"`
+	Command     map[string]interface{} `json:"command"`
+	Duration    time.Duration          `json:"duration"`
+	Client      string                 `json:"client"`
+	Description string                 `json:"description"`
+	PlanSummary string                 `json:"planSummary"`
+}
+
+// OperationWatchdogStats model for OperationWatchdog metrics
+type OperationWatchdogStats struct {
+	Checks    uint64 `json:"checks"`
+	Killed    uint64 `json:"killed"`    // number of operations killed over budget
+	LastError string `json:"lastError"` // error of the last check or kill, empty when it succeeded
+}
+
+// SearchResult model for a page of Elasticsearch search hits
+type SearchResult struct {
+	Total           int64         `json:"total"`           // number of matching documents, a lower bound above 10000
+	Hits            interface{}   `json:"hits"`            // pointer to a slice of the data model
+	Scores          []float64     `json:"scores"`          // score of each hit
+	NextSearchAfter []interface{} `json:"nextSearchAfter"` // sort values of the last hit, SearchAfter of the next page when sorted
+}
+
+// ElasticByQueryResult model for Elasticsearch update and delete by query results
+type ElasticByQueryResult struct {
+	Total   int64 `json:"total"`
+	Updated int64 `json:"updated"`
+	Deleted int64 `json:"deleted"`
+}
+
+// ElasticMirrorStats model for ElasticMirror metrics
+type ElasticMirrorStats struct {
+	Mirrored  uint64 `json:"mirrored"`  // number of documents indexed or deleted in Elasticsearch
+	Failed    uint64 `json:"failed"`    // number of writes not mirrored
+	LastError string `json:"lastError"` // error of the last failed write, empty when none failed
+}
+
+// Divergence model for a write of DualWriter where the shadow database did not match the primary database
+type Divergence struct {
+	Time       time.Time `json:"time"`
+	Operation  string    `json:"operation"` // create, read, update or delete
+	Database   string    `json:"database"`
+	Collection string    `json:"collection"`
+	Reason     string    `json:"reason"` // shadow error or difference of the results
+}
+
+// ReconciliationReport model for DualWriter.Reconcile, documents are identified by their _id as string
+type ReconciliationReport struct {
+	Database   string   `json:"database"`
+	Collection string   `json:"collection"`
+	Compared   int64    `json:"compared"`  // number of documents read from the primary database
+	Missing    []string `json:"missing"`   // documents of the primary database missing from the shadow database
+	Extra      []string `json:"extra"`     // documents of the shadow database missing from the primary database
+	Different  []string `json:"different"` // documents with different fields
+	Repaired   int64    `json:"repaired"`  // number of documents fixed in the shadow database when repair is asked
+}
+
+// SagaExecution model for the persisted state of a saga run by SagaCoordinator
+type SagaExecution struct {
+	ID        string    `json:"id" bson:"_id"`
+	Saga      string    `json:"saga" bson:"saga"`
+	Status    string    `json:"status" bson:"status"`   // running, compensating, completed, compensated or failed
+	Step      int       `json:"step" bson:"step"`       // step running, or next step to compensate
+	Payload   bson.M    `json:"payload" bson:"payload"` // data shared by the steps, saved after each step
+	Error     string    `json:"error" bson:"error"`     // error of the failed step and of the failed compensation
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// TopologyEvent model for a change of the MongoDB deployment, see MongoClient.Subscribe
+type TopologyEvent struct {
+	Type    string    `json:"type"`    // primaryElected, primaryLost, serverAdded, serverRemoved or poolCleared
+	Address string    `json:"address"` // host:port of the server
+	Time    time.Time `json:"time"`
+}
+
+// End Document Models //
+
+// -------------------------------------------------------------------------
+
 // Begin File Models //
 
 // GoogleFileListModel for unmarshal object has interface type
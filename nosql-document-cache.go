@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// cacheKeyPrefix is the prefix of the keys written by CachedDatabase
+	cacheKeyPrefix = "storage:document:"
+)
+
+// CachedDatabase decorate INoSQLDocument with a read-through cache of the documents read by ID,
+// like Read(databaseName, collectionName, bson.M{"_id": id}, ...), stored in a key-value backend (REDIS, BIGCACHE or CUSTOM)
+type CachedDatabase struct {
+	db    INoSQLDocument
+	cache INoSQLKeyValue
+	ttl   time.Duration
+}
+
+// NewCachedDatabase init new cached database, cached documents expire after ttl
+func NewCachedDatabase(db INoSQLDocument, cache INoSQLKeyValue, ttl time.Duration) *CachedDatabase {
+	return &CachedDatabase{db: db, cache: cache, ttl: ttl}
+}
+
+// Create the documents, the cache is not changed because only found documents are cached
+func (c *CachedDatabase) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	return c.db.Create(databaseName, collectionName, documents)
+}
+
+// Read documents from the cache when filter select one ID, from the database otherwise
+func (c *CachedDatabase) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	id, ok := getFilterID(filter)
+	if !ok {
+		return c.db.Read(databaseName, collectionName, filter, limit, dataModel)
+	}
+
+	key, err := getCacheKey(databaseName, collectionName, id)
+	if err != nil {
+		return c.db.Read(databaseName, collectionName, filter, limit, dataModel)
+	}
+
+	if results, ok := c.get(key, dataModel); ok {
+		return results, nil
+	}
+
+	results, err := c.db.Read(databaseName, collectionName, filter, limit, dataModel)
+	if err != nil {
+		return nil, err
+	}
+	c.set(key, results)
+
+	return results, nil
+}
+
+// Update the documents matching filter and invalidate their cache entries
+func (c *CachedDatabase) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	ids := c.getIDs(databaseName, collectionName, filter)
+	defer putValues(ids)
+	result, err := c.db.Update(databaseName, collectionName, filter, update)
+	c.invalidate(databaseName, collectionName, *ids)
+
+	return result, err
+}
+
+// Delete the documents matching filter and invalidate their cache entries
+func (c *CachedDatabase) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	ids := c.getIDs(databaseName, collectionName, filter)
+	defer putValues(ids)
+	result, err := c.db.Delete(databaseName, collectionName, filter)
+	c.invalidate(databaseName, collectionName, *ids)
+
+	return result, err
+}
+
+// WatchInvalidations invalidate the cache entries of the documents changed in databaseName by any client,
+// through the MongoDB change stream. Call the returned function to stop watching.
+func (c *CachedDatabase) WatchInvalidations(client *MongoClient, databaseName string) func() {
+	watchCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		pipeline := bson.A{bson.M{"$match": bson.M{"operationType": bson.M{"$in": bson.A{"update", "replace", "delete"}}}}}
+		var resumeToken bson.Raw
+		for watchCtx.Err() == nil {
+			streamOptions := options.ChangeStream().SetMaxAwaitTime(time.Second)
+			if resumeToken != nil {
+				streamOptions.SetResumeAfter(resumeToken)
+			}
+
+			stream, err := client.getClient().Database(databaseName).Watch(watchCtx, pipeline, streamOptions)
+			if err != nil {
+				log.Println("Unable to watch cache invalidations: ", err)
+				select {
+				case <-time.After(time.Second):
+				case <-watchCtx.Done():
+				}
+				continue
+			}
+
+			for stream.Next(watchCtx) {
+				var event changeEvent
+				if err := stream.Decode(&event); err != nil {
+					log.Println("Unable to decode change event: ", err)
+					continue
+				}
+				c.invalidate(event.Namespace.DB, event.Namespace.Coll, []interface{}{event.DocumentKey.Lookup("_id")})
+				resumeToken = stream.ResumeToken()
+			}
+			if err := stream.Err(); err != nil && watchCtx.Err() == nil {
+				log.Println("Unable to watch cache invalidations: ", err)
+			}
+			stream.Close(context.Background())
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// get return the cached results of key decoded to a slice of dataModel
+func (c *CachedDatabase) get(key string, dataModel reflect.Type) (interface{}, bool) {
+	value, err := c.cache.Get(key)
+	if err != nil {
+		return nil, false
+	}
+
+	return decodeCacheEntry(value, dataModel)
+}
+
+// set cache the results when they are not empty, a missing document is never cached
+func (c *CachedDatabase) set(key string, results interface{}) {
+	value := reflect.Indirect(reflect.ValueOf(results))
+	if value.Kind() != reflect.Slice || value.Len() == 0 {
+		return
+	}
+
+	data, ok := encodeCacheEntry(results)
+	if !ok {
+		return
+	}
+
+	if err := c.cache.Set(key, data, c.ttl); err != nil {
+		log.Println("Unable to set cache entry: ", err)
+	}
+}
+
+// getIDs return the IDs of the documents matching filter in a slice of valuesPool, give it back with putValues
+func (c *CachedDatabase) getIDs(databaseName, collectionName string, filter interface{}) *[]interface{} {
+	ids := getValues()
+	if id, ok := getFilterID(filter); ok {
+		*ids = append(*ids, id)
+		return ids
+	}
+
+	results, err := c.db.Read(databaseName, collectionName, filter, 0, reflect.TypeOf(bson.M{}))
+	if err != nil {
+		log.Println("Unable to read the documents to invalidate: ", err)
+		return ids
+	}
+
+	documents, ok := results.(*[]bson.M)
+	if !ok {
+		return ids
+	}
+
+	for _, document := range *documents {
+		*ids = append(*ids, document["_id"])
+	}
+
+	return ids
+}
+
+// invalidate delete the cache entries of the IDs
+func (c *CachedDatabase) invalidate(databaseName, collectionName string, ids []interface{}) {
+	for _, id := range ids {
+		key, err := getCacheKey(databaseName, collectionName, id)
+		if err != nil {
+			continue
+		}
+
+		if err := c.cache.Delete(key); err != nil {
+			log.Println("Unable to delete cache entry: ", err)
+		}
+	}
+}
+
+// encodeCacheEntry return the cache entry of the results returned by Read or Aggregate
+func encodeCacheEntry(results interface{}) (string, bool) {
+	value := reflect.Indirect(reflect.ValueOf(results))
+	if value.Kind() != reflect.Slice {
+		return "", false
+	}
+
+	data, err := marshalExtJSON(bson.M{"documents": value.Interface()}, true)
+	if err != nil {
+		log.Println("Unable to marshal cache entry: ", err)
+		return "", false
+	}
+
+	return data, true
+}
+
+// decodeCacheEntry return the pointer to a new slice of dataModel holding the documents of the cache entry
+func decodeCacheEntry(value interface{}, dataModel reflect.Type) (interface{}, bool) {
+	var data []byte
+	switch v := value.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return nil, false
+	}
+	if len(data) == 0 {
+		return nil, false
+	}
+
+	var entry struct {
+		Documents []bson.Raw `bson:"documents"`
+	}
+	if err := bson.UnmarshalExtJSON(data, true, &entry); err != nil {
+		return nil, false
+	}
+
+	results := reflect.New(reflect.SliceOf(dataModel))
+	results.Elem().Set(reflect.MakeSlice(results.Elem().Type(), len(entry.Documents), len(entry.Documents)))
+	for i, document := range entry.Documents {
+		if err := bson.Unmarshal(document, results.Elem().Index(i).Addr().Interface()); err != nil {
+			return nil, false
+		}
+	}
+
+	return results.Interface(), true
+}
+
+// getFilterID return the ID of filter when it only select one document by _id equality
+func getFilterID(filter interface{}) (interface{}, bool) {
+	var id interface{}
+	switch f := filter.(type) {
+	case bson.M:
+		if len(f) != 1 {
+			return nil, false
+		}
+		var ok bool
+		if id, ok = f["_id"]; !ok {
+			return nil, false
+		}
+	case map[string]interface{}:
+		return getFilterID(bson.M(f))
+	case bson.D:
+		if len(f) != 1 || f[0].Key != "_id" {
+			return nil, false
+		}
+		id = f[0].Value
+	default:
+		return nil, false
+	}
+
+	switch id.(type) {
+	case bson.M, bson.D, map[string]interface{}, bson.A, []interface{}, nil:
+		// Operators like $in select several documents
+		return nil, false
+	}
+
+	return id, true
+}
+
+// getCacheKey return the cache key of the document
+func getCacheKey(databaseName, collectionName string, id interface{}) (string, error) {
+	if value, ok := id.(bson.RawValue); ok {
+		// IDs of change events keep their BSON type
+		var decoded interface{}
+		if err := value.Unmarshal(&decoded); err != nil {
+			return "", err
+		}
+		id = decoded
+	}
+
+	data, err := marshalExtJSON(bson.D{primitive.E{Key: "_id", Value: id}}, false)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%s.%s:%s", cacheKeyPrefix, databaseName, collectionName, data), nil
+}
@@ -0,0 +1,583 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultChunkSize is the size of the chunks of a large value, like GridFS
+	defaultChunkSize = 255 * 1024
+	// maxChunkSize leave room in the chunk documents for their other fields
+	maxChunkSize = 15 * 1024 * 1024
+	// defaultChunkThreshold is the encoded size above which the values of a chunked field are stored out of the document
+	defaultChunkThreshold = 1024 * 1024
+	// chunkReferenceKey is the key of the document replacing a chunked value
+	chunkReferenceKey = "_chunked"
+	// chunksCollectionSuffix is appended to the collection name to get the chunks collection, or the GridFS bucket
+	chunksCollectionSuffix = "_chunks"
+)
+
+var (
+	// ErrChunkedPipeline is returned when an update pipeline is applied to a collection with chunked fields
+	ErrChunkedPipeline = errors.New("Update pipelines are not supported on chunked collections")
+)
+
+// ChunkedField model for a top level field of the collection model whose large values are stored in chunks
+type ChunkedField struct {
+	Database   string
+	Collection string
+	Field      string
+	Threshold  int  // encoded size in bytes above which the values are chunked, default 1MB
+	GridFS     bool // store the chunks in a GridFS bucket instead of a chunks collection, needs MongoClient
+}
+
+// chunkDocument private model for a chunk of a large value in the chunks collection
+type chunkDocument struct {
+	ID   string `bson:"_id"`
+	Ref  string `bson:"ref"`
+	N    int    `bson:"n"`
+	Data []byte `bson:"data"`
+}
+
+// chunkStore write, read and delete the chunks of large values by reference
+type chunkStore interface {
+	put(databaseName, collectionName, ref string, data []byte) error
+	get(databaseName, collectionName string, refs []string) (map[string][]byte, error)
+	delete(databaseName, collectionName string, refs []string) error
+}
+
+// ChunkedDatabase decorate INoSQLDocument with the storage of large field values out of their documents, for payloads
+// above the BSON limit. Create and Update split the values above the threshold of their field in chunks written to the
+// <collection>_chunks collection, or GridFS bucket, and replace them with a reference. Read reassemble the values, so
+// the documents keep their model. Filters can not match the values of chunked fields.
+type ChunkedDatabase struct {
+	db         INoSQLDocument
+	fields     []ChunkedField
+	collection chunkStore
+	gridFS     chunkStore
+}
+
+// NewChunkedDatabase init new chunked database splitting the values in chunks of chunkSize bytes, default 255KB
+func NewChunkedDatabase(db INoSQLDocument, chunkSize int, fields ...ChunkedField) (*ChunkedDatabase, error) {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	var errs *multierror.Error
+	if chunkSize > maxChunkSize {
+		errs = multierror.Append(errs, fmt.Errorf("chunking: chunkSize can not exceed %d", maxChunkSize))
+	}
+	client, isMongo := db.(*MongoClient)
+	for i := range fields {
+		field := &fields[i]
+		if field.Database == "" || field.Collection == "" || field.Field == "" {
+			errs = multierror.Append(errs, errors.New("chunking: database, collection and field are required"))
+		}
+		if field.Field == "_id" || strings.Contains(field.Field, ".") {
+			errs = multierror.Append(errs, fmt.Errorf("chunking: field %q must be a top level field other than _id", field.Field))
+		}
+		if field.GridFS && !isMongo {
+			errs = multierror.Append(errs, fmt.Errorf("chunking: gridFS of field %q needs MongoClient", field.Field))
+		}
+		if field.Threshold <= 0 {
+			field.Threshold = defaultChunkThreshold
+		}
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+
+	chunked := &ChunkedDatabase{db: db, fields: fields, collection: &collectionChunkStore{db: db, chunkSize: chunkSize}}
+	if isMongo {
+		chunked.gridFS = &gridFSChunkStore{client: client, chunkSize: int32(chunkSize)}
+	}
+
+	return chunked, nil
+}
+
+// Create the documents with the large values of their chunked fields written to the chunks first
+func (c *ChunkedDatabase) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	fields := c.getFields(databaseName, collectionName)
+	if len(fields) == 0 {
+		return c.db.Create(databaseName, collectionName, documents)
+	}
+
+	written := map[ChunkedField][]string{}
+	chunked := make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		value, err := toDocument(document)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.chunkDocument(databaseName, collectionName, value, fields, written); err != nil {
+			c.deleteChunks(databaseName, collectionName, written)
+			return nil, err
+		}
+		chunked = append(chunked, value)
+	}
+
+	result, err := c.db.Create(databaseName, collectionName, chunked)
+	if err != nil {
+		c.deleteChunks(databaseName, collectionName, written)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Read documents based on filter and reassemble the values of their chunked fields
+func (c *ChunkedDatabase) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	fields := c.getFields(databaseName, collectionName)
+	if len(fields) == 0 {
+		return c.db.Read(databaseName, collectionName, filter, limit, dataModel)
+	}
+	if err := checkDataModel(dataModel); err != nil {
+		return nil, err
+	}
+
+	// The references can not be decoded into the fields of the model, the documents are decoded once reassembled
+	documents, err := c.readDocuments(databaseName, collectionName, filter, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.reassemble(databaseName, collectionName, documents, fields); err != nil {
+		return nil, err
+	}
+
+	results := reflect.New(reflect.SliceOf(dataModel))
+	results.Elem().Set(reflect.MakeSlice(results.Elem().Type(), len(documents), len(documents)))
+	for i, document := range documents {
+		raw, err := bson.Marshal(document)
+		if err != nil {
+			return nil, err
+		}
+		if err := bson.Unmarshal(raw, results.Elem().Index(i).Addr().Interface()); err != nil {
+			return nil, err
+		}
+	}
+
+	return results.Interface(), nil
+}
+
+// Update the documents matching filter, the large values of chunked fields set by $set or the replacement are written
+// to the chunks, and the chunks of the replaced values are deleted once they are not referenced anymore
+func (c *ChunkedDatabase) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	fields := c.getFields(databaseName, collectionName)
+	if len(fields) == 0 {
+		return c.db.Update(databaseName, collectionName, filter, update)
+	}
+
+	switch update.(type) {
+	case mongo.Pipeline, bson.A, []bson.D, []bson.M, []interface{}:
+		return nil, ErrChunkedPipeline
+	}
+
+	document, err := toDocument(update)
+	if err != nil {
+		return nil, err
+	}
+
+	written := map[ChunkedField][]string{}
+	touched, err := c.chunkUpdate(databaseName, collectionName, document, fields, written)
+	if err != nil {
+		c.deleteChunks(databaseName, collectionName, written)
+		return nil, err
+	}
+
+	previous := map[ChunkedField][]string{}
+	if len(touched) > 0 {
+		if previous, err = c.getReferences(databaseName, collectionName, filter, touched); err != nil {
+			c.deleteChunks(databaseName, collectionName, written)
+			return nil, err
+		}
+	}
+
+	result, err := c.db.Update(databaseName, collectionName, filter, document)
+	if err != nil {
+		c.deleteChunks(databaseName, collectionName, written)
+		return nil, err
+	}
+
+	return result, c.deleteUnreferenced(databaseName, collectionName, previous)
+}
+
+// Delete the documents matching filter and the chunks of their values once they are not referenced anymore
+func (c *ChunkedDatabase) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	fields := c.getFields(databaseName, collectionName)
+	if len(fields) == 0 {
+		return c.db.Delete(databaseName, collectionName, filter)
+	}
+
+	previous, err := c.getReferences(databaseName, collectionName, filter, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.db.Delete(databaseName, collectionName, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, c.deleteUnreferenced(databaseName, collectionName, previous)
+}
+
+// getFields return the chunked fields of the collection
+func (c *ChunkedDatabase) getFields(databaseName, collectionName string) []ChunkedField {
+	var fields []ChunkedField
+	for _, field := range c.fields {
+		if field.Database == databaseName && field.Collection == collectionName {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// getStore return the store of the chunks of field
+func (c *ChunkedDatabase) getStore(field ChunkedField) chunkStore {
+	if field.GridFS {
+		return c.gridFS
+	}
+
+	return c.collection
+}
+
+// chunkDocument replace the large values of the chunked fields of document with references, the references of the
+// written values are added to written
+func (c *ChunkedDatabase) chunkDocument(databaseName, collectionName string, document bson.D, fields []ChunkedField, written map[ChunkedField][]string) error {
+	for i, element := range document {
+		for _, field := range fields {
+			if element.Key != field.Field {
+				continue
+			}
+
+			value, err := c.chunkValue(databaseName, collectionName, element.Value, field, written)
+			if err != nil {
+				return err
+			}
+			document[i].Value = value
+		}
+	}
+
+	return nil
+}
+
+// chunkUpdate chunk the values of the replacement or of $set in update and return the chunked fields it changes
+func (c *ChunkedDatabase) chunkUpdate(databaseName, collectionName string, update bson.D, fields []ChunkedField, written map[ChunkedField][]string) ([]ChunkedField, error) {
+	if len(update) == 0 || !strings.HasPrefix(update[0].Key, "$") {
+		return fields, c.chunkDocument(databaseName, collectionName, update, fields, written)
+	}
+
+	var touched []ChunkedField
+	for _, operator := range update {
+		values, _ := operator.Value.(bson.D)
+		for i, element := range values {
+			for _, field := range fields {
+				if element.Key != field.Field && !strings.HasPrefix(element.Key, field.Field+".") {
+					continue
+				}
+
+				switch {
+				case operator.Key == "$set" && element.Key == field.Field:
+					value, err := c.chunkValue(databaseName, collectionName, element.Value, field, written)
+					if err != nil {
+						return nil, err
+					}
+					values[i].Value = value
+				case operator.Key == "$unset" && element.Key == field.Field:
+				default:
+					return nil, fmt.Errorf("Unsupported operator %s on chunked field %q", operator.Key, element.Key)
+				}
+				touched = append(touched, field)
+			}
+		}
+	}
+
+	return touched, nil
+}
+
+// chunkValue write value to the chunks and return its reference when its encoded size is above the threshold of field
+func (c *ChunkedDatabase) chunkValue(databaseName, collectionName string, value interface{}, field ChunkedField, written map[ChunkedField][]string) (interface{}, error) {
+	data, err := bson.Marshal(bson.D{primitive.E{Key: "v", Value: value}})
+	if err != nil {
+		return nil, err
+	}
+	if len(data) <= field.Threshold {
+		return value, nil
+	}
+
+	ref := primitive.NewObjectID().Hex()
+	if err := c.getStore(field).put(databaseName, collectionName, ref, data); err != nil {
+		return nil, err
+	}
+	written[field] = append(written[field], ref)
+
+	return bson.D{primitive.E{Key: chunkReferenceKey, Value: ref}, primitive.E{Key: "size", Value: int64(len(data))}}, nil
+}
+
+// readDocuments return the documents matching filter as bson.D
+func (c *ChunkedDatabase) readDocuments(databaseName, collectionName string, filter interface{}, limit int64) ([]bson.D, error) {
+	results, err := c.db.Read(databaseName, collectionName, filter, limit, reflect.TypeOf(bson.D{}))
+	if err != nil {
+		return nil, err
+	}
+
+	documents, ok := results.(*[]bson.D)
+	if !ok {
+		return nil, errors.New("Unable to map results to bson.D")
+	}
+
+	return *documents, nil
+}
+
+// reassemble replace the references of the chunked fields of documents with their values
+func (c *ChunkedDatabase) reassemble(databaseName, collectionName string, documents []bson.D, fields []ChunkedField) error {
+	for _, field := range fields {
+		refs := getChunkReferences(documents, field)
+		if len(refs) == 0 {
+			continue
+		}
+
+		values, err := c.getStore(field).get(databaseName, collectionName, refs)
+		if err != nil {
+			return err
+		}
+
+		for _, document := range documents {
+			for i, element := range document {
+				ref, ok := getChunkReference(element, field)
+				if !ok {
+					continue
+				}
+				data, ok := values[ref]
+				if !ok {
+					return fmt.Errorf("Chunks %s of field %q are missing", ref, field.Field)
+				}
+
+				var wrapper bson.D
+				if err := bson.Unmarshal(data, &wrapper); err != nil || len(wrapper) != 1 {
+					return fmt.Errorf("Chunks %s of field %q are corrupted", ref, field.Field)
+				}
+				document[i].Value = wrapper[0].Value
+			}
+		}
+	}
+
+	return nil
+}
+
+// getReferences return the references of the chunked fields of the documents matching filter
+func (c *ChunkedDatabase) getReferences(databaseName, collectionName string, filter interface{}, fields []ChunkedField) (map[ChunkedField][]string, error) {
+	documents, err := c.readDocuments(databaseName, collectionName, filter, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	references := map[ChunkedField][]string{}
+	for _, field := range fields {
+		if refs := getChunkReferences(documents, field); len(refs) > 0 {
+			references[field] = refs
+		}
+	}
+
+	return references, nil
+}
+
+// deleteUnreferenced delete the chunks of the references no document refers to anymore, a value set on several
+// documents by one update share the same chunks
+func (c *ChunkedDatabase) deleteUnreferenced(databaseName, collectionName string, references map[ChunkedField][]string) error {
+	unreferenced := map[ChunkedField][]string{}
+	for field, refs := range references {
+		documents, err := c.readDocuments(databaseName, collectionName, bson.M{field.Field + "." + chunkReferenceKey: bson.M{"$in": refs}}, 0)
+		if err != nil {
+			return err
+		}
+
+		used := map[string]bool{}
+		for _, ref := range getChunkReferences(documents, field) {
+			used[ref] = true
+		}
+		for _, ref := range refs {
+			if !used[ref] {
+				unreferenced[field] = append(unreferenced[field], ref)
+			}
+		}
+	}
+
+	return c.deleteChunks(databaseName, collectionName, unreferenced)
+}
+
+// deleteChunks delete the chunks of the references
+func (c *ChunkedDatabase) deleteChunks(databaseName, collectionName string, references map[ChunkedField][]string) error {
+	var errs *multierror.Error
+	for field, refs := range references {
+		if err := c.getStore(field).delete(databaseName, collectionName, refs); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// getChunkReferences return the distinct references of field in documents
+func getChunkReferences(documents []bson.D, field ChunkedField) []string {
+	seen := map[string]bool{}
+	var refs []string
+	for _, document := range documents {
+		for _, element := range document {
+			if ref, ok := getChunkReference(element, field); ok && !seen[ref] {
+				seen[ref] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+
+	return refs
+}
+
+// getChunkReference return the reference of the element when it is a chunked value of field
+func getChunkReference(element primitive.E, field ChunkedField) (string, bool) {
+	if element.Key != field.Field {
+		return "", false
+	}
+
+	reference, ok := element.Value.(bson.D)
+	if !ok || len(reference) == 0 || reference[0].Key != chunkReferenceKey {
+		return "", false
+	}
+	ref, ok := reference[0].Value.(string)
+
+	return ref, ok
+}
+
+// collectionChunkStore store the chunks as documents of the <collection>_chunks collection of db
+type collectionChunkStore struct {
+	db        INoSQLDocument
+	chunkSize int
+}
+
+// put write the chunks of data
+func (s *collectionChunkStore) put(databaseName, collectionName, ref string, data []byte) error {
+	var chunks []interface{}
+	for n := 0; n*s.chunkSize < len(data); n++ {
+		end := (n + 1) * s.chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunks = append(chunks, chunkDocument{ID: fmt.Sprintf("%s-%06d", ref, n), Ref: ref, N: n, Data: data[n*s.chunkSize : end]})
+	}
+
+	_, err := s.db.Create(databaseName, collectionName+chunksCollectionSuffix, chunks)
+	return err
+}
+
+// get read the chunks of refs and return the data by reference
+func (s *collectionChunkStore) get(databaseName, collectionName string, refs []string) (map[string][]byte, error) {
+	results, err := s.db.Read(databaseName, collectionName+chunksCollectionSuffix, bson.M{"ref": bson.M{"$in": refs}}, 0, reflect.TypeOf(chunkDocument{}))
+	if err != nil {
+		return nil, err
+	}
+	chunks, ok := results.(*[]chunkDocument)
+	if !ok {
+		return nil, errors.New("Unable to map results to chunk model")
+	}
+
+	sort.Slice(*chunks, func(i, j int) bool {
+		if (*chunks)[i].Ref != (*chunks)[j].Ref {
+			return (*chunks)[i].Ref < (*chunks)[j].Ref
+		}
+		return (*chunks)[i].N < (*chunks)[j].N
+	})
+
+	values := map[string][]byte{}
+	for _, chunk := range *chunks {
+		if chunk.N != 0 && len(values[chunk.Ref]) == 0 {
+			return nil, fmt.Errorf("Chunks %s are incomplete", chunk.Ref)
+		}
+		values[chunk.Ref] = append(values[chunk.Ref], chunk.Data...)
+	}
+
+	return values, nil
+}
+
+// delete the chunks of refs
+func (s *collectionChunkStore) delete(databaseName, collectionName string, refs []string) error {
+	if len(refs) == 0 {
+		return nil
+	}
+
+	_, err := s.db.Delete(databaseName, collectionName+chunksCollectionSuffix, bson.M{"ref": bson.M{"$in": refs}})
+	return err
+}
+
+// gridFSChunkStore store the values as files of the <collection>_chunks GridFS bucket, identified by reference
+type gridFSChunkStore struct {
+	client    *MongoClient
+	chunkSize int32
+}
+
+// getBucket return the bucket of the collection
+func (s *gridFSChunkStore) getBucket(databaseName, collectionName string) (*gridfs.Bucket, error) {
+	bucketOptions := options.GridFSBucket().SetName(collectionName + chunksCollectionSuffix).SetChunkSizeBytes(s.chunkSize)
+	return gridfs.NewBucket(s.client.getClient().Database(databaseName), bucketOptions)
+}
+
+// put upload data as the file ref
+func (s *gridFSChunkStore) put(databaseName, collectionName, ref string, data []byte) error {
+	bucket, err := s.getBucket(databaseName, collectionName)
+	if err != nil {
+		return err
+	}
+
+	return bucket.UploadFromStreamWithID(ref, ref, bytes.NewReader(data))
+}
+
+// get download the files of refs and return their data by reference
+func (s *gridFSChunkStore) get(databaseName, collectionName string, refs []string) (map[string][]byte, error) {
+	bucket, err := s.getBucket(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string][]byte{}
+	for _, ref := range refs {
+		var buffer bytes.Buffer
+		if _, err := bucket.DownloadToStream(ref, &buffer); err != nil {
+			if err == gridfs.ErrFileNotFound {
+				continue
+			}
+			return nil, err
+		}
+		values[ref] = buffer.Bytes()
+	}
+
+	return values, nil
+}
+
+// delete the files of refs, the missing files are ignored
+func (s *gridFSChunkStore) delete(databaseName, collectionName string, refs []string) error {
+	bucket, err := s.getBucket(databaseName, collectionName)
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range refs {
+		if err := bucket.Delete(ref); err != nil && err != gridfs.ErrFileNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
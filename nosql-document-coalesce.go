@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/sync/singleflight"
+)
+
+// CoalescedDatabase decorate INoSQLDocument so identical concurrent Read calls run one database query,
+// put it under CachedDatabase or QueryCache to turn a cache miss stampede into one query.
+// The callers receive their own slice, the documents it holds are shared.
+type CoalescedDatabase struct {
+	db    INoSQLDocument
+	group singleflight.Group
+}
+
+// NewCoalescedDatabase init new coalesced database
+func NewCoalescedDatabase(db INoSQLDocument) *CoalescedDatabase {
+	return &CoalescedDatabase{db: db}
+}
+
+// Create the documents
+func (c *CoalescedDatabase) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	return c.db.Create(databaseName, collectionName, documents)
+}
+
+// Read documents, waiting for the identical read in flight when there is one
+func (c *CoalescedDatabase) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	query, err := marshalExtJSON(bson.D{
+		primitive.E{Key: "filter", Value: normalizeQuery(filter)},
+		primitive.E{Key: "limit", Value: limit},
+	}, true)
+	if err != nil {
+		return c.db.Read(databaseName, collectionName, filter, limit, dataModel)
+	}
+
+	key := fmt.Sprintf("%s.%s:%s:%s", databaseName, collectionName, dataModel.String(), query)
+	results, err, shared := c.group.Do(key, func() (interface{}, error) {
+		return c.db.Read(databaseName, collectionName, filter, limit, dataModel)
+	})
+	if err != nil || !shared {
+		return results, err
+	}
+
+	return copyResults(results), nil
+}
+
+// Update the documents
+func (c *CoalescedDatabase) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	return c.db.Update(databaseName, collectionName, filter, update)
+}
+
+// Delete the documents
+func (c *CoalescedDatabase) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	return c.db.Delete(databaseName, collectionName, filter)
+}
+
+// copyResults return a pointer to a copy of the slice of results, other values are returned unchanged
+func copyResults(results interface{}) interface{} {
+	value := reflect.ValueOf(results)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Slice {
+		return results
+	}
+
+	copied := reflect.New(value.Elem().Type())
+	copied.Elem().Set(reflect.AppendSlice(reflect.MakeSlice(value.Elem().Type(), 0, value.Elem().Len()), value.Elem()))
+
+	return copied.Interface()
+}
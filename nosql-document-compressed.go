@@ -0,0 +1,367 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/klauspost/compress/zstd"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// GzipCompression compress the field with gzip, tag `compress:"gzip"`
+	GzipCompression = "gzip"
+	// ZstdCompression compress the field with zstd, faster with a better ratio, tag `compress:"zstd"`
+	ZstdCompression = "zstd"
+
+	// compressedSubtype is the user defined binary subtype of the compressed values
+	compressedSubtype = 0x80
+	// minCompressedSize is the size below which the values are stored as is, they would not shrink
+	minCompressedSize = 256
+
+	// compressedString and compressedBytes are the kinds of the compressed values
+	compressedString = 's'
+	compressedBytes  = 'b'
+)
+
+var (
+	// ErrCompressedPipeline is returned when an update pipeline is applied to a collection with compressed fields
+	ErrCompressedPipeline = errors.New("Update pipelines are not supported on compressed collections")
+
+	// compressionIDs are the first byte of the compressed values
+	compressionIDs = map[string]byte{GzipCompression: 1, ZstdCompression: 2}
+)
+
+// CompressedCollection model for a collection whose model has fields tagged with compress, like
+// Body string `bson:"body" compress:"zstd"`. Only top level and inline string and []byte fields can be compressed.
+type CompressedCollection struct {
+	Database   string
+	Collection string
+	Model      interface{} // struct or pointer to struct
+}
+
+// CompressedDatabase decorate INoSQLDocument with the compression of large text and binary fields. Create and Update
+// write the values of the tagged fields as compressed binaries, Read decompress them before decoding the documents, so
+// the uncompressed values written before are read as is. Filters can not match the values of compressed fields.
+type CompressedDatabase struct {
+	db          INoSQLDocument
+	collections map[string]map[string]string // compression by field by namespace
+	encoder     *zstd.Encoder
+	decoder     *zstd.Decoder
+}
+
+// NewCompressedDatabase init new compressed database with the compressed fields of the collection models
+func NewCompressedDatabase(db INoSQLDocument, collections ...CompressedCollection) (*CompressedDatabase, error) {
+	var errs *multierror.Error
+	namespaces := make(map[string]map[string]string, len(collections))
+	for _, collection := range collections {
+		if err := checkNamespace(collection.Database, collection.Collection); err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+
+		modelType := reflect.TypeOf(collection.Model)
+		for modelType != nil && modelType.Kind() == reflect.Ptr {
+			modelType = modelType.Elem()
+		}
+		if modelType == nil || modelType.Kind() != reflect.Struct {
+			errs = multierror.Append(errs, fmt.Errorf("compression: model of %s.%s must be a struct", collection.Database, collection.Collection))
+			continue
+		}
+
+		fields := map[string]string{}
+		if err := getCompressedFields(modelType, fields); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		namespaces[collection.Database+"."+collection.Collection] = fields
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CompressedDatabase{db: db, collections: namespaces, encoder: encoder, decoder: decoder}, nil
+}
+
+// Create the documents with the values of their compressed fields compressed
+func (c *CompressedDatabase) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	fields := c.collections[databaseName+"."+collectionName]
+	if len(fields) == 0 {
+		return c.db.Create(databaseName, collectionName, documents)
+	}
+
+	compressed := make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		value, err := toDocument(document)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.compressDocument(value, fields); err != nil {
+			return nil, err
+		}
+		compressed = append(compressed, value)
+	}
+
+	return c.db.Create(databaseName, collectionName, compressed)
+}
+
+// Read documents based on filter and decompress the values of their compressed fields
+func (c *CompressedDatabase) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	fields := c.collections[databaseName+"."+collectionName]
+	if len(fields) == 0 {
+		return c.db.Read(databaseName, collectionName, filter, limit, dataModel)
+	}
+	if err := checkDataModel(dataModel); err != nil {
+		return nil, err
+	}
+
+	// The compressed binaries can not be decoded into string fields, the documents are decoded once decompressed
+	read, err := c.db.Read(databaseName, collectionName, filter, limit, reflect.TypeOf(bson.D{}))
+	if err != nil {
+		return nil, err
+	}
+	documents, ok := read.(*[]bson.D)
+	if !ok {
+		return nil, errors.New("Unable to map results to bson.D")
+	}
+
+	results := reflect.New(reflect.SliceOf(dataModel))
+	results.Elem().Set(reflect.MakeSlice(results.Elem().Type(), len(*documents), len(*documents)))
+	for i, document := range *documents {
+		if err := c.decompressDocument(document, fields); err != nil {
+			return nil, err
+		}
+
+		raw, err := bson.Marshal(document)
+		if err != nil {
+			return nil, err
+		}
+		if err := bson.Unmarshal(raw, results.Elem().Index(i).Addr().Interface()); err != nil {
+			return nil, err
+		}
+	}
+
+	return results.Interface(), nil
+}
+
+// Update the documents matching filter, the values of compressed fields set by $set or the replacement are compressed
+func (c *CompressedDatabase) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	fields := c.collections[databaseName+"."+collectionName]
+	if len(fields) == 0 {
+		return c.db.Update(databaseName, collectionName, filter, update)
+	}
+
+	switch update.(type) {
+	case mongo.Pipeline, bson.A, []bson.D, []bson.M, []interface{}:
+		return nil, ErrCompressedPipeline
+	}
+
+	document, err := toDocument(update)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(document) == 0 || !strings.HasPrefix(document[0].Key, "$") {
+		if err := c.compressDocument(document, fields); err != nil {
+			return nil, err
+		}
+		return c.db.Update(databaseName, collectionName, filter, document)
+	}
+
+	for _, operator := range document {
+		values, _ := operator.Value.(bson.D)
+		for i, element := range values {
+			field := strings.SplitN(element.Key, ".", 2)[0]
+			compression, ok := fields[field]
+			if !ok {
+				continue
+			}
+
+			switch {
+			case (operator.Key == "$set" || operator.Key == "$setOnInsert") && element.Key == field:
+				value, err := c.compress(element.Value, compression)
+				if err != nil {
+					return nil, err
+				}
+				values[i].Value = value
+			case operator.Key == "$unset" && element.Key == field:
+			default:
+				return nil, fmt.Errorf("Unsupported operator %s on compressed field %q", operator.Key, element.Key)
+			}
+		}
+	}
+
+	return c.db.Update(databaseName, collectionName, filter, document)
+}
+
+// Delete the documents matching filter
+func (c *CompressedDatabase) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	return c.db.Delete(databaseName, collectionName, filter)
+}
+
+// compressDocument compress the values of the compressed fields of document in place
+func (c *CompressedDatabase) compressDocument(document bson.D, fields map[string]string) error {
+	for i, element := range document {
+		compression, ok := fields[element.Key]
+		if !ok {
+			continue
+		}
+
+		value, err := c.compress(element.Value, compression)
+		if err != nil {
+			return err
+		}
+		document[i].Value = value
+	}
+
+	return nil
+}
+
+// decompressDocument decompress the compressed values of the fields of document in place
+func (c *CompressedDatabase) decompressDocument(document bson.D, fields map[string]string) error {
+	for i, element := range document {
+		if _, ok := fields[element.Key]; !ok {
+			continue
+		}
+
+		value, err := c.decompress(element.Value)
+		if err != nil {
+			return fmt.Errorf("Unable to decompress field %q: %v", element.Key, err)
+		}
+		document[i].Value = value
+	}
+
+	return nil
+}
+
+// compress return the compressed binary of the string or bytes value, the small values and null are returned as is
+func (c *CompressedDatabase) compress(value interface{}, compression string) (interface{}, error) {
+	var data []byte
+	var kind byte
+	switch v := value.(type) {
+	case string:
+		data, kind = []byte(v), compressedString
+	case []byte:
+		data, kind = v, compressedBytes
+	case primitive.Binary:
+		data, kind = v.Data, compressedBytes
+	case nil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("Compressed values must be string or []byte, got %T", value)
+	}
+	if len(data) < minCompressedSize {
+		return value, nil
+	}
+
+	header := []byte{compressionIDs[compression], kind}
+	if compression == ZstdCompression {
+		return primitive.Binary{Subtype: compressedSubtype, Data: c.encoder.EncodeAll(data, header)}, nil
+	}
+
+	buffer := bytes.NewBuffer(header)
+	writer := gzip.NewWriter(buffer)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return primitive.Binary{Subtype: compressedSubtype, Data: buffer.Bytes()}, nil
+}
+
+// decompress return the string or bytes of the compressed binary value, the other values are returned as is
+func (c *CompressedDatabase) decompress(value interface{}) (interface{}, error) {
+	binary, ok := value.(primitive.Binary)
+	if !ok || binary.Subtype != compressedSubtype || len(binary.Data) < 2 {
+		return value, nil
+	}
+
+	var data []byte
+	var err error
+	switch binary.Data[0] {
+	case compressionIDs[ZstdCompression]:
+		data, err = c.decoder.DecodeAll(binary.Data[2:], nil)
+	case compressionIDs[GzipCompression]:
+		var reader *gzip.Reader
+		if reader, err = gzip.NewReader(bytes.NewReader(binary.Data[2:])); err == nil {
+			data, err = ioutil.ReadAll(reader)
+		}
+	default:
+		return nil, fmt.Errorf("unknown compression %d", binary.Data[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if binary.Data[1] == compressedString {
+		return string(data), nil
+	}
+
+	return primitive.Binary{Data: data}, nil
+}
+
+// getCompressedFields add the fields of structType tagged with compress to fields by their bson name
+func getCompressedFields(structType reflect.Type, fields map[string]string) error {
+	var errs *multierror.Error
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("bson"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		for _, flag := range tag[1:] {
+			if flag == "inline" && fieldType.Kind() == reflect.Struct {
+				if err := getCompressedFields(fieldType, fields); err != nil {
+					errs = multierror.Append(errs, err)
+				}
+			}
+		}
+
+		compression, ok := field.Tag.Lookup("compress")
+		if !ok {
+			continue
+		}
+		if _, ok := compressionIDs[compression]; !ok {
+			errs = multierror.Append(errs, fmt.Errorf("compression: unknown compression %q of field %s", compression, field.Name))
+			continue
+		}
+		if fieldType.Kind() != reflect.String && fieldType != bytesType {
+			errs = multierror.Append(errs, fmt.Errorf("compression: field %s must be string or []byte", field.Name))
+			continue
+		}
+		fields[name] = compression
+	}
+
+	return errs.ErrorOrNil()
+}
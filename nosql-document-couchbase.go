@@ -0,0 +1,602 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/golang-common-packages/hash"
+)
+
+var (
+	// couchbaseClientSessionMapping singleton pattern
+	couchbaseClientSessionMapping = make(map[string]*CouchbaseClient)
+	// n1qlComparisons map the MongoDB comparison operators to N1QL comparators
+	n1qlComparisons = map[string]string{"$gt": ">", "$gte": ">=", "$lt": "<", "$lte": "<="}
+)
+
+// CouchbaseClient manage all Couchbase actions through the N1QL query service. Documents of collectionName are the
+// documents of the collection in the scope of the config and the bucket databaseName, keyed by their _id. Reads by _id
+// and Get use the key-value path of USE KEYS, the other filters need an index of the collection.
+type CouchbaseClient struct {
+	Client     *http.Client
+	Config     *Couchbase
+	durability string
+	mu         *sync.Mutex
+	next       *int // address tried first by the next request
+}
+
+// CouchbaseError is returned when the query service answer with errors
+type CouchbaseError struct {
+	Status  int
+	Code    int // like 12009 for a DML error, 13014 for missing credentials
+	Message string
+}
+
+// Error return the message of the first Couchbase error
+func (e *CouchbaseError) Error() string {
+	return fmt.Sprintf("Couchbase error %d: %s", e.Code, e.Message)
+}
+
+// n1qlExpression private model for the named parameters of a N1QL statement
+type n1qlExpression struct {
+	params map[string]interface{}
+}
+
+// n1qlResponse private model for the response of the query service
+type n1qlResponse struct {
+	Status  string            `json:"status"`
+	Results []json.RawMessage `json:"results"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"msg"`
+	} `json:"errors"`
+	Metrics struct {
+		MutationCount int64 `json:"mutationCount"`
+	} `json:"metrics"`
+}
+
+// newCouchbase init new instance
+func newCouchbase(config *Couchbase) INoSQLDocument {
+	hasher := &hash.Client{}
+	configAsJSON, err := json.Marshal(config)
+	if err != nil {
+		log.Fatalln("Unable to marshal Couchbase configuration: ", err)
+	}
+	configAsString := hasher.SHA1(string(configAsJSON))
+
+	currentCouchbaseSession := couchbaseClientSessionMapping[configAsString]
+	if currentCouchbaseSession == nil {
+		currentCouchbaseSession = NewCouchbaseClient(config)
+
+		// Check the connection status
+		if _, err := currentCouchbaseSession.query("SELECT RAW 1", nil, false); err != nil {
+			log.Fatalln("Unable to connect to Couchbase: ", err)
+		}
+		couchbaseClientSessionMapping[configAsString] = currentCouchbaseSession
+		log.Println("Connected to Couchbase")
+	}
+
+	return currentCouchbaseSession
+}
+
+// NewCouchbaseClient init new client of config without checking the connection, New check it
+func NewCouchbaseClient(config *Couchbase) *CouchbaseClient {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &CouchbaseClient{
+		Client:     &http.Client{Timeout: timeout},
+		Config:     config,
+		durability: config.Durability,
+		mu:         &sync.Mutex{},
+		next:       new(int),
+	}
+}
+
+// WithDurability return a client sharing the connections of c whose writes wait for the durability level, empty for
+// the default of the bucket, majority, majorityAndPersistActive or persistToMajority
+func (c *CouchbaseClient) WithDurability(level string) (*CouchbaseClient, error) {
+	if err := checkCouchbaseDurability(level); err != nil {
+		return nil, err
+	}
+
+	durable := *c
+	durable.durability = level
+
+	return &durable, nil
+}
+
+// Get return the document of id in the collection as a pointer to a new dataModel, read by key without index,
+// ErrDocumentNotFound when it does not exist
+func (c *CouchbaseClient) Get(databaseName, collectionName, id string, dataModel reflect.Type) (interface{}, error) {
+	documents, err := c.Read(databaseName, collectionName, bson.M{"_id": id}, 1, dataModel)
+	if err != nil {
+		return nil, err
+	}
+
+	results := reflect.ValueOf(documents).Elem()
+	if results.Len() == 0 {
+		return nil, ErrDocumentNotFound
+	}
+
+	return results.Index(0).Addr().Interface(), nil
+}
+
+// Create insert the documents in one statement and return their _id, an ObjectID is generated for the documents
+// without _id. The statement fails when a key already exists.
+func (c *CouchbaseClient) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	keyspace, err := c.getKeyspace(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	if len(documents) == 0 {
+		return nil, &InvalidArgumentError{Argument: "documents", Reason: "cannot be empty"}
+	}
+
+	expression := newN1QLExpression()
+	result := &mongo.InsertManyResult{InsertedIDs: make([]interface{}, 0, len(documents))}
+	values := make([]string, 0, len(documents))
+	for _, document := range documents {
+		id, err := GetDocumentID(document)
+		if err != nil {
+			id = primitive.NewObjectID()
+		}
+		source, err := toJSONSource(document)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, "("+expression.value(getIDString(id))+", "+expression.value(source)+")")
+		result.InsertedIDs = append(result.InsertedIDs, id)
+	}
+
+	if _, err := c.query("INSERT INTO "+keyspace+" (KEY, VALUE) VALUES "+strings.Join(values, ", "), expression.params, true); err != nil {
+		log.Println("Unable to create document: ", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Read documents from the collection based on filter, limit 0 means no limit
+func (c *CouchbaseClient) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	keyspace, err := c.getKeyspace(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLimit(limit); err != nil {
+		return nil, err
+	}
+	if err := checkDataModel(dataModel); err != nil {
+		return nil, err
+	}
+
+	expression := newN1QLExpression()
+	keys, where, err := expression.target(filter)
+	if err != nil {
+		return nil, err
+	}
+	statement := "SELECT META(d).id AS _id, d.* FROM " + keyspace + " AS d" + keys + where
+	if limit > 0 {
+		statement += " LIMIT " + strconv.FormatInt(limit, 10)
+	}
+
+	response, err := c.query(statement, expression.params, false)
+	if err != nil {
+		log.Println("Unable to read documents: ", err)
+		return nil, err
+	}
+
+	results := reflect.New(reflect.SliceOf(dataModel))
+	results.Elem().Set(reflect.MakeSlice(results.Elem().Type(), len(response.Results), len(response.Results)))
+	for i, raw := range response.Results {
+		var source map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		if err := decoder.Decode(&source); err != nil {
+			return nil, err
+		}
+		if err := decodeJSONDocument(source, results.Elem().Index(i).Addr().Interface()); err != nil {
+			log.Println("Unable to decode document: ", err)
+			return nil, err
+		}
+	}
+
+	return results.Interface(), nil
+}
+
+// Update the documents matching filter in one statement, update support $set, $unset and $inc
+func (c *CouchbaseClient) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	keyspace, err := c.getKeyspace(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNotNil("filter", filter); err != nil {
+		return nil, err
+	}
+	if err := checkNotNil("update", update); err != nil {
+		return nil, err
+	}
+
+	expression := newN1QLExpression()
+	keys, where, err := expression.target(filter)
+	if err != nil {
+		return nil, err
+	}
+	mutations, err := expression.update(update)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.query("UPDATE "+keyspace+" AS d"+keys+" "+mutations+where, expression.params, true)
+	if err != nil {
+		log.Println("Unable to update: ", err)
+		return nil, err
+	}
+
+	return &mongo.UpdateResult{MatchedCount: response.Metrics.MutationCount, ModifiedCount: response.Metrics.MutationCount}, nil
+}
+
+// Delete the documents matching filter in one statement
+func (c *CouchbaseClient) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	keyspace, err := c.getKeyspace(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNotNil("filter", filter); err != nil {
+		return nil, err
+	}
+
+	expression := newN1QLExpression()
+	keys, where, err := expression.target(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.query("DELETE FROM "+keyspace+" AS d"+keys+where, expression.params, true)
+	if err != nil {
+		log.Println("Unable to delete: ", err)
+		return nil, err
+	}
+
+	return &mongo.DeleteResult{DeletedCount: response.Metrics.MutationCount}, nil
+}
+
+// getKeyspace return the escaped keyspace of the collection in the bucket databaseName
+func (c *CouchbaseClient) getKeyspace(databaseName, collectionName string) (string, error) {
+	if err := checkNamespace(databaseName, collectionName); err != nil {
+		return "", err
+	}
+
+	scope := c.Config.Scope
+	if scope == "" {
+		scope = "_default"
+	}
+	for _, name := range []string{databaseName, scope, collectionName} {
+		if strings.Contains(name, "`") {
+			return "", &InvalidArgumentError{Argument: "namespace", Reason: "cannot contain backquotes"}
+		}
+	}
+
+	return "`" + databaseName + "`.`" + scope + "`.`" + collectionName + "`", nil
+}
+
+// query run the statement with its named parameters on the addresses in turn until one answers, mutation apply the
+// durability level of the client. A mutation may have been applied when the request failed after being sent, so
+// mutations are only sent to the next address when the connection could not be established.
+func (c *CouchbaseClient) query(statement string, params map[string]interface{}, mutation bool) (*n1qlResponse, error) {
+	body := map[string]interface{}{"statement": statement}
+	for name, value := range params {
+		body[name] = value
+	}
+	if c.Config.ScanConsistency != "" {
+		body["scan_consistency"] = c.Config.ScanConsistency
+	} else {
+		body["scan_consistency"] = "request_plus"
+	}
+	if mutation && c.durability != "" {
+		body["durability_level"] = c.durability
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	first := *c.next
+	c.mu.Unlock()
+
+	var lastErr error
+	for i := range c.Config.Addresses {
+		index := (first + i) % len(c.Config.Addresses)
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(c.Config.Addresses[index], "/")+"/query/service", bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Content-Type", "application/json")
+		if c.Config.Username != "" {
+			request.SetBasicAuth(c.Config.Username, c.Config.Password)
+		}
+
+		response, err := c.Client.Do(request)
+		if err != nil {
+			lastErr = err
+			if mutation && !isDialError(err) {
+				return nil, err
+			}
+			continue
+		}
+
+		c.mu.Lock()
+		*c.next = index
+		c.mu.Unlock()
+
+		return decodeN1QLResponse(response)
+	}
+	if lastErr == nil {
+		lastErr = errors.New("No Couchbase address")
+	}
+
+	return nil, lastErr
+}
+
+// isDialError return true when err is a failure to connect, so the request was not sent
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// decodeN1QLResponse close the response and decode it, or return the CouchbaseError of its first error
+func decodeN1QLResponse(response *http.Response) (*n1qlResponse, error) {
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result n1qlResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, &CouchbaseError{Status: response.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+	if len(result.Errors) > 0 {
+		return nil, &CouchbaseError{Status: response.StatusCode, Code: result.Errors[0].Code, Message: result.Errors[0].Message}
+	}
+	if response.StatusCode >= http.StatusMultipleChoices || result.Status != "success" {
+		return nil, &CouchbaseError{Status: response.StatusCode, Message: "query status " + result.Status}
+	}
+
+	return &result, nil
+}
+
+// checkCouchbaseDurability return an error when level is not a durability level of Couchbase
+func checkCouchbaseDurability(level string) error {
+	switch level {
+	case "", "none", "majority", "majorityAndPersistActive", "persistToMajority":
+		return nil
+	}
+
+	return fmt.Errorf("unknown durability %q", level)
+}
+
+// newN1QLExpression init new expression without parameters
+func newN1QLExpression() *n1qlExpression {
+	return &n1qlExpression{params: map[string]interface{}{}}
+}
+
+// value return the named parameter of the BSON value
+func (ne *n1qlExpression) value(value interface{}) string {
+	name := "$p" + strconv.Itoa(len(ne.params))
+	ne.params[name] = toJSONValue(value)
+
+	return name
+}
+
+// path return the expression of the dotted path of the document d, _id is its key and array indexes are kept like [2]
+func (ne *n1qlExpression) path(path string) (string, error) {
+	if path == "_id" {
+		return "META(d).id", nil
+	}
+	if err := checkFieldPath(path); err != nil {
+		return "", err
+	}
+
+	var expression strings.Builder
+	expression.WriteString("d")
+	for i, segment := range strings.Split(path, ".") {
+		if strings.Contains(segment, "`") {
+			return "", &InvalidArgumentError{Argument: "path", Reason: "cannot contain backquotes"}
+		}
+		if _, err := strconv.Atoi(segment); err == nil && i > 0 {
+			expression.WriteString("[" + segment + "]")
+			continue
+		}
+		expression.WriteString(".`" + segment + "`")
+	}
+
+	return expression.String(), nil
+}
+
+// target return the USE KEYS clause of the filter on _id or the WHERE clause of the other filters, with their leading
+// space, USE KEYS follow the keyspace and WHERE end the statement
+func (ne *n1qlExpression) target(filter interface{}) (string, string, error) {
+	if id, ok := getFilterID(filter); ok {
+		return " USE KEYS " + ne.value(getIDString(id)), "", nil
+	}
+	if filter == nil {
+		return "", "", nil
+	}
+
+	document, err := toDocument(filter)
+	if err != nil {
+		return "", "", err
+	}
+	condition, err := ne.condition(document)
+	if err != nil || condition == "" {
+		return "", "", err
+	}
+
+	return "", " WHERE " + condition, nil
+}
+
+// condition return the N1QL condition of the filter document, empty when it has no element
+func (ne *n1qlExpression) condition(document bson.D) (string, error) {
+	clauses := make([]string, 0, len(document))
+	for _, element := range document {
+		switch element.Key {
+		case "$and", "$or", "$nor":
+			items, ok := element.Value.(bson.A)
+			if !ok || len(items) == 0 {
+				return "", fmt.Errorf("%w: %s requires a non-empty array", ErrUnsupportedFilter, element.Key)
+			}
+			conditions := make([]string, 0, len(items))
+			for _, item := range items {
+				itemDocument, ok := item.(bson.D)
+				if !ok {
+					return "", fmt.Errorf("%w: %s requires documents", ErrUnsupportedFilter, element.Key)
+				}
+				condition, err := ne.condition(itemDocument)
+				if err != nil {
+					return "", err
+				}
+				if condition == "" {
+					condition = "TRUE"
+				}
+				conditions = append(conditions, "("+condition+")")
+			}
+			switch element.Key {
+			case "$and":
+				clauses = append(clauses, strings.Join(conditions, " AND "))
+			case "$or":
+				clauses = append(clauses, "("+strings.Join(conditions, " OR ")+")")
+			case "$nor":
+				clauses = append(clauses, "NOT ("+strings.Join(conditions, " OR ")+")")
+			}
+			continue
+		}
+		if strings.HasPrefix(element.Key, "$") {
+			return "", fmt.Errorf("%w: %s", ErrUnsupportedFilter, element.Key)
+		}
+
+		operators, ok := element.Value.(bson.D)
+		if !ok || len(operators) == 0 || !strings.HasPrefix(operators[0].Key, "$") {
+			operators = bson.D{primitive.E{Key: "$eq", Value: element.Value}}
+		}
+
+		path, err := ne.path(element.Key)
+		if err != nil {
+			return "", err
+		}
+		for _, operator := range operators {
+			switch operator.Key {
+			case "$eq":
+				if isBSONNull(operator.Value) {
+					clauses = append(clauses, path+" IS NOT VALUED")
+				} else {
+					clauses = append(clauses, path+" = "+ne.value(operator.Value))
+				}
+			case "$ne":
+				// Missing and null fields are different from every value, like in MongoDB
+				if isBSONNull(operator.Value) {
+					clauses = append(clauses, path+" IS VALUED")
+				} else {
+					clauses = append(clauses, "NOT IFMISSINGORNULL("+path+" = "+ne.value(operator.Value)+", FALSE)")
+				}
+			case "$in", "$nin":
+				if _, ok := operator.Value.(bson.A); !ok {
+					return "", fmt.Errorf("%w: %s requires an array", ErrUnsupportedFilter, operator.Key)
+				}
+				if operator.Key == "$in" {
+					clauses = append(clauses, path+" IN "+ne.value(operator.Value))
+				} else {
+					clauses = append(clauses, "NOT IFMISSINGORNULL("+path+" IN "+ne.value(operator.Value)+", FALSE)")
+				}
+			case "$gt", "$gte", "$lt", "$lte":
+				clauses = append(clauses, path+" "+n1qlComparisons[operator.Key]+" "+ne.value(operator.Value))
+			case "$exists":
+				if exists, _ := operator.Value.(bool); exists {
+					clauses = append(clauses, path+" IS NOT MISSING")
+				} else {
+					clauses = append(clauses, path+" IS MISSING")
+				}
+			default:
+				return "", fmt.Errorf("%w: %s", ErrUnsupportedFilter, operator.Key)
+			}
+		}
+	}
+
+	return strings.Join(clauses, " AND "), nil
+}
+
+// update return the SET and UNSET clauses of the $set, $unset and $inc fields of update
+func (ne *n1qlExpression) update(update interface{}) (string, error) {
+	document, err := toDocument(update)
+	if err != nil {
+		return "", err
+	}
+
+	var set, unset []string
+	for _, element := range document {
+		fields, ok := element.Value.(bson.D)
+		if !ok {
+			return "", fmt.Errorf("%w: update %s requires a document", ErrUnsupportedFilter, element.Key)
+		}
+
+		for _, field := range fields {
+			if field.Key == "_id" {
+				return "", &InvalidArgumentError{Argument: "update", Reason: "cannot change _id"}
+			}
+			path, err := ne.path(field.Key)
+			if err != nil {
+				return "", err
+			}
+
+			switch element.Key {
+			case "$set":
+				set = append(set, path+" = "+ne.value(field.Value))
+			case "$inc":
+				set = append(set, path+" = IFMISSINGORNULL("+path+", 0) + "+ne.value(field.Value))
+			case "$unset":
+				unset = append(unset, path)
+			default:
+				return "", fmt.Errorf("%w: update %s", ErrUnsupportedFilter, element.Key)
+			}
+		}
+	}
+	if len(set) == 0 && len(unset) == 0 {
+		return "", &InvalidArgumentError{Argument: "update", Reason: "cannot be empty"}
+	}
+
+	var clauses []string
+	if len(set) > 0 {
+		clauses = append(clauses, "SET "+strings.Join(set, ", "))
+	}
+	if len(unset) > 0 {
+		clauses = append(clauses, "UNSET "+strings.Join(unset, ", "))
+	}
+
+	return strings.Join(clauses, " "), nil
+}
+
+// Capabilities implement ICapabilities
+func (c *CouchbaseClient) Capabilities() Capabilities {
+	return Capabilities{MaxDocumentSize: 20 * 1024 * 1024}
+}
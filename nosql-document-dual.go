@@ -0,0 +1,375 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// defaultMaxDivergences is the number of divergences kept by DualWriter
+	defaultMaxDivergences = 1000
+)
+
+// DualWriterOptions model for DualWriter
+type DualWriterOptions struct {
+	CompareReads   bool // read from the shadow database too and record the reads with different documents
+	MaxDivergences int  // number of last divergences kept, default 1000
+}
+
+// DualWriter decorate INoSQLDocument with the dual-write of every write to a shadow database, like MongoDB to DynamoDB
+// during a migration. The primary database stays the source of truth: its errors are returned and the shadow is not
+// written, the shadow errors and the results different from the primary are recorded as divergences. The documents
+// created without _id are written to the shadow with the IDs generated by the primary.
+type DualWriter struct {
+	primary     INoSQLDocument
+	shadow      INoSQLDocument
+	options     DualWriterOptions
+	mu          sync.Mutex
+	divergences []Divergence
+	total       uint64
+}
+
+// NewDualWriter init new dual-writer of primary into shadow
+func NewDualWriter(primary, shadow INoSQLDocument, dualOptions *DualWriterOptions) *DualWriter {
+	if dualOptions == nil {
+		dualOptions = &DualWriterOptions{}
+	}
+	if dualOptions.MaxDivergences <= 0 {
+		dualOptions.MaxDivergences = defaultMaxDivergences
+	}
+
+	return &DualWriter{primary: primary, shadow: shadow, options: *dualOptions}
+}
+
+// Create the documents in the primary database then in the shadow database
+func (dw *DualWriter) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	result, err := dw.primary.Create(databaseName, collectionName, documents)
+	if err != nil {
+		return nil, err
+	}
+
+	if insertResult, ok := result.(*mongo.InsertManyResult); ok {
+		documents = withInsertedIDs(documents, insertResult.InsertedIDs)
+	}
+	shadowResult, err := dw.shadow.Create(databaseName, collectionName, documents)
+	dw.compare("create", databaseName, collectionName, result, shadowResult, err)
+
+	return result, nil
+}
+
+// Read documents from the primary database, and from the shadow database to compare them when CompareReads is set
+func (dw *DualWriter) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	results, err := dw.primary.Read(databaseName, collectionName, filter, limit, dataModel)
+	if err != nil || !dw.options.CompareReads {
+		return results, err
+	}
+
+	shadowResults, err := dw.shadow.Read(databaseName, collectionName, filter, limit, dataModel)
+	if err != nil {
+		dw.record("read", databaseName, collectionName, "shadow error: "+err.Error())
+		return results, nil
+	}
+
+	primaryDocuments, err := getComparableDocuments(results)
+	if err == nil {
+		var shadowDocuments map[string]string
+		if shadowDocuments, err = getComparableDocuments(shadowResults); err == nil {
+			// A limited read may select other documents of the same filter, only the common documents are compared
+			for id, document := range primaryDocuments {
+				shadowDocument, ok := shadowDocuments[id]
+				if !ok && limit == 0 {
+					dw.record("read", databaseName, collectionName, fmt.Sprintf("document %s is missing", id))
+					break
+				}
+				if ok && shadowDocument != document {
+					dw.record("read", databaseName, collectionName, fmt.Sprintf("document %s is different", id))
+					break
+				}
+			}
+		}
+	}
+	if err != nil {
+		dw.record("read", databaseName, collectionName, "unable to compare results: "+err.Error())
+	}
+
+	return results, nil
+}
+
+// Update the documents in the primary database then in the shadow database
+func (dw *DualWriter) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	result, err := dw.primary.Update(databaseName, collectionName, filter, update)
+	if err != nil {
+		return nil, err
+	}
+
+	shadowResult, err := dw.shadow.Update(databaseName, collectionName, filter, update)
+	dw.compare("update", databaseName, collectionName, result, shadowResult, err)
+
+	return result, nil
+}
+
+// Delete the documents from the primary database then from the shadow database
+func (dw *DualWriter) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	result, err := dw.primary.Delete(databaseName, collectionName, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	shadowResult, err := dw.shadow.Delete(databaseName, collectionName, filter)
+	dw.compare("delete", databaseName, collectionName, result, shadowResult, err)
+
+	return result, nil
+}
+
+// Aggregate run the pipeline on the primary database when it implements IAggregate
+func (dw *DualWriter) Aggregate(databaseName, collectionName string, pipeline interface{}, dataModel reflect.Type) (interface{}, error) {
+	aggregator, ok := dw.primary.(IAggregate)
+	if !ok {
+		return nil, errors.New("Database does not support aggregation")
+	}
+
+	return aggregator.Aggregate(databaseName, collectionName, pipeline, dataModel)
+}
+
+// Divergences return the last divergences, oldest first, and the total number of divergences recorded
+func (dw *DualWriter) Divergences() ([]Divergence, uint64) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	return append([]Divergence(nil), dw.divergences...), dw.total
+}
+
+// Reconcile compare the documents matching filter in the primary and the shadow databases by _id. When repair is
+// set, the missing and different documents are written again to the shadow database and the extra ones deleted.
+func (dw *DualWriter) Reconcile(databaseName, collectionName string, filter interface{}, repair bool) (*ReconciliationReport, error) {
+	primaryDocuments, err := dw.readDocuments(dw.primary, databaseName, collectionName, filter)
+	if err != nil {
+		return nil, err
+	}
+	shadowDocuments, err := dw.readDocuments(dw.shadow, databaseName, collectionName, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReconciliationReport{
+		Database:   databaseName,
+		Collection: collectionName,
+		Compared:   int64(len(primaryDocuments)),
+		Missing:    []string{},
+		Extra:      []string{},
+		Different:  []string{},
+	}
+
+	var rewrites []bson.M
+	var extras []interface{}
+	for id, document := range primaryDocuments {
+		shadowDocument, ok := shadowDocuments[id]
+		switch {
+		case !ok:
+			report.Missing = append(report.Missing, id)
+		case shadowDocument.comparable != document.comparable:
+			report.Different = append(report.Different, id)
+		default:
+			continue
+		}
+		rewrites = append(rewrites, document.document)
+	}
+	for id, document := range shadowDocuments {
+		if _, ok := primaryDocuments[id]; !ok {
+			report.Extra = append(report.Extra, id)
+			extras = append(extras, document.document["_id"])
+		}
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.Extra)
+	sort.Strings(report.Different)
+
+	if !repair {
+		return report, nil
+	}
+
+	for _, document := range rewrites {
+		// Delete then create, so the fields removed from the primary document are removed from the shadow too
+		if _, err := dw.shadow.Delete(databaseName, collectionName, bson.M{"_id": document["_id"]}); err != nil {
+			log.Println("Unable to repair shadow document: ", err)
+			return report, err
+		}
+		if _, err := dw.shadow.Create(databaseName, collectionName, []interface{}{document}); err != nil {
+			log.Println("Unable to repair shadow document: ", err)
+			return report, err
+		}
+		report.Repaired++
+	}
+	if len(extras) > 0 {
+		if _, err := dw.shadow.Delete(databaseName, collectionName, bson.M{"_id": bson.M{"$in": extras}}); err != nil {
+			log.Println("Unable to delete extra shadow documents: ", err)
+			return report, err
+		}
+		report.Repaired += int64(len(extras))
+	}
+
+	return report, nil
+}
+
+// reconciledDocument private model for a document read by Reconcile
+type reconciledDocument struct {
+	document   bson.M
+	comparable string
+}
+
+// readDocuments return the documents matching filter in db by _id
+func (dw *DualWriter) readDocuments(db INoSQLDocument, databaseName, collectionName string, filter interface{}) (map[string]reconciledDocument, error) {
+	results, err := db.Read(databaseName, collectionName, filter, 0, reflect.TypeOf(bson.M{}))
+	if err != nil {
+		return nil, err
+	}
+
+	documents, ok := results.(*[]bson.M)
+	if !ok {
+		return nil, errors.New("Unable to map results to bson.M model")
+	}
+
+	reconciled := make(map[string]reconciledDocument, len(*documents))
+	for _, document := range *documents {
+		comparable, err := getComparableDocument(document)
+		if err != nil {
+			return nil, err
+		}
+		reconciled[getIDString(document["_id"])] = reconciledDocument{document: document, comparable: comparable}
+	}
+
+	return reconciled, nil
+}
+
+// compare record a divergence when the shadow write failed or affected a different number of documents
+func (dw *DualWriter) compare(operation, databaseName, collectionName string, result, shadowResult interface{}, shadowErr error) {
+	if shadowErr != nil {
+		dw.record(operation, databaseName, collectionName, "shadow error: "+shadowErr.Error())
+		return
+	}
+
+	count, ok := getWriteCount(result)
+	shadowCount, shadowOk := getWriteCount(shadowResult)
+	if ok && shadowOk && count != shadowCount {
+		dw.record(operation, databaseName, collectionName, fmt.Sprintf("%d documents written to the primary and %d to the shadow", count, shadowCount))
+	}
+}
+
+// record keep the divergence and drop the oldest one over MaxDivergences
+func (dw *DualWriter) record(operation, databaseName, collectionName, reason string) {
+	log.Printf("Shadow database diverged on %s of %s.%s: %s", operation, databaseName, collectionName, reason)
+
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	dw.total++
+	dw.divergences = append(dw.divergences, Divergence{
+		Time:       time.Now(),
+		Operation:  operation,
+		Database:   databaseName,
+		Collection: collectionName,
+		Reason:     reason,
+	})
+	if len(dw.divergences) > dw.options.MaxDivergences {
+		dw.divergences = dw.divergences[len(dw.divergences)-dw.options.MaxDivergences:]
+	}
+}
+
+// withInsertedIDs return the documents with the _id generated by the primary database for those without _id
+func withInsertedIDs(documents []interface{}, ids []interface{}) []interface{} {
+	if len(ids) != len(documents) {
+		return documents
+	}
+
+	identified := make([]interface{}, len(documents))
+	for i, document := range documents {
+		identified[i] = document
+		if _, err := GetDocumentID(document); err == nil {
+			continue
+		}
+		if value, err := toDocument(document); err == nil {
+			identified[i] = append(bson.D{primitive.E{Key: "_id", Value: ids[i]}}, value...)
+		}
+	}
+
+	return identified
+}
+
+// getWriteCount return the number of documents written according to result
+func getWriteCount(result interface{}) (int64, bool) {
+	switch r := result.(type) {
+	case *mongo.InsertManyResult:
+		return int64(len(r.InsertedIDs)), true
+	case *mongo.UpdateResult:
+		return r.MatchedCount, true
+	case *mongo.DeleteResult:
+		return r.DeletedCount, true
+	}
+
+	return 0, false
+}
+
+// getComparableDocuments return the documents of results, a pointer to a slice, in comparable form by _id
+func getComparableDocuments(results interface{}) (map[string]string, error) {
+	value := reflect.ValueOf(results)
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Slice {
+		return nil, errors.New("Unable to map results to a slice")
+	}
+
+	documents := make(map[string]string, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		document := value.Index(i).Interface()
+		id, err := GetDocumentID(document)
+		if err != nil {
+			return nil, err
+		}
+		if documents[getIDString(id)], err = getComparableDocument(document); err != nil {
+			return nil, err
+		}
+	}
+
+	return documents, nil
+}
+
+// getComparableDocument return the document as JSON with sorted keys, so the same document read from different
+// databases compare equal despite their numeric types, ObjectID as string and date precision
+func getComparableDocument(document interface{}) (string, error) {
+	value, err := toDocument(document)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(toJSONValue(value))
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// Capabilities implement ICapabilities, the capabilities of the primary database without transactions since the shadow
+// writes are not part of them, and the smallest maximum document size of both databases
+func (dw *DualWriter) Capabilities() Capabilities {
+	capabilities := getDecoratedCapabilities(dw.primary)
+	capabilities.Transactions = false
+
+	shadowSize := GetCapabilities(dw.shadow).MaxDocumentSize
+	if shadowSize > 0 && (capabilities.MaxDocumentSize == 0 || shadowSize < capabilities.MaxDocumentSize) {
+		capabilities.MaxDocumentSize = shadowSize
+	}
+
+	return capabilities
+}
@@ -0,0 +1,878 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/golang-common-packages/hash"
+)
+
+const (
+	// dynamoBatchSize is the maximum number of requests of a BatchWriteItem
+	dynamoBatchSize = 25
+	// dynamoBatchAttempts is the number of attempts of the unprocessed requests of a batch
+	dynamoBatchAttempts = 8
+)
+
+var (
+	// dynamoDBClientSessionMapping singleton pattern
+	dynamoDBClientSessionMapping = make(map[string]*DynamoClient)
+	// dynamoComparisons map the MongoDB comparison operators to DynamoDB comparators
+	dynamoComparisons = map[string]string{"$gt": ">", "$gte": ">=", "$lt": "<", "$lte": "<="}
+)
+
+// DynamoClient manage all DynamoDB actions. Documents of collectionName are the items of the table configured for
+// collectionName, or of the table databaseName-collectionName with _id hash key. Filters fixing the hash key of the
+// table or of a global secondary index run as Query, the other filters as Scan. Results of the writes are the MongoDB
+// result models, so Repository works with both databases.
+type DynamoClient struct {
+	Client dynamodbiface.DynamoDBAPI
+	Config *DynamoDB
+}
+
+// dynamoExpression private model for the placeholders of the attribute names and values of DynamoDB expressions
+type dynamoExpression struct {
+	names       map[string]*string
+	values      map[string]*dynamodb.AttributeValue
+	placeholder map[string]string // by attribute name
+}
+
+// dynamoPlan private model for the Query or Scan of a filter
+type dynamoPlan struct {
+	query        bool
+	indexName    string
+	keyCondition string
+	filter       string
+}
+
+// newDynamoDB init new instance
+func newDynamoDB(config *DynamoDB) INoSQLDocument {
+	hasher := &hash.Client{}
+	configAsJSON, err := json.Marshal(config)
+	if err != nil {
+		log.Fatalln("Unable to marshal DynamoDB configuration: ", err)
+	}
+	configAsString := hasher.SHA1(string(configAsJSON))
+
+	currentDynamoSession := dynamoDBClientSessionMapping[configAsString]
+	if currentDynamoSession == nil {
+		currentDynamoSession, err = NewDynamoClient(config)
+		if err != nil {
+			log.Fatalln("Unable to create DynamoDB session: ", err)
+		}
+
+		// Check the connection status
+		if _, err := currentDynamoSession.Client.ListTablesWithContext(ctx, &dynamodb.ListTablesInput{Limit: aws.Int64(1)}); err != nil {
+			log.Fatalln("Unable to connect to DynamoDB: ", err)
+		}
+		dynamoDBClientSessionMapping[configAsString] = currentDynamoSession
+		log.Println("Connected to DynamoDB")
+	}
+
+	return currentDynamoSession
+}
+
+// NewDynamoClient init new client of config without checking the connection, New check it
+func NewDynamoClient(config *DynamoDB) (*DynamoClient, error) {
+	awsConfig := aws.NewConfig().WithRegion(config.Region)
+	if config.Endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(config.Endpoint)
+	}
+	if config.AccessKeyID != "" {
+		awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(config.AccessKeyID, config.SecretAccessKey, config.SessionToken))
+	}
+
+	awsSession, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DynamoClient{Client: dynamodb.New(awsSession), Config: config}, nil
+}
+
+// Create put the documents in the table in order and return their hash keys, like InsertMany it stops at the first
+// error and fails on the documents whose keys already exist. An ObjectID _id is generated when the hash key is a missing _id.
+func (d *DynamoClient) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	if err := checkNamespace(databaseName, collectionName); err != nil {
+		return nil, err
+	}
+	if len(documents) == 0 {
+		return nil, &InvalidArgumentError{Argument: "documents", Reason: "cannot be empty"}
+	}
+
+	table := d.getTable(databaseName, collectionName)
+	expression := newDynamoExpression()
+	condition := "attribute_not_exists(" + expression.name(table.HashKey) + ")"
+
+	result := &mongo.InsertManyResult{InsertedIDs: make([]interface{}, 0, len(documents))}
+	for _, document := range documents {
+		value, err := toDocument(document)
+		if err != nil {
+			return result, err
+		}
+		if _, ok := getElement(value, "_id"); !ok && table.HashKey == "_id" {
+			value = append(bson.D{primitive.E{Key: "_id", Value: primitive.NewObjectID()}}, value...)
+		}
+
+		id, ok := getElement(value, table.HashKey)
+		_, hasRangeKey := getElement(value, table.RangeKey)
+		if !ok || (table.RangeKey != "" && !hasRangeKey) {
+			return result, &InvalidArgumentError{Argument: "documents", Reason: "must have the keys of table " + table.Name}
+		}
+
+		if _, err := d.Client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+			TableName:                aws.String(table.Name),
+			Item:                     toDynamoValue(value).M,
+			ConditionExpression:      aws.String(condition),
+			ExpressionAttributeNames: expression.names,
+		}); err != nil {
+			log.Println("Unable to create document: ", err)
+			return result, err
+		}
+		result.InsertedIDs = append(result.InsertedIDs, id)
+	}
+
+	return result, nil
+}
+
+// Read documents from the table based on filter, limit 0 means no limit
+func (d *DynamoClient) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	page, err := d.GetALL(databaseName, collectionName, filter, "", limit, dataModel)
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Data, nil
+}
+
+// GetByField return the documents whose field equal value, from a Query when field is the hash key of the table or
+// of an index
+func (d *DynamoClient) GetByField(databaseName, collectionName, field string, value interface{}, dataModel reflect.Type) (interface{}, error) {
+	if err := checkFieldPath(field); err != nil {
+		return nil, err
+	}
+
+	return d.Read(databaseName, collectionName, bson.D{primitive.E{Key: field, Value: value}}, 0, dataModel)
+}
+
+// GetALL return a page of at most limit documents matching filter after the lastID cursor, empty for the first page.
+// The Next cursor of the page encode the exclusive start key of the next page and is empty on the last page,
+// limit 0 means every document.
+func (d *DynamoClient) GetALL(databaseName, collectionName string, filter interface{}, lastID string, limit int64, dataModel reflect.Type) (*Page, error) {
+	if err := checkNamespace(databaseName, collectionName); err != nil {
+		return nil, err
+	}
+	if err := checkLimit(limit); err != nil {
+		return nil, err
+	}
+	if err := checkDataModel(dataModel); err != nil {
+		return nil, err
+	}
+
+	startKey, err := decodeDynamoCursor(lastID)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []map[string]*dynamodb.AttributeValue
+	lastKey, err := d.iterate(d.getTable(databaseName, collectionName), filter, startKey, limit, false, func(page []map[string]*dynamodb.AttributeValue) {
+		items = append(items, page...)
+	})
+	if err != nil {
+		log.Println("Unable to read documents: ", err)
+		return nil, err
+	}
+
+	results := reflect.New(reflect.SliceOf(dataModel))
+	results.Elem().Set(reflect.MakeSlice(results.Elem().Type(), len(items), len(items)))
+	for i, item := range items {
+		if err := decodeJSONDocument(fromDynamoValue(&dynamodb.AttributeValue{M: item}).(map[string]interface{}), results.Elem().Index(i).Addr().Interface()); err != nil {
+			log.Println("Unable to decode document: ", err)
+			return nil, err
+		}
+	}
+
+	page := &Page{Data: results.Interface()}
+	if page.Next, err = encodeDynamoCursor(lastKey); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// Update the items matching filter one by one, update support $set, $unset and $inc. The items deleted between the
+// read of their keys and their update are skipped, they are not created again.
+func (d *DynamoClient) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	if err := checkNamespace(databaseName, collectionName); err != nil {
+		return nil, err
+	}
+	if err := checkNotNil("filter", filter); err != nil {
+		return nil, err
+	}
+	if err := checkNotNil("update", update); err != nil {
+		return nil, err
+	}
+
+	table := d.getTable(databaseName, collectionName)
+	expression := newDynamoExpression()
+	updateExpression, err := expression.update(update)
+	if err != nil {
+		return nil, err
+	}
+	condition := "attribute_exists(" + expression.name(table.HashKey) + ")"
+
+	keys, err := d.getKeys(table, filter)
+	if err != nil {
+		log.Println("Unable to update: ", err)
+		return nil, err
+	}
+
+	result := &mongo.UpdateResult{}
+	for _, key := range keys {
+		if _, err := d.Client.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(table.Name),
+			Key:                       key,
+			UpdateExpression:          aws.String(updateExpression),
+			ConditionExpression:       aws.String(condition),
+			ExpressionAttributeNames:  expression.names,
+			ExpressionAttributeValues: expression.getValues(),
+		}); err != nil {
+			if isDynamoError(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+				continue
+			}
+			log.Println("Unable to update: ", err)
+			return result, err
+		}
+		result.MatchedCount++
+		result.ModifiedCount++
+	}
+
+	return result, nil
+}
+
+// Delete the items matching filter by batches
+func (d *DynamoClient) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	if err := checkNamespace(databaseName, collectionName); err != nil {
+		return nil, err
+	}
+	if err := checkNotNil("filter", filter); err != nil {
+		return nil, err
+	}
+
+	table := d.getTable(databaseName, collectionName)
+	keys, err := d.getKeys(table, filter)
+	if err != nil {
+		log.Println("Unable to delete: ", err)
+		return nil, err
+	}
+
+	result := &mongo.DeleteResult{}
+	for start := 0; start < len(keys); start += dynamoBatchSize {
+		end := start + dynamoBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		requests := make([]*dynamodb.WriteRequest, 0, end-start)
+		for _, key := range keys[start:end] {
+			requests = append(requests, &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: key}})
+		}
+		if err := d.batchWrite(table.Name, requests); err != nil {
+			log.Println("Unable to delete: ", err)
+			return result, err
+		}
+		result.DeletedCount += int64(len(requests))
+	}
+
+	return result, nil
+}
+
+// getTable return the table config of the collection with its name
+func (d *DynamoClient) getTable(databaseName, collectionName string) DynamoDBTable {
+	table, ok := d.Config.Tables[collectionName]
+	if !ok {
+		table = DynamoDBTable{HashKey: "_id"}
+	}
+	if table.Name == "" {
+		table.Name = databaseName + "-" + collectionName
+	}
+
+	return table
+}
+
+// getKeys return the primary keys of the items matching filter
+func (d *DynamoClient) getKeys(table DynamoDBTable, filter interface{}) ([]map[string]*dynamodb.AttributeValue, error) {
+	var keys []map[string]*dynamodb.AttributeValue
+	_, err := d.iterate(table, filter, nil, 0, true, func(page []map[string]*dynamodb.AttributeValue) {
+		keys = append(keys, page...)
+	})
+
+	return keys, err
+}
+
+// iterate run the Query or Scan of filter from startKey and call fn with the items of each page until limit items
+// are read, limit 0 means every item. The returned key is the exclusive start key of the next item, nil when none is left.
+// keysOnly project the items to the primary key of the table.
+func (d *DynamoClient) iterate(table DynamoDBTable, filter interface{}, startKey map[string]*dynamodb.AttributeValue, limit int64, keysOnly bool, fn func([]map[string]*dynamodb.AttributeValue)) (map[string]*dynamodb.AttributeValue, error) {
+	expression := newDynamoExpression()
+	plan, err := expression.plan(table, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var projection *string
+	if keysOnly {
+		keys := expression.name(table.HashKey)
+		if table.RangeKey != "" {
+			keys += ", " + expression.name(table.RangeKey)
+		}
+		projection = aws.String(keys)
+	}
+
+	var read int64
+	for {
+		// The limit of DynamoDB count the evaluated items, so the last evaluated key is the position of the last item read
+		var pageLimit *int64
+		if limit > 0 {
+			pageLimit = aws.Int64(limit - read)
+		}
+
+		var (
+			items   []map[string]*dynamodb.AttributeValue
+			lastKey map[string]*dynamodb.AttributeValue
+		)
+		if plan.query {
+			input := &dynamodb.QueryInput{
+				TableName:                 aws.String(table.Name),
+				KeyConditionExpression:    aws.String(plan.keyCondition),
+				ExpressionAttributeNames:  expression.getNames(),
+				ExpressionAttributeValues: expression.getValues(),
+				ProjectionExpression:      projection,
+				ExclusiveStartKey:         startKey,
+				Limit:                     pageLimit,
+			}
+			if plan.indexName != "" {
+				input.IndexName = aws.String(plan.indexName)
+			}
+			if plan.filter != "" {
+				input.FilterExpression = aws.String(plan.filter)
+			}
+
+			output, err := d.Client.QueryWithContext(ctx, input)
+			if err != nil {
+				return nil, err
+			}
+			items, lastKey = output.Items, output.LastEvaluatedKey
+		} else {
+			input := &dynamodb.ScanInput{
+				TableName:                 aws.String(table.Name),
+				ExpressionAttributeNames:  expression.getNames(),
+				ExpressionAttributeValues: expression.getValues(),
+				ProjectionExpression:      projection,
+				ExclusiveStartKey:         startKey,
+				Limit:                     pageLimit,
+			}
+			if plan.filter != "" {
+				input.FilterExpression = aws.String(plan.filter)
+			}
+
+			output, err := d.Client.ScanWithContext(ctx, input)
+			if err != nil {
+				return nil, err
+			}
+			items, lastKey = output.Items, output.LastEvaluatedKey
+		}
+
+		fn(items)
+		read += int64(len(items))
+		startKey = lastKey
+		if len(lastKey) == 0 || (limit > 0 && read >= limit) {
+			return lastKey, nil
+		}
+	}
+}
+
+// batchWrite run the requests in one BatchWriteItem and retry the unprocessed requests with backoff
+func (d *DynamoClient) batchWrite(tableName string, requests []*dynamodb.WriteRequest) error {
+	backoff := 50 * time.Millisecond
+	for attempt := 0; attempt < dynamoBatchAttempts; attempt++ {
+		output, err := d.Client.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]*dynamodb.WriteRequest{tableName: requests},
+		})
+		if err != nil {
+			return err
+		}
+
+		requests = output.UnprocessedItems[tableName]
+		if len(requests) == 0 {
+			return nil
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	return fmt.Errorf("%d requests of the batch are still unprocessed", len(requests))
+}
+
+// isDynamoError return true when err is the DynamoDB error of code
+func isDynamoError(err error, code string) bool {
+	awsErr, ok := err.(interface{ Code() string })
+	return ok && awsErr.Code() == code
+}
+
+// encodeDynamoCursor return the opaque cursor of the exclusive start key, empty when key is empty
+func encodeDynamoCursor(key map[string]*dynamodb.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	b, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeDynamoCursor return the exclusive start key of the cursor, nil when cursor is empty
+func decodeDynamoCursor(cursor string) (map[string]*dynamodb.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, &InvalidArgumentError{Argument: "lastID", Reason: "is not a valid cursor"}
+	}
+
+	var key map[string]*dynamodb.AttributeValue
+	if err := json.Unmarshal(b, &key); err != nil || len(key) == 0 {
+		return nil, &InvalidArgumentError{Argument: "lastID", Reason: "is not a valid cursor"}
+	}
+
+	return key, nil
+}
+
+// newDynamoExpression init new expression without placeholders
+func newDynamoExpression() *dynamoExpression {
+	return &dynamoExpression{
+		names:       map[string]*string{},
+		values:      map[string]*dynamodb.AttributeValue{},
+		placeholder: map[string]string{},
+	}
+}
+
+// name return the placeholder of the dotted path, array indexes are kept like #n0[2]
+func (de *dynamoExpression) name(path string) string {
+	segments := strings.Split(path, ".")
+	var expression strings.Builder
+	for i, segment := range segments {
+		if _, err := strconv.Atoi(segment); err == nil && i > 0 {
+			expression.WriteString("[" + segment + "]")
+			continue
+		}
+
+		placeholder, ok := de.placeholder[segment]
+		if !ok {
+			placeholder = "#n" + strconv.Itoa(len(de.placeholder))
+			de.placeholder[segment] = placeholder
+			de.names[placeholder] = aws.String(segment)
+		}
+		if i > 0 {
+			expression.WriteString(".")
+		}
+		expression.WriteString(placeholder)
+	}
+
+	return expression.String()
+}
+
+// value return the placeholder of the BSON value
+func (de *dynamoExpression) value(value interface{}) string {
+	placeholder := ":v" + strconv.Itoa(len(de.values))
+	de.values[placeholder] = toDynamoValue(value)
+
+	return placeholder
+}
+
+// getNames return the attribute names, nil when there is none because DynamoDB refuse empty maps
+func (de *dynamoExpression) getNames() map[string]*string {
+	if len(de.names) == 0 {
+		return nil
+	}
+
+	return de.names
+}
+
+// getValues return the attribute values, nil when there is none because DynamoDB refuse empty maps
+func (de *dynamoExpression) getValues() map[string]*dynamodb.AttributeValue {
+	if len(de.values) == 0 {
+		return nil
+	}
+
+	return de.values
+}
+
+// plan return the Query of the first key, of the table then of the indexes, whose hash key is fixed by an equality
+// of filter, with the rest of filter as filter expression, or the Scan of the filter
+func (de *dynamoExpression) plan(table DynamoDBTable, filter interface{}) (*dynamoPlan, error) {
+	document := bson.D{}
+	if filter != nil {
+		value, err := toDocument(filter)
+		if err != nil {
+			return nil, err
+		}
+		document = value
+	}
+
+	plan := &dynamoPlan{}
+	candidates := append([]DynamoDBIndex{{HashKey: table.HashKey, RangeKey: table.RangeKey}}, table.Indexes...)
+	for _, candidate := range candidates {
+		hashIndex := -1
+		for i, element := range document {
+			if element.Key == candidate.HashKey && getEqualityValue(element.Value) != nil {
+				hashIndex = i
+				break
+			}
+		}
+		if hashIndex < 0 {
+			continue
+		}
+		// Query refuse the key attributes in the filter expression
+		if rangeValue, ok := getElement(document, candidate.RangeKey); ok && candidate.RangeKey != "" && !isDynamoKeyRange(rangeValue) {
+			continue
+		}
+
+		plan.query, plan.indexName = true, candidate.Name
+		plan.keyCondition = de.name(candidate.HashKey) + " = " + de.value(getEqualityValue(document[hashIndex].Value))
+		rest := make(bson.D, 0, len(document))
+		for i, element := range document {
+			if i == hashIndex {
+				continue
+			}
+			if element.Key == candidate.RangeKey && candidate.RangeKey != "" {
+				plan.keyCondition += " AND " + de.rangeCondition(element)
+				continue
+			}
+			rest = append(rest, element)
+		}
+		document = rest
+		break
+	}
+
+	condition, err := de.condition(document)
+	if err != nil {
+		return nil, err
+	}
+	plan.filter = condition
+
+	return plan, nil
+}
+
+// rangeCondition return the key condition of the sort key element, its value is checked by isDynamoKeyRange
+func (de *dynamoExpression) rangeCondition(element primitive.E) string {
+	if value := getEqualityValue(element.Value); value != nil {
+		return de.name(element.Key) + " = " + de.value(value)
+	}
+
+	operators := element.Value.(bson.D)
+	if len(operators) == 1 {
+		return de.name(element.Key) + " " + dynamoComparisons[operators[0].Key] + " " + de.value(operators[0].Value)
+	}
+	lower, _ := getElement(operators, "$gte")
+	upper, _ := getElement(operators, "$lte")
+
+	return de.name(element.Key) + " BETWEEN " + de.value(lower) + " AND " + de.value(upper)
+}
+
+// isDynamoKeyRange return true when the filter of the sort key is an equality, a comparison or $gte with $lte
+func isDynamoKeyRange(value interface{}) bool {
+	if getEqualityValue(value) != nil {
+		return true
+	}
+
+	operators, ok := value.(bson.D)
+	if !ok {
+		return false
+	}
+	switch len(operators) {
+	case 1:
+		return dynamoComparisons[operators[0].Key] != ""
+	case 2:
+		_, hasLower := getElement(operators, "$gte")
+		_, hasUpper := getElement(operators, "$lte")
+		return hasLower && hasUpper
+	}
+
+	return false
+}
+
+// condition return the condition expression of the filter document, empty when it has no element
+func (de *dynamoExpression) condition(document bson.D) (string, error) {
+	clauses := make([]string, 0, len(document))
+	for _, element := range document {
+		switch element.Key {
+		case "$and", "$or", "$nor":
+			items, ok := element.Value.(bson.A)
+			if !ok || len(items) == 0 {
+				return "", fmt.Errorf("%w: %s requires a non-empty array", ErrUnsupportedFilter, element.Key)
+			}
+			conditions := make([]string, 0, len(items))
+			for _, item := range items {
+				itemDocument, ok := item.(bson.D)
+				if !ok || len(itemDocument) == 0 {
+					return "", fmt.Errorf("%w: %s requires non-empty documents", ErrUnsupportedFilter, element.Key)
+				}
+				condition, err := de.condition(itemDocument)
+				if err != nil {
+					return "", err
+				}
+				conditions = append(conditions, "("+condition+")")
+			}
+			switch element.Key {
+			case "$and":
+				clauses = append(clauses, strings.Join(conditions, " AND "))
+			case "$or":
+				clauses = append(clauses, "("+strings.Join(conditions, " OR ")+")")
+			case "$nor":
+				clauses = append(clauses, "NOT ("+strings.Join(conditions, " OR ")+")")
+			}
+			continue
+		}
+		if strings.HasPrefix(element.Key, "$") {
+			return "", fmt.Errorf("%w: %s", ErrUnsupportedFilter, element.Key)
+		}
+
+		operators, ok := element.Value.(bson.D)
+		if !ok || len(operators) == 0 || !strings.HasPrefix(operators[0].Key, "$") {
+			operators = bson.D{primitive.E{Key: "$eq", Value: element.Value}}
+		}
+
+		name := de.name(element.Key)
+		for _, operator := range operators {
+			switch operator.Key {
+			case "$eq":
+				if isBSONNull(operator.Value) {
+					clauses = append(clauses, "(attribute_not_exists("+name+") OR attribute_type("+name+", "+de.value("NULL")+"))")
+				} else {
+					clauses = append(clauses, name+" = "+de.value(operator.Value))
+				}
+			case "$ne":
+				if isBSONNull(operator.Value) {
+					clauses = append(clauses, "(attribute_exists("+name+") AND NOT attribute_type("+name+", "+de.value("NULL")+"))")
+				} else {
+					clauses = append(clauses, "(attribute_not_exists("+name+") OR "+name+" <> "+de.value(operator.Value)+")")
+				}
+			case "$in", "$nin":
+				items, ok := operator.Value.(bson.A)
+				if !ok || len(items) == 0 {
+					return "", fmt.Errorf("%w: %s requires a non-empty array", ErrUnsupportedFilter, operator.Key)
+				}
+				values := make([]string, 0, len(items))
+				for _, item := range items {
+					values = append(values, de.value(item))
+				}
+				condition := name + " IN (" + strings.Join(values, ", ") + ")"
+				if operator.Key == "$nin" {
+					condition = "NOT (" + condition + ")"
+				}
+				clauses = append(clauses, condition)
+			case "$gt", "$gte", "$lt", "$lte":
+				clauses = append(clauses, name+" "+dynamoComparisons[operator.Key]+" "+de.value(operator.Value))
+			case "$exists":
+				if exists, _ := operator.Value.(bool); exists {
+					clauses = append(clauses, "attribute_exists("+name+")")
+				} else {
+					clauses = append(clauses, "attribute_not_exists("+name+")")
+				}
+			default:
+				return "", fmt.Errorf("%w: %s", ErrUnsupportedFilter, operator.Key)
+			}
+		}
+	}
+
+	return strings.Join(clauses, " AND "), nil
+}
+
+// update return the update expression of the $set, $unset and $inc fields of update
+func (de *dynamoExpression) update(update interface{}) (string, error) {
+	document, err := toDocument(update)
+	if err != nil {
+		return "", err
+	}
+
+	var set, remove []string
+	for _, element := range document {
+		fields, ok := element.Value.(bson.D)
+		if !ok {
+			return "", fmt.Errorf("%w: update %s requires a document", ErrUnsupportedFilter, element.Key)
+		}
+
+		for _, field := range fields {
+			name := de.name(field.Key)
+			switch element.Key {
+			case "$set":
+				set = append(set, name+" = "+de.value(field.Value))
+			case "$inc":
+				set = append(set, name+" = if_not_exists("+name+", "+de.value(int64(0))+") + "+de.value(field.Value))
+			case "$unset":
+				remove = append(remove, name)
+			default:
+				return "", fmt.Errorf("%w: update %s", ErrUnsupportedFilter, element.Key)
+			}
+		}
+	}
+	if len(set) == 0 && len(remove) == 0 {
+		return "", &InvalidArgumentError{Argument: "update", Reason: "cannot be empty"}
+	}
+
+	var expression []string
+	if len(set) > 0 {
+		expression = append(expression, "SET "+strings.Join(set, ", "))
+	}
+	if len(remove) > 0 {
+		expression = append(expression, "REMOVE "+strings.Join(remove, ", "))
+	}
+
+	return strings.Join(expression, " "), nil
+}
+
+// getEqualityValue return the value of an equality filter, directly or with $eq, nil for the other filters and null
+func getEqualityValue(value interface{}) interface{} {
+	if operators, ok := value.(bson.D); ok && len(operators) > 0 && strings.HasPrefix(operators[0].Key, "$") {
+		if len(operators) != 1 || operators[0].Key != "$eq" {
+			return nil
+		}
+		value = operators[0].Value
+	}
+	if isBSONNull(value) {
+		return nil
+	}
+
+	return value
+}
+
+// isBSONNull return true when the BSON value is null or undefined
+func isBSONNull(value interface{}) bool {
+	switch value.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return true
+	}
+
+	return false
+}
+
+// toDynamoValue return the BSON value as an attribute value, ObjectID are hex strings, dates RFC 3339 strings which
+// sort in time order, and decimals numbers
+func toDynamoValue(value interface{}) *dynamodb.AttributeValue {
+	switch v := value.(type) {
+	case bson.D:
+		document := make(map[string]*dynamodb.AttributeValue, len(v))
+		for _, element := range v {
+			document[element.Key] = toDynamoValue(element.Value)
+		}
+		return &dynamodb.AttributeValue{M: document}
+	case bson.A:
+		list := make([]*dynamodb.AttributeValue, 0, len(v))
+		for _, item := range v {
+			list = append(list, toDynamoValue(item))
+		}
+		return &dynamodb.AttributeValue{L: list}
+	case string:
+		return &dynamodb.AttributeValue{S: aws.String(v)}
+	case bool:
+		return &dynamodb.AttributeValue{BOOL: aws.Bool(v)}
+	case int32:
+		return &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(int64(v), 10))}
+	case int64:
+		return &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(v, 10))}
+	case int:
+		return &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(v))}
+	case float64:
+		return &dynamodb.AttributeValue{N: aws.String(strconv.FormatFloat(v, 'f', -1, 64))}
+	case primitive.Decimal128:
+		return &dynamodb.AttributeValue{N: aws.String(v.String())}
+	case primitive.ObjectID:
+		return &dynamodb.AttributeValue{S: aws.String(v.Hex())}
+	case primitive.DateTime:
+		return &dynamodb.AttributeValue{S: aws.String(v.Time().UTC().Format(time.RFC3339Nano))}
+	case primitive.Timestamp:
+		return &dynamodb.AttributeValue{S: aws.String(time.Unix(int64(v.T), 0).UTC().Format(time.RFC3339Nano))}
+	case primitive.Binary:
+		return &dynamodb.AttributeValue{B: v.Data}
+	case primitive.Regex:
+		return &dynamodb.AttributeValue{S: aws.String(v.Pattern)}
+	case nil, primitive.Null, primitive.Undefined:
+		return &dynamodb.AttributeValue{NULL: aws.Bool(true)}
+	}
+
+	return &dynamodb.AttributeValue{S: aws.String(fmt.Sprint(value))}
+}
+
+// fromDynamoValue return the attribute value as a JSON value, numbers are json.Number and binaries []byte
+func fromDynamoValue(value *dynamodb.AttributeValue) interface{} {
+	switch {
+	case value == nil || value.NULL != nil:
+		return nil
+	case value.S != nil:
+		return *value.S
+	case value.N != nil:
+		return json.Number(*value.N)
+	case value.BOOL != nil:
+		return *value.BOOL
+	case value.B != nil:
+		return value.B
+	case value.M != nil:
+		document := make(map[string]interface{}, len(value.M))
+		for key, item := range value.M {
+			document[key] = fromDynamoValue(item)
+		}
+		return document
+	case value.L != nil:
+		list := make([]interface{}, 0, len(value.L))
+		for _, item := range value.L {
+			list = append(list, fromDynamoValue(item))
+		}
+		return list
+	case value.SS != nil:
+		list := make([]interface{}, 0, len(value.SS))
+		for _, item := range value.SS {
+			list = append(list, *item)
+		}
+		return list
+	case value.NS != nil:
+		list := make([]interface{}, 0, len(value.NS))
+		for _, item := range value.NS {
+			list = append(list, json.Number(*item))
+		}
+		return list
+	case value.BS != nil:
+		list := make([]interface{}, 0, len(value.BS))
+		for _, item := range value.BS {
+			list = append(list, item)
+		}
+		return list
+	}
+
+	return nil
+}
+
+// Capabilities implement ICapabilities
+func (d *DynamoClient) Capabilities() Capabilities {
+	return Capabilities{MaxDocumentSize: 400 * 1024}
+}
@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"errors"
+	"log"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ElasticMirror decorate INoSQLDocument with the dual-write of the written documents into Elasticsearch, the documents
+// created or updated are read back from the database and indexed by _id, the deleted documents are removed from the index.
+// The database stays the source of truth, a failed mirror is logged and counted in Stats but does not fail the write,
+// reads go to the database and searches to Elasticsearch.
+type ElasticMirror struct {
+	db        INoSQLDocument
+	elastic   *ElasticClient
+	mirrored  uint64
+	failed    uint64
+	mu        sync.Mutex
+	lastError string
+}
+
+// NewElasticMirror init new mirror of the writes of db into elastic
+func NewElasticMirror(db INoSQLDocument, elastic *ElasticClient) *ElasticMirror {
+	return &ElasticMirror{db: db, elastic: elastic}
+}
+
+// Create the documents in the database and index them
+func (em *ElasticMirror) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	result, err := em.db.Create(databaseName, collectionName, documents)
+	if err != nil {
+		return nil, err
+	}
+
+	if insertResult, ok := result.(*mongo.InsertManyResult); ok {
+		em.reindex(databaseName, collectionName, insertResult.InsertedIDs)
+	} else {
+		em.fail(errors.New("Unable to map result to InsertManyResult model"))
+	}
+
+	return result, nil
+}
+
+// Read documents from the database
+func (em *ElasticMirror) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	return em.db.Read(databaseName, collectionName, filter, limit, dataModel)
+}
+
+// Update the documents in the database and reindex the documents matching filter before the update
+func (em *ElasticMirror) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	ids, err := em.getIDs(databaseName, collectionName, filter)
+	if err != nil {
+		em.fail(err)
+	}
+
+	result, err := em.db.Update(databaseName, collectionName, filter, update)
+	if err != nil {
+		return nil, err
+	}
+	em.reindex(databaseName, collectionName, ids)
+
+	return result, nil
+}
+
+// Delete the documents from the database and from the index
+func (em *ElasticMirror) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	ids, err := em.getIDs(databaseName, collectionName, filter)
+	if err != nil {
+		em.fail(err)
+	}
+
+	result, err := em.db.Delete(databaseName, collectionName, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) > 0 {
+		actions := make([]elasticBulkAction, 0, len(ids))
+		for _, id := range ids {
+			actions = append(actions, elasticBulkAction{index: getElasticIndex(databaseName, collectionName), id: getIDString(id)})
+		}
+		em.record(em.elastic.bulk(actions))
+	}
+
+	return result, nil
+}
+
+// Aggregate run the pipeline on the database when it implements IAggregate
+func (em *ElasticMirror) Aggregate(databaseName, collectionName string, pipeline interface{}, dataModel reflect.Type) (interface{}, error) {
+	aggregator, ok := em.db.(IAggregate)
+	if !ok {
+		return nil, errors.New("Database does not support aggregation")
+	}
+
+	return aggregator.Aggregate(databaseName, collectionName, pipeline, dataModel)
+}
+
+// Search the index of collectionName in Elasticsearch, see ElasticClient.Search
+func (em *ElasticMirror) Search(databaseName, collectionName string, query interface{}, searchOptions *SearchOptions, dataModel reflect.Type) (*SearchResult, error) {
+	return em.elastic.Search(databaseName, collectionName, query, searchOptions, dataModel)
+}
+
+// Reindex read the documents matching filter from the database and index them, to backfill the index of an existing
+// collection or repair it after failed mirrors
+func (em *ElasticMirror) Reindex(databaseName, collectionName string, filter interface{}) error {
+	documents, err := em.readDocuments(databaseName, collectionName, filter)
+	if err != nil {
+		return err
+	}
+
+	return em.index(databaseName, collectionName, documents)
+}
+
+// Stats return the number of documents mirrored, of failed mirrors and the last mirror error
+func (em *ElasticMirror) Stats() ElasticMirrorStats {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	return ElasticMirrorStats{
+		Mirrored:  atomic.LoadUint64(&em.mirrored),
+		Failed:    atomic.LoadUint64(&em.failed),
+		LastError: em.lastError,
+	}
+}
+
+// getIDs return the IDs of the documents matching filter in the database
+func (em *ElasticMirror) getIDs(databaseName, collectionName string, filter interface{}) ([]interface{}, error) {
+	if id, ok := getFilterID(filter); ok {
+		return []interface{}{id}, nil
+	}
+
+	documents, err := em.readDocuments(databaseName, collectionName, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		ids = append(ids, document["_id"])
+	}
+
+	return ids, nil
+}
+
+// reindex read the documents of ids from the database and index them, the documents not found are left unchanged
+func (em *ElasticMirror) reindex(databaseName, collectionName string, ids []interface{}) {
+	if len(ids) == 0 {
+		return
+	}
+
+	documents, err := em.readDocuments(databaseName, collectionName, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		em.fail(err)
+		return
+	}
+
+	// The failure is recorded by index
+	_ = em.index(databaseName, collectionName, documents)
+}
+
+// index the documents in one bulk request and record the outcome
+func (em *ElasticMirror) index(databaseName, collectionName string, documents []bson.M) error {
+	if len(documents) == 0 {
+		return nil
+	}
+
+	indexName := getElasticIndex(databaseName, collectionName)
+	actions := make([]elasticBulkAction, 0, len(documents))
+	for _, document := range documents {
+		actions = append(actions, elasticBulkAction{index: indexName, id: getIDString(document["_id"]), document: document})
+	}
+
+	ids, err := em.elastic.bulk(actions)
+	em.record(ids, err)
+
+	return err
+}
+
+// readDocuments return the documents matching filter from the database
+func (em *ElasticMirror) readDocuments(databaseName, collectionName string, filter interface{}) ([]bson.M, error) {
+	results, err := em.db.Read(databaseName, collectionName, filter, 0, reflect.TypeOf(bson.M{}))
+	if err != nil {
+		return nil, err
+	}
+
+	documents, ok := results.(*[]bson.M)
+	if !ok {
+		return nil, errors.New("Unable to map results to bson.M model")
+	}
+
+	return *documents, nil
+}
+
+// record count the documents mirrored by a bulk request and its failure
+func (em *ElasticMirror) record(ids []string, err error) {
+	atomic.AddUint64(&em.mirrored, uint64(len(ids)))
+	if err != nil {
+		em.fail(err)
+	}
+}
+
+// fail count and log a failed mirror
+func (em *ElasticMirror) fail(err error) {
+	log.Println("Unable to mirror to Elasticsearch: ", err)
+	atomic.AddUint64(&em.failed, 1)
+
+	em.mu.Lock()
+	em.lastError = err.Error()
+	em.mu.Unlock()
+}
+
+// Capabilities implement ICapabilities, the capabilities of the database with the search of Elasticsearch and
+// without transactions since the mirrored writes are not part of them
+func (em *ElasticMirror) Capabilities() Capabilities {
+	capabilities := getDecoratedCapabilities(em.db)
+	capabilities.TextSearch = true
+	capabilities.Transactions = false
+
+	return capabilities
+}
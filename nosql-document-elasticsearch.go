@@ -0,0 +1,817 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/golang-common-packages/hash"
+)
+
+const (
+	// maxElasticResultWindow is the default maximum of from + size of Elasticsearch searches, it is the size of Read without limit
+	maxElasticResultWindow = 10000
+	// elasticUpdateScript apply the $set, $unset and $inc fields of params to the source, dotted paths create the missing objects
+	elasticUpdateScript = `
+for (entry in params.set.entrySet()) {
+  def path = entry.getKey().splitOnToken('.'); def target = ctx._source;
+  for (int i = 0; i < path.length - 1; i++) { if (!(target[path[i]] instanceof Map)) { target[path[i]] = new HashMap(); } target = target[path[i]]; }
+  target[path[path.length - 1]] = entry.getValue();
+}
+for (entry in params.inc.entrySet()) {
+  def path = entry.getKey().splitOnToken('.'); def target = ctx._source;
+  for (int i = 0; i < path.length - 1; i++) { if (!(target[path[i]] instanceof Map)) { target[path[i]] = new HashMap(); } target = target[path[i]]; }
+  def last = path[path.length - 1]; target[last] = (target[last] == null ? 0 : target[last]) + entry.getValue();
+}
+for (field in params.unset) {
+  def path = field.splitOnToken('.'); def target = ctx._source;
+  for (int i = 0; i < path.length - 1 && target != null; i++) { target = target[path[i]] instanceof Map ? target[path[i]] : null; }
+  if (target != null) { target.remove(path[path.length - 1]); }
+}`
+)
+
+var (
+	// ErrUnsupportedFilter is returned when a filter or update operator can not be translated to the query language of the database
+	ErrUnsupportedFilter = errors.New("Operator is not supported by the database")
+	// elasticsearchClientSessionMapping singleton pattern
+	elasticsearchClientSessionMapping = make(map[string]*ElasticClient)
+)
+
+// ElasticQuery is an Elasticsearch query DSL used as is as filter of Read, Update and Delete, like
+// ElasticQuery{"match": bson.M{"title": "full text"}}, other filters are translated from MongoDB operators
+type ElasticQuery map[string]interface{}
+
+// SearchOptions model for Search pagination and sort
+type SearchOptions struct {
+	From        int           // offset of the first hit, for shallow pagination
+	Size        int           // number of hits, default 10
+	Sort        []interface{} // like []interface{}{bson.M{"createdAt": "desc"}, "_score"}
+	SearchAfter []interface{} // NextSearchAfter of the previous page, for deep pagination with Sort
+}
+
+// ElasticError is returned when Elasticsearch answer with an error status
+type ElasticError struct {
+	Status int
+	Type   string // like index_not_found_exception
+	Reason string
+}
+
+// Error return the message of the Elasticsearch error
+func (e *ElasticError) Error() string {
+	return fmt.Sprintf("Elasticsearch error %d %s: %s", e.Status, e.Type, e.Reason)
+}
+
+// ElasticClient manage all Elasticsearch actions through its REST API. Documents of databaseName and
+// collectionName are kept in the index databaseName-collectionName in lower case, _id is the document ID.
+type ElasticClient struct {
+	Client *http.Client
+	Config *Elasticsearch
+	mu     sync.Mutex
+	next   int // address tried first by the next request
+}
+
+// elasticHit private model for a hit of a search or get response
+type elasticHit struct {
+	ID     string                 `json:"_id"`
+	Score  *float64               `json:"_score"`
+	Source map[string]interface{} `json:"_source"`
+	Sort   []interface{}          `json:"sort"`
+	Found  bool                   `json:"found"`
+}
+
+// elasticBulkAction private model for an action of a bulk request, document is nil for deletes
+type elasticBulkAction struct {
+	index    string
+	id       string
+	document interface{}
+}
+
+// newElasticsearch init new instance
+func newElasticsearch(config *Elasticsearch) INoSQLDocument {
+	hasher := &hash.Client{}
+	configAsJSON, err := json.Marshal(config)
+	if err != nil {
+		log.Fatalln("Unable to marshal Elasticsearch configuration: ", err)
+	}
+	configAsString := hasher.SHA1(string(configAsJSON))
+
+	currentElasticSession := elasticsearchClientSessionMapping[configAsString]
+	if currentElasticSession == nil {
+		currentElasticSession = NewElasticClient(config)
+
+		// Check the connection status
+		if err := currentElasticSession.do(http.MethodGet, "/", nil, nil); err != nil {
+			log.Fatalln("Unable to connect to Elasticsearch: ", err)
+		}
+		elasticsearchClientSessionMapping[configAsString] = currentElasticSession
+		log.Println("Connected to Elasticsearch")
+	}
+
+	return currentElasticSession
+}
+
+// NewElasticClient init new client of config without checking the connection, New check it
+func NewElasticClient(config *Elasticsearch) *ElasticClient {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &ElasticClient{Client: &http.Client{Timeout: timeout}, Config: config}
+}
+
+// Index create or replace the document of id in the index of collectionName, an ID is generated when id is empty
+func (e *ElasticClient) Index(databaseName, collectionName, id string, document interface{}) error {
+	if err := checkNamespace(databaseName, collectionName); err != nil {
+		return err
+	}
+
+	indexName := getElasticIndex(databaseName, collectionName)
+	source, err := toJSONSource(document)
+	if err != nil {
+		return err
+	}
+
+	if id == "" {
+		return e.do(http.MethodPost, "/"+url.PathEscape(indexName)+"/_doc"+e.getRefresh(), source, nil)
+	}
+
+	return e.do(http.MethodPut, "/"+url.PathEscape(indexName)+"/_doc/"+url.PathEscape(id)+e.getRefresh(), source, nil)
+}
+
+// Get return the document of id in the index of collectionName as a pointer to a new dataModel, ErrDocumentNotFound when it does not exist
+func (e *ElasticClient) Get(databaseName, collectionName, id string, dataModel reflect.Type) (interface{}, error) {
+	if err := checkNamespace(databaseName, collectionName); err != nil {
+		return nil, err
+	}
+	if err := checkDataModel(dataModel); err != nil {
+		return nil, err
+	}
+
+	indexName := getElasticIndex(databaseName, collectionName)
+
+	var hit elasticHit
+	if err := e.do(http.MethodGet, "/"+url.PathEscape(indexName)+"/_doc/"+url.PathEscape(id), nil, &hit); err != nil {
+		var elasticErr *ElasticError
+		if errors.As(err, &elasticErr) && elasticErr.Status == http.StatusNotFound {
+			return nil, ErrDocumentNotFound
+		}
+		return nil, err
+	}
+	if !hit.Found {
+		return nil, ErrDocumentNotFound
+	}
+
+	document := reflect.New(dataModel)
+	if err := decodeElasticHit(hit, document.Interface()); err != nil {
+		return nil, err
+	}
+
+	return document.Interface(), nil
+}
+
+// Search return the page of hits of query in the index of collectionName, decoded to a slice of dataModel.
+// query is an ElasticQuery, a MongoDB filter translated like Read, or nil to match every document.
+func (e *ElasticClient) Search(databaseName, collectionName string, query interface{}, searchOptions *SearchOptions, dataModel reflect.Type) (*SearchResult, error) {
+	if err := checkNamespace(databaseName, collectionName); err != nil {
+		return nil, err
+	}
+	if err := checkDataModel(dataModel); err != nil {
+		return nil, err
+	}
+	if searchOptions == nil {
+		searchOptions = &SearchOptions{}
+	}
+	if searchOptions.From < 0 || searchOptions.Size < 0 {
+		return nil, &InvalidArgumentError{Argument: "searchOptions", Reason: "from and size cannot be negative"}
+	}
+	if len(searchOptions.SearchAfter) > 0 && len(searchOptions.Sort) == 0 {
+		return nil, &InvalidArgumentError{Argument: "searchOptions", Reason: "searchAfter requires sort"}
+	}
+
+	elasticQuery, err := getElasticQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{"query": elasticQuery, "track_total_hits": true}
+	if searchOptions.From > 0 {
+		body["from"] = searchOptions.From
+	}
+	if searchOptions.Size > 0 {
+		body["size"] = searchOptions.Size
+	}
+	if len(searchOptions.Sort) > 0 {
+		body["sort"] = searchOptions.Sort
+	}
+	if len(searchOptions.SearchAfter) > 0 {
+		body["search_after"] = searchOptions.SearchAfter
+	}
+
+	var response struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []elasticHit `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := e.do(http.MethodPost, "/"+url.PathEscape(getElasticIndex(databaseName, collectionName))+"/_search", body, &response); err != nil {
+		log.Println("Unable to search: ", err)
+		return nil, err
+	}
+
+	hits := reflect.New(reflect.SliceOf(dataModel))
+	hits.Elem().Set(reflect.MakeSlice(hits.Elem().Type(), len(response.Hits.Hits), len(response.Hits.Hits)))
+	result := &SearchResult{Total: response.Hits.Total.Value, Hits: hits.Interface(), Scores: make([]float64, len(response.Hits.Hits))}
+	for i, hit := range response.Hits.Hits {
+		if err := decodeElasticHit(hit, hits.Elem().Index(i).Addr().Interface()); err != nil {
+			log.Println("Unable to decode hit: ", err)
+			return nil, err
+		}
+		if hit.Score != nil {
+			result.Scores[i] = *hit.Score
+		}
+		result.NextSearchAfter = hit.Sort
+	}
+
+	return result, nil
+}
+
+// Create index the documents in the index of collectionName in one bulk request and return their IDs,
+// the _id of the documents is used as ID and generated when it is missing
+func (e *ElasticClient) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	if err := checkNamespace(databaseName, collectionName); err != nil {
+		return nil, err
+	}
+	if len(documents) == 0 {
+		return nil, &InvalidArgumentError{Argument: "documents", Reason: "cannot be empty"}
+	}
+
+	indexName := getElasticIndex(databaseName, collectionName)
+	actions := make([]elasticBulkAction, 0, len(documents))
+	for _, document := range documents {
+		action := elasticBulkAction{index: indexName, document: document}
+		if id, err := GetDocumentID(document); err == nil {
+			action.id = getIDString(id)
+		}
+		actions = append(actions, action)
+	}
+
+	ids, err := e.bulk(actions)
+	if err != nil {
+		log.Println("Unable to create document: ", err)
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// Read documents from the index of collectionName based on filter, limit 0 means the result window of the index
+func (e *ElasticClient) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	if err := checkLimit(limit); err != nil {
+		return nil, err
+	}
+	if limit == 0 || limit > maxElasticResultWindow {
+		limit = maxElasticResultWindow
+	}
+
+	result, err := e.Search(databaseName, collectionName, filter, &SearchOptions{Size: int(limit)}, dataModel)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Hits, nil
+}
+
+// Update the documents matching filter by query, update support $set, $unset and $inc
+func (e *ElasticClient) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	if err := checkNamespace(databaseName, collectionName); err != nil {
+		return nil, err
+	}
+	if err := checkNotNil("filter", filter); err != nil {
+		return nil, err
+	}
+	if err := checkNotNil("update", update); err != nil {
+		return nil, err
+	}
+
+	elasticQuery, err := getElasticQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+	params, err := getElasticUpdateParams(update)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ElasticByQueryResult
+	if err := e.do(http.MethodPost, "/"+url.PathEscape(getElasticIndex(databaseName, collectionName))+"/_update_by_query?conflicts=proceed"+strings.Replace(e.getRefresh(), "?", "&", 1), map[string]interface{}{
+		"query":  elasticQuery,
+		"script": map[string]interface{}{"source": elasticUpdateScript, "lang": "painless", "params": params},
+	}, &result); err != nil {
+		log.Println("Unable to update: ", err)
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Delete the documents matching filter by query
+func (e *ElasticClient) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	if err := checkNamespace(databaseName, collectionName); err != nil {
+		return nil, err
+	}
+	if err := checkNotNil("filter", filter); err != nil {
+		return nil, err
+	}
+
+	elasticQuery, err := getElasticQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ElasticByQueryResult
+	if err := e.do(http.MethodPost, "/"+url.PathEscape(getElasticIndex(databaseName, collectionName))+"/_delete_by_query?conflicts=proceed"+strings.Replace(e.getRefresh(), "?", "&", 1), map[string]interface{}{
+		"query": elasticQuery,
+	}, &result); err != nil {
+		log.Println("Unable to delete: ", err)
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// bulk run the actions in one bulk request and return the IDs of the documents, the first failed item is returned as error
+func (e *ElasticClient) bulk(actions []elasticBulkAction) ([]string, error) {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, action := range actions {
+		metadata := map[string]interface{}{"_index": action.index}
+		if action.id != "" {
+			metadata["_id"] = action.id
+		}
+
+		if action.document == nil {
+			if err := encoder.Encode(map[string]interface{}{"delete": metadata}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		source, err := toJSONSource(action.document)
+		if err != nil {
+			return nil, err
+		}
+		if err := encoder.Encode(map[string]interface{}{"index": metadata}); err != nil {
+			return nil, err
+		}
+		if err := encoder.Encode(source); err != nil {
+			return nil, err
+		}
+	}
+
+	var response struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string `json:"_id"`
+			Status int    `json:"status"`
+			Error  *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := e.do(http.MethodPost, "/_bulk"+e.getRefresh(), body.Bytes(), &response); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(response.Items))
+	for _, item := range response.Items {
+		for _, result := range item {
+			// Deleting a missing document is not a failure of the bulk
+			if result.Error != nil {
+				return ids, &ElasticError{Status: result.Status, Type: result.Error.Type, Reason: result.Error.Reason}
+			}
+			ids = append(ids, result.ID)
+		}
+	}
+
+	return ids, nil
+}
+
+// do send the request to the addresses in turn until one answers and decode the JSON response into result,
+// body is encoded to JSON unless it is []byte, sent as NDJSON
+func (e *ElasticClient) do(method, path string, body interface{}, result interface{}) error {
+	var payload []byte
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		payload, contentType = b, "application/x-ndjson"
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		payload = encoded
+	}
+
+	e.mu.Lock()
+	first := e.next
+	e.mu.Unlock()
+
+	var lastErr error
+	for i := range e.Config.Addresses {
+		address := e.Config.Addresses[(first+i)%len(e.Config.Addresses)]
+		response, err := e.send(method, strings.TrimRight(address, "/")+path, contentType, payload)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		e.mu.Lock()
+		e.next = (first + i) % len(e.Config.Addresses)
+		e.mu.Unlock()
+
+		return decodeElasticResponse(response, result)
+	}
+	if lastErr == nil {
+		lastErr = errors.New("No Elasticsearch address")
+	}
+
+	return lastErr
+}
+
+// send the request with the credentials of the config
+func (e *ElasticClient) send(method, address, contentType string, payload []byte) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, address, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		request.Header.Set("Content-Type", contentType)
+	}
+	switch {
+	case e.Config.APIKey != "":
+		request.Header.Set("Authorization", "ApiKey "+e.Config.APIKey)
+	case e.Config.Username != "":
+		request.SetBasicAuth(e.Config.Username, e.Config.Password)
+	}
+
+	return e.Client.Do(request)
+}
+
+// getRefresh return the refresh query parameter of the writes
+func (e *ElasticClient) getRefresh() string {
+	if e.Config.Refresh == "" {
+		return ""
+	}
+
+	return "?refresh=" + url.QueryEscape(e.Config.Refresh)
+}
+
+// decodeElasticResponse close the response and decode it into result, or return the ElasticError of the status
+func decodeElasticResponse(response *http.Response, result interface{}) error {
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusMultipleChoices {
+		var failure struct {
+			Error struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		}
+		data, _ := io.ReadAll(response.Body)
+		if err := json.Unmarshal(data, &failure); err != nil || failure.Error.Type == "" {
+			failure.Error.Reason = strings.TrimSpace(string(data))
+		}
+		return &ElasticError{Status: response.StatusCode, Type: failure.Error.Type, Reason: failure.Error.Reason}
+	}
+
+	if result == nil {
+		_, err := io.Copy(io.Discard, response.Body)
+		return err
+	}
+
+	decoder := json.NewDecoder(response.Body)
+	decoder.UseNumber()
+
+	return decoder.Decode(result)
+}
+
+// getElasticIndex return the index of the collection
+func getElasticIndex(databaseName, collectionName string) string {
+	return strings.ToLower(databaseName + "-" + collectionName)
+}
+
+// toJSONSource return the document as JSON values without _id, ObjectID are hex strings and dates RFC 3339 strings
+func toJSONSource(document interface{}) (map[string]interface{}, error) {
+	value, err := toDocument(document)
+	if err != nil {
+		return nil, err
+	}
+
+	source := make(map[string]interface{}, len(value))
+	for _, element := range value {
+		if element.Key != "_id" {
+			source[element.Key] = toJSONValue(element.Value)
+		}
+	}
+
+	return source, nil
+}
+
+// toJSONValue return the BSON value as a JSON value
+func toJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		document := make(map[string]interface{}, len(v))
+		for _, element := range v {
+			document[element.Key] = toJSONValue(element.Value)
+		}
+		return document
+	case bson.A:
+		list := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			list = append(list, toJSONValue(item))
+		}
+		return list
+	case primitive.ObjectID:
+		return v.Hex()
+	case primitive.DateTime:
+		return v.Time().UTC().Format(time.RFC3339Nano)
+	case primitive.Timestamp:
+		return time.Unix(int64(v.T), 0).UTC().Format(time.RFC3339Nano)
+	case primitive.Decimal128:
+		return v.String()
+	case primitive.Binary:
+		return v.Data
+	case primitive.Regex:
+		return v.Pattern
+	case primitive.Null, primitive.Undefined:
+		return nil
+	}
+
+	return value
+}
+
+// decodeElasticHit decode the source and the ID of the hit into document, a pointer to the data model
+func decodeElasticHit(hit elasticHit, document interface{}) error {
+	source := make(map[string]interface{}, len(hit.Source)+1)
+	for key, value := range hit.Source {
+		source[key] = value
+	}
+	source["_id"] = hit.ID
+
+	return decodeJSONDocument(source, document)
+}
+
+// decodeJSONDocument decode the JSON values of source into document, a pointer to the data model. JSON strings are
+// converted to dates, ObjectID, decimals and binaries for the fields of that type in the model, and a hex _id to
+// ObjectID for the models without _id field, like the documents mirrored from MongoDB read as bson.M.
+func decodeJSONDocument(source map[string]interface{}, document interface{}) error {
+	fields := map[string]schemaField{}
+	modelType := reflect.TypeOf(document).Elem()
+	for modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	if modelType.Kind() == reflect.Struct {
+		getSchemaFields(modelType, "", fields)
+	}
+
+	value := fromJSONValue(source, "", fields).(bson.D)
+	if _, ok := fields["_id"]; !ok {
+		for i, element := range value {
+			if id, ok := element.Value.(string); ok && element.Key == "_id" {
+				if objectID, err := primitive.ObjectIDFromHex(id); err == nil {
+					value[i].Value = objectID
+				}
+			}
+		}
+	}
+
+	raw, err := bson.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return bson.Unmarshal(raw, document)
+}
+
+// fromJSONValue return the JSON value at path as a BSON value for the fields of the model
+func fromJSONValue(value interface{}, path string, fields map[string]schemaField) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		document := make(bson.D, 0, len(v))
+		for key, item := range v {
+			itemPath := key
+			if path != "" {
+				itemPath = path + "." + key
+			}
+			document = append(document, primitive.E{Key: key, Value: fromJSONValue(item, itemPath, fields)})
+		}
+		return document
+	case []interface{}:
+		list := make(bson.A, 0, len(v))
+		for _, item := range v {
+			list = append(list, fromJSONValue(item, path, fields))
+		}
+		return list
+	case json.Number:
+		if integer, err := v.Int64(); err == nil {
+			return integer
+		}
+		number, _ := v.Float64()
+		return number
+	case string:
+		field, ok := fields[path]
+		if !ok || len(field.types) == 0 {
+			return v
+		}
+		switch field.types[0] {
+		case "date":
+			if date, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return date
+			}
+		case "objectId":
+			if objectID, err := primitive.ObjectIDFromHex(v); err == nil {
+				return objectID
+			}
+		case "decimal":
+			if decimal, err := primitive.ParseDecimal128(v); err == nil {
+				return decimal
+			}
+		case "binData":
+			if data, err := base64.StdEncoding.DecodeString(v); err == nil {
+				return data
+			}
+		}
+		return v
+	}
+
+	return value
+}
+
+// getElasticQuery return the Elasticsearch query of filter, ElasticQuery as is and MongoDB filters translated
+// to bool queries of term, terms, range and exists clauses
+func getElasticQuery(filter interface{}) (interface{}, error) {
+	if query, ok := filter.(ElasticQuery); ok {
+		return map[string]interface{}(query), nil
+	}
+	if filter == nil {
+		return map[string]interface{}{"match_all": map[string]interface{}{}}, nil
+	}
+
+	document, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(document) == 0 {
+		return map[string]interface{}{"match_all": map[string]interface{}{}}, nil
+	}
+
+	return getElasticBool(document)
+}
+
+// getElasticBool return the bool query of the filter document
+func getElasticBool(document bson.D) (map[string]interface{}, error) {
+	filters := []interface{}{}
+	mustNot := []interface{}{}
+
+	for _, element := range document {
+		switch element.Key {
+		case "$and", "$or", "$nor":
+			items, ok := element.Value.(bson.A)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s requires an array", ErrUnsupportedFilter, element.Key)
+			}
+			queries := make([]interface{}, 0, len(items))
+			for _, item := range items {
+				itemDocument, ok := item.(bson.D)
+				if !ok {
+					return nil, fmt.Errorf("%w: %s requires documents", ErrUnsupportedFilter, element.Key)
+				}
+				query, err := getElasticBool(itemDocument)
+				if err != nil {
+					return nil, err
+				}
+				queries = append(queries, query)
+			}
+			switch element.Key {
+			case "$and":
+				filters = append(filters, queries...)
+			case "$or":
+				filters = append(filters, map[string]interface{}{"bool": map[string]interface{}{"should": queries, "minimum_should_match": 1}})
+			case "$nor":
+				mustNot = append(mustNot, queries...)
+			}
+			continue
+		}
+		if strings.HasPrefix(element.Key, "$") {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedFilter, element.Key)
+		}
+
+		operators, ok := element.Value.(bson.D)
+		if !ok || len(operators) == 0 || !strings.HasPrefix(operators[0].Key, "$") {
+			operators = bson.D{primitive.E{Key: "$eq", Value: element.Value}}
+		}
+
+		ranges := map[string]interface{}{}
+		for _, operator := range operators {
+			value := toJSONValue(operator.Value)
+			switch operator.Key {
+			case "$eq":
+				if value == nil {
+					mustNot = append(mustNot, getElasticClause("exists", "field", element.Key))
+				} else {
+					filters = append(filters, getElasticClause("term", element.Key, value))
+				}
+			case "$ne":
+				if value == nil {
+					filters = append(filters, getElasticClause("exists", "field", element.Key))
+				} else {
+					mustNot = append(mustNot, getElasticClause("term", element.Key, value))
+				}
+			case "$in", "$nin":
+				if _, ok := value.([]interface{}); !ok {
+					return nil, fmt.Errorf("%w: %s requires an array", ErrUnsupportedFilter, operator.Key)
+				}
+				if operator.Key == "$in" {
+					filters = append(filters, getElasticClause("terms", element.Key, value))
+				} else {
+					mustNot = append(mustNot, getElasticClause("terms", element.Key, value))
+				}
+			case "$gt", "$gte", "$lt", "$lte":
+				ranges[strings.TrimPrefix(operator.Key, "$")] = value
+			case "$exists":
+				if exists, _ := value.(bool); exists {
+					filters = append(filters, getElasticClause("exists", "field", element.Key))
+				} else {
+					mustNot = append(mustNot, getElasticClause("exists", "field", element.Key))
+				}
+			default:
+				return nil, fmt.Errorf("%w: %s", ErrUnsupportedFilter, operator.Key)
+			}
+		}
+		if len(ranges) > 0 {
+			filters = append(filters, getElasticClause("range", element.Key, ranges))
+		}
+	}
+
+	return map[string]interface{}{"bool": map[string]interface{}{"filter": filters, "must_not": mustNot}}, nil
+}
+
+// getElasticClause return the query clause of kind like {"term": {field: value}}
+func getElasticClause(kind, field string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{kind: map[string]interface{}{field: value}}
+}
+
+// getElasticUpdateParams return the params of elasticUpdateScript for the $set, $unset and $inc fields of update
+func getElasticUpdateParams(update interface{}) (map[string]interface{}, error) {
+	document, err := toDocument(update)
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]interface{}{"set": map[string]interface{}{}, "inc": map[string]interface{}{}, "unset": []string{}}
+	for _, element := range document {
+		fields, ok := element.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("%w: update %s requires a document", ErrUnsupportedFilter, element.Key)
+		}
+
+		for _, field := range fields {
+			switch element.Key {
+			case "$set":
+				params["set"].(map[string]interface{})[field.Key] = toJSONValue(field.Value)
+			case "$inc":
+				params["inc"].(map[string]interface{})[field.Key] = toJSONValue(field.Value)
+			case "$unset":
+				params["unset"] = append(params["unset"].([]string), field.Key)
+			default:
+				return nil, fmt.Errorf("%w: update %s", ErrUnsupportedFilter, element.Key)
+			}
+		}
+	}
+
+	return params, nil
+}
+
+// Capabilities implement ICapabilities, the maximum document size is the default http.max_content_length of the cluster
+func (e *ElasticClient) Capabilities() Capabilities {
+	return Capabilities{TextSearch: true, MaxDocumentSize: 100 * 1024 * 1024}
+}
@@ -0,0 +1,457 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// matchDocument return true when document match the filter, it support $eq, $ne, $in, $nin, $gt, $gte, $lt, $lte,
+// $exists, $and, $or and $nor, and an array field match when the array or one of its elements match like in MongoDB
+func matchDocument(document, filter bson.D) (bool, error) {
+	for _, element := range filter {
+		switch element.Key {
+		case "$and", "$or", "$nor":
+			items, ok := element.Value.(bson.A)
+			if !ok || len(items) == 0 {
+				return false, fmt.Errorf("%w: %s requires a non-empty array", ErrUnsupportedFilter, element.Key)
+			}
+			matched := 0
+			for _, item := range items {
+				itemFilter, ok := item.(bson.D)
+				if !ok {
+					return false, fmt.Errorf("%w: %s requires documents", ErrUnsupportedFilter, element.Key)
+				}
+				ok, err := matchDocument(document, itemFilter)
+				if err != nil {
+					return false, err
+				}
+				if ok {
+					matched++
+				}
+			}
+			if (element.Key == "$and" && matched != len(items)) || (element.Key == "$or" && matched == 0) || (element.Key == "$nor" && matched > 0) {
+				return false, nil
+			}
+			continue
+		}
+		if strings.HasPrefix(element.Key, "$") {
+			return false, fmt.Errorf("%w: %s", ErrUnsupportedFilter, element.Key)
+		}
+
+		operators, ok := element.Value.(bson.D)
+		if !ok || len(operators) == 0 || !strings.HasPrefix(operators[0].Key, "$") {
+			operators = bson.D{primitive.E{Key: "$eq", Value: element.Value}}
+		}
+
+		values := getPathValues(document, strings.Split(element.Key, "."))
+		for _, operator := range operators {
+			ok, err := matchOperator(operator, values)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// matchOperator return true when one of the values of the field match the operator, values is empty when the field is missing
+func matchOperator(operator primitive.E, values []interface{}) (bool, error) {
+	switch operator.Key {
+	case "$eq":
+		return matchEquality(operator.Value, values), nil
+	case "$ne":
+		return !matchEquality(operator.Value, values), nil
+	case "$in", "$nin":
+		items, ok := operator.Value.(bson.A)
+		if !ok {
+			return false, fmt.Errorf("%w: %s requires an array", ErrUnsupportedFilter, operator.Key)
+		}
+		matched := false
+		for _, item := range items {
+			if matchEquality(item, values) {
+				matched = true
+				break
+			}
+		}
+		return matched == (operator.Key == "$in"), nil
+	case "$gt", "$gte", "$lt", "$lte":
+		for _, value := range values {
+			result, ok := compareBSON(value, operator.Value)
+			if !ok {
+				continue
+			}
+			if (operator.Key == "$gt" && result > 0) || (operator.Key == "$gte" && result >= 0) ||
+				(operator.Key == "$lt" && result < 0) || (operator.Key == "$lte" && result <= 0) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$exists":
+		exists, _ := operator.Value.(bool)
+		return exists == (len(values) > 0), nil
+	}
+
+	return false, fmt.Errorf("%w: %s", ErrUnsupportedFilter, operator.Key)
+}
+
+// matchEquality return true when one of the values equal expected, null match the missing fields
+func matchEquality(expected interface{}, values []interface{}) bool {
+	if isBSONNull(expected) && len(values) == 0 {
+		return true
+	}
+
+	for _, value := range values {
+		if equalBSON(value, expected) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getPathValues return the values at the dotted path, with the elements of the arrays on the path and of the final
+// array, empty when the path is missing
+func getPathValues(value interface{}, segments []string) []interface{} {
+	if len(segments) == 0 {
+		if list, ok := value.(bson.A); ok {
+			return append([]interface{}{value}, list...)
+		}
+		return []interface{}{value}
+	}
+
+	switch v := value.(type) {
+	case bson.D:
+		field, ok := getElement(v, segments[0])
+		if !ok {
+			return nil
+		}
+		return getPathValues(field, segments[1:])
+	case bson.A:
+		var values []interface{}
+		if i, err := strconv.Atoi(segments[0]); err == nil && i >= 0 && i < len(v) {
+			values = append(values, getPathValues(v[i], segments[1:])...)
+		}
+		for _, item := range v {
+			if _, ok := item.(bson.D); ok {
+				values = append(values, getPathValues(item, segments)...)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
+
+// equalBSON return true when the BSON values are equal, numbers of every type are compared by value
+func equalBSON(a, b interface{}) bool {
+	if isBSONNull(a) || isBSONNull(b) {
+		return isBSONNull(a) && isBSONNull(b)
+	}
+	if result, ok := compareBSON(a, b); ok {
+		return result == 0
+	}
+
+	rawA, errA := bson.Marshal(bson.D{primitive.E{Key: "v", Value: a}})
+	rawB, errB := bson.Marshal(bson.D{primitive.E{Key: "v", Value: b}})
+
+	return errA == nil && errB == nil && bytes.Equal(rawA, rawB)
+}
+
+// compareBSON return the order of the scalar BSON values and true when they are comparable, numbers with numbers,
+// strings with strings, dates with dates, ObjectID with ObjectID and booleans with booleans
+func compareBSON(a, b interface{}) (int, bool) {
+	if integerA, ok := getInteger(a); ok {
+		if integerB, ok := getInteger(b); ok {
+			return compareInt64(integerA, integerB), true
+		}
+	}
+	if numberA, ok := getNumber(a); ok {
+		if numberB, ok := getNumber(b); ok {
+			switch {
+			case numberA < numberB:
+				return -1, true
+			case numberA > numberB:
+				return 1, true
+			}
+			return 0, true
+		}
+		return 0, false
+	}
+
+	switch v := a.(type) {
+	case string:
+		if w, ok := b.(string); ok {
+			return strings.Compare(v, w), true
+		}
+	case primitive.DateTime:
+		if w, ok := b.(primitive.DateTime); ok {
+			return compareInt64(int64(v), int64(w)), true
+		}
+	case primitive.ObjectID:
+		if w, ok := b.(primitive.ObjectID); ok {
+			return bytes.Compare(v[:], w[:]), true
+		}
+	case bool:
+		if w, ok := b.(bool); ok {
+			if v == w {
+				return 0, true
+			}
+			if !v {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+
+	return 0, false
+}
+
+// getInteger return the BSON integer as int64
+func getInteger(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	}
+
+	return 0, false
+}
+
+// getNumber return the BSON number as float64
+func getNumber(value interface{}) (float64, bool) {
+	if integer, ok := getInteger(value); ok {
+		return float64(integer), true
+	}
+
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case primitive.Decimal128:
+		number, err := strconv.ParseFloat(v.String(), 64)
+		return number, err == nil
+	}
+
+	return 0, false
+}
+
+// applyUpdate return a copy of document with the $set, $unset and $inc fields of update applied, _id cannot change
+func applyUpdate(document, update bson.D) (bson.D, error) {
+	result := copyDocument(document)
+	for _, element := range update {
+		fields, ok := element.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("%w: update %s requires a document", ErrUnsupportedFilter, element.Key)
+		}
+
+		for _, field := range fields {
+			if field.Key == "_id" || strings.HasPrefix(field.Key, "_id.") {
+				return nil, &InvalidArgumentError{Argument: "update", Reason: "cannot change _id"}
+			}
+			segments := strings.Split(field.Key, ".")
+
+			var err error
+			switch element.Key {
+			case "$set":
+				result, err = setPathValue(result, segments, field.Value)
+			case "$unset":
+				result = unsetPathValue(result, segments)
+			case "$inc":
+				current, _ := getPathValue(result, segments)
+				var sum interface{}
+				if sum, err = addNumbers(current, field.Value); err == nil {
+					result, err = setPathValue(result, segments, sum)
+				}
+			default:
+				err = fmt.Errorf("%w: update %s", ErrUnsupportedFilter, element.Key)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// copyDocument return a deep copy of the documents and arrays of document
+func copyDocument(document bson.D) bson.D {
+	result := make(bson.D, len(document))
+	for i, element := range document {
+		result[i] = primitive.E{Key: element.Key, Value: copyValue(element.Value)}
+	}
+
+	return result
+}
+
+// copyValue return a deep copy of the documents and arrays of value
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		return copyDocument(v)
+	case bson.A:
+		list := make(bson.A, len(v))
+		for i, item := range v {
+			list[i] = copyValue(item)
+		}
+		return list
+	}
+
+	return value
+}
+
+// getPathValue return the value at the dotted path without expanding the arrays, indexes select array elements
+func getPathValue(value interface{}, segments []string) (interface{}, bool) {
+	for _, segment := range segments {
+		switch v := value.(type) {
+		case bson.D:
+			field, ok := getElement(v, segment)
+			if !ok {
+				return nil, false
+			}
+			value = field
+		case bson.A:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// setPathValue set value at the dotted path of document, the missing documents of the path are created
+func setPathValue(document bson.D, segments []string, value interface{}) (bson.D, error) {
+	if len(segments) == 1 {
+		for i, element := range document {
+			if element.Key == segments[0] {
+				document[i].Value = value
+				return document, nil
+			}
+		}
+		return append(document, primitive.E{Key: segments[0], Value: value}), nil
+	}
+
+	for i, element := range document {
+		if element.Key != segments[0] {
+			continue
+		}
+
+		child, err := setChildValue(element.Value, segments[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		document[i].Value = child
+		return document, nil
+	}
+
+	child, err := setPathValue(bson.D{}, segments[1:], value)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(document, primitive.E{Key: segments[0], Value: child}), nil
+}
+
+// setChildValue set value at the dotted path of a document or an array element
+func setChildValue(container interface{}, segments []string, value interface{}) (interface{}, error) {
+	switch v := container.(type) {
+	case bson.D:
+		return setPathValue(v, segments, value)
+	case bson.A:
+		i, err := strconv.Atoi(segments[0])
+		if err != nil || i < 0 || i >= len(v) {
+			return nil, &InvalidArgumentError{Argument: "update", Reason: "array index " + segments[0] + " is out of range"}
+		}
+		if len(segments) == 1 {
+			v[i] = value
+			return v, nil
+		}
+		child, err := setChildValue(v[i], segments[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = child
+		return v, nil
+	case nil, primitive.Null:
+		return setPathValue(bson.D{}, segments, value)
+	}
+
+	return nil, &InvalidArgumentError{Argument: "update", Reason: "cannot create field " + segments[0] + " in a scalar value"}
+}
+
+// unsetPathValue remove the field at the dotted path of document, array elements are set to null like $unset
+func unsetPathValue(document bson.D, segments []string) bson.D {
+	for i, element := range document {
+		if element.Key != segments[0] {
+			continue
+		}
+		if len(segments) == 1 {
+			return append(document[:i], document[i+1:]...)
+		}
+
+		switch v := element.Value.(type) {
+		case bson.D:
+			document[i].Value = unsetPathValue(v, segments[1:])
+		case bson.A:
+			if j, err := strconv.Atoi(segments[1]); err == nil && j >= 0 && j < len(v) {
+				if len(segments) == 2 {
+					v[j] = nil
+				} else if child, ok := v[j].(bson.D); ok {
+					v[j] = unsetPathValue(child, segments[2:])
+				}
+			}
+		}
+		return document
+	}
+
+	return document
+}
+
+// addNumbers return current incremented by increment, a missing current value count as zero, integers stay integers
+// until they overflow
+func addNumbers(current, increment interface{}) (interface{}, error) {
+	if _, ok := getNumber(increment); !ok {
+		return nil, &InvalidArgumentError{Argument: "update", Reason: "$inc requires a number"}
+	}
+	if current == nil {
+		return increment, nil
+	}
+
+	integerA, okA := getInteger(current)
+	integerB, okB := getInteger(increment)
+	if okA && okB {
+		sum := integerA + integerB
+		if (integerB > 0 && sum < integerA) || (integerB < 0 && sum > integerA) {
+			return nil, errors.New("Integer overflow of $inc")
+		}
+		_, currentInt32 := current.(int32)
+		_, incrementInt32 := increment.(int32)
+		if currentInt32 && incrementInt32 && sum >= math.MinInt32 && sum <= math.MaxInt32 {
+			return int32(sum), nil
+		}
+		return sum, nil
+	}
+
+	numberA, ok := getNumber(current)
+	if !ok {
+		return nil, &InvalidArgumentError{Argument: "update", Reason: "$inc cannot apply to a non-numeric field"}
+	}
+	numberB, _ := getNumber(increment)
+
+	return numberA + numberB, nil
+}
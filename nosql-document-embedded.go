@@ -0,0 +1,612 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/golang-common-packages/hash"
+)
+
+const (
+	// embeddedOpenTimeout is the time to wait for the lock of a database file held by another process
+	embeddedOpenTimeout = time.Second
+	// embeddedPut is the write of created or updated documents
+	embeddedPut = "put"
+	// embeddedDelete is the write of deleted documents
+	embeddedDelete = "delete"
+)
+
+var (
+	// ErrDuplicateKey is returned when a created document has the _id of an existing document
+	ErrDuplicateKey = errors.New("Duplicate key")
+	// embeddedClientSessionMapping singleton pattern
+	embeddedClientSessionMapping = make(map[string]*EmbeddedClient)
+)
+
+// EmbeddedClient manage a document store embedded in the process, for the tools and edge deployments without database
+// server. The documents are stored as canonical Extended JSON in the BoltDB file path/databaseName.db, one bucket per
+// collection keyed by _id, and each write is one BoltDB transaction. The documents of a collection are also kept in
+// memory, loaded when the collection is first used. The fields of the Indexes config are indexed in memory for the
+// equality and $in filters, like GetByField, the other filters scan the collection.
+type EmbeddedClient struct {
+	Config      *Embedded
+	mu          sync.Mutex
+	databases   map[string]*bolt.DB
+	collections map[string]*embeddedCollection
+}
+
+// embeddedCollection private model for the documents, the indexes and the bucket of a collection
+type embeddedCollection struct {
+	mu        sync.RWMutex
+	database  *bolt.DB
+	bucket    []byte
+	documents map[string]*embeddedDocument          // by _id key
+	indexes   map[string]map[string]map[string]bool // _id keys by field and value key
+	sequence  uint64
+}
+
+// embeddedDocument private model for a document with its insertion order
+type embeddedDocument struct {
+	document bson.D
+	sequence uint64
+}
+
+// embeddedRecord private model for a value of a collection bucket
+type embeddedRecord struct {
+	Sequence uint64          `json:"seq"` // insertion order
+	Document json.RawMessage `json:"doc"` // canonical Extended JSON
+}
+
+// newEmbedded init new instance
+func newEmbedded(config *Embedded) INoSQLDocument {
+	hasher := &hash.Client{}
+	configAsJSON, err := json.Marshal(config)
+	if err != nil {
+		log.Fatalln("Unable to marshal embedded database configuration: ", err)
+	}
+	configAsString := hasher.SHA1(string(configAsJSON))
+
+	currentEmbeddedSession := embeddedClientSessionMapping[configAsString]
+	if currentEmbeddedSession == nil {
+		currentEmbeddedSession, err = NewEmbeddedClient(config)
+		if err != nil {
+			log.Fatalln("Unable to open embedded database: ", err)
+		}
+		embeddedClientSessionMapping[configAsString] = currentEmbeddedSession
+		log.Println("Embedded database is ready")
+	}
+
+	return currentEmbeddedSession
+}
+
+// NewEmbeddedClient init new client storing its collections below the path of config
+func NewEmbeddedClient(config *Embedded) (*EmbeddedClient, error) {
+	if config.Path == "" {
+		return nil, &InvalidArgumentError{Argument: "path", Reason: "cannot be empty"}
+	}
+	if err := os.MkdirAll(config.Path, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &EmbeddedClient{Config: config, databases: make(map[string]*bolt.DB), collections: make(map[string]*embeddedCollection)}, nil
+}
+
+// Create insert the documents and return their _id, an ObjectID is generated for the documents without _id.
+// Nothing is inserted when an _id already exists.
+func (e *EmbeddedClient) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	if len(documents) == 0 {
+		return nil, &InvalidArgumentError{Argument: "documents", Reason: "cannot be empty"}
+	}
+	collection, err := e.getCollection(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]bson.D, 0, len(documents))
+	for _, document := range documents {
+		value, err := toDocument(document)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := getElement(value, "_id"); !ok {
+			value = append(bson.D{primitive.E{Key: "_id", Value: primitive.NewObjectID()}}, value...)
+		}
+		values = append(values, value)
+	}
+
+	collection.mu.Lock()
+	defer collection.mu.Unlock()
+
+	result := &mongo.InsertManyResult{InsertedIDs: make([]interface{}, 0, len(values))}
+	keys := make(map[string]bool, len(values))
+	for _, value := range values {
+		id, _ := getElement(value, "_id")
+		key, err := getEmbeddedKey(id)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := collection.documents[key]; ok || keys[key] {
+			return nil, fmt.Errorf("%w: _id %s", ErrDuplicateKey, key)
+		}
+		keys[key] = true
+		result.InsertedIDs = append(result.InsertedIDs, id)
+	}
+
+	if err := e.write(collection, embeddedPut, values); err != nil {
+		log.Println("Unable to create document: ", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Read documents from the collection based on filter in insertion order, limit 0 means no limit
+func (e *EmbeddedClient) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	if err := checkLimit(limit); err != nil {
+		return nil, err
+	}
+	if err := checkDataModel(dataModel); err != nil {
+		return nil, err
+	}
+	collection, err := e.getCollection(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	collection.mu.RLock()
+	documents, err := collection.find(filter)
+	collection.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && int64(len(documents)) > limit {
+		documents = documents[:limit]
+	}
+
+	results := reflect.New(reflect.SliceOf(dataModel))
+	results.Elem().Set(reflect.MakeSlice(results.Elem().Type(), len(documents), len(documents)))
+	for i, document := range documents {
+		raw, err := bson.Marshal(document)
+		if err != nil {
+			return nil, err
+		}
+		if err := bson.Unmarshal(raw, results.Elem().Index(i).Addr().Interface()); err != nil {
+			log.Println("Unable to decode document: ", err)
+			return nil, err
+		}
+	}
+
+	return results.Interface(), nil
+}
+
+// GetByField return the documents whose field equal value, from the index of field when it is configured
+func (e *EmbeddedClient) GetByField(databaseName, collectionName, field string, value interface{}, dataModel reflect.Type) (interface{}, error) {
+	if err := checkFieldPath(field); err != nil {
+		return nil, err
+	}
+
+	return e.Read(databaseName, collectionName, bson.D{primitive.E{Key: field, Value: value}}, 0, dataModel)
+}
+
+// Update the documents matching filter, update support $set, $unset and $inc
+func (e *EmbeddedClient) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	if err := checkNotNil("filter", filter); err != nil {
+		return nil, err
+	}
+	if err := checkNotNil("update", update); err != nil {
+		return nil, err
+	}
+	updateDocument, err := toDocument(update)
+	if err != nil {
+		return nil, err
+	}
+	collection, err := e.getCollection(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	collection.mu.Lock()
+	defer collection.mu.Unlock()
+
+	documents, err := collection.find(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &mongo.UpdateResult{MatchedCount: int64(len(documents))}
+	updated := make([]bson.D, 0, len(documents))
+	for _, document := range documents {
+		value, err := applyUpdate(document, updateDocument)
+		if err != nil {
+			return nil, err
+		}
+		if !equalBSON(value, document) {
+			updated = append(updated, value)
+		}
+	}
+	if len(updated) == 0 {
+		return result, nil
+	}
+
+	if err := e.write(collection, embeddedPut, updated); err != nil {
+		log.Println("Unable to update: ", err)
+		return nil, err
+	}
+	result.ModifiedCount = int64(len(updated))
+
+	return result, nil
+}
+
+// Delete the documents matching filter
+func (e *EmbeddedClient) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	if err := checkNotNil("filter", filter); err != nil {
+		return nil, err
+	}
+	collection, err := e.getCollection(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	collection.mu.Lock()
+	defer collection.mu.Unlock()
+
+	documents, err := collection.find(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(documents) == 0 {
+		return &mongo.DeleteResult{}, nil
+	}
+
+	ids := make([]bson.D, 0, len(documents))
+	for _, document := range documents {
+		id, _ := getElement(document, "_id")
+		ids = append(ids, bson.D{primitive.E{Key: "_id", Value: id}})
+	}
+	if err := e.write(collection, embeddedDelete, ids); err != nil {
+		log.Println("Unable to delete: ", err)
+		return nil, err
+	}
+
+	return &mongo.DeleteResult{DeletedCount: int64(len(ids))}, nil
+}
+
+// Close the database files, the client cannot be used anymore
+func (e *EmbeddedClient) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var err error
+	for name, database := range e.databases {
+		if closeErr := database.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		delete(e.databases, name)
+	}
+	for name := range e.collections {
+		delete(e.collections, name)
+	}
+
+	return err
+}
+
+// getCollection return the collection, its documents are loaded the first time
+func (e *EmbeddedClient) getCollection(databaseName, collectionName string) (*embeddedCollection, error) {
+	if err := checkNamespace(databaseName, collectionName); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	name := databaseName + "." + collectionName
+	if collection, ok := e.collections[name]; ok {
+		return collection, nil
+	}
+
+	database, ok := e.databases[databaseName]
+	if !ok {
+		var err error
+		database, err = bolt.Open(filepath.Join(e.Config.Path, url.PathEscape(databaseName)+".db"), 0o644, &bolt.Options{
+			Timeout: embeddedOpenTimeout,
+			NoSync:  e.Config.NoSync,
+		})
+		if err != nil {
+			log.Println("Unable to open database file: ", err)
+			return nil, err
+		}
+		e.databases[databaseName] = database
+	}
+
+	collection := &embeddedCollection{
+		database:  database,
+		bucket:    []byte(collectionName),
+		documents: make(map[string]*embeddedDocument),
+		indexes:   make(map[string]map[string]map[string]bool),
+	}
+	for _, field := range e.Config.Indexes[collectionName] {
+		collection.indexes[field] = make(map[string]map[string]bool)
+	}
+	if err := collection.load(); err != nil {
+		log.Println("Unable to load collection: ", err)
+		return nil, err
+	}
+	e.collections[name] = collection
+
+	return collection, nil
+}
+
+// write put or delete the documents in the bucket of the collection in one transaction, then apply them in memory.
+// Nothing is applied when the transaction fails.
+func (e *EmbeddedClient) write(collection *embeddedCollection, op string, documents []bson.D) error {
+	keys := make([]string, 0, len(documents))
+	sequences := make([]uint64, 0, len(documents))
+	sequence := collection.sequence
+	for _, document := range documents {
+		id, ok := getElement(document, "_id")
+		if !ok {
+			return errors.New("Document without _id")
+		}
+		key, err := getEmbeddedKey(id)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+
+		if previous, ok := collection.documents[key]; ok {
+			sequences = append(sequences, previous.sequence)
+		} else {
+			sequence++
+			sequences = append(sequences, sequence)
+		}
+	}
+
+	if err := collection.database.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(collection.bucket)
+		for i, document := range documents {
+			if op == embeddedDelete {
+				if err := bucket.Delete([]byte(keys[i])); err != nil {
+					return err
+				}
+				continue
+			}
+
+			value, err := encodeEmbeddedRecord(sequences[i], document)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(keys[i]), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for i, document := range documents {
+		collection.apply(op, keys[i], document, sequences[i])
+	}
+
+	return nil
+}
+
+// load create the bucket of the collection when it does not exist and read its documents
+func (ec *embeddedCollection) load() error {
+	return ec.database.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(ec.bucket)
+		if err != nil {
+			return err
+		}
+
+		return bucket.ForEach(func(key, value []byte) error {
+			var record embeddedRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return fmt.Errorf("Invalid record %s of %s: %w", key, ec.bucket, err)
+			}
+			var document bson.D
+			if err := bson.UnmarshalExtJSON(record.Document, true, &document); err != nil {
+				return fmt.Errorf("Invalid document %s of %s: %w", key, ec.bucket, err)
+			}
+
+			ec.apply(embeddedPut, string(key), document, record.Sequence)
+			return nil
+		})
+	})
+}
+
+// apply op for the document of key to the documents and the indexes
+func (ec *embeddedCollection) apply(op, key string, document bson.D, sequence uint64) {
+	if previous, ok := ec.documents[key]; ok {
+		ec.index(key, previous.document, false)
+		delete(ec.documents, key)
+	}
+	if op != embeddedPut {
+		return
+	}
+
+	ec.documents[key] = &embeddedDocument{document: document, sequence: sequence}
+	ec.index(key, document, true)
+	if sequence > ec.sequence {
+		ec.sequence = sequence
+	}
+}
+
+// index add or remove the document of key in the indexes of the collection
+func (ec *embeddedCollection) index(key string, document bson.D, add bool) {
+	for field, values := range ec.indexes {
+		for _, value := range getPathValues(document, strings.Split(field, ".")) {
+			valueKey, err := getEmbeddedKey(value)
+			if err != nil {
+				continue
+			}
+
+			if add {
+				if values[valueKey] == nil {
+					values[valueKey] = make(map[string]bool)
+				}
+				values[valueKey][key] = true
+			} else if ids := values[valueKey]; ids != nil {
+				delete(ids, key)
+				if len(ids) == 0 {
+					delete(values, valueKey)
+				}
+			}
+		}
+	}
+}
+
+// find return the documents matching filter in insertion order, the candidates are the documents of the _id and of
+// the indexed fields fixed by an equality or $in of filter, every document otherwise
+func (ec *embeddedCollection) find(filter interface{}) ([]bson.D, error) {
+	document := bson.D{}
+	if filter != nil {
+		value, err := toDocument(filter)
+		if err != nil {
+			return nil, err
+		}
+		document = value
+	}
+
+	var candidates map[string]bool
+	for _, element := range document {
+		keys, ok := ec.lookup(element)
+		if !ok {
+			continue
+		}
+		if candidates == nil {
+			candidates = keys
+			continue
+		}
+		for key := range candidates {
+			if !keys[key] {
+				delete(candidates, key)
+			}
+		}
+	}
+
+	matches := make([]*embeddedDocument, 0)
+	check := func(candidate *embeddedDocument) error {
+		ok, err := matchDocument(candidate.document, document)
+		if ok {
+			matches = append(matches, candidate)
+		}
+		return err
+	}
+	if candidates != nil {
+		for key := range candidates {
+			if candidate, ok := ec.documents[key]; ok {
+				if err := check(candidate); err != nil {
+					return nil, err
+				}
+			}
+		}
+	} else {
+		for _, candidate := range ec.documents {
+			if err := check(candidate); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].sequence < matches[j].sequence })
+
+	documents := make([]bson.D, 0, len(matches))
+	for _, match := range matches {
+		documents = append(documents, match.document)
+	}
+
+	return documents, nil
+}
+
+// lookup return the _id keys of the documents which can match the filter element, false when the element is not an
+// equality or $in on _id or an indexed field
+func (ec *embeddedCollection) lookup(element primitive.E) (map[string]bool, bool) {
+	values, ok := ec.indexes[element.Key]
+	if !ok && element.Key != "_id" {
+		return nil, false
+	}
+
+	var expected []interface{}
+	if value := getEqualityValue(element.Value); value != nil {
+		expected = []interface{}{value}
+	} else if operators, ok := element.Value.(bson.D); ok && len(operators) == 1 && operators[0].Key == "$in" {
+		items, ok := operators[0].Value.(bson.A)
+		if !ok {
+			return nil, false
+		}
+		for _, item := range items {
+			// null match the missing fields, which are not indexed
+			if isBSONNull(item) {
+				return nil, false
+			}
+			expected = append(expected, item)
+		}
+	} else {
+		return nil, false
+	}
+
+	keys := make(map[string]bool)
+	for _, value := range expected {
+		valueKey, err := getEmbeddedKey(value)
+		if err != nil {
+			return nil, false
+		}
+		if element.Key == "_id" {
+			if _, ok := ec.documents[valueKey]; ok {
+				keys[valueKey] = true
+			}
+			continue
+		}
+		for key := range values[valueKey] {
+			keys[key] = true
+		}
+	}
+
+	return keys, true
+}
+
+// encodeEmbeddedRecord return the value of document in the bucket of its collection
+func encodeEmbeddedRecord(sequence uint64, document bson.D) ([]byte, error) {
+	extJSON, err := bson.MarshalExtJSON(document, true, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(embeddedRecord{Sequence: sequence, Document: extJSON})
+}
+
+// getEmbeddedKey return the key of the value in the documents and the indexes, numbers equal by value have the same key
+func getEmbeddedKey(value interface{}) (string, error) {
+	if integer, ok := getInteger(value); ok {
+		value = integer
+	} else if number, ok := getNumber(value); ok {
+		if number == math.Trunc(number) && math.Abs(number) < 1<<53 {
+			value = int64(number)
+		} else {
+			value = number
+		}
+	}
+
+	return marshalExtJSON(bson.D{primitive.E{Key: "v", Value: value}}, true)
+}
+
+// Capabilities implement ICapabilities, documents are only limited by memory
+func (e *EmbeddedClient) Capabilities() Capabilities {
+	return Capabilities{}
+}
@@ -0,0 +1,517 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// encryptedSubtype is the user defined binary subtype of the encrypted values
+	encryptedSubtype = 0x81
+	// encryptedVersion is the first byte of the encrypted values, followed by the key ID, the nonce and the ciphertext
+	encryptedVersion = 1
+)
+
+var (
+	// ErrEncryptedPipeline is returned when an update pipeline is applied to a collection with encrypted fields
+	ErrEncryptedPipeline = errors.New("Update pipelines are not supported on encrypted collections")
+	// ErrUnknownKey is returned by the key providers for a key ID they do not have
+	ErrUnknownKey = errors.New("Encryption key is unknown")
+)
+
+// IKeyProvider interface for the AES keys of EncryptedDatabase, like a KMS or a secret manager. The values are written
+// with the current key and read with the key of their ID, so the previous keys must stay available until Rotate has
+// re-encrypted the documents.
+type IKeyProvider interface {
+	CurrentKey() (keyID string, key []byte, err error)
+	Key(keyID string) ([]byte, error)
+}
+
+// StaticKeyProvider implement IKeyProvider with keys in memory, like loaded from the config
+type StaticKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewStaticKeyProvider init new key provider writing with the key current of keys, the keys are 16, 24 or 32 bytes
+// for AES-128, AES-192 or AES-256
+func NewStaticKeyProvider(current string, keys map[string][]byte) (*StaticKeyProvider, error) {
+	var errs *multierror.Error
+	if _, ok := keys[current]; !ok {
+		errs = multierror.Append(errs, fmt.Errorf("encryption: current key %q is missing", current))
+	}
+	for keyID, key := range keys {
+		if keyID == "" || len(keyID) > 255 {
+			errs = multierror.Append(errs, fmt.Errorf("encryption: key ID %q must be 1 to 255 bytes", keyID))
+		}
+		if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+			errs = multierror.Append(errs, fmt.Errorf("encryption: key %q must be 16, 24 or 32 bytes", keyID))
+		}
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+
+	return &StaticKeyProvider{current: current, keys: keys}, nil
+}
+
+// CurrentKey return the key used for the writes
+func (p *StaticKeyProvider) CurrentKey() (string, []byte, error) {
+	return p.current, p.keys[p.current], nil
+}
+
+// Key return the key of keyID, or ErrUnknownKey
+func (p *StaticKeyProvider) Key(keyID string) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	return key, nil
+}
+
+// EncryptedCollection model for a collection whose model has fields tagged with encrypt, like
+// SSN string `bson:"ssn" encrypt:"true"`. Only top level and inline fields can be encrypted, of any type.
+type EncryptedCollection struct {
+	Database   string
+	Collection string
+	Model      interface{} // struct or pointer to struct
+}
+
+// EncryptedDatabase decorate INoSQLDocument with the AES-GCM encryption of fields at the application layer, for the
+// deployments without client-side field level encryption. Create and Update write the values of the tagged fields as
+// binaries holding the key ID, the nonce and the ciphertext, bound to their namespace and field. Read decrypt them
+// before decoding the documents. The nonces are random, so filters can not match the values of encrypted fields.
+type EncryptedDatabase struct {
+	db          INoSQLDocument
+	keys        IKeyProvider
+	collections map[string]map[string]bool // encrypted fields by namespace
+	mu          sync.RWMutex
+	ciphers     map[string]cipher.AEAD
+}
+
+// NewEncryptedDatabase init new encrypted database with the encrypted fields of the collection models
+func NewEncryptedDatabase(db INoSQLDocument, keys IKeyProvider, collections ...EncryptedCollection) (*EncryptedDatabase, error) {
+	var errs *multierror.Error
+	if keys == nil {
+		errs = multierror.Append(errs, errors.New("encryption: key provider is required"))
+	}
+	namespaces := make(map[string]map[string]bool, len(collections))
+	for _, collection := range collections {
+		if err := checkNamespace(collection.Database, collection.Collection); err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+
+		modelType := reflect.TypeOf(collection.Model)
+		for modelType != nil && modelType.Kind() == reflect.Ptr {
+			modelType = modelType.Elem()
+		}
+		if modelType == nil || modelType.Kind() != reflect.Struct {
+			errs = multierror.Append(errs, fmt.Errorf("encryption: model of %s.%s must be a struct", collection.Database, collection.Collection))
+			continue
+		}
+
+		fields := map[string]bool{}
+		if err := getEncryptedFields(modelType, fields); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		namespaces[collection.Database+"."+collection.Collection] = fields
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+
+	return &EncryptedDatabase{db: db, keys: keys, collections: namespaces, ciphers: make(map[string]cipher.AEAD)}, nil
+}
+
+// Create the documents with the values of their encrypted fields encrypted with the current key
+func (e *EncryptedDatabase) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	namespace := databaseName + "." + collectionName
+	fields := e.collections[namespace]
+	if len(fields) == 0 {
+		return e.db.Create(databaseName, collectionName, documents)
+	}
+
+	encrypted := make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		value, err := toDocument(document)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.encryptDocument(namespace, value, fields); err != nil {
+			return nil, err
+		}
+		encrypted = append(encrypted, value)
+	}
+
+	return e.db.Create(databaseName, collectionName, encrypted)
+}
+
+// Read documents based on filter and decrypt the values of their encrypted fields
+func (e *EncryptedDatabase) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	namespace := databaseName + "." + collectionName
+	fields := e.collections[namespace]
+	if len(fields) == 0 {
+		return e.db.Read(databaseName, collectionName, filter, limit, dataModel)
+	}
+	if err := checkDataModel(dataModel); err != nil {
+		return nil, err
+	}
+
+	// The encrypted binaries can not be decoded into the fields of the model, the documents are decoded once decrypted
+	documents, err := e.readDocuments(databaseName, collectionName, filter, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	results := reflect.New(reflect.SliceOf(dataModel))
+	results.Elem().Set(reflect.MakeSlice(results.Elem().Type(), len(documents), len(documents)))
+	for i, document := range documents {
+		if err := e.decryptDocument(namespace, document, fields); err != nil {
+			return nil, err
+		}
+
+		raw, err := bson.Marshal(document)
+		if err != nil {
+			return nil, err
+		}
+		if err := bson.Unmarshal(raw, results.Elem().Index(i).Addr().Interface()); err != nil {
+			return nil, err
+		}
+	}
+
+	return results.Interface(), nil
+}
+
+// Update the documents matching filter, the values of encrypted fields set by $set, $setOnInsert or the replacement
+// are encrypted with the current key
+func (e *EncryptedDatabase) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	namespace := databaseName + "." + collectionName
+	fields := e.collections[namespace]
+	if len(fields) == 0 {
+		return e.db.Update(databaseName, collectionName, filter, update)
+	}
+
+	switch update.(type) {
+	case mongo.Pipeline, bson.A, []bson.D, []bson.M, []interface{}:
+		return nil, ErrEncryptedPipeline
+	}
+
+	document, err := toDocument(update)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(document) == 0 || !strings.HasPrefix(document[0].Key, "$") {
+		if err := e.encryptDocument(namespace, document, fields); err != nil {
+			return nil, err
+		}
+		return e.db.Update(databaseName, collectionName, filter, document)
+	}
+
+	for _, operator := range document {
+		values, _ := operator.Value.(bson.D)
+		for i, element := range values {
+			field := strings.SplitN(element.Key, ".", 2)[0]
+			if !fields[field] {
+				continue
+			}
+
+			switch {
+			case (operator.Key == "$set" || operator.Key == "$setOnInsert") && element.Key == field:
+				value, err := e.encrypt(namespace, field, element.Value)
+				if err != nil {
+					return nil, err
+				}
+				values[i].Value = value
+			case operator.Key == "$unset" && element.Key == field:
+			default:
+				return nil, fmt.Errorf("Unsupported operator %s on encrypted field %q", operator.Key, element.Key)
+			}
+		}
+	}
+
+	return e.db.Update(databaseName, collectionName, filter, document)
+}
+
+// Delete the documents matching filter
+func (e *EncryptedDatabase) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	return e.db.Delete(databaseName, collectionName, filter)
+}
+
+// Rotate re-encrypt with the current key the encrypted fields of the documents matching filter written with another
+// key and return the number of documents updated, the previous keys can be retired once it completes. A document is
+// only updated while its fields still hold the ciphertexts read, so a concurrent write is not overwritten and its
+// fields are rotated by the next Rotate.
+func (e *EncryptedDatabase) Rotate(databaseName, collectionName string, filter interface{}) (int64, error) {
+	namespace := databaseName + "." + collectionName
+	fields := e.collections[namespace]
+	if len(fields) == 0 {
+		return 0, nil
+	}
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	currentKeyID, _, err := e.keys.CurrentKey()
+	if err != nil {
+		return 0, err
+	}
+
+	documents, err := e.readDocuments(databaseName, collectionName, filter, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	var rotated int64
+	for _, document := range documents {
+		var id interface{}
+		set := bson.D{}
+		rotatedFilter := bson.D{}
+		for _, element := range document {
+			if element.Key == "_id" {
+				id = element.Value
+			}
+			keyID, ok := getEncryptionKeyID(element.Value)
+			if !fields[element.Key] || !ok || keyID == currentKeyID {
+				continue
+			}
+
+			value, err := e.decrypt(namespace, element.Key, element.Value)
+			if err != nil {
+				return rotated, err
+			}
+			if value, err = e.encrypt(namespace, element.Key, value); err != nil {
+				return rotated, err
+			}
+			set = append(set, primitive.E{Key: element.Key, Value: value})
+			rotatedFilter = append(rotatedFilter, primitive.E{Key: element.Key, Value: element.Value})
+		}
+		if len(set) == 0 || id == nil {
+			continue
+		}
+
+		rotatedFilter = append(bson.D{primitive.E{Key: "_id", Value: id}}, rotatedFilter...)
+		result, err := e.db.Update(databaseName, collectionName, rotatedFilter, bson.M{"$set": set})
+		if err != nil {
+			return rotated, err
+		}
+		if updateResult, ok := result.(*mongo.UpdateResult); ok && updateResult.MatchedCount == 0 {
+			continue
+		}
+		rotated++
+	}
+
+	return rotated, nil
+}
+
+// readDocuments return the documents matching filter as bson.D
+func (e *EncryptedDatabase) readDocuments(databaseName, collectionName string, filter interface{}, limit int64) ([]bson.D, error) {
+	results, err := e.db.Read(databaseName, collectionName, filter, limit, reflect.TypeOf(bson.D{}))
+	if err != nil {
+		return nil, err
+	}
+
+	documents, ok := results.(*[]bson.D)
+	if !ok {
+		return nil, errors.New("Unable to map results to bson.D")
+	}
+
+	return *documents, nil
+}
+
+// encryptDocument encrypt the values of the encrypted fields of document in place
+func (e *EncryptedDatabase) encryptDocument(namespace string, document bson.D, fields map[string]bool) error {
+	for i, element := range document {
+		if !fields[element.Key] {
+			continue
+		}
+
+		value, err := e.encrypt(namespace, element.Key, element.Value)
+		if err != nil {
+			return err
+		}
+		document[i].Value = value
+	}
+
+	return nil
+}
+
+// decryptDocument decrypt the encrypted values of the fields of document in place
+func (e *EncryptedDatabase) decryptDocument(namespace string, document bson.D, fields map[string]bool) error {
+	for i, element := range document {
+		if !fields[element.Key] {
+			continue
+		}
+
+		value, err := e.decrypt(namespace, element.Key, element.Value)
+		if err != nil {
+			return fmt.Errorf("Unable to decrypt field %q: %v", element.Key, err)
+		}
+		document[i].Value = value
+	}
+
+	return nil
+}
+
+// encrypt return the encrypted binary of value with the current key, null is returned as is
+func (e *EncryptedDatabase) encrypt(namespace, field string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	plaintext, err := bson.Marshal(bson.D{primitive.E{Key: "v", Value: value}})
+	if err != nil {
+		return nil, err
+	}
+
+	keyID, key, err := e.keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := e.getCipher(keyID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	data := append([]byte{encryptedVersion, byte(len(keyID))}, keyID...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	data = append(data, nonce...)
+
+	return primitive.Binary{Subtype: encryptedSubtype, Data: aead.Seal(data, nonce, plaintext, []byte(namespace+"."+field))}, nil
+}
+
+// decrypt return the value of the encrypted binary value, the other values are returned as is
+func (e *EncryptedDatabase) decrypt(namespace, field string, value interface{}) (interface{}, error) {
+	keyID, ok := getEncryptionKeyID(value)
+	if !ok {
+		return value, nil
+	}
+
+	key, err := e.keys.Key(keyID)
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", keyID, err)
+	}
+	aead, err := e.getCipher(keyID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	data := value.(primitive.Binary).Data[2+len(keyID):]
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(namespace+"."+field))
+	if err != nil {
+		return nil, err
+	}
+
+	var wrapper bson.D
+	if err := bson.Unmarshal(plaintext, &wrapper); err != nil || len(wrapper) != 1 {
+		return nil, errors.New("plaintext is corrupted")
+	}
+
+	return wrapper[0].Value, nil
+}
+
+// getCipher return the AES-GCM cipher of the key, cached by key ID
+func (e *EncryptedDatabase) getCipher(keyID string, key []byte) (cipher.AEAD, error) {
+	e.mu.RLock()
+	aead, ok := e.ciphers[keyID]
+	e.mu.RUnlock()
+	if ok {
+		return aead, nil
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if aead, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.ciphers[keyID] = aead
+
+	return aead, nil
+}
+
+// getEncryptionKeyID return the key ID of the encrypted binary value
+func getEncryptionKeyID(value interface{}) (string, bool) {
+	binary, ok := value.(primitive.Binary)
+	if !ok || binary.Subtype != encryptedSubtype || len(binary.Data) < 2 || binary.Data[0] != encryptedVersion {
+		return "", false
+	}
+
+	size := int(binary.Data[1])
+	if len(binary.Data) < 2+size {
+		return "", false
+	}
+
+	return string(binary.Data[2 : 2+size]), true
+}
+
+// getEncryptedFields add the fields of structType tagged with encrypt to fields by their bson name
+func getEncryptedFields(structType reflect.Type, fields map[string]bool) error {
+	var errs *multierror.Error
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("bson"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		for _, flag := range tag[1:] {
+			if flag == "inline" && fieldType.Kind() == reflect.Struct {
+				if err := getEncryptedFields(fieldType, fields); err != nil {
+					errs = multierror.Append(errs, err)
+				}
+			}
+		}
+
+		switch field.Tag.Get("encrypt") {
+		case "":
+			continue
+		case "true":
+		default:
+			errs = multierror.Append(errs, fmt.Errorf("encryption: encrypt tag of field %s must be true", field.Name))
+			continue
+		}
+		if name == "_id" {
+			errs = multierror.Append(errs, errors.New("encryption: _id can not be encrypted"))
+			continue
+		}
+		fields[name] = true
+	}
+
+	return errs.ErrorOrNil()
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// encryptedTestModel is the model of the encrypted collection of the tests
+type encryptedTestModel struct {
+	ID   string `bson:"_id"`
+	Name string `bson:"name"`
+	SSN  string `bson:"ssn" encrypt:"true"`
+	Card bson.M `bson:"card" encrypt:"true"`
+}
+
+// newEncryptedTestDatabase return an encrypted database of app.users in an embedded database, with the keys of keys
+func newEncryptedTestDatabase(t *testing.T, current string, keys map[string][]byte) (*EncryptedDatabase, *EmbeddedClient) {
+	t.Helper()
+
+	embedded, err := NewEmbeddedClient(&Embedded{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewEmbeddedClient() error = %v", err)
+	}
+	t.Cleanup(func() { embedded.Close() })
+
+	provider, err := NewStaticKeyProvider(current, keys)
+	if err != nil {
+		t.Fatalf("NewStaticKeyProvider() error = %v", err)
+	}
+	encrypted, err := NewEncryptedDatabase(embedded, provider, EncryptedCollection{Database: "app", Collection: "users", Model: encryptedTestModel{}})
+	if err != nil {
+		t.Fatalf("NewEncryptedDatabase() error = %v", err)
+	}
+
+	return encrypted, embedded
+}
+
+func TestEncryptedDatabaseEncryptDecrypt(t *testing.T) {
+	encrypted, _ := newEncryptedTestDatabase(t, "k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{name: "string", value: "123-45-6789"},
+		{name: "integer", value: int32(42)},
+		{name: "document", value: bson.D{{Key: "number", Value: "4111"}, {Key: "cvc", Value: int32(123)}}},
+		{name: "array", value: bson.A{"a", "b"}},
+		{name: "empty string", value: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ciphertext, err := encrypted.encrypt("app.users", "ssn", test.value)
+			if err != nil {
+				t.Fatalf("encrypt() error = %v", err)
+			}
+			if keyID, ok := getEncryptionKeyID(ciphertext); !ok || keyID != "k1" {
+				t.Fatalf("getEncryptionKeyID() = %q, %v, want k1, true", keyID, ok)
+			}
+
+			again, err := encrypted.encrypt("app.users", "ssn", test.value)
+			if err != nil {
+				t.Fatalf("encrypt() error = %v", err)
+			}
+			if reflect.DeepEqual(ciphertext, again) {
+				t.Error("encrypt() returned the same ciphertext twice, want random nonces")
+			}
+
+			got, err := encrypted.decrypt("app.users", "ssn", ciphertext)
+			if err != nil {
+				t.Fatalf("decrypt() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, test.value) {
+				t.Errorf("decrypt() = %#v, want %#v", got, test.value)
+			}
+		})
+	}
+}
+
+func TestEncryptedDatabaseDecryptErrors(t *testing.T) {
+	encrypted, _ := newEncryptedTestDatabase(t, "k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 16)})
+
+	ciphertext, err := encrypted.encrypt("app.users", "ssn", "123-45-6789")
+	if err != nil {
+		t.Fatalf("encrypt() error = %v", err)
+	}
+	binary := ciphertext.(primitive.Binary)
+	tampered := primitive.Binary{Subtype: binary.Subtype, Data: append([]byte(nil), binary.Data...)}
+	tampered.Data[len(tampered.Data)-1] ^= 0xff
+	unknownKey := primitive.Binary{Subtype: encryptedSubtype, Data: append([]byte{encryptedVersion, 2}, "k9"...)}
+
+	tests := []struct {
+		name      string
+		namespace string
+		field     string
+		value     interface{}
+		err       error
+	}{
+		{name: "other field", namespace: "app.users", field: "card", value: ciphertext},
+		{name: "other namespace", namespace: "app.admins", field: "ssn", value: ciphertext},
+		{name: "tampered ciphertext", namespace: "app.users", field: "ssn", value: tampered},
+		{name: "unknown key", namespace: "app.users", field: "ssn", value: unknownKey, err: ErrUnknownKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := encrypted.decrypt(test.namespace, test.field, test.value)
+			if err == nil {
+				t.Fatal("decrypt() error = nil, want an error")
+			}
+			if test.err != nil && !errors.Is(err, test.err) {
+				t.Errorf("decrypt() error = %v, want %v", err, test.err)
+			}
+		})
+	}
+
+	t.Run("plain values are returned as is", func(t *testing.T) {
+		got, err := encrypted.decrypt("app.users", "ssn", "plain")
+		if err != nil || got != "plain" {
+			t.Errorf("decrypt() = %v, %v, want plain, nil", got, err)
+		}
+	})
+}
+
+func TestEncryptedDatabaseRotate(t *testing.T) {
+	keys := map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32), "k2": bytes.Repeat([]byte{2}, 32)}
+	encrypted, embedded := newEncryptedTestDatabase(t, "k1", keys)
+
+	users := []interface{}{
+		encryptedTestModel{ID: "1", Name: "alice", SSN: "111-11-1111", Card: bson.M{"number": "4111"}},
+		encryptedTestModel{ID: "2", Name: "bob", SSN: "222-22-2222", Card: bson.M{"number": "5500"}},
+	}
+	if _, err := encrypted.Create("app", "users", users); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	rotatedKeys, err := NewStaticKeyProvider("k2", keys)
+	if err != nil {
+		t.Fatalf("NewStaticKeyProvider() error = %v", err)
+	}
+	encrypted.keys = rotatedKeys
+
+	tests := []struct {
+		name    string
+		filter  interface{}
+		rotated int64
+	}{
+		{name: "filtered documents", filter: bson.M{"name": "alice"}, rotated: 1},
+		{name: "remaining documents", filter: nil, rotated: 1},
+		{name: "nothing left to rotate", filter: nil, rotated: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rotated, err := encrypted.Rotate("app", "users", test.filter)
+			if err != nil {
+				t.Fatalf("Rotate() error = %v", err)
+			}
+			if rotated != test.rotated {
+				t.Errorf("Rotate() = %d, want %d", rotated, test.rotated)
+			}
+		})
+	}
+
+	stored, err := embedded.Read("app", "users", bson.M{}, 0, reflect.TypeOf(bson.D{}))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	for _, document := range *stored.(*[]bson.D) {
+		for _, field := range []string{"ssn", "card"} {
+			value, _ := getElement(document, field)
+			if keyID, ok := getEncryptionKeyID(value); !ok || keyID != "k2" {
+				t.Errorf("key ID of %s = %q, %v, want k2, true", field, keyID, ok)
+			}
+		}
+	}
+
+	results, err := encrypted.Read("app", "users", bson.M{}, 0, reflect.TypeOf(encryptedTestModel{}))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	got := *results.(*[]encryptedTestModel)
+	if len(got) != len(users) {
+		t.Fatalf("Read() returned %d documents, want %d", len(got), len(users))
+	}
+	for i, user := range users {
+		if !reflect.DeepEqual(got[i], user) {
+			t.Errorf("Read()[%d] = %+v, want %+v", i, got[i], user)
+		}
+	}
+}
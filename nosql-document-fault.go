@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// FaultLatency delay the operation
+	FaultLatency = "latency"
+	// FaultTransient fail the operation with a retryable error, the operation is not run
+	FaultTransient = "transient"
+	// FaultDuplicateKey fail Create and Update with a duplicate key error, the operation is not run
+	FaultDuplicateKey = "duplicateKey"
+	// FaultPartial run part of the operation and fail, Create insert the first documents only and Update and Delete
+	// are applied before failing like a lost acknowledgement, reads fail like FaultTransient
+	FaultPartial = "partial"
+	// primarySteppedDownCode is the code of the transient errors injected by FaultInjector
+	primarySteppedDownCode = 189
+)
+
+const (
+	// OperationCreate is the operation name of Create in faults
+	OperationCreate = "create"
+	// OperationRead is the operation name of Read in faults
+	OperationRead = "read"
+	// OperationUpdate is the operation name of Update in faults
+	OperationUpdate = "update"
+	// OperationDelete is the operation name of Delete in faults
+	OperationDelete = "delete"
+	// OperationAggregate is the operation name of Aggregate in faults
+	OperationAggregate = "aggregate"
+)
+
+var (
+	// ErrInjectedFault is the message of the errors injected by FaultInjector, the transient errors wrap it
+	ErrInjectedFault = errors.New("Injected fault")
+)
+
+// Fault model for a fault injected by FaultInjector in the matching operations
+type Fault struct {
+	Kind        string                                                    // latency, transient, duplicateKey or partial
+	Operations  []string                                                  // create, read, update, delete or aggregate, every operation when it is empty
+	Database    string                                                    // every database when it is empty
+	Collection  string                                                    // every collection when it is empty
+	Probability float64                                                   // chance of injection of each matching operation, 0 means always
+	Match       func(operation, databaseName, collectionName string) bool // optional rule on top of the fields
+	Latency     time.Duration                                             // delay of latency faults
+	Times       int                                                       // maximum number of injections, 0 means no maximum
+}
+
+// FaultInjector decorate INoSQLDocument with faults injected by probability or rule, to test the retry and resilience
+// logic of consumers. Faults are checked in order, latency faults add up and the first error fault stops the others.
+type FaultInjector struct {
+	db INoSQLDocument
+	*faultInjection
+}
+
+// faultInjection private model for the faults and the injections of a fault injector, shared by its transaction handles
+type faultInjection struct {
+	mu       sync.Mutex
+	random   *rand.Rand
+	faults   []Fault
+	injected []int
+	enabled  bool
+}
+
+// NewFaultInjector init new enabled fault injector, seed makes the injections reproducible
+func NewFaultInjector(db INoSQLDocument, seed int64, faults ...Fault) (*FaultInjector, error) {
+	var errs *multierror.Error
+	for _, fault := range faults {
+		switch fault.Kind {
+		case FaultLatency, FaultTransient, FaultDuplicateKey, FaultPartial:
+		default:
+			errs = multierror.Append(errs, fmt.Errorf("fault: unknown kind %q", fault.Kind))
+		}
+		if fault.Probability < 0 || fault.Probability > 1 {
+			errs = multierror.Append(errs, errors.New("fault: probability must be between 0 and 1"))
+		}
+		if fault.Kind == FaultLatency && fault.Latency <= 0 {
+			errs = multierror.Append(errs, errors.New("fault: latency must be positive"))
+		}
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+
+	return &FaultInjector{db: db, faultInjection: &faultInjection{
+		random:   rand.New(rand.NewSource(seed)),
+		faults:   faults,
+		injected: make([]int, len(faults)),
+		enabled:  true,
+	}}, nil
+}
+
+// SetEnabled enable or disable the injections, the operations reach the database untouched when it is disabled
+func (f *FaultInjector) SetEnabled(enabled bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.enabled = enabled
+}
+
+// Injected return the number of injections of each fault, in the order of the faults
+func (f *FaultInjector) Injected() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]int(nil), f.injected...)
+}
+
+// Create the documents unless a fault is injected
+func (f *FaultInjector) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	switch kind := f.inject(OperationCreate, databaseName, collectionName); kind {
+	case FaultPartial:
+		if len(documents) < 2 {
+			return nil, getInjectedError(FaultTransient)
+		}
+		f.mu.Lock()
+		count := 1 + f.random.Intn(len(documents)-1)
+		f.mu.Unlock()
+
+		result, err := f.db.Create(databaseName, collectionName, documents[:count])
+		if err != nil {
+			return nil, err
+		}
+		return result, mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{
+			Index:   count,
+			Code:    primarySteppedDownCode,
+			Message: ErrInjectedFault.Error(),
+		}}}}
+	case "":
+	default:
+		return nil, getInjectedError(kind)
+	}
+
+	return f.db.Create(databaseName, collectionName, documents)
+}
+
+// Read documents unless a fault is injected
+func (f *FaultInjector) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	if kind := f.inject(OperationRead, databaseName, collectionName); kind != "" {
+		return nil, getInjectedError(kind)
+	}
+
+	return f.db.Read(databaseName, collectionName, filter, limit, dataModel)
+}
+
+// Aggregate run the pipeline unless a fault is injected, see IAggregate
+func (f *FaultInjector) Aggregate(databaseName, collectionName string, pipeline interface{}, dataModel reflect.Type) (interface{}, error) {
+	aggregator, ok := f.db.(IAggregate)
+	if !ok {
+		return nil, errors.New("Database does not support aggregation")
+	}
+
+	if kind := f.inject(OperationAggregate, databaseName, collectionName); kind != "" {
+		return nil, getInjectedError(kind)
+	}
+
+	return aggregator.Aggregate(databaseName, collectionName, pipeline, dataModel)
+}
+
+// Update the documents matching filter unless a fault is injected
+func (f *FaultInjector) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	switch kind := f.inject(OperationUpdate, databaseName, collectionName); kind {
+	case FaultPartial:
+		if _, err := f.db.Update(databaseName, collectionName, filter, update); err != nil {
+			return nil, err
+		}
+		return nil, getInjectedError(FaultTransient)
+	case "":
+	default:
+		return nil, getInjectedError(kind)
+	}
+
+	return f.db.Update(databaseName, collectionName, filter, update)
+}
+
+// Delete the documents matching filter unless a fault is injected
+func (f *FaultInjector) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	switch kind := f.inject(OperationDelete, databaseName, collectionName); kind {
+	case FaultPartial:
+		if _, err := f.db.Delete(databaseName, collectionName, filter); err != nil {
+			return nil, err
+		}
+		return nil, getInjectedError(FaultTransient)
+	case "":
+	default:
+		return nil, getInjectedError(kind)
+	}
+
+	return f.db.Delete(databaseName, collectionName, filter)
+}
+
+// inject sleep for the matching latency faults and return the kind of the first matching error fault, empty for none
+func (f *FaultInjector) inject(operation, databaseName, collectionName string) string {
+	var latency time.Duration
+	kind := ""
+
+	f.mu.Lock()
+	if f.enabled {
+		for i, fault := range f.faults {
+			if kind != "" && fault.Kind != FaultLatency {
+				continue
+			}
+			if !fault.matches(operation, databaseName, collectionName) || (fault.Times > 0 && f.injected[i] >= fault.Times) {
+				continue
+			}
+			if fault.Probability > 0 && f.random.Float64() >= fault.Probability {
+				continue
+			}
+
+			f.injected[i]++
+			if fault.Kind == FaultLatency {
+				latency += fault.Latency
+			} else {
+				kind = fault.Kind
+			}
+		}
+	}
+	f.mu.Unlock()
+
+	time.Sleep(latency)
+
+	return kind
+}
+
+// matches return true when the fault apply to the operation
+func (f *Fault) matches(operation, databaseName, collectionName string) bool {
+	if len(f.Operations) > 0 && !containsString(f.Operations, operation) {
+		return false
+	}
+	// Only inserts and updates can violate a unique index
+	if f.Kind == FaultDuplicateKey && operation != OperationCreate && operation != OperationUpdate {
+		return false
+	}
+	if (f.Database != "" && f.Database != databaseName) || (f.Collection != "" && f.Collection != collectionName) {
+		return false
+	}
+
+	return f.Match == nil || f.Match(operation, databaseName, collectionName)
+}
+
+// getInjectedError return the error of the fault kind, shaped like the errors of the MongoDB driver
+func getInjectedError(kind string) error {
+	if kind == FaultDuplicateKey {
+		return mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{
+			Code:    duplicateKeyCode,
+			Message: "E11000 duplicate key error: " + ErrInjectedFault.Error(),
+		}}}}
+	}
+
+	return mongo.CommandError{
+		Code:    primarySteppedDownCode,
+		Message: ErrInjectedFault.Error(),
+		Labels:  []string{"TransientTransactionError", "RetryableWriteError"},
+		Name:    "PrimarySteppedDown",
+		Wrapped: ErrInjectedFault,
+	}
+}
+
+// Capabilities implement ICapabilities
+func (f *FaultInjector) Capabilities() Capabilities {
+	return getDecoratedCapabilities(f.db)
+}
+
+// RunInTransaction implement ITransactional with the transactions of the database, tx is a *FaultInjector sharing
+// the faults and the injections of f
+func (f *FaultInjector) RunInTransaction(fn func(tx interface{}) error) error {
+	return WithTransaction(f.db, func(tx interface{}) error {
+		db, ok := tx.(INoSQLDocument)
+		if !ok {
+			return ErrTransactionsUnsupported
+		}
+
+		return fn(&FaultInjector{db: db, faultInjection: f.faultInjection})
+	})
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// defaultLoaderWait is the time a Loader collect IDs before running the batch query
+	defaultLoaderWait = 2 * time.Millisecond
+	// defaultLoaderMaxBatch is the maximum number of IDs of a Loader batch query
+	defaultLoaderMaxBatch = 100
+)
+
+// Loader batch the Load calls of a short window into one GetByIDs query and memoize the results,
+// like DataLoader for GraphQL resolvers. Create one Loader per request so memoized documents don't go stale.
+type Loader[T any] struct {
+	mu         sync.Mutex
+	repository *Repository[T]
+	wait       time.Duration
+	maxBatch   int
+	cache      map[string]*loaderResult[T]
+	batch      []*loaderResult[T]
+}
+
+// loaderResult private model for the memoized result of one ID, done is closed when it is loaded
+type loaderResult[T any] struct {
+	id       string
+	done     chan struct{}
+	document *T
+	err      error
+}
+
+// NewLoader init new loader of repository documents, wait (default 2ms) is the batch window and
+// maxBatch (default 100) the maximum number of IDs per query
+func NewLoader[T any](repository *Repository[T], wait time.Duration, maxBatch int) *Loader[T] {
+	if wait <= 0 {
+		wait = defaultLoaderWait
+	}
+	if maxBatch <= 0 {
+		maxBatch = defaultLoaderMaxBatch
+	}
+
+	return &Loader[T]{repository: repository, wait: wait, maxBatch: maxBatch, cache: make(map[string]*loaderResult[T])}
+}
+
+// Load return the document based on its ID, ErrDocumentNotFound when it does not exist
+func (l *Loader[T]) Load(id string) (*T, error) {
+	result := l.enqueue(id)
+	<-result.done
+
+	return result.document, result.err
+}
+
+// LoadMany return the documents of ids in the same order, the error of a missing document is ErrDocumentNotFound
+func (l *Loader[T]) LoadMany(ids []string) ([]*T, []error) {
+	results := make([]*loaderResult[T], 0, len(ids))
+	for _, id := range ids {
+		results = append(results, l.enqueue(id))
+	}
+
+	documents := make([]*T, len(ids))
+	errs := make([]error, len(ids))
+	for i, result := range results {
+		<-result.done
+		documents[i], errs[i] = result.document, result.err
+	}
+
+	return documents, errs
+}
+
+// Clear forget the memoized document of id, the next Load query it again
+func (l *Loader[T]) Clear(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.cache, id)
+}
+
+// enqueue return the memoized result of id, adding id to the current batch when it is not loaded yet
+func (l *Loader[T]) enqueue(id string) *loaderResult[T] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if result, ok := l.cache[id]; ok {
+		return result
+	}
+
+	result := &loaderResult[T]{id: id, done: make(chan struct{})}
+	l.cache[id] = result
+	l.batch = append(l.batch, result)
+
+	switch len(l.batch) {
+	case l.maxBatch:
+		batch := l.batch
+		l.batch = nil
+		go l.dispatch(batch)
+	case 1:
+		batch := l.batch
+		time.AfterFunc(l.wait, func() {
+			l.mu.Lock()
+			// The batch has already been dispatched when it reached maxBatch
+			if len(l.batch) == 0 || l.batch[0] != batch[0] {
+				l.mu.Unlock()
+				return
+			}
+			batch := l.batch
+			l.batch = nil
+			l.mu.Unlock()
+
+			l.dispatch(batch)
+		})
+	}
+
+	return result
+}
+
+// dispatch load the batch and complete its results
+func (l *Loader[T]) dispatch(batch []*loaderResult[T]) {
+	ids := make([]string, 0, len(batch))
+	for _, result := range batch {
+		ids = append(ids, result.id)
+	}
+
+	documents, missing, err := l.repository.GetByIDs(ids)
+
+	found := 0
+	for _, result := range batch {
+		switch {
+		case err != nil:
+			result.err = err
+		case missing[result.id]:
+			result.err = ErrDocumentNotFound
+		default:
+			document := documents[found]
+			result.document = &document
+			found++
+		}
+		close(result.done)
+	}
+}
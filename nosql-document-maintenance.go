@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// defaultMaintenanceLockTTL is the lifetime of a task lock, renewed while the task runs, so the lock of a crashed
+	// instance expires
+	defaultMaintenanceLockTTL = 5 * time.Minute
+)
+
+var (
+	// ErrUnknownTask is returned when the maintenance task is not registered
+	ErrUnknownTask = errors.New("Maintenance task is not registered")
+	// ErrLockLost is returned when the lock of a running maintenance task expired or was taken by another instance
+	ErrLockLost = errors.New("Maintenance task lock is lost")
+)
+
+// MaintenanceTask model for a periodic task of MaintenanceScheduler, like a TTL sweep, an index rebuild with
+// CreateIndex or a stats collection with StatsCollector.Collect
+type MaintenanceTask struct {
+	Name    string                              // name of the task and of its lock, shared by the instances
+	Cron    string                              // standard 5 fields cron expression or descriptor like @hourly
+	Run     func(taskCtx context.Context) error // the task, run by one instance at a time, taskCtx is done when the lock is lost
+	LockTTL time.Duration                       // lifetime of the lock renewed while the task runs, default 5 minutes
+}
+
+// maintenanceLock private model for the lock of a task
+type maintenanceLock struct {
+	Name      string    `bson:"_id"`
+	Owner     string    `bson:"owner"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// maintenanceTask private model for a registered task and its metrics
+type maintenanceTask struct {
+	task    MaintenanceTask
+	running sync.Mutex
+	stats   MaintenanceTaskStats
+}
+
+// MaintenanceScheduler run maintenance tasks on schedule inside the services, without external cron. The tasks are
+// locked by a document per task in a collection of any backend refusing duplicate _id, like MongoDB or the embedded
+// database, so only one instance runs each task while the others skip it.
+type MaintenanceScheduler struct {
+	locks          INoSQLDocument
+	databaseName   string
+	collectionName string
+	owner          string
+	cron           *cron.Cron
+	mu             sync.RWMutex
+	tasks          map[string]*maintenanceTask
+}
+
+// NewMaintenanceScheduler init new scheduler locking the tasks in databaseName.collectionName of locks, register the
+// tasks then call Start to run it
+func NewMaintenanceScheduler(locks INoSQLDocument, databaseName, collectionName string) (*MaintenanceScheduler, error) {
+	if err := checkNamespace(databaseName, collectionName); err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), primitive.NewObjectID().Hex())
+
+	return &MaintenanceScheduler{
+		locks:          locks,
+		databaseName:   databaseName,
+		collectionName: collectionName,
+		owner:          owner,
+		cron:           cron.New(),
+		tasks:          make(map[string]*maintenanceTask),
+	}, nil
+}
+
+// Register the task and schedule it
+func (s *MaintenanceScheduler) Register(task MaintenanceTask) error {
+	if task.Name == "" {
+		return &InvalidArgumentError{Argument: "name", Reason: "cannot be empty"}
+	}
+	if task.Run == nil {
+		return &InvalidArgumentError{Argument: "run", Reason: "cannot be nil"}
+	}
+	if task.LockTTL <= 0 {
+		task.LockTTL = defaultMaintenanceLockTTL
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tasks[task.Name]; ok {
+		return fmt.Errorf("Maintenance task %s is already registered", task.Name)
+	}
+
+	name := task.Name
+	if _, err := s.cron.AddFunc(task.Cron, func() {
+		if _, err := s.RunTask(name); err != nil {
+			log.Printf("Unable to run maintenance task %s: %v\n", name, err)
+		}
+	}); err != nil {
+		return fmt.Errorf("Invalid cron of maintenance task %s: %v", name, err)
+	}
+	s.tasks[name] = &maintenanceTask{task: task}
+
+	return nil
+}
+
+// Start run the tasks on schedule
+func (s *MaintenanceScheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop the schedule and wait for the running tasks
+func (s *MaintenanceScheduler) Stop() {
+	<-s.cron.Stop().Done()
+}
+
+// RunTask run the task name now when no other instance holds its lock and return whether it ran. ErrLockLost is
+// returned when the lock could not be renewed while the task ran, so another instance may have run it too.
+func (s *MaintenanceScheduler) RunTask(name string) (bool, error) {
+	s.mu.RLock()
+	registered, ok := s.tasks[name]
+	s.mu.RUnlock()
+	if !ok {
+		return false, ErrUnknownTask
+	}
+
+	registered.running.Lock()
+	defer registered.running.Unlock()
+
+	acquired, err := s.lock(registered.task)
+	if err != nil || !acquired {
+		s.mu.Lock()
+		if err != nil {
+			registered.stats.LastError = err.Error()
+			registered.stats.Failures++
+		} else {
+			registered.stats.Skipped++
+		}
+		s.mu.Unlock()
+		return false, err
+	}
+
+	taskCtx, cancel := context.WithCancel(context.Background())
+	renewed := make(chan bool, 1)
+	go func() {
+		renewed <- s.renew(taskCtx, cancel, registered.task)
+	}()
+
+	start := time.Now().UTC()
+	err = registered.task.Run(taskCtx)
+	cancel()
+	if !<-renewed {
+		err = ErrLockLost
+	} else {
+		s.unlock(registered.task)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	registered.stats.LastRun = start
+	registered.stats.LastDuration = time.Since(start)
+	registered.stats.Runs++
+	if err == ErrLockLost {
+		registered.stats.LostLocks++
+	}
+	if err != nil {
+		registered.stats.LastError = err.Error()
+		registered.stats.Failures++
+		return true, err
+	}
+	registered.stats.LastError = ""
+
+	return true, nil
+}
+
+// Stats return the metrics of the tasks by name
+func (s *MaintenanceScheduler) Stats() map[string]MaintenanceTaskStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := make(map[string]MaintenanceTaskStats, len(s.tasks))
+	for name, registered := range s.tasks {
+		stats[name] = registered.stats
+	}
+
+	return stats
+}
+
+// lock create the lock of the task after deleting it when it expired, false means another instance holds it
+func (s *MaintenanceScheduler) lock(task MaintenanceTask) (bool, error) {
+	now := time.Now().UTC()
+	if _, err := s.locks.Delete(s.databaseName, s.collectionName, bson.M{"_id": task.Name, "expiresAt": bson.M{"$lt": now}}); err != nil {
+		return false, err
+	}
+
+	lock := maintenanceLock{Name: task.Name, Owner: s.owner, ExpiresAt: now.Add(task.LockTTL)}
+	if _, err := s.locks.Create(s.databaseName, s.collectionName, []interface{}{lock}); err != nil {
+		if isDuplicateKeyOnly(err) || errors.Is(err, ErrDuplicateKey) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// renew extend the lock of the task until taskCtx is done and return whether the lock was held all along. When the
+// lock is no longer held by this instance, or could not be renewed before it expired, cancel stop the task.
+func (s *MaintenanceScheduler) renew(taskCtx context.Context, cancel context.CancelFunc, task MaintenanceTask) bool {
+	ticker := time.NewTicker(task.LockTTL / 3)
+	defer ticker.Stop()
+
+	expiresAt := time.Now().UTC().Add(task.LockTTL)
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now().UTC()
+			update := bson.M{"$set": bson.M{"expiresAt": now.Add(task.LockTTL)}}
+			result, err := s.locks.Update(s.databaseName, s.collectionName, bson.M{"_id": task.Name, "owner": s.owner}, update)
+			if err != nil {
+				log.Printf("Unable to renew lock of maintenance task %s: %v\n", task.Name, err)
+				if !now.Before(expiresAt) {
+					cancel()
+					return false
+				}
+				continue
+			}
+			if updateResult, ok := result.(*mongo.UpdateResult); ok && updateResult.MatchedCount == 0 {
+				log.Printf("Unable to renew lock of maintenance task %s: %v\n", task.Name, ErrLockLost)
+				cancel()
+				return false
+			}
+			expiresAt = now.Add(task.LockTTL)
+		case <-taskCtx.Done():
+			return true
+		}
+	}
+}
+
+// unlock delete the lock of the task when it is still held by this instance
+func (s *MaintenanceScheduler) unlock(task MaintenanceTask) {
+	if _, err := s.locks.Delete(s.databaseName, s.collectionName, bson.M{"_id": task.Name, "owner": s.owner}); err != nil {
+		log.Printf("Unable to release lock of maintenance task %s: %v\n", task.Name, err)
+	}
+}
+
+// TTLSweepTask return a task deleting the documents of databaseName.collectionName whose time field is older than
+// maxAge, for the backends without TTL indexes like the embedded database
+func TTLSweepTask(db INoSQLDocument, databaseName, collectionName, field string, maxAge time.Duration) func(taskCtx context.Context) error {
+	return func(taskCtx context.Context) error {
+		if err := taskCtx.Err(); err != nil {
+			return err
+		}
+
+		cutoff := time.Now().UTC().Add(-maxAge)
+		_, err := db.Delete(databaseName, collectionName, bson.M{field: bson.M{"$lt": cutoff}})
+		return err
+	}
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxAppNameSize is the maximum size of the application name accepted by MongoDB in the handshake
+const maxAppNameSize = 128
+
+// applyAppName set the application name of config, sent in the handshake of every connection so it appears in
+// currentOp, the server logs and the Atlas metrics. The driver has no option for custom metadata, so Metadata is
+// appended to the name, like "orders-api (team=payments,version=1.4.2)".
+func applyAppName(config *MongoDB, clientOptions *options.ClientOptions) {
+	appName := config.AppName
+	if appName == "" && clientOptions.AppName != nil {
+		// Keep the appname of the connection string options
+		appName = *clientOptions.AppName
+	}
+
+	if appName = getAppName(appName, config.Metadata); appName != "" {
+		clientOptions.SetAppName(appName)
+	}
+}
+
+// getAppName return appName with metadata sorted by key
+func getAppName(appName string, metadata map[string]string) string {
+	if len(metadata) == 0 {
+		return appName
+	}
+
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + metadata[key]
+	}
+
+	return strings.TrimSpace(appName + " (" + strings.Join(pairs, ",") + ")")
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"log"
+	"path"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// archiveTimeLayout is the layout of the time in archive names, names sort by time
+	archiveTimeLayout = "20060102T150405.000000000Z"
+	// archiveExtension is the extension of archive names
+	archiveExtension = ".ndjson.gz"
+	// defaultArchiveBatchSize is the number of documents per archive file
+	defaultArchiveBatchSize = 1000
+)
+
+// Archiver move cold documents to gzip compressed NDJSON files on an object storage and restore them
+type Archiver struct {
+	client    *MongoClient
+	storage   IObjectStorage
+	prefix    string
+	batchSize int
+}
+
+// NewArchiver init new archiver writing files named <prefix><database>/<collection>/<time>.ndjson.gz,
+// each file hold up to batchSize documents, default is 1000
+func NewArchiver(client *MongoClient, objectStorage IObjectStorage, prefix string, batchSize int) *Archiver {
+	if batchSize <= 0 {
+		batchSize = defaultArchiveBatchSize
+	}
+
+	return &Archiver{client: client, storage: objectStorage, prefix: prefix, batchSize: batchSize}
+}
+
+// Archive move the documents matching filter to archive files batch by batch and return the names of the files written.
+// Each batch is read and deleted in one transaction when the deployment supports it, so only the documents written
+// to the file are deleted, the file is removed when the transaction fails.
+func (a *Archiver) Archive(databaseName, collectionName string, filter interface{}) ([]string, int64, error) {
+	var names []string
+	var count int64
+	for {
+		name, archived, err := a.archiveBatch(databaseName, collectionName, filter)
+		if err != nil {
+			log.Println("Unable to archive documents: ", err)
+			return names, count, err
+		}
+		if archived == 0 {
+			return names, count, nil
+		}
+
+		names = append(names, name)
+		count += archived
+		if archived < int64(a.batchSize) {
+			return names, count, nil
+		}
+	}
+}
+
+// archiveBatch archive the next batch of documents matching filter
+func (a *Archiver) archiveBatch(databaseName, collectionName string, filter interface{}) (string, int64, error) {
+	name := path.Join(a.prefix+databaseName, collectionName, time.Now().UTC().Format(archiveTimeLayout)+archiveExtension)
+	uploaded := false
+
+	var count int64
+	err := a.client.execute(func(sc context.Context) error {
+		count = 0
+		if uploaded {
+			// The transaction is retried, the documents of the batch can change
+			if err := a.storage.Delete(name); err != nil {
+				return err
+			}
+			uploaded = false
+		}
+
+		collection := a.client.getClient().Database(databaseName).Collection(collectionName)
+		findOptions := options.Find().SetLimit(int64(a.batchSize)).SetSort(bson.D{primitive.E{Key: "_id", Value: 1}})
+		cur, err := collection.Find(sc, filter, findOptions)
+		if err != nil {
+			return err
+		}
+
+		var documents []bson.Raw
+		if err := cur.All(sc, &documents); err != nil {
+			return err
+		}
+		if len(documents) == 0 {
+			return nil
+		}
+
+		var buffer bytes.Buffer
+		gzipWriter := gzip.NewWriter(&buffer)
+		ids := make(bson.A, 0, len(documents))
+		for _, document := range documents {
+			// Canonical Extended JSON keep the BSON types of the values
+			line, err := bson.MarshalExtJSON(document, true, false)
+			if err != nil {
+				return err
+			}
+			gzipWriter.Write(append(line, '\n'))
+			ids = append(ids, document.Lookup("_id"))
+		}
+		if err := gzipWriter.Close(); err != nil {
+			return err
+		}
+
+		if err := a.storage.Upload(name, &buffer); err != nil {
+			return err
+		}
+		uploaded = true
+
+		result, err := collection.DeleteMany(sc, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return err
+		}
+		count = result.DeletedCount
+
+		return nil
+	})
+	if err != nil && uploaded {
+		if deleteErr := a.storage.Delete(name); deleteErr != nil {
+			log.Println("Unable to delete archive file of failed batch: ", deleteErr)
+		}
+	}
+
+	return name, count, err
+}
+
+// List return the names of the archive files of the collection, oldest first
+func (a *Archiver) List(databaseName, collectionName string) ([]string, error) {
+	return a.storage.List(path.Join(a.prefix+databaseName, collectionName) + "/")
+}
+
+// Restore insert the documents of the archive file name back into the collection, documents already
+// in the collection are reported as rejected
+func (a *Archiver) Restore(databaseName, collectionName, name string) (*ImportReport, error) {
+	file, err := a.storage.Download(name)
+	if err != nil {
+		log.Println("Unable to download archive file: ", err)
+		return nil, err
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		log.Println("Unable to read archive file: ", err)
+		return nil, err
+	}
+	defer gzipReader.Close()
+
+	report, err := a.client.Import(databaseName, collectionName, gzipReader, &ImportOptions{Format: ImportNDJSON, BatchSize: a.batchSize})
+	if err != nil {
+		return report, err
+	}
+	if report.Inserted == 0 && len(report.Rejected) > 0 {
+		return report, errors.New("Unable to restore archive file: " + report.Rejected[0].Error)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UpdateArrayElement update the elements of embedded arrays without rewriting the documents matching filter.
+// update use the positional operators: $ for the first element matched by filter, like
+// filter bson.M{"_id": id, "items.sku": "A1"} and update bson.M{"$set": bson.M{"items.$.quantity": 2}},
+// or $[<identifier>] for the elements matched by arrayFilters, like update bson.M{"$inc": bson.M{"items.$[item].quantity": 1}}
+// and arrayFilters []interface{}{bson.M{"item.sku": "A1"}}.
+func (m *MongoClient) UpdateArrayElement(databaseName, collectionName string, filter, update interface{}, arrayFilters []interface{}) (interface{}, error) {
+	release, err := m.admit(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	updateOptions := options.Update()
+	if len(arrayFilters) > 0 {
+		updateOptions.SetArrayFilters(options.ArrayFilters{Filters: arrayFilters})
+	}
+
+	var result interface{}
+	timeout := m.getTimeout(OperationUpdate, databaseName, collectionName)
+	if err := m.execute(func(sc context.Context) (err error) {
+		sc, cancel := withTimeout(sc, timeout)
+		defer cancel()
+
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+		result, err = collection.UpdateMany(sc, filter, update, updateOptions)
+		if err != nil {
+			log.Println("Unable to update array element: ", err)
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		log.Println("Unable to execute MongoDB operation: ", err)
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,352 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// AuditCreate is the operation of the audit entries written by Create
+	AuditCreate = "create"
+	// AuditUpdate is the operation of the audit entries written by Update
+	AuditUpdate = "update"
+	// AuditDelete is the operation of the audit entries written by Delete
+	AuditDelete = "delete"
+	// defaultAuditCollection is the default collection of the audit entries
+	defaultAuditCollection = "audit"
+)
+
+// auditActorKey is the context key of the actor recorded by AuditedDatabase
+type auditActorKey struct{}
+
+// AuditQuery model for FindAudit conditions, zero values match every entry
+type AuditQuery struct {
+	Collection string
+	DocumentID interface{}
+	Actor      string
+	Operation  string    // create, update or delete
+	From       time.Time // inclusive
+	To         time.Time // exclusive
+	Limit      int64
+}
+
+// WithActor return a context carrying actor, bind it with AuditedDatabase.For so the writes of the handle record it
+func WithActor(parent context.Context, actor string) context.Context {
+	return context.WithValue(parent, auditActorKey{}, actor)
+}
+
+// GetActor return the actor carried by the context, empty when there is none
+func GetActor(ctx context.Context) string {
+	actor, _ := ctx.Value(auditActorKey{}).(string)
+	return actor
+}
+
+// AuditedDatabase decorate MongoClient with an audit trail, Create, Update and Delete write one audit entry per
+// written document with the actor of the context and the before and after images of the document, in the audit
+// collection of the database. The entries and the writes run in one transaction when the deployment supports them.
+// Update and Delete read the matching documents first, so they hold their images in memory. The actor is bound per
+// request with For, the entries written without it have no actor.
+type AuditedDatabase struct {
+	client     *MongoClient
+	collection string
+	actor      string
+}
+
+// NewAuditedDatabase init new audited database writing entries to auditCollection of each database, default audit
+func NewAuditedDatabase(client *MongoClient, auditCollection string) *AuditedDatabase {
+	if auditCollection == "" {
+		auditCollection = defaultAuditCollection
+	}
+
+	return &AuditedDatabase{client: client, collection: auditCollection}
+}
+
+// For return a handle running the operations with reqCtx and recording the actor it carries, see WithActor
+func (a *AuditedDatabase) For(reqCtx context.Context) *AuditedDatabase {
+	return &AuditedDatabase{client: a.client.WithContext(reqCtx), collection: a.collection, actor: GetActor(reqCtx)}
+}
+
+// Create the documents and their audit entries, documents without _id get a new ObjectID
+func (a *AuditedDatabase) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	if len(documents) == 0 {
+		return nil, &InvalidArgumentError{Argument: "documents", Reason: "cannot be empty"}
+	}
+
+	release, err := a.client.admit(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	values := make([]interface{}, 0, len(documents))
+	entries := make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		value, err := toDocument(document)
+		if err != nil {
+			return nil, err
+		}
+
+		id, ok := getElement(value, "_id")
+		if !ok {
+			id = primitive.NewObjectID()
+			value = append(bson.D{{Key: "_id", Value: id}}, value...)
+		}
+
+		values = append(values, value)
+		entries = append(entries, a.newEntry(databaseName, collectionName, AuditCreate, id, nil, value.Map()))
+	}
+
+	var result interface{}
+	if err := a.client.execute(func(sc context.Context) (err error) {
+		database := a.client.getClient().Database(databaseName)
+		if result, err = database.Collection(collectionName).InsertMany(sc, values); err != nil {
+			return err
+		}
+
+		_, err = database.Collection(a.collection).InsertMany(sc, entries)
+		return err
+	}); err != nil {
+		log.Println("Unable to create document: ", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Read documents from collection based on filter
+func (a *AuditedDatabase) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	return a.client.Read(databaseName, collectionName, filter, limit, dataModel)
+}
+
+// Update the documents matching filter and write the audit entries of the modified ones
+func (a *AuditedDatabase) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	if err := checkNotNil("filter", filter); err != nil {
+		return nil, err
+	}
+	if err := checkNotNil("update", update); err != nil {
+		return nil, err
+	}
+
+	release, err := a.client.admit(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var result interface{}
+	if err := a.client.execute(func(sc context.Context) (err error) {
+		collection := a.client.getClient().Database(databaseName).Collection(collectionName)
+
+		before, ids, err := a.getImages(sc, collection, filter)
+		if err != nil || len(ids) == 0 {
+			result = &mongo.UpdateResult{}
+			return err
+		}
+
+		idFilter := bson.M{"_id": bson.M{"$in": ids}}
+		if result, err = collection.UpdateMany(sc, bson.M{"$and": bson.A{filter, idFilter}}, update); err != nil {
+			return err
+		}
+
+		after, _, err := a.getImages(sc, collection, idFilter)
+		if err != nil {
+			return err
+		}
+
+		entries := make([]interface{}, 0, len(ids))
+		for _, id := range ids {
+			key := getValueKey(id)
+			// Matched documents left unchanged by the update are not audited
+			if reflect.DeepEqual(before[key], after[key]) {
+				continue
+			}
+			entries = append(entries, a.newEntry(databaseName, collectionName, AuditUpdate, id, before[key], after[key]))
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		_, err = collection.Database().Collection(a.collection).InsertMany(sc, entries)
+		return err
+	}); err != nil {
+		log.Println("Unable to update: ", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Delete the documents matching filter and write their audit entries
+func (a *AuditedDatabase) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	if err := checkNotNil("filter", filter); err != nil {
+		return nil, err
+	}
+
+	release, err := a.client.admit(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var result interface{}
+	if err := a.client.execute(func(sc context.Context) (err error) {
+		collection := a.client.getClient().Database(databaseName).Collection(collectionName)
+
+		before, ids, err := a.getImages(sc, collection, filter)
+		if err != nil || len(ids) == 0 {
+			result = &mongo.DeleteResult{}
+			return err
+		}
+
+		if result, err = collection.DeleteMany(sc, bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$in": ids}}}}); err != nil {
+			return err
+		}
+
+		entries := make([]interface{}, 0, len(ids))
+		for _, id := range ids {
+			entries = append(entries, a.newEntry(databaseName, collectionName, AuditDelete, id, before[getValueKey(id)], nil))
+		}
+
+		_, err = collection.Database().Collection(a.collection).InsertMany(sc, entries)
+		return err
+	}); err != nil {
+		log.Println("Unable to delete: ", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// AuditTrail return the audit entries of the document, oldest first
+func (a *AuditedDatabase) AuditTrail(databaseName, collectionName string, id interface{}) ([]AuditEntry, error) {
+	return a.FindAudit(databaseName, &AuditQuery{Collection: collectionName, DocumentID: id})
+}
+
+// FindAudit return the audit entries of the database matching query, oldest first
+func (a *AuditedDatabase) FindAudit(databaseName string, query *AuditQuery) ([]AuditEntry, error) {
+	if query == nil {
+		query = &AuditQuery{}
+	}
+	if err := checkLimit(query.Limit); err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{}
+	if query.Collection != "" {
+		filter["collection"] = query.Collection
+	}
+	if query.DocumentID != nil {
+		filter["documentId"] = query.DocumentID
+	}
+	if query.Actor != "" {
+		filter["actor"] = query.Actor
+	}
+	if query.Operation != "" {
+		filter["operation"] = query.Operation
+	}
+	timestamp := bson.M{}
+	if !query.From.IsZero() {
+		timestamp["$gte"] = query.From
+	}
+	if !query.To.IsZero() {
+		timestamp["$lt"] = query.To
+	}
+	if len(timestamp) > 0 {
+		filter["timestamp"] = timestamp
+	}
+
+	release, err := a.client.admit(databaseName, a.collection)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var entries []AuditEntry
+	if err := a.client.executeWithoutTransaction(func(sc context.Context) error {
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "_id", Value: 1}}).
+			SetLimit(query.Limit)
+
+		collection := a.client.getClient().Database(databaseName).Collection(a.collection)
+		cur, err := collection.Find(sc, filter, findOptions)
+		if err != nil {
+			return err
+		}
+
+		return cur.All(sc, &entries)
+	}); err != nil {
+		log.Println("Unable to find audit entries: ", err)
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// CreateAuditIndexes create the indexes of AuditTrail and FindAudit on the audit collection of the database
+func (a *AuditedDatabase) CreateAuditIndexes(databaseName string) error {
+	for _, keys := range []bson.D{
+		{{Key: "collection", Value: 1}, {Key: "documentId", Value: 1}, {Key: "timestamp", Value: 1}},
+		{{Key: "actor", Value: 1}, {Key: "timestamp", Value: 1}},
+		{{Key: "timestamp", Value: 1}},
+	} {
+		if _, err := a.client.CreateIndex(databaseName, a.collection, keys, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getImages return the documents matching filter by getValueKey of their _id, and their _id in ascending order
+func (a *AuditedDatabase) getImages(sc context.Context, collection *mongo.Collection, filter interface{}) (map[string]bson.M, []interface{}, error) {
+	cur, err := collection.Find(sc, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cur.Close(sc)
+
+	images := map[string]bson.M{}
+	var ids []interface{}
+	for cur.Next(sc) {
+		var document bson.M
+		if err := cur.Decode(&document); err != nil {
+			return nil, nil, err
+		}
+		images[getValueKey(document["_id"])] = document
+		ids = append(ids, document["_id"])
+	}
+
+	return images, ids, cur.Err()
+}
+
+// newEntry return the audit entry of a written document with the actor of the handle
+func (a *AuditedDatabase) newEntry(databaseName, collectionName, operation string, id interface{}, before, after bson.M) AuditEntry {
+	return AuditEntry{
+		Database:   databaseName,
+		Collection: collectionName,
+		Operation:  operation,
+		DocumentID: id,
+		Actor:      a.actor,
+		Before:     before,
+		After:      after,
+		Timestamp:  time.Now(),
+	}
+}
+
+// getElement return the value of the top-level key of document
+func getElement(document bson.D, key string) (interface{}, bool) {
+	for _, element := range document {
+		if element.Key == key {
+			return element.Value, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BackfillTransform return the update of document, like bson.M{"$set": bson.M{"fullName": ...}}, or nil to skip it
+type BackfillTransform func(document bson.M) (interface{}, error)
+
+// BackfillOptions model for Backfill
+type BackfillOptions struct {
+	Filter       interface{}                           // documents to backfill, all by default
+	BatchSize    int                                   // documents read and updated per batch, default 1000
+	PauseBetween time.Duration                         // sleep after each batch so large backfills don't hold the cluster
+	Checkpoint   ICheckpoint                           // save the progress after each batch, and resume from it
+	Name         string                                // checkpoint name, required with Checkpoint
+	Progress     func(progress BackfillProgress) error // called after each batch, an error stops the backfill
+}
+
+// Backfill apply a transform to every document of a collection by ranges of _id, like to fill a new field from the
+// existing ones. The updates of a batch are written at once, and the progress is saved after each batch so a crashed
+// backfill resume after the last processed _id. The transform may run again on the documents of the interrupted batch.
+type Backfill struct {
+	client          *MongoClient
+	databaseName    string
+	collectionName  string
+	transform       BackfillTransform
+	backfillOptions BackfillOptions
+}
+
+// backfillCheckpoint private model of the checkpoint token of a backfill
+type backfillCheckpoint struct {
+	LastID    interface{} `bson:"_id"`
+	Processed int64       `bson:"processed"`
+	Updated   int64       `bson:"updated"`
+	Skipped   int64       `bson:"skipped"`
+	Batches   int64       `bson:"batches"`
+}
+
+// NewBackfill init new backfill of databaseName.collectionName with transform
+func NewBackfill(client *MongoClient, databaseName, collectionName string, transform BackfillTransform, backfillOptions BackfillOptions) (*Backfill, error) {
+	if err := checkNamespace(databaseName, collectionName); err != nil {
+		return nil, err
+	}
+	if transform == nil {
+		return nil, &InvalidArgumentError{Argument: "transform", Reason: "cannot be nil"}
+	}
+	if backfillOptions.Checkpoint != nil && backfillOptions.Name == "" {
+		return nil, &InvalidArgumentError{Argument: "name", Reason: "required with a checkpoint"}
+	}
+	if backfillOptions.BatchSize <= 0 {
+		backfillOptions.BatchSize = defaultImportBatchSize
+	}
+	if backfillOptions.Filter == nil {
+		backfillOptions.Filter = bson.M{}
+	}
+
+	return &Backfill{
+		client:          client,
+		databaseName:    databaseName,
+		collectionName:  collectionName,
+		transform:       transform,
+		backfillOptions: backfillOptions,
+	}, nil
+}
+
+// Run the backfill until every document is processed, from the checkpoint when one is saved
+func (b *Backfill) Run() (*BackfillProgress, error) {
+	progress := &BackfillProgress{StartedAt: time.Now()}
+	if err := b.load(progress); err != nil {
+		log.Println("Unable to load backfill checkpoint: ", err)
+		return nil, err
+	}
+
+	remaining, err := b.count(progress.LastID)
+	if err != nil {
+		log.Println("Unable to count backfill documents: ", err)
+		return nil, err
+	}
+	progress.Total = progress.Processed + remaining
+	resumed := progress.Processed
+
+	for {
+		if err := b.client.waitHealthy(); err != nil {
+			return progress, err
+		}
+
+		documents, err := b.readBatch(progress.LastID)
+		if err != nil {
+			log.Println("Unable to read backfill batch: ", err)
+			return progress, err
+		}
+		if len(documents) == 0 {
+			progress.ETA = 0
+			return progress, nil
+		}
+
+		updated, err := b.writeBatch(documents)
+		if err != nil {
+			log.Println("Unable to write backfill batch: ", err)
+			return progress, err
+		}
+
+		progress.Batches++
+		progress.Processed += int64(len(documents))
+		progress.Updated += updated
+		progress.Skipped += int64(len(documents)) - updated
+		progress.LastID = documents[len(documents)-1]["_id"]
+		if progress.Processed > progress.Total {
+			// Documents created during the backfill
+			progress.Total = progress.Processed
+		}
+		if elapsed := time.Since(progress.StartedAt).Seconds(); elapsed > 0 {
+			progress.Rate = float64(progress.Processed-resumed) / elapsed
+		}
+		if progress.Rate > 0 {
+			progress.ETA = time.Duration(float64(progress.Total-progress.Processed) / progress.Rate * float64(time.Second))
+		}
+
+		if err := b.save(progress); err != nil {
+			log.Println("Unable to save backfill checkpoint: ", err)
+			return progress, err
+		}
+
+		if b.backfillOptions.Progress != nil {
+			if err := b.backfillOptions.Progress(*progress); err != nil {
+				return progress, err
+			}
+		}
+
+		if len(documents) < b.backfillOptions.BatchSize {
+			progress.ETA = 0
+			return progress, nil
+		}
+		if b.backfillOptions.PauseBetween > 0 {
+			time.Sleep(b.backfillOptions.PauseBetween)
+		}
+	}
+}
+
+// load the progress of the previous runs from the checkpoint
+func (b *Backfill) load(progress *BackfillProgress) error {
+	if b.backfillOptions.Checkpoint == nil {
+		return nil
+	}
+
+	token, err := b.backfillOptions.Checkpoint.Load(b.backfillOptions.Name)
+	if err != nil || token == nil {
+		return err
+	}
+
+	var checkpoint backfillCheckpoint
+	if err := bson.Unmarshal(token, &checkpoint); err != nil {
+		return err
+	}
+	progress.LastID = checkpoint.LastID
+	progress.Processed = checkpoint.Processed
+	progress.Updated = checkpoint.Updated
+	progress.Skipped = checkpoint.Skipped
+	progress.Batches = checkpoint.Batches
+
+	return nil
+}
+
+// save the progress in the checkpoint
+func (b *Backfill) save(progress *BackfillProgress) error {
+	if b.backfillOptions.Checkpoint == nil {
+		return nil
+	}
+
+	token, err := bson.Marshal(backfillCheckpoint{
+		LastID:    progress.LastID,
+		Processed: progress.Processed,
+		Updated:   progress.Updated,
+		Skipped:   progress.Skipped,
+		Batches:   progress.Batches,
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.backfillOptions.Checkpoint.Save(b.backfillOptions.Name, token)
+}
+
+// getFilter return the filter of the documents after lastID
+func (b *Backfill) getFilter(lastID interface{}) interface{} {
+	if lastID == nil {
+		return b.backfillOptions.Filter
+	}
+
+	return bson.M{"$and": bson.A{b.backfillOptions.Filter, bson.M{"_id": bson.M{"$gt": lastID}}}}
+}
+
+// count the documents left after lastID
+func (b *Backfill) count(lastID interface{}) (int64, error) {
+	var count int64
+	err := b.client.executeWithoutTransaction(func(sc context.Context) (err error) {
+		collection := b.client.getClient().Database(b.databaseName).Collection(b.collectionName)
+		count, err = collection.CountDocuments(sc, b.getFilter(lastID))
+		return err
+	})
+
+	return count, err
+}
+
+// readBatch return the next batch of documents after lastID in _id order
+func (b *Backfill) readBatch(lastID interface{}) ([]bson.M, error) {
+	var documents []bson.M
+	err := b.client.executeWithoutTransaction(func(sc context.Context) error {
+		collection := b.client.getClient().Database(b.databaseName).Collection(b.collectionName)
+		findOptions := options.Find().
+			SetLimit(int64(b.backfillOptions.BatchSize)).
+			SetSort(bson.D{primitive.E{Key: "_id", Value: 1}})
+		cur, err := collection.Find(sc, b.getFilter(lastID), findOptions)
+		if err != nil {
+			return err
+		}
+
+		return cur.All(sc, &documents)
+	})
+
+	return documents, err
+}
+
+// writeBatch apply the transform to documents and write their updates at once, the documents which no longer match
+// the filter are not updated
+func (b *Backfill) writeBatch(documents []bson.M) (int64, error) {
+	models := make([]mongo.WriteModel, 0, len(documents))
+	for _, document := range documents {
+		update, err := b.transform(document)
+		if err != nil {
+			return 0, err
+		}
+		if update == nil {
+			continue
+		}
+
+		filter := bson.M{"$and": bson.A{b.backfillOptions.Filter, bson.M{"_id": document["_id"]}}}
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update))
+	}
+	if len(models) == 0 {
+		return 0, nil
+	}
+
+	var updated int64
+	err := b.client.execute(func(sc context.Context) error {
+		collection := b.client.getClient().Database(b.databaseName).Collection(b.collectionName)
+		result, err := collection.BulkWrite(sc, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return err
+		}
+		updated = result.MatchedCount
+
+		return nil
+	})
+
+	return updated, err
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// backupTimeLayout is the layout of the time in backup names, names sort by time
+	backupTimeLayout = "20060102T150405Z"
+	// backupExtension is the extension of backup names
+	backupExtension = ".tar.gz"
+)
+
+// BackupSchedule model for BackupScheduler
+type BackupSchedule struct {
+	Cron     string         // standard 5 fields cron expression or descriptor like @daily
+	Database string         // database to backup
+	Prefix   string         // prefix of the backup names, like backups/
+	KeepLast int            // number of most recent backups always kept, 0 keep all
+	MaxAge   time.Duration  // backups older than MaxAge are deleted once KeepLast are kept, 0 disable the rule
+	Options  *BackupOptions // collections filters of the backups
+}
+
+// BackupScheduler run Backup based on a cron expression, upload the archives to a storage backend and prune old ones
+type BackupScheduler struct {
+	client   *MongoClient
+	storage  IObjectStorage
+	schedule BackupSchedule
+	cron     *cron.Cron
+	running  sync.Mutex
+	mu       sync.RWMutex
+	stats    BackupSchedulerStats
+}
+
+// NewBackupScheduler init new backup scheduler, call Start to run it
+func NewBackupScheduler(client *MongoClient, backupStorage IObjectStorage, schedule BackupSchedule) (*BackupScheduler, error) {
+	if schedule.Database == "" {
+		return nil, errors.New("Backup schedule database is required")
+	}
+	if schedule.KeepLast < 0 || schedule.MaxAge < 0 {
+		return nil, errors.New("Backup schedule retention rules must be positive")
+	}
+
+	scheduler := &BackupScheduler{client: client, storage: backupStorage, schedule: schedule, cron: cron.New()}
+	if _, err := scheduler.cron.AddFunc(schedule.Cron, func() {
+		if err := scheduler.Run(); err != nil {
+			log.Println("Unable to run scheduled backup: ", err)
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	return scheduler, nil
+}
+
+// Start run the backups on schedule
+func (s *BackupScheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop the schedule and wait for the running backup
+func (s *BackupScheduler) Stop() {
+	<-s.cron.Stop().Done()
+}
+
+// Stats return the backup metrics
+func (s *BackupScheduler) Stats() BackupSchedulerStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.stats
+}
+
+// Run backup the database and prune old backups now, runs never overlap
+func (s *BackupScheduler) Run() error {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	start := time.Now().UTC()
+	name := s.schedule.Prefix + s.schedule.Database + "-" + start.Format(backupTimeLayout) + backupExtension
+
+	reader, writer := io.Pipe()
+	go func() {
+		_, err := s.client.Backup(s.schedule.Database, writer, s.schedule.Options)
+		writer.CloseWithError(err)
+	}()
+
+	err := s.storage.Upload(name, reader)
+	reader.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		s.recordFailure(err)
+		return err
+	}
+
+	s.mu.Lock()
+	s.stats.LastSuccess = start
+	s.stats.LastBackup = name
+	s.stats.LastDuration = time.Since(start)
+	s.stats.LastError = ""
+	s.stats.Successes++
+	s.mu.Unlock()
+
+	if err := s.prune(start); err != nil {
+		log.Println("Unable to prune backups: ", err)
+		s.recordFailure(err)
+		return err
+	}
+
+	return nil
+}
+
+// prune delete the backups not kept by the retention rules
+func (s *BackupScheduler) prune(now time.Time) error {
+	if s.schedule.KeepLast == 0 && s.schedule.MaxAge == 0 {
+		return nil
+	}
+
+	prefix := s.schedule.Prefix + s.schedule.Database + "-"
+	names, err := s.storage.List(prefix)
+	if err != nil {
+		return err
+	}
+
+	type backup struct {
+		name      string
+		createdAt time.Time
+	}
+	var backups []backup
+	for _, name := range names {
+		createdAt, err := time.Parse(backupTimeLayout, strings.TrimSuffix(strings.TrimPrefix(name, prefix), backupExtension))
+		if err != nil {
+			// Not a backup of the scheduler
+			continue
+		}
+		backups = append(backups, backup{name, createdAt})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].createdAt.After(backups[j].createdAt) })
+
+	for i, backup := range backups {
+		keep := i < s.schedule.KeepLast
+		if !keep && s.schedule.MaxAge > 0 {
+			keep = now.Sub(backup.createdAt) <= s.schedule.MaxAge
+		}
+		if keep {
+			continue
+		}
+
+		if err := s.storage.Delete(backup.name); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.stats.Pruned++
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+// recordFailure update the metrics of a failed run
+func (s *BackupScheduler) recordFailure(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.LastError = err.Error()
+	s.stats.Failures++
+}
@@ -0,0 +1,342 @@
+package storage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// backupBSONSuffix is the suffix of the archive entries holding the documents of a collection
+	backupBSONSuffix = ".bson"
+	// backupMetadataSuffix is the suffix of the archive entries holding the options and indexes of a collection
+	backupMetadataSuffix = ".metadata.json"
+)
+
+// BackupOptions model for Backup and Restore
+type BackupOptions struct {
+	Include   []string // collections to process, path.Match patterns, all collections when empty
+	Exclude   []string // collections to skip, path.Match patterns
+	BatchSize int32    // documents fetched or inserted per batch, default is 1000
+	Drop      bool     // drop the collections before restoring them
+}
+
+// collectionMetadata is the metadata entry of a collection, in the mongodump layout
+type collectionMetadata struct {
+	CollectionName string   `bson:"collectionName"`
+	Type           string   `bson:"type"`
+	Options        bson.Raw `bson:"options"`
+	Indexes        []bson.D `bson:"indexes"`
+}
+
+// Backup write a gzip compressed tar archive of the collections of databaseName to w, with one <database>/<collection>.bson
+// and <database>/<collection>.metadata.json entry per collection like mongodump, so the extracted archive can also
+// be read by mongorestore. Use UploadExport to write the archive to a storage backend.
+func (m *MongoClient) Backup(databaseName string, w io.Writer, backupOptions *BackupOptions) (*BackupReport, error) {
+	if backupOptions == nil {
+		backupOptions = &BackupOptions{}
+	}
+
+	var specifications []*collectionMetadata
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		results, err := m.getClient().Database(databaseName).ListCollectionSpecifications(sc, bson.M{"type": "collection"})
+		if err != nil {
+			return err
+		}
+
+		specifications = nil
+		for _, result := range results {
+			if strings.HasPrefix(result.Name, "system.") || !matchCollection(result.Name, backupOptions.Include, backupOptions.Exclude) {
+				continue
+			}
+			specifications = append(specifications, &collectionMetadata{CollectionName: result.Name, Type: result.Type, Options: result.Options})
+		}
+
+		return nil
+	}); err != nil {
+		log.Println("Unable to list collections: ", err)
+		return nil, err
+	}
+
+	gzipWriter := gzip.NewWriter(w)
+	archive := tar.NewWriter(gzipWriter)
+	report := &BackupReport{Database: databaseName, Collections: map[string]int64{}}
+	for _, metadata := range specifications {
+		count, err := m.backupCollection(archive, databaseName, metadata, backupOptions)
+		if err != nil {
+			log.Println("Unable to backup collection: ", err)
+			return report, err
+		}
+		report.Collections[metadata.CollectionName] = count
+	}
+
+	if err := archive.Close(); err != nil {
+		return report, err
+	}
+
+	return report, gzipWriter.Close()
+}
+
+// backupCollection write the metadata and documents entries of the collection to archive, the documents are
+// spooled to a temporary file because the size of tar entries must be known before writing them
+func (m *MongoClient) backupCollection(archive *tar.Writer, databaseName string, metadata *collectionMetadata, backupOptions *BackupOptions) (int64, error) {
+	spool, err := ioutil.TempFile("", "storage-backup-*"+backupBSONSuffix)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	batchSize := backupOptions.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultExportBatchSize
+	}
+
+	var count int64
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		count = 0
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if err := spool.Truncate(0); err != nil {
+			return err
+		}
+
+		collection := m.getClient().Database(databaseName).Collection(metadata.CollectionName)
+		indexes, err := collection.Indexes().List(sc)
+		if err != nil {
+			return err
+		}
+		metadata.Indexes = nil
+		if err := indexes.All(sc, &metadata.Indexes); err != nil {
+			return err
+		}
+
+		cur, err := collection.Find(sc, bson.M{}, options.Find().SetBatchSize(batchSize))
+		if err != nil {
+			return err
+		}
+		defer cur.Close(sc)
+
+		for cur.Next(sc) {
+			if _, err := spool.Write(cur.Current); err != nil {
+				return err
+			}
+			count++
+		}
+
+		return cur.Err()
+	}); err != nil {
+		return count, err
+	}
+
+	metadataJSON, err := bson.MarshalExtJSON(metadata, true, false)
+	if err != nil {
+		return count, err
+	}
+
+	name := path.Join(databaseName, metadata.CollectionName)
+	if err := writeArchiveEntry(archive, name+backupMetadataSuffix, int64(len(metadataJSON)), strings.NewReader(string(metadataJSON))); err != nil {
+		return count, err
+	}
+
+	size, err := spool.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return count, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return count, err
+	}
+
+	return count, writeArchiveEntry(archive, name+backupBSONSuffix, size, spool)
+}
+
+// Restore read a Backup archive from r and restore its collections into databaseName,
+// indexes are created before the documents are inserted by batches
+func (m *MongoClient) Restore(databaseName string, r io.Reader, backupOptions *BackupOptions) (*BackupReport, error) {
+	if backupOptions == nil {
+		backupOptions = &BackupOptions{}
+	}
+	batchSize := int(backupOptions.BatchSize)
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		log.Println("Unable to read backup archive: ", err)
+		return nil, err
+	}
+	defer gzipReader.Close()
+
+	archive := tar.NewReader(gzipReader)
+	report := &BackupReport{Database: databaseName, Collections: map[string]int64{}}
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Println("Unable to read backup archive: ", err)
+			return report, err
+		}
+
+		name := path.Base(header.Name)
+		switch {
+		case strings.HasSuffix(name, backupMetadataSuffix):
+			collectionName := strings.TrimSuffix(name, backupMetadataSuffix)
+			if !matchCollection(collectionName, backupOptions.Include, backupOptions.Exclude) {
+				continue
+			}
+			if err := m.restoreMetadata(databaseName, collectionName, archive, backupOptions.Drop); err != nil {
+				log.Println("Unable to restore collection metadata: ", err)
+				return report, err
+			}
+		case strings.HasSuffix(name, backupBSONSuffix):
+			collectionName := strings.TrimSuffix(name, backupBSONSuffix)
+			if !matchCollection(collectionName, backupOptions.Include, backupOptions.Exclude) {
+				continue
+			}
+
+			importReport, err := m.Import(databaseName, collectionName, archive, &ImportOptions{Format: ImportBSON, BatchSize: batchSize})
+			if importReport != nil {
+				report.Collections[collectionName] = importReport.Inserted
+			}
+			if err != nil {
+				return report, err
+			}
+			if len(importReport.Rejected) > 0 {
+				return report, errors.New("Unable to restore " + collectionName + ": " + importReport.Rejected[0].Error)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// restoreMetadata create the collection and its indexes based on the metadata entry
+func (m *MongoClient) restoreMetadata(databaseName, collectionName string, r io.Reader, drop bool) error {
+	metadataJSON, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	var metadata collectionMetadata
+	if err := bson.UnmarshalExtJSON(metadataJSON, true, &metadata); err != nil {
+		return err
+	}
+
+	return m.executeWithoutTransaction(func(sc context.Context) error {
+		database := m.getClient().Database(databaseName)
+		if drop {
+			if err := database.Collection(collectionName).Drop(sc); err != nil {
+				return err
+			}
+		}
+
+		create := bson.D{primitive.E{Key: "create", Value: collectionName}}
+		if len(metadata.Options) > 0 {
+			elements, err := metadata.Options.Elements()
+			if err != nil {
+				return err
+			}
+			for _, element := range elements {
+				create = append(create, primitive.E{Key: element.Key(), Value: element.Value()})
+			}
+		}
+		if err := database.RunCommand(sc, create).Err(); err != nil && !isNamespaceExists(err) {
+			return err
+		}
+
+		return createIndexes(sc, database, collectionName, metadata.Indexes)
+	})
+}
+
+// createIndexes create the indexes listed by listIndexes on the collection, the _id index is skipped
+func createIndexes(sc context.Context, database *mongo.Database, collectionName string, specifications []bson.D) error {
+	indexes := bson.A{}
+	for _, index := range specifications {
+		specification := bson.D{}
+		for _, element := range index {
+			if element.Key == "v" || element.Key == "ns" {
+				continue
+			}
+			specification = append(specification, element)
+		}
+		if getIndexName(specification) != "_id_" {
+			indexes = append(indexes, specification)
+		}
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	return database.RunCommand(sc, bson.D{
+		primitive.E{Key: "createIndexes", Value: collectionName},
+		primitive.E{Key: "indexes", Value: indexes},
+	}).Err()
+}
+
+// writeArchiveEntry write the entry name of size bytes read from r to archive
+func writeArchiveEntry(archive *tar.Writer, name string, size int64, r io.Reader) error {
+	if err := archive.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(archive, r)
+	return err
+}
+
+// matchCollection return true when the collection is included and not excluded, patterns are path.Match patterns
+func matchCollection(collectionName string, include, exclude []string) bool {
+	match := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, collectionName); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(include) > 0 && !match(include) {
+		return false
+	}
+
+	return !match(exclude)
+}
+
+// getIndexName return the name of the index specification
+func getIndexName(specification bson.D) string {
+	for _, element := range specification {
+		if element.Key == "name" {
+			name, _ := element.Value.(string)
+			return name
+		}
+	}
+
+	return ""
+}
+
+// isNamespaceExists return true when the error is returned because the collection already exists
+func isNamespaceExists(err error) bool {
+	var commandErr mongo.CommandError
+	return errors.As(err, &commandErr) && commandErr.Code == 48
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BatchUpdateOptions model for UpdateManyInBatches
+type BatchUpdateOptions struct {
+	Progress    func(progress BatchUpdateProgress) error // called after each batch, an error stops the update
+	ResumeAfter interface{}                              // _id of the last updated document of a previous run
+	Checkpoint  ICheckpoint                              // save the last updated _id after each batch, and resume from it
+	Name        string                                   // checkpoint name, required with Checkpoint
+}
+
+// UpdateManyInBatches apply update to the documents matching filter in batches of batchSize documents in _id order,
+// sleeping pauseBetween after each batch so large backfills don't hold the cluster. Batches are only applied to
+// documents still matching filter. An interrupted update continues after the last updated _id from
+// BatchUpdateOptions.ResumeAfter or the checkpoint.
+func (m *MongoClient) UpdateManyInBatches(databaseName, collectionName string, filter, update interface{}, batchSize int, pauseBetween time.Duration, batchOptions *BatchUpdateOptions) (*BatchUpdateProgress, error) {
+	if batchOptions == nil {
+		batchOptions = &BatchUpdateOptions{}
+	}
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	progress := &BatchUpdateProgress{LastID: batchOptions.ResumeAfter}
+	if batchOptions.Checkpoint != nil {
+		token, err := batchOptions.Checkpoint.Load(batchOptions.Name)
+		if err != nil {
+			log.Println("Unable to load batch update checkpoint: ", err)
+			return nil, err
+		}
+		if token != nil {
+			progress.LastID = token.Lookup("_id")
+		}
+	}
+
+	for {
+		if err := m.waitHealthy(); err != nil {
+			return progress, err
+		}
+
+		matched, modified, lastID, err := m.updateBatch(databaseName, collectionName, filter, update, batchSize, progress.LastID)
+		if err != nil {
+			log.Println("Unable to update batch: ", err)
+			return progress, err
+		}
+		if lastID == nil {
+			return progress, nil
+		}
+
+		progress.Batches++
+		progress.Matched += matched
+		progress.Modified += modified
+		progress.LastID = lastID
+
+		if batchOptions.Checkpoint != nil {
+			token, err := bson.Marshal(bson.M{"_id": lastID})
+			if err != nil {
+				return progress, err
+			}
+			if err := batchOptions.Checkpoint.Save(batchOptions.Name, token); err != nil {
+				log.Println("Unable to save batch update checkpoint: ", err)
+				return progress, err
+			}
+		}
+
+		if batchOptions.Progress != nil {
+			if err := batchOptions.Progress(*progress); err != nil {
+				return progress, err
+			}
+		}
+
+		if pauseBetween > 0 {
+			time.Sleep(pauseBetween)
+		}
+	}
+}
+
+// updateBatch update the next batchSize documents after lastID, the returned _id is nil when no document is left
+func (m *MongoClient) updateBatch(databaseName, collectionName string, filter, update interface{}, batchSize int, lastID interface{}) (int64, int64, interface{}, error) {
+	var matched, modified int64
+	var nextID interface{}
+
+	err := m.execute(func(sc context.Context) error {
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+
+		batchFilter := filter
+		if lastID != nil {
+			batchFilter = bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$gt": lastID}}}}
+		}
+
+		findOptions := options.Find().
+			SetLimit(int64(batchSize)).
+			SetSort(bson.D{primitive.E{Key: "_id", Value: 1}}).
+			SetProjection(bson.M{"_id": 1})
+		cur, err := collection.Find(sc, batchFilter, findOptions)
+		if err != nil {
+			return err
+		}
+
+		var documents []bson.Raw
+		if err := cur.All(sc, &documents); err != nil {
+			return err
+		}
+		if len(documents) == 0 {
+			return nil
+		}
+
+		ids := make(bson.A, 0, len(documents))
+		for _, document := range documents {
+			ids = append(ids, document.Lookup("_id"))
+		}
+
+		result, err := collection.UpdateMany(sc, bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$in": ids}}}}, update)
+		if err != nil {
+			return err
+		}
+		matched, modified = result.MatchedCount, result.ModifiedCount
+		nextID = ids[len(ids)-1]
+
+		return nil
+	})
+
+	return matched, modified, nextID, err
+}
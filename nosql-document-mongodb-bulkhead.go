@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrBulkheadFull is returned when an operation waited longer than the queue timeout for a slot
+	ErrBulkheadFull = errors.New("Too many concurrent operations")
+)
+
+// bulkhead limit the number of operations running at once
+type bulkhead struct {
+	slots        chan struct{}
+	queueTimeout time.Duration
+	inFlight     int64
+	queued       int64
+	rejected     uint64
+}
+
+// newBulkhead init the bulkhead based on config, nil when there is no limit
+func newBulkhead(config *MongoDBBulkhead) *bulkhead {
+	if config.MaxConcurrent <= 0 {
+		return nil
+	}
+
+	return &bulkhead{slots: make(chan struct{}, config.MaxConcurrent), queueTimeout: config.QueueTimeout}
+}
+
+// acquire wait for a slot until c is done and return the function releasing it
+func (b *bulkhead) acquire(c context.Context) (func(), error) {
+	release := func() {
+		atomic.AddInt64(&b.inFlight, -1)
+		<-b.slots
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		atomic.AddInt64(&b.inFlight, 1)
+		return release, nil
+	default:
+	}
+
+	atomic.AddInt64(&b.queued, 1)
+	defer atomic.AddInt64(&b.queued, -1)
+
+	var timeout <-chan time.Time
+	if b.queueTimeout > 0 {
+		timer := time.NewTimer(b.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		atomic.AddInt64(&b.inFlight, 1)
+		return release, nil
+	case <-timeout:
+		atomic.AddUint64(&b.rejected, 1)
+		return nil, ErrBulkheadFull
+	case <-c.Done():
+		return nil, c.Err()
+	}
+}
+
+// Bulkhead return the concurrency metrics of the client
+func (m *MongoClient) Bulkhead() BulkheadStats {
+	m.mu.RLock()
+	b := m.bulkhead
+	m.mu.RUnlock()
+
+	if b == nil {
+		return BulkheadStats{}
+	}
+
+	return BulkheadStats{
+		MaxConcurrent: cap(b.slots),
+		InFlight:      atomic.LoadInt64(&b.inFlight),
+		Queued:        atomic.LoadInt64(&b.queued),
+		Rejected:      atomic.LoadUint64(&b.rejected),
+	}
+}
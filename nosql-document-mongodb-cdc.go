@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IPublisher interface for publishing change events to a message broker, Publish return once the message is acknowledged
+// or publishCtx is done
+type IPublisher interface {
+	Publish(publishCtx context.Context, topic string, key, value []byte) error
+}
+
+// CDCOptions model for CDC
+type CDCOptions struct {
+	Name               string        // name of the resume token checkpoint, default is cdc-<database>
+	Database           string        // database to capture
+	Collections        []string      // collections to capture, all collections when empty
+	TopicPrefix        string        // events of db.coll are published to <prefix>db.coll
+	CheckpointInterval time.Duration // nanosecond, default is 10 seconds
+}
+
+// ChangeEnvelope model of the published events, based on the Debezium envelope.
+// Before is always empty because change streams do not provide the previous document.
+type ChangeEnvelope struct {
+	Before            json.RawMessage `json:"before"`
+	After             json.RawMessage `json:"after"`             // relaxed Extended JSON, null for deletes
+	UpdateDescription json.RawMessage `json:"updateDescription"` // updated and removed fields of updates
+	Source            ChangeSource    `json:"source"`
+	Op                string          `json:"op"` // c for insert, u for update and replace, d for delete
+	TsMs              int64           `json:"ts_ms"`
+}
+
+// ChangeSource model of the origin of a ChangeEnvelope
+type ChangeSource struct {
+	DB         string `json:"db"`
+	Collection string `json:"collection"`
+	TsMs       int64  `json:"ts_ms"` // cluster time of the change
+	Order      uint32 `json:"ord"`   // order of the change within the cluster time second
+}
+
+// CDC publish the change events of a database, with the document ID as message key. The resume token is
+// checkpointed after the events are acknowledged, so events are delivered at least once.
+type CDC struct {
+	client     *MongoClient
+	publisher  IPublisher
+	checkpoint ICheckpoint
+	options    CDCOptions
+}
+
+// NewCDC init new change data capture runner
+func NewCDC(client *MongoClient, publisher IPublisher, checkpoint ICheckpoint, cdcOptions CDCOptions) (*CDC, error) {
+	if cdcOptions.Database == "" {
+		return nil, errors.New("CDC database is required")
+	}
+	if cdcOptions.Name == "" {
+		cdcOptions.Name = "cdc-" + cdcOptions.Database
+	}
+	if cdcOptions.CheckpointInterval <= 0 {
+		cdcOptions.CheckpointInterval = defaultSyncCheckpointInterval
+	}
+
+	return &CDC{client: client, publisher: publisher, checkpoint: checkpoint, options: cdcOptions}, nil
+}
+
+// Start run the CDC in background until the returned function is called
+func (c *CDC) Start() func() {
+	runCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		if err := c.Run(runCtx); err != nil && runCtx.Err() == nil {
+			log.Println("Unable to capture changes: ", err)
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Run publish the change events until runCtx is done, from the checkpointed resume token or from now
+func (c *CDC) Run(runCtx context.Context) error {
+	token, err := c.checkpoint.Load(c.options.Name)
+	if err != nil {
+		log.Println("Unable to load CDC checkpoint: ", err)
+		return err
+	}
+
+	// TryNext wait up to MaxAwaitTime for new events
+	streamOptions := options.ChangeStream().SetFullDocument(options.UpdateLookup).SetMaxAwaitTime(time.Second)
+	if token != nil {
+		streamOptions.SetResumeAfter(token)
+	}
+
+	pipeline := mongo.Pipeline{}
+	if len(c.options.Collections) > 0 {
+		pipeline = mongo.Pipeline{bson.D{primitive.E{Key: "$match", Value: bson.M{"ns.coll": bson.M{"$in": c.options.Collections}}}}}
+	}
+
+	stream, err := c.client.getClient().Database(c.options.Database).Watch(runCtx, pipeline, streamOptions)
+	if err != nil {
+		log.Println("Unable to watch database: ", err)
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	lastCheckpoint := time.Now()
+	for {
+		if !stream.TryNext(runCtx) {
+			if runCtx.Err() != nil {
+				// Every event before the resume token is acknowledged
+				return c.save(stream.ResumeToken())
+			}
+			if err := stream.Err(); err != nil {
+				return err
+			}
+		} else {
+			var event changeEvent
+			if err := stream.Decode(&event); err != nil {
+				return err
+			}
+			if err := c.publish(runCtx, &event); err != nil {
+				log.Println("Unable to publish change event: ", err)
+				return err
+			}
+		}
+
+		if time.Since(lastCheckpoint) >= c.options.CheckpointInterval {
+			if err := c.save(stream.ResumeToken()); err != nil {
+				return err
+			}
+			lastCheckpoint = time.Now()
+		}
+	}
+}
+
+// publish the envelope of the event to the topic of its namespace
+func (c *CDC) publish(runCtx context.Context, event *changeEvent) error {
+	envelope := ChangeEnvelope{
+		Before:            json.RawMessage("null"),
+		After:             json.RawMessage("null"),
+		UpdateDescription: json.RawMessage("null"),
+		Source: ChangeSource{
+			DB:         event.Namespace.DB,
+			Collection: event.Namespace.Coll,
+			TsMs:       int64(event.ClusterTime.T) * 1000,
+			Order:      event.ClusterTime.I,
+		},
+		TsMs: time.Now().UnixNano() / int64(time.Millisecond),
+	}
+
+	switch event.OperationType {
+	case "insert":
+		envelope.Op = "c"
+	case "update", "replace":
+		envelope.Op = "u"
+	case "delete":
+		envelope.Op = "d"
+	default:
+		// drop, rename and invalidate events do not change documents
+		return nil
+	}
+
+	var err error
+	if event.FullDocument != nil {
+		if envelope.After, err = bson.MarshalExtJSON(event.FullDocument, false, false); err != nil {
+			return err
+		}
+	}
+	if event.UpdateDescription != nil {
+		if envelope.UpdateDescription, err = bson.MarshalExtJSON(event.UpdateDescription, false, false); err != nil {
+			return err
+		}
+	}
+
+	value, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	key, err := bson.MarshalExtJSON(event.DocumentKey, false, false)
+	if err != nil {
+		return err
+	}
+
+	return c.publisher.Publish(runCtx, c.options.TopicPrefix+event.Namespace.DB+"."+event.Namespace.Coll, key, value)
+}
+
+// save checkpoint the resume token
+func (c *CDC) save(token bson.Raw) error {
+	if token == nil {
+		return nil
+	}
+
+	if err := c.checkpoint.Save(c.options.Name, token); err != nil {
+		log.Println("Unable to save CDC checkpoint: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// kafkaBatchTimeout time to wait for more messages before sending a batch to Kafka
+const kafkaBatchTimeout = 5 * time.Millisecond
+
+// KafkaPublisher publish messages to Kafka, messages with the same key go to the same partition
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaPublisher init new Kafka publisher, messages are acknowledged by all in-sync replicas. Publish wait for the
+// acknowledgment of each message, so the batch is sent after kafkaBatchTimeout instead of the 1s default of the writer.
+func NewKafkaPublisher(brokers []string) *KafkaPublisher {
+	return &KafkaPublisher{writer: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: kafkaBatchTimeout,
+	}}
+}
+
+// Publish implement IPublisher
+func (k *KafkaPublisher) Publish(publishCtx context.Context, topic string, key, value []byte) error {
+	return k.writer.WriteMessages(publishCtx, kafka.Message{Topic: topic, Key: key, Value: value})
+}
+
+// Close flush the pending messages and close the connections
+func (k *KafkaPublisher) Close() error {
+	return k.writer.Close()
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ICheckpoint interface for storing the change stream resume tokens of long running consumers
+type ICheckpoint interface {
+	Load(name string) (bson.Raw, error) // nil when no token is stored
+	Save(name string, token bson.Raw) error
+}
+
+// CollectionCheckpoint store resume tokens in a metadata collection, one document per consumer name
+type CollectionCheckpoint struct {
+	client         *MongoClient
+	databaseName   string
+	collectionName string
+}
+
+// checkpointDocument model of a CollectionCheckpoint document
+type checkpointDocument struct {
+	Name      string    `bson:"_id"`
+	Token     bson.Raw  `bson:"token"`
+	UpdatedAt time.Time `bson:"updatedAt"`
+}
+
+// NewCollectionCheckpoint init new checkpoint store in databaseName.collectionName
+func NewCollectionCheckpoint(client *MongoClient, databaseName, collectionName string) *CollectionCheckpoint {
+	return &CollectionCheckpoint{client: client, databaseName: databaseName, collectionName: collectionName}
+}
+
+// Load return the token saved for name
+func (c *CollectionCheckpoint) Load(name string) (bson.Raw, error) {
+	var document checkpointDocument
+	err := c.client.executeWithoutTransaction(func(sc context.Context) error {
+		collection := c.client.getClient().Database(c.databaseName).Collection(c.collectionName)
+		return collection.FindOne(sc, bson.M{"_id": name}).Decode(&document)
+	})
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return document.Token, nil
+}
+
+// Save store token for name
+func (c *CollectionCheckpoint) Save(name string, token bson.Raw) error {
+	return c.client.executeWithoutTransaction(func(sc context.Context) error {
+		collection := c.client.getClient().Database(c.databaseName).Collection(c.collectionName)
+		_, err := collection.ReplaceOne(sc, bson.M{"_id": name}, checkpointDocument{name, token, time.Now()}, options.Replace().SetUpsert(true))
+		return err
+	})
+}
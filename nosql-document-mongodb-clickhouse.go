@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ClickHouseBridgeOptions model for ClickHouseBridge
+type ClickHouseBridgeOptions struct {
+	Name           string        // name of the resume token checkpoint, default is clickhouse-<database>
+	Database       string        // database to capture
+	Collections    []string      // collections to capture, all collections when empty
+	TargetDatabase string        // ClickHouse database of the tables, default is Database
+	TablePrefix    string        // events of coll are inserted into <prefix>coll
+	BatchSize      int           // events buffered before an insert, default is the BatchSize of the ClickHouse config
+	FlushInterval  time.Duration // nanosecond, default is 10 seconds
+}
+
+// ClickHouseChange model of the rows of the bridge tables. The tables are ReplacingMergeTree ordered by id, so
+// SELECT ... FINAL WHERE deleted = 0 return the last version of every document.
+type ClickHouseChange struct {
+	ID       string    `bson:"id"`
+	Op       string    `bson:"op"`       // c for insert, u for update and replace, d for delete
+	Version  int64     `bson:"version"`  // cluster time of the change, seconds in the high 32 bits and order in the low 32 bits
+	Ts       time.Time `bson:"ts"`       // cluster time of the change
+	Deleted  uint8     `bson:"deleted"`  // 1 for deletes
+	Document string    `bson:"document"` // relaxed Extended JSON, empty for deletes
+}
+
+// ClickHouseBridge insert the change events of a database into ClickHouse tables for reporting, one table per
+// collection created on first use. The buffered events are inserted before the resume token is checkpointed,
+// so events are delivered at least once and redelivered rows are merged by version.
+type ClickHouseBridge struct {
+	client     *MongoClient
+	clickhouse *ClickHouseClient
+	checkpoint ICheckpoint
+	options    ClickHouseBridgeOptions
+	tables     map[string]bool
+	rows       map[string][]interface{}
+	buffered   int
+}
+
+// NewClickHouseBridge init new bridge of the changes of client into clickhouse
+func NewClickHouseBridge(client *MongoClient, clickhouse *ClickHouseClient, checkpoint ICheckpoint, bridgeOptions ClickHouseBridgeOptions) (*ClickHouseBridge, error) {
+	if bridgeOptions.Database == "" {
+		return nil, errors.New("ClickHouse bridge database is required")
+	}
+	if bridgeOptions.Name == "" {
+		bridgeOptions.Name = "clickhouse-" + bridgeOptions.Database
+	}
+	if bridgeOptions.TargetDatabase == "" {
+		bridgeOptions.TargetDatabase = bridgeOptions.Database
+	}
+	if bridgeOptions.BatchSize <= 0 {
+		bridgeOptions.BatchSize = clickhouse.Config.BatchSize
+	}
+	if bridgeOptions.FlushInterval <= 0 {
+		bridgeOptions.FlushInterval = defaultSyncCheckpointInterval
+	}
+
+	return &ClickHouseBridge{
+		client:     client,
+		clickhouse: clickhouse,
+		checkpoint: checkpoint,
+		options:    bridgeOptions,
+		tables:     make(map[string]bool),
+		rows:       make(map[string][]interface{}),
+	}, nil
+}
+
+// Start run the bridge in background until the returned function is called
+func (b *ClickHouseBridge) Start() func() {
+	runCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		if err := b.Run(runCtx); err != nil && runCtx.Err() == nil {
+			log.Println("Unable to bridge changes to ClickHouse: ", err)
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Run insert the change events until runCtx is done, from the checkpointed resume token or from now
+func (b *ClickHouseBridge) Run(runCtx context.Context) error {
+	token, err := b.checkpoint.Load(b.options.Name)
+	if err != nil {
+		log.Println("Unable to load ClickHouse bridge checkpoint: ", err)
+		return err
+	}
+
+	// TryNext wait up to MaxAwaitTime for new events
+	streamOptions := options.ChangeStream().SetFullDocument(options.UpdateLookup).SetMaxAwaitTime(time.Second)
+	if token != nil {
+		streamOptions.SetResumeAfter(token)
+	}
+
+	pipeline := mongo.Pipeline{}
+	if len(b.options.Collections) > 0 {
+		pipeline = mongo.Pipeline{bson.D{primitive.E{Key: "$match", Value: bson.M{"ns.coll": bson.M{"$in": b.options.Collections}}}}}
+	}
+
+	stream, err := b.client.getClient().Database(b.options.Database).Watch(runCtx, pipeline, streamOptions)
+	if err != nil {
+		log.Println("Unable to watch database: ", err)
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	lastFlush := time.Now()
+	for {
+		if !stream.TryNext(runCtx) {
+			if runCtx.Err() != nil {
+				// Every event before the resume token is buffered
+				return b.flush(stream.ResumeToken())
+			}
+			if err := stream.Err(); err != nil {
+				return err
+			}
+		} else {
+			var event changeEvent
+			if err := stream.Decode(&event); err != nil {
+				return err
+			}
+			if err := b.add(&event); err != nil {
+				log.Println("Unable to convert change event: ", err)
+				return err
+			}
+		}
+
+		if b.buffered >= b.options.BatchSize || time.Since(lastFlush) >= b.options.FlushInterval {
+			if err := b.flush(stream.ResumeToken()); err != nil {
+				return err
+			}
+			lastFlush = time.Now()
+		}
+	}
+}
+
+// add buffer the row of the event in the table of its collection
+func (b *ClickHouseBridge) add(event *changeEvent) error {
+	row := ClickHouseChange{
+		Version: int64(event.ClusterTime.T)<<32 | int64(event.ClusterTime.I),
+		Ts:      time.Unix(int64(event.ClusterTime.T), 0).UTC(),
+	}
+
+	switch event.OperationType {
+	case "insert":
+		row.Op = "c"
+	case "update", "replace":
+		row.Op = "u"
+	case "delete":
+		row.Op, row.Deleted = "d", 1
+	default:
+		// drop, rename and invalidate events do not change documents
+		return nil
+	}
+
+	var key struct {
+		ID interface{} `bson:"_id"`
+	}
+	if err := bson.Unmarshal(event.DocumentKey, &key); err != nil {
+		return err
+	}
+	row.ID = getIDString(key.ID)
+
+	if event.FullDocument != nil {
+		document, err := bson.MarshalExtJSON(event.FullDocument, false, false)
+		if err != nil {
+			return err
+		}
+		row.Document = string(document)
+	} else if row.Op == "u" {
+		// The document was deleted before the lookup, its delete event follows
+		return nil
+	}
+
+	tableName := b.options.TablePrefix + event.Namespace.Coll
+	b.rows[tableName] = append(b.rows[tableName], row)
+	b.buffered++
+
+	return nil
+}
+
+// flush insert the buffered rows and checkpoint the resume token
+func (b *ClickHouseBridge) flush(token bson.Raw) error {
+	for tableName, rows := range b.rows {
+		if err := b.createTable(tableName); err != nil {
+			log.Println("Unable to create ClickHouse table: ", err)
+			return err
+		}
+		if err := b.clickhouse.Insert(b.options.TargetDatabase, tableName, rows); err != nil {
+			return err
+		}
+		delete(b.rows, tableName)
+		b.buffered -= len(rows)
+	}
+
+	if token == nil {
+		return nil
+	}
+	if err := b.checkpoint.Save(b.options.Name, token); err != nil {
+		log.Println("Unable to save ClickHouse bridge checkpoint: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// createTable create the table of the rows of ClickHouseChange once per run
+func (b *ClickHouseBridge) createTable(tableName string) error {
+	if b.tables[tableName] {
+		return nil
+	}
+
+	query := "CREATE TABLE IF NOT EXISTS " + getClickHouseTable(b.options.TargetDatabase, tableName) +
+		" (id String, op LowCardinality(String), version Int64, ts DateTime64(3, 'UTC'), deleted UInt8, document String)" +
+		" ENGINE = ReplacingMergeTree(version) ORDER BY id"
+	if err := b.clickhouse.do(query, nil, nil, nil); err != nil {
+		return err
+	}
+	b.tables[tableName] = true
+
+	return nil
+}
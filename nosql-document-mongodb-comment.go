@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// commentKey is the context key of the operation comment
+type commentKey struct{}
+
+// WithComment return a context carrying comment, like a request ID. Bind it with MongoClient.WithContext so the reads,
+// aggregations, updates and deletes of the handle send it as their comment, it appears in the profiler, currentOp and
+// the slow query logs. Inserts have no comment option and are sent without it.
+func WithComment(parent context.Context, comment string) context.Context {
+	return context.WithValue(parent, commentKey{}, comment)
+}
+
+// GetComment return the comment carried by the context, empty when there is none
+func GetComment(ctx context.Context) string {
+	comment, _ := ctx.Value(commentKey{}).(string)
+	return comment
+}
+
+// getComment return the comment carried by the context of the handle, empty for the client
+func (m *MongoClient) getComment() string {
+	if m.context == nil {
+		return ""
+	}
+
+	return GetComment(m.context)
+}
+
+// withFilterComment return filter with comment as $comment query operator, for the operations without comment option.
+// The filter is unchanged when comment is empty.
+func withFilterComment(comment string, filter interface{}) interface{} {
+	if comment == "" {
+		return filter
+	}
+
+	return bson.M{"$and": bson.A{filter}, "$comment": comment}
+}
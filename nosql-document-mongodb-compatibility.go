@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// DocumentDBCompatibility adjust the MongoDB client for AWS DocumentDB: standard URI, no retryable writes, TLS and no transactions
+	DocumentDBCompatibility = "documentdb"
+	// CosmosDBCompatibility adjust the MongoDB client for Azure Cosmos DB API for MongoDB: standard URI, no retryable writes,
+	// retry on request rate throttling, check of unsupported aggregation stages and adaptation of the created indexes
+	CosmosDBCompatibility = "cosmosdb"
+
+	// cosmosDBThrottlingCode is returned by Cosmos DB when the request rate (RU/s) is too large
+	cosmosDBThrottlingCode = 16500
+	// cosmosDBMaxRetries is the number of retries on request rate throttling
+	cosmosDBMaxRetries = 5
+	// cosmosDBTimestampField is the last modification time of the documents, the only field of Cosmos DB TTL indexes
+	cosmosDBTimestampField = "_ts"
+)
+
+var (
+	// cosmosDBRetryAfterPattern match the delay hint in Cosmos DB throttling error message
+	cosmosDBRetryAfterPattern = regexp.MustCompile(`RetryAfterMs=(\d+)`)
+
+	// cosmosDBUnsupportedStages is the list of aggregation stages not available on Cosmos DB API for MongoDB
+	cosmosDBUnsupportedStages = []string{"$graphLookup", "$merge", "$unionWith", "$planCacheStats", "$listSessions", "$currentOp", "$indexStats"}
+)
+
+// applyCompatibility adjust client options for MongoDB API compatible services
+func applyCompatibility(config *MongoDB, clientOptions *options.ClientOptions) error {
+	if config.CAFile != "" {
+		tlsConfig, err := getTLSConfig(config.CAFile)
+		if err != nil {
+			return err
+		}
+		clientOptions.SetTLSConfig(tlsConfig)
+	}
+
+	switch config.Compatibility {
+	case DocumentDBCompatibility, CosmosDBCompatibility:
+		// DocumentDB and Cosmos DB do not support retryable writes and always require TLS
+		clientOptions.SetRetryWrites(false)
+		if clientOptions.TLSConfig == nil {
+			clientOptions.SetTLSConfig(&tls.Config{})
+		}
+	}
+
+	return nil
+}
+
+// getTLSConfig return TLS config trusting the CA bundle provided
+func getTLSConfig(caFile string) (*tls.Config, error) {
+	ca, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca) {
+		return nil, errors.New("Unable to parse CA bundle")
+	}
+
+	return &tls.Config{RootCAs: roots}, nil
+}
+
+// getThrottlingDelay return the delay before retry when err is a Cosmos DB request rate throttling error
+func getThrottlingDelay(err error) (time.Duration, bool) {
+	var message string
+	throttled := false
+
+	var commandError mongo.CommandError
+	var writeException mongo.WriteException
+	var bulkWriteException mongo.BulkWriteException
+	switch {
+	case errors.As(err, &commandError):
+		throttled, message = commandError.Code == cosmosDBThrottlingCode, commandError.Message
+	case errors.As(err, &writeException):
+		for _, writeError := range writeException.WriteErrors {
+			if writeError.Code == cosmosDBThrottlingCode {
+				throttled, message = true, writeError.Message
+			}
+		}
+	case errors.As(err, &bulkWriteException):
+		for _, writeError := range bulkWriteException.WriteErrors {
+			if writeError.Code == cosmosDBThrottlingCode {
+				throttled, message = true, writeError.Message
+			}
+		}
+	}
+
+	if !throttled {
+		return 0, false
+	}
+
+	if match := cosmosDBRetryAfterPattern.FindStringSubmatch(message); match != nil {
+		if retryAfter, err := strconv.Atoi(match[1]); err == nil {
+			return time.Duration(retryAfter) * time.Millisecond, true
+		}
+	}
+
+	return 100 * time.Millisecond, true
+}
+
+// setDocumentIDs return documents with a new ObjectID as _id when they have none and the _id of every document
+func setDocumentIDs(documents []interface{}) ([]interface{}, []interface{}, error) {
+	identified := make([]interface{}, len(documents))
+	ids := make([]interface{}, len(documents))
+	for i, document := range documents {
+		if id, err := GetDocumentID(document); err == nil {
+			identified[i], ids[i] = document, id
+			continue
+		}
+
+		value, err := toDocument(document)
+		if err != nil {
+			return nil, nil, err
+		}
+		ids[i] = primitive.NewObjectID()
+		identified[i] = append(bson.D{{Key: "_id", Value: ids[i]}}, value...)
+	}
+
+	return identified, ids, nil
+}
+
+// getUnwrittenDocuments return the documents an ordered insert failed with err did not write, the ones from the
+// first write error
+func getUnwrittenDocuments(documents []interface{}, err error) []interface{} {
+	var bulkWriteException mongo.BulkWriteException
+	if !errors.As(err, &bulkWriteException) || len(bulkWriteException.WriteErrors) == 0 {
+		return documents
+	}
+
+	index := len(documents)
+	for _, writeError := range bulkWriteException.WriteErrors {
+		if writeError.Index < index {
+			index = writeError.Index
+		}
+	}
+
+	return documents[index:]
+}
+
+// retryOnThrottling run fn and retry it after the delay asked by Cosmos DB when the request rate is too large, the
+// retries stop when c is done
+func retryOnThrottling(c context.Context, fn func() error) error {
+	err := fn()
+	for i := 0; i < cosmosDBMaxRetries; i++ {
+		delay, throttled := getThrottlingDelay(err)
+		if !throttled {
+			return err
+		}
+
+		log.Printf("Request rate is too large, retry in %v", delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-c.Done():
+			timer.Stop()
+			return c.Err()
+		}
+		err = fn()
+	}
+
+	return err
+}
+
+// adaptIndex return the keys and options of an index supported by the service. Cosmos DB ignores background builds,
+// has no text indexes nor partial indexes, and only expire documents by their last modification time _ts, so the
+// other TTL indexes are refused instead of silently expiring documents at another time.
+func adaptIndex(config *MongoDB, keys interface{}, indexOptions *options.IndexOptions) (*options.IndexOptions, error) {
+	if config.Compatibility != CosmosDBCompatibility {
+		return indexOptions, nil
+	}
+
+	document, err := toDocument(keys)
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range document {
+		if element.Value == "text" {
+			return nil, fmt.Errorf("Text index on %s is not supported by Cosmos DB", element.Key)
+		}
+	}
+	if indexOptions == nil {
+		return nil, nil
+	}
+
+	adapted := *indexOptions
+	adapted.Background = nil
+	if adapted.PartialFilterExpression != nil {
+		return nil, errors.New("Partial indexes are not supported by Cosmos DB")
+	}
+	if adapted.ExpireAfterSeconds != nil && (len(document) != 1 || document[0].Key != cosmosDBTimestampField) {
+		return nil, fmt.Errorf("Cosmos DB only supports TTL indexes on %s, the last modification time of the documents", cosmosDBTimestampField)
+	}
+
+	return &adapted, nil
+}
+
+// checkUniqueIndex return an error when a unique index is created on a collection with documents, Cosmos DB only
+// create unique indexes on empty collections
+func checkUniqueIndex(sc context.Context, config *MongoDB, collection *mongo.Collection, indexOptions *options.IndexOptions) error {
+	if config.Compatibility != CosmosDBCompatibility || indexOptions == nil || indexOptions.Unique == nil || !*indexOptions.Unique {
+		return nil
+	}
+
+	count, err := collection.CountDocuments(sc, bson.M{}, options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("Unique index on %s must be created while it is empty on Cosmos DB", collection.Name())
+	}
+
+	return nil
+}
+
+// checkPipelineCompatibility return an error when the aggregation pipeline uses a stage not supported by the service
+func checkPipelineCompatibility(config *MongoDB, pipeline interface{}) error {
+	if config.Compatibility != CosmosDBCompatibility {
+		return nil
+	}
+
+	// Decode the pipeline whatever its type (mongo.Pipeline, []bson.M, bson.A...) to the list of stages
+	pipelineAsBSON, err := bson.Marshal(bson.M{"pipeline": pipeline})
+	if err != nil {
+		return err
+	}
+
+	var stages struct {
+		Pipeline []bson.M `bson:"pipeline"`
+	}
+	if err := bson.Unmarshal(pipelineAsBSON, &stages); err != nil {
+		return err
+	}
+
+	for _, stage := range stages.Pipeline {
+		for _, unsupportedStage := range cosmosDBUnsupportedStages {
+			if _, ok := stage[unsupportedStage]; ok {
+				return fmt.Errorf("Aggregation stage %v is not supported by Cosmos DB", unsupportedStage)
+			}
+		}
+	}
+
+	return nil
+}
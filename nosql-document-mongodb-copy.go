@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CopyOptions model for CopyCollection and CloneDatabase
+type CopyOptions struct {
+	Target    *MongoClient // client of the destination cluster, default is the source client
+	Filter    interface{}  // documents to copy, all documents when nil
+	Indexes   bool         // copy the indexes of the source collections
+	BatchSize int32        // documents read and written per batch, default is 1000
+	Include   []string     // collections cloned by CloneDatabase, path.Match patterns, all collections when empty
+	Exclude   []string     // collections skipped by CloneDatabase, path.Match patterns
+}
+
+// CopyCollection copy the documents of srcDB.srcColl to dstDB.dstColl by batches and return the number of documents copied.
+// Documents are replaced by _id in the destination, so an interrupted copy can be run again.
+func (m *MongoClient) CopyCollection(srcDB, srcColl, dstDB, dstColl string, copyOptions *CopyOptions) (int64, error) {
+	if copyOptions == nil {
+		copyOptions = &CopyOptions{}
+	}
+	target := copyOptions.Target
+	if target == nil {
+		target = m
+	}
+	filter := copyOptions.Filter
+	if filter == nil {
+		filter = bson.M{}
+	}
+	batchSize := copyOptions.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultExportBatchSize
+	}
+
+	if copyOptions.Indexes {
+		if err := m.copyIndexes(target, srcDB, srcColl, dstDB, dstColl); err != nil {
+			log.Println("Unable to copy indexes: ", err)
+			return 0, err
+		}
+	}
+
+	var count int64
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		count = 0
+
+		findOptions := options.Find().SetBatchSize(batchSize).SetSort(bson.D{primitive.E{Key: "_id", Value: 1}})
+		cur, err := m.getClient().Database(srcDB).Collection(srcColl).Find(sc, filter, findOptions)
+		if err != nil {
+			return err
+		}
+		defer cur.Close(sc)
+
+		batch := make([]mongo.WriteModel, 0, batchSize)
+		for cur.Next(sc) {
+			document := append(bson.Raw(nil), cur.Current...)
+			batch = append(batch, mongo.NewReplaceOneModel().
+				SetFilter(bson.D{primitive.E{Key: "_id", Value: document.Lookup("_id")}}).
+				SetReplacement(document).
+				SetUpsert(true))
+
+			if len(batch) == int(batchSize) {
+				if err := target.writeBatch(dstDB, dstColl, batch); err != nil {
+					return err
+				}
+				count += int64(len(batch))
+				batch = batch[:0]
+			}
+		}
+		if err := cur.Err(); err != nil {
+			return err
+		}
+
+		if err := target.writeBatch(dstDB, dstColl, batch); err != nil {
+			return err
+		}
+		count += int64(len(batch))
+
+		return nil
+	}); err != nil {
+		log.Println("Unable to copy collection: ", err)
+		return count, err
+	}
+
+	return count, nil
+}
+
+// CloneDatabase copy the collections of srcDB to dstDB, see CopyCollection, and return the number
+// of documents copied per collection
+func (m *MongoClient) CloneDatabase(srcDB, dstDB string, copyOptions *CopyOptions) (*BackupReport, error) {
+	if copyOptions == nil {
+		copyOptions = &CopyOptions{}
+	}
+
+	var collections []string
+	if err := m.executeWithoutTransaction(func(sc context.Context) (err error) {
+		collections, err = m.getClient().Database(srcDB).ListCollectionNames(sc, bson.M{"type": "collection"})
+		return err
+	}); err != nil {
+		log.Println("Unable to list collections: ", err)
+		return nil, err
+	}
+
+	var errs *multierror.Error
+	report := &BackupReport{Database: dstDB, Collections: map[string]int64{}}
+	for _, collectionName := range collections {
+		if strings.HasPrefix(collectionName, "system.") || !matchCollection(collectionName, copyOptions.Include, copyOptions.Exclude) {
+			continue
+		}
+
+		count, err := m.CopyCollection(srcDB, collectionName, dstDB, collectionName, copyOptions)
+		report.Collections[collectionName] = count
+		errs = multierror.Append(errs, err)
+	}
+
+	return report, errs.ErrorOrNil()
+}
+
+// copyIndexes create the indexes of the source collection on the destination collection
+func (m *MongoClient) copyIndexes(target *MongoClient, srcDB, srcColl, dstDB, dstColl string) error {
+	var indexes []bson.D
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		cur, err := m.getClient().Database(srcDB).Collection(srcColl).Indexes().List(sc)
+		if err != nil {
+			return err
+		}
+
+		indexes = nil
+		return cur.All(sc, &indexes)
+	}); err != nil {
+		return err
+	}
+
+	return target.executeWithoutTransaction(func(sc context.Context) error {
+		return createIndexes(sc, target.getClient().Database(dstDB), dstColl, indexes)
+	})
+}
+
+// writeBatch run the write models, unordered
+func (m *MongoClient) writeBatch(databaseName, collectionName string, batch []mongo.WriteModel) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := m.waitHealthy(); err != nil {
+		return err
+	}
+
+	return m.executeWithoutTransaction(func(sc context.Context) error {
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+		_, err := collection.BulkWrite(sc, batch, options.BulkWrite().SetOrdered(false))
+		return err
+	})
+}
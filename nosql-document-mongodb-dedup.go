@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// DedupKeepFirst keep the duplicate with the lowest _id
+	DedupKeepFirst = "keepFirst"
+	// DedupKeepLast keep the duplicate with the highest _id
+	DedupKeepLast = "keepLast"
+	// DedupMerge keep the duplicate with the lowest _id and set the fields of the others on it, higher _id winning
+	DedupMerge = "merge"
+)
+
+// FindDuplicates return the groups of documents matching filter which have the same values of keys,
+// the _id of each group are in ascending order
+func (m *MongoClient) FindDuplicates(databaseName, collectionName string, keys []string, filter interface{}) ([]DuplicateGroup, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("Keys cannot be empty")
+	}
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	groupKey := bson.D{}
+	for _, key := range keys {
+		groupKey = append(groupKey, primitive.E{Key: key, Value: "$" + key})
+	}
+	pipeline := mongo.Pipeline{
+		bson.D{primitive.E{Key: "$match", Value: filter}},
+		bson.D{primitive.E{Key: "$sort", Value: bson.D{primitive.E{Key: "_id", Value: 1}}}},
+		bson.D{primitive.E{Key: "$group", Value: bson.D{
+			primitive.E{Key: "_id", Value: groupKey},
+			primitive.E{Key: "ids", Value: bson.M{"$push": "$_id"}},
+			primitive.E{Key: "count", Value: bson.M{"$sum": 1}},
+		}}},
+		bson.D{primitive.E{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}
+
+	var groups []DuplicateGroup
+	err := m.executeWithoutTransaction(func(sc context.Context) error {
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+		cur, err := collection.Aggregate(sc, pipeline, options.Aggregate().SetAllowDiskUse(true))
+		if err != nil {
+			return err
+		}
+
+		var results []struct {
+			Key   bson.M        `bson:"_id"`
+			IDs   []interface{} `bson:"ids"`
+			Count int64         `bson:"count"`
+		}
+		if err := cur.All(sc, &results); err != nil {
+			return err
+		}
+
+		groups = make([]DuplicateGroup, 0, len(results))
+		for _, result := range results {
+			groups = append(groups, DuplicateGroup{Key: result.Key, IDs: result.IDs, Count: result.Count})
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Println("Unable to find duplicates: ", err)
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// RemoveDuplicates delete the duplicates of keys found by FindDuplicates according to policy
+// and return the number of deleted documents, each group is processed in its own transaction
+func (m *MongoClient) RemoveDuplicates(databaseName, collectionName string, keys []string, filter interface{}, policy string) (int64, error) {
+	switch policy {
+	case "":
+		policy = DedupKeepFirst
+	case DedupKeepFirst, DedupKeepLast, DedupMerge:
+	default:
+		return 0, fmt.Errorf("Unknown dedup policy %q", policy)
+	}
+
+	groups, err := m.FindDuplicates(databaseName, collectionName, keys, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for _, group := range groups {
+		deleted, err := m.removeDuplicateGroup(databaseName, collectionName, group, policy)
+		if err != nil {
+			log.Println("Unable to remove duplicates: ", err)
+			return count, err
+		}
+		count += deleted
+	}
+
+	return count, nil
+}
+
+// removeDuplicateGroup keep one document of the group and delete the others
+func (m *MongoClient) removeDuplicateGroup(databaseName, collectionName string, group DuplicateGroup, policy string) (int64, error) {
+	keep, remove := group.IDs[0], group.IDs[1:]
+	if policy == DedupKeepLast {
+		keep, remove = group.IDs[len(group.IDs)-1], group.IDs[:len(group.IDs)-1]
+	}
+
+	var count int64
+	err := m.execute(func(sc context.Context) error {
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+
+		if policy == DedupMerge {
+			cur, err := collection.Find(sc, bson.M{"_id": bson.M{"$in": remove}}, options.Find().SetSort(bson.D{primitive.E{Key: "_id", Value: 1}}))
+			if err != nil {
+				return err
+			}
+
+			var documents []bson.M
+			if err := cur.All(sc, &documents); err != nil {
+				return err
+			}
+
+			merged := bson.M{}
+			for _, document := range documents {
+				for field, value := range document {
+					if field != "_id" && value != nil {
+						merged[field] = value
+					}
+				}
+			}
+			if len(merged) > 0 {
+				if _, err := collection.UpdateOne(sc, bson.M{"_id": keep}, bson.M{"$set": merged}); err != nil {
+					return err
+				}
+			}
+		}
+
+		result, err := collection.DeleteMany(sc, bson.M{"_id": bson.M{"$in": remove}})
+		if err != nil {
+			return err
+		}
+		count = result.DeletedCount
+
+		return nil
+	})
+
+	return count, err
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/net/proxy"
+)
+
+// sshDialer dial MongoDB hosts through a SSH bastion
+type sshDialer struct {
+	mu      sync.Mutex
+	config  *MongoDBProxy
+	forward proxy.ContextDialer
+	client  *ssh.Client
+}
+
+// enabled return true when MongoDB connections are dialed through a proxy
+func (p *MongoDBProxy) enabled() bool {
+	return p.SSHHost != "" || p.SOCKS5Address != ""
+}
+
+// getDialer return the dialer for MongoDB connections based on proxy config
+func getDialer(config *MongoDBProxy) (proxy.ContextDialer, error) {
+	var dialer proxy.ContextDialer = &net.Dialer{Timeout: 30 * time.Second}
+
+	if config.SOCKS5Address != "" {
+		var auth *proxy.Auth
+		if config.SOCKS5User != "" {
+			auth = &proxy.Auth{User: config.SOCKS5User, Password: config.SOCKS5Password}
+		}
+
+		socks5Dialer, err := proxy.SOCKS5("tcp", config.SOCKS5Address, auth, dialer.(*net.Dialer))
+		if err != nil {
+			return nil, err
+		}
+
+		contextDialer, ok := socks5Dialer.(proxy.ContextDialer)
+		if !ok {
+			return nil, errors.New("SOCKS5 dialer does not support context")
+		}
+		dialer = contextDialer
+	}
+
+	if config.SSHHost != "" {
+		if config.SSHKnownHosts == "" && !config.SSHInsecureIgnoreHostKey {
+			return nil, &InvalidArgumentError{Argument: "sshKnownHosts", Reason: "is required unless sshInsecureIgnoreHostKey is set"}
+		}
+		// The bastion is reached through the SOCKS5 proxy when both are configured
+		dialer = &sshDialer{config: config, forward: dialer}
+	}
+
+	return dialer, nil
+}
+
+// getSSHClientConfig return SSH client config with password or private key authentication, the host key is checked
+// with the known_hosts file unless SSHInsecureIgnoreHostKey is set
+func getSSHClientConfig(config *MongoDBProxy) (*ssh.ClientConfig, error) {
+	var authMethods []ssh.AuthMethod
+	if config.SSHPrivateKey != "" {
+		key, err := ioutil.ReadFile(config.SSHPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		authMethods = append(authMethods, ssh.PublicKeys(signer))
+	}
+	if config.SSHPassword != "" {
+		authMethods = append(authMethods, ssh.Password(config.SSHPassword))
+	}
+
+	var hostKeyCallback ssh.HostKeyCallback
+	switch {
+	case config.SSHKnownHosts != "":
+		callback, err := knownhosts.New(config.SSHKnownHosts)
+		if err != nil {
+			return nil, err
+		}
+		hostKeyCallback = callback
+	case config.SSHInsecureIgnoreHostKey:
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, &InvalidArgumentError{Argument: "sshKnownHosts", Reason: "is required unless sshInsecureIgnoreHostKey is set"}
+	}
+
+	return &ssh.ClientConfig{
+		User:            config.SSHUser,
+		Auth:            authMethods,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         30 * time.Second,
+	}, nil
+}
+
+// connect return the SSH client, the bastion connection is established on first use
+func (sd *sshDialer) connect(ctx context.Context) (*ssh.Client, error) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	if sd.client != nil {
+		return sd.client, nil
+	}
+
+	clientConfig, err := getSSHClientConfig(sd.config)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := sd.forward.DialContext(ctx, "tcp", sd.config.SSHHost)
+	if err != nil {
+		return nil, err
+	}
+
+	sshConn, channels, requests, err := ssh.NewClientConn(conn, sd.config.SSHHost, clientConfig)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	sd.client = ssh.NewClient(sshConn, channels, requests)
+	return sd.client, nil
+}
+
+// reset close the broken SSH client so the next dial establish a new one
+func (sd *sshDialer) reset(client *ssh.Client) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	if sd.client == client {
+		sd.client.Close()
+		sd.client = nil
+	}
+}
+
+// DialContext open a connection to address through the SSH bastion
+func (sd *sshDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	client, err := sd.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := client.Dial(network, address)
+	if err != nil {
+		// The bastion connection may be dropped, retry once with a new one
+		sd.reset(client)
+		if client, err = sd.connect(ctx); err != nil {
+			return nil, err
+		}
+
+		return client.Dial(network, address)
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// enabled return true when hosts are discovered from DNS instead of config
+func (d *MongoDBDiscovery) enabled() bool {
+	return d.SRV != "" || d.Service != ""
+}
+
+// discoverHosts resolve the SRV record or the Kubernetes headless service endpoints to the sorted list of host:port
+func discoverHosts(config *MongoDBDiscovery) ([]string, error) {
+	var hosts []string
+
+	if config.SRV != "" {
+		_, records, err := net.LookupSRV("", "", config.SRV)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range records {
+			hosts = append(hosts, net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port))))
+		}
+	} else {
+		addresses, err := net.LookupHost(config.Service)
+		if err != nil {
+			return nil, err
+		}
+
+		port := config.Port
+		if port == 0 {
+			port = 27017
+		}
+
+		for _, address := range addresses {
+			hosts = append(hosts, net.JoinHostPort(address, strconv.Itoa(port)))
+		}
+	}
+
+	if len(hosts) == 0 {
+		return nil, errors.New("No MongoDB host discovered")
+	}
+	sort.Strings(hosts)
+
+	return hosts, nil
+}
+
+// getStandardURI return mongo connection URI with the standard format for the hosts provided
+func getStandardURI(config *MongoDB, hosts []string) string {
+	credential := ""
+	if config.User != "" || config.Password != "" {
+		credential = url.UserPassword(config.User, config.Password).String() + "@"
+	}
+
+	return fmt.Sprintf("mongodb://%v%v/%v?%v", credential, strings.Join(hosts, ","), config.DB, strings.Join(config.Options, "&"))
+}
+
+// startHostRefresh resolve hosts every interval and reconnect when they change, until the returned function is called
+func (m *MongoClient) startHostRefresh(interval time.Duration) func() {
+	done := make(chan struct{})
+	go m.refreshHosts(interval, done)
+
+	return func() {
+		close(done)
+	}
+}
+
+// refreshHosts resolve hosts every interval and reconnect when they change until done is closed
+func (m *MongoClient) refreshHosts(interval time.Duration, done chan struct{}) {
+	config := m.getConfig()
+	hosts, err := discoverHosts(&config.Discovery)
+	if err != nil {
+		log.Println("Unable to discover MongoDB hosts: ", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+
+		config := m.getConfig()
+		currentHosts, err := discoverHosts(&config.Discovery)
+		if err != nil {
+			log.Println("Unable to discover MongoDB hosts: ", err)
+			continue
+		}
+
+		if reflect.DeepEqual(hosts, currentHosts) {
+			continue
+		}
+
+		// The refresh can be stopped while resolving, by Close or by a reload changing the discovery
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		if err := m.reconnect(config); err != nil {
+			log.Println("Unable to reconnect to discovered MongoDB hosts: ", err)
+			continue
+		}
+
+		hosts = currentHosts
+		log.Println("Reconnected to discovered MongoDB hosts: ", strings.Join(hosts, ","))
+	}
+}
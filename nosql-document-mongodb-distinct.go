@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// distinctTooBigCode is returned when the distinct values exceed the maximum document size
+	distinctTooBigCode = 17217
+	// bsonObjectTooLargeCode is returned when a response exceeds the maximum document size
+	bsonObjectTooLargeCode = 10334
+)
+
+// Distinct return the distinct values of field in the documents matching filter, array values are flattened.
+// Values exceeding the 16MB limit of the distinct command are collected by an aggregation instead.
+func (m *MongoClient) Distinct(databaseName, collectionName, field string, filter interface{}) ([]interface{}, error) {
+	release, err := m.admit(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	var values []interface{}
+	timeout := m.getTimeout(OperationRead, databaseName, collectionName)
+	if err := m.executeWithoutTransaction(func(sc context.Context) (err error) {
+		sc, cancel := withTimeout(sc, timeout)
+		defer cancel()
+
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+
+		values, err = collection.Distinct(sc, field, filter)
+		var commandErr mongo.CommandError
+		if !errors.As(err, &commandErr) || (commandErr.Code != distinctTooBigCode && commandErr.Code != bsonObjectTooLargeCode) {
+			return err
+		}
+
+		cur, err := collection.Aggregate(sc, mongo.Pipeline{
+			bson.D{primitive.E{Key: "$match", Value: filter}},
+			bson.D{primitive.E{Key: "$unwind", Value: "$" + field}},
+			bson.D{primitive.E{Key: "$group", Value: bson.M{"_id": "$" + field}}},
+		}, options.Aggregate().SetAllowDiskUse(true))
+		if err != nil {
+			return err
+		}
+		defer cur.Close(sc)
+
+		values = nil
+		for cur.Next(sc) {
+			var result struct {
+				Value interface{} `bson:"_id"`
+			}
+			if err := cur.Decode(&result); err != nil {
+				return err
+			}
+			values = append(values, result.Value)
+		}
+
+		return cur.Err()
+	}); err != nil {
+		log.Println("Unable to get distinct values: ", err)
+		return nil, err
+	}
+
+	return values, nil
+}
@@ -0,0 +1,292 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// ExportNDJSON export one Extended JSON document per line
+	ExportNDJSON = "ndjson"
+	// ExportJSON export a JSON array of Extended JSON documents
+	ExportJSON = "json"
+	// ExportCSV export one row per document, nested fields are selected with dot notation
+	ExportCSV = "csv"
+
+	// defaultExportBatchSize is the number of documents fetched per cursor batch
+	defaultExportBatchSize = 1000
+)
+
+// ExportOptions model for Export
+type ExportOptions struct {
+	Format    string   // ndjson (default), json or csv
+	Fields    []string // fields to export, all fields when empty (csv use the fields of the first document)
+	BatchSize int32    // documents fetched per cursor batch, default is 1000
+}
+
+// Export stream the documents matching filter to w and return the number of documents exported,
+// documents are fetched by cursor batches so the collection is never held in memory
+func (m *MongoClient) Export(databaseName, collectionName string, filter interface{}, w io.Writer, exportOptions *ExportOptions) (int64, error) {
+	if exportOptions == nil {
+		exportOptions = &ExportOptions{}
+	}
+	format := exportOptions.Format
+	if format == "" {
+		format = ExportNDJSON
+	}
+	if format != ExportNDJSON && format != ExportJSON && format != ExportCSV {
+		return 0, errors.New("Export format must be ndjson, json or csv")
+	}
+	batchSize := exportOptions.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultExportBatchSize
+	}
+
+	var count int64
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		count = 0
+
+		findOptions := options.Find()
+		findOptions.SetBatchSize(batchSize)
+		findOptions.SetSort(bson.D{primitive.E{Key: "_id", Value: 1}})
+		if len(exportOptions.Fields) > 0 {
+			projection := bson.D{}
+			for _, field := range exportOptions.Fields {
+				projection = append(projection, primitive.E{Key: field, Value: 1})
+			}
+			findOptions.SetProjection(projection)
+		}
+
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+		cur, err := collection.Find(sc, filter, findOptions)
+		if err != nil {
+			log.Println("Unable to read document: ", err)
+			return err
+		}
+		defer cur.Close(sc)
+
+		writer := newExportWriter(w, format, exportOptions.Fields)
+		for cur.Next(sc) {
+			if count > 0 && count%int64(batchSize) == 0 {
+				if err := m.waitHealthy(); err != nil {
+					return err
+				}
+			}
+			if err := writer.write(cur.Current); err != nil {
+				log.Println("Unable to export document: ", err)
+				return err
+			}
+			count++
+		}
+		if err := cur.Err(); err != nil {
+			log.Println("Unable to iterate cursor: ", err)
+			return err
+		}
+
+		return writer.close()
+	}); err != nil {
+		log.Println("Unable to execute MongoDB operation: ", err)
+		return count, err
+	}
+
+	return count, nil
+}
+
+// exportWriter encode documents to the export format
+type exportWriter struct {
+	format string
+	fields []string
+	buffer *bufio.Writer
+	csv    *csv.Writer
+	count  int64
+}
+
+// newExportWriter init new export writer
+func newExportWriter(w io.Writer, format string, fields []string) *exportWriter {
+	buffer := bufio.NewWriter(w)
+	writer := &exportWriter{format: format, fields: fields, buffer: buffer}
+	if format == ExportCSV {
+		writer.csv = csv.NewWriter(buffer)
+	}
+
+	return writer
+}
+
+// write encode document
+func (e *exportWriter) write(document bson.Raw) error {
+	defer func() { e.count++ }()
+
+	switch e.format {
+	case ExportCSV:
+		return e.writeCSV(document)
+	case ExportJSON:
+		separator := ",\n"
+		if e.count == 0 {
+			separator = "[\n"
+		}
+		if _, err := e.buffer.WriteString(separator); err != nil {
+			return err
+		}
+		return e.writeExtJSON(document)
+	default:
+		if err := e.writeExtJSON(document); err != nil {
+			return err
+		}
+		return e.buffer.WriteByte('\n')
+	}
+}
+
+// writeExtJSON encode document as relaxed Extended JSON
+func (e *exportWriter) writeExtJSON(document bson.Raw) error {
+	b, err := bson.MarshalExtJSON(document, false, false)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.buffer.Write(b)
+	return err
+}
+
+// writeCSV encode document as a CSV row, the header is written before the first row
+func (e *exportWriter) writeCSV(document bson.Raw) error {
+	if e.count == 0 {
+		if len(e.fields) == 0 {
+			elements, err := document.Elements()
+			if err != nil {
+				return err
+			}
+			for _, element := range elements {
+				e.fields = append(e.fields, element.Key())
+			}
+		}
+		if err := e.csv.Write(e.fields); err != nil {
+			return err
+		}
+	}
+
+	row := make([]string, len(e.fields))
+	for i, field := range e.fields {
+		value, err := document.LookupErr(strings.Split(field, ".")...)
+		if err != nil {
+			continue
+		}
+		row[i] = formatCSVValue(value)
+	}
+
+	return e.csv.Write(row)
+}
+
+// close flush the pending output and terminate the JSON array
+func (e *exportWriter) close() error {
+	switch e.format {
+	case ExportCSV:
+		if e.count == 0 && len(e.fields) > 0 {
+			if err := e.csv.Write(e.fields); err != nil {
+				return err
+			}
+		}
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	case ExportJSON:
+		end := "\n]\n"
+		if e.count == 0 {
+			end = "[]\n"
+		}
+		if _, err := e.buffer.WriteString(end); err != nil {
+			return err
+		}
+	}
+
+	return e.buffer.Flush()
+}
+
+// formatCSVValue return the CSV cell of value, documents and arrays are written as relaxed Extended JSON
+func formatCSVValue(value bson.RawValue) string {
+	switch value.Type {
+	case bsontype.String:
+		return value.StringValue()
+	case bsontype.ObjectID:
+		return value.ObjectID().Hex()
+	case bsontype.DateTime:
+		return value.Time().UTC().Format(time.RFC3339Nano)
+	case bsontype.Boolean:
+		return fmt.Sprint(value.Boolean())
+	case bsontype.Int32:
+		return fmt.Sprint(value.Int32())
+	case bsontype.Int64:
+		return fmt.Sprint(value.Int64())
+	case bsontype.Double:
+		return fmt.Sprint(value.Double())
+	case bsontype.Null, bsontype.Undefined:
+		return ""
+	}
+
+	b, err := bson.MarshalExtJSON(bson.D{primitive.E{Key: "v", Value: value}}, false, false)
+	if err != nil {
+		return value.String()
+	}
+
+	// Strip the {"v": ...} wrapper
+	return strings.TrimSuffix(strings.TrimPrefix(string(b), `{"v":`), "}")
+}
+
+// UploadExport upload the output of export to file as name, the output is streamed so it is never held in memory
+//
+//	result, err := storage.UploadExport(drive, "users.parquet", func(w io.Writer) error {
+//		_, err := client.ExportParquet("shop", "users", bson.M{}, w, nil)
+//		return err
+//	})
+func UploadExport(file IFILE, name string, export func(w io.Writer) error, parents ...string) (interface{}, error) {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(export(writer))
+	}()
+
+	result, err := file.Upload(name, reader, parents...)
+	// Unblock export when the upload stop before reading all the output
+	reader.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		log.Println("Unable to upload export: ", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// UploadObjectExport upload the output of export to the object name of objectStorage, like S3 or GCS, the output is
+// streamed so it is never held in memory
+//
+//	err := storage.UploadObjectExport(s3, "lake/users.parquet", func(w io.Writer) error {
+//		_, err := client.ExportParquet("shop", "users", bson.M{}, w, nil)
+//		return err
+//	})
+func UploadObjectExport(objectStorage IObjectStorage, name string, export func(w io.Writer) error) error {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(export(writer))
+	}()
+
+	err := objectStorage.Upload(name, reader)
+	// Unblock export when the upload stop before reading all the output
+	reader.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		log.Println("Unable to upload export: ", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Facets return the number of documents matching filter per value of each facet field in one $facet aggregation,
+// like {"brand": {"acme": 12, "globex": 3}}. Values of array fields are counted separately, missing and null
+// values are skipped and limit (0 means no limit) keep the most frequent values of each facet.
+func (m *MongoClient) Facets(databaseName, collectionName string, filter interface{}, fields []string, limit int) (map[string]map[string]int64, error) {
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	// Facet names can not contain dots, fields are named by their position
+	facets := bson.D{}
+	for i, field := range fields {
+		stages := bson.A{
+			bson.M{"$unwind": "$" + field},
+			bson.M{"$match": bson.M{field: bson.M{"$ne": nil}}},
+			bson.M{"$group": bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.D{primitive.E{Key: "count", Value: -1}, primitive.E{Key: "_id", Value: 1}}},
+		}
+		if limit > 0 {
+			stages = append(stages, bson.M{"$limit": limit})
+		}
+		facets = append(facets, primitive.E{Key: "f" + strconv.Itoa(i), Value: stages})
+	}
+
+	release, err := m.admit(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	results := make(map[string]map[string]int64, len(fields))
+	timeout := m.getTimeout(OperationAggregate, databaseName, collectionName)
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		sc, cancel := withTimeout(sc, timeout)
+		defer cancel()
+
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+		cur, err := collection.Aggregate(sc, mongo.Pipeline{
+			bson.D{primitive.E{Key: "$match", Value: filter}},
+			bson.D{primitive.E{Key: "$facet", Value: facets}},
+		})
+		if err != nil {
+			return err
+		}
+
+		var documents []map[string][]struct {
+			Value interface{} `bson:"_id"`
+			Count int64       `bson:"count"`
+		}
+		if err := cur.All(sc, &documents); err != nil {
+			return err
+		}
+
+		for i, field := range fields {
+			counts := map[string]int64{}
+			if len(documents) > 0 {
+				for _, bucket := range documents[0]["f"+strconv.Itoa(i)] {
+					counts[getIDString(bucket.Value)] = bucket.Count
+				}
+			}
+			results[field] = counts
+		}
+
+		return nil
+	}); err != nil {
+		log.Println("Unable to get facets: ", err)
+		return nil, err
+	}
+
+	return results, nil
+}
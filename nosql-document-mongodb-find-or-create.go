@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// findAndModifyResult private model for the findAndModify command response
+type findAndModifyResult struct {
+	LastErrorObject struct {
+		UpdatedExisting bool `bson:"updatedExisting"`
+	} `bson:"lastErrorObject"`
+	Value bson.Raw `bson:"value"`
+}
+
+// FindOrCreate return the document matching filter, or atomically create it from the equality fields of filter
+// and defaults, created is true when the document has been inserted. The result is a pointer to a dataModel value.
+func (m *MongoClient) FindOrCreate(databaseName, collectionName string, filter, defaults interface{}, dataModel reflect.Type) (interface{}, bool, error) {
+	release, err := m.admit(databaseName, collectionName)
+	if err != nil {
+		return nil, false, err
+	}
+	defer release()
+
+	// An empty update would replace the found document, a pipeline keep it as is
+	var update interface{} = mongo.Pipeline{bson.D{primitive.E{Key: "$replaceWith", Value: "$$ROOT"}}}
+	if defaults != nil {
+		raw, err := bson.Marshal(defaults)
+		if err != nil {
+			return nil, false, err
+		}
+		if elements, _ := bson.Raw(raw).Elements(); len(elements) > 0 {
+			update = bson.M{"$setOnInsert": bson.Raw(raw)}
+		}
+	}
+
+	var response findAndModifyResult
+	command := bson.D{
+		primitive.E{Key: "findAndModify", Value: collectionName},
+		primitive.E{Key: "query", Value: filter},
+		primitive.E{Key: "update", Value: update},
+		primitive.E{Key: "upsert", Value: true},
+		primitive.E{Key: "new", Value: true},
+	}
+	timeout := m.getTimeout(OperationUpdate, databaseName, collectionName)
+	if err := m.execute(func(sc context.Context) error {
+		sc, cancel := withTimeout(sc, timeout)
+		defer cancel()
+
+		err := m.getClient().Database(databaseName).RunCommand(sc, command).Decode(&response)
+		// Concurrent upserts of the same unique key fail for all but one, the others find the document
+		var commandErr mongo.CommandError
+		if errors.As(err, &commandErr) && commandErr.Code == duplicateKeyCode {
+			err = m.getClient().Database(databaseName).RunCommand(sc, command).Decode(&response)
+		}
+		return err
+	}); err != nil {
+		log.Println("Unable to find or create document: ", err)
+		return nil, false, err
+	}
+
+	result := reflect.New(dataModel).Interface()
+	if err := bson.Unmarshal(response.Value, result); err != nil {
+		log.Println("Unable to decode document: ", err)
+		return nil, false, err
+	}
+
+	return result, !response.LastErrorObject.UpdatedExisting, nil
+}
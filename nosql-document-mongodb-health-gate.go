@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultHealthGateTimeout is the maximum pause of the batch operations waiting for a primary, elections usually
+	// complete within seconds
+	defaultHealthGateTimeout = 2 * time.Minute
+)
+
+var (
+	// ErrClusterUnhealthy is returned when no primary is elected before the end of the wait
+	ErrClusterUnhealthy = errors.New("MongoDB cluster has no primary")
+)
+
+// HealthGate tell whether the MongoDB cluster can take writes, based on the topology events. It is unhealthy from the
+// loss of the primary of a replica set until the election of a new one, standalone servers and sharded clusters are
+// always healthy. The batch operations of the package, like UpdateManyInBatches, CopyCollection, Import and Export,
+// wait on it between batches so they pause during elections and resume afterward.
+type HealthGate struct {
+	mu         sync.Mutex
+	replicaSet bool
+	primaries  map[string]bool
+	ready      chan struct{} // closed while healthy
+}
+
+// newHealthGate init new healthy gate following the events of topology
+func newHealthGate(topology *topologyNotifier) *HealthGate {
+	gate := &HealthGate{primaries: make(map[string]bool), ready: make(chan struct{})}
+	close(gate.ready)
+	topology.subscribe(gate.update)
+
+	return gate
+}
+
+// HealthGate return the gate of the cluster, to pause the long running jobs of the application during elections too
+func (m *MongoClient) HealthGate() *HealthGate {
+	return m.healthGate
+}
+
+// Healthy return true when the cluster has a primary
+func (g *HealthGate) Healthy() bool {
+	select {
+	case <-g.getReady():
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait block until the cluster is healthy and return ErrClusterUnhealthy after timeout, 0 means no timeout, or the
+// error of the current context when it is done first
+func (g *HealthGate) Wait(timeout time.Duration) error {
+	return g.wait(ctx, timeout)
+}
+
+// wait block like Wait until c is done
+func (g *HealthGate) wait(c context.Context, timeout time.Duration) error {
+	ready := g.getReady()
+	select {
+	case <-ready:
+		return nil
+	default:
+	}
+
+	log.Println("MongoDB cluster has no primary, waiting for the election")
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case <-ready:
+		log.Println("MongoDB primary elected, resuming")
+		return nil
+	case <-expired:
+		return ErrClusterUnhealthy
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+// getReady return the channel closed while the cluster is healthy
+func (g *HealthGate) getReady() chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.ready
+}
+
+// update the primaries of the cluster with topologyEvent and open or close the gate
+func (g *HealthGate) update(topologyEvent TopologyEvent) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch topologyEvent.Type {
+	case TopologyPrimaryElected:
+		g.replicaSet = true
+		g.primaries[topologyEvent.Address] = true
+	case TopologyPrimaryLost, TopologyServerRemoved:
+		delete(g.primaries, topologyEvent.Address)
+	default:
+		return
+	}
+
+	healthy := !g.replicaSet || len(g.primaries) > 0
+	select {
+	case <-g.ready:
+		if !healthy {
+			g.ready = make(chan struct{})
+		}
+	default:
+		if healthy {
+			close(g.ready)
+		}
+	}
+}
+
+// waitHealthy wait for the cluster to be healthy before the next batch of a batch operation
+func (m *MongoClient) waitHealthy() error {
+	if m.healthGate == nil {
+		return nil
+	}
+
+	return m.healthGate.wait(m.Context(), defaultHealthGateTimeout)
+}
@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// ImportNDJSON import one Extended JSON document per line
+	ImportNDJSON = "ndjson"
+	// ImportCSV import one document per row, the first row is the header
+	ImportCSV = "csv"
+	// ImportBSON import a mongodump .bson file
+	ImportBSON = "bson"
+
+	// defaultImportBatchSize is the number of documents inserted per batch
+	defaultImportBatchSize = 1000
+	// maxBSONDocumentSize is the maximum size of a MongoDB document
+	maxBSONDocumentSize = 16 * 1024 * 1024
+)
+
+// ImportOptions model for Import
+type ImportOptions struct {
+	Format    string            // ndjson (default), csv or bson
+	Headers   map[string]string // CSV column to document field, dot notation creates nested documents
+	BatchSize int               // documents inserted per batch, default is 1000
+	Ordered   bool              // stop at the first rejected row instead of inserting the remaining ones
+}
+
+// importRow is a document read from the input and its position
+type importRow struct {
+	row      int64
+	document interface{}
+}
+
+// Import read documents from r and insert them by batches, rows that can not be decoded or inserted
+// are listed in the report, in ordered mode the import stops at the first one
+func (m *MongoClient) Import(databaseName, collectionName string, r io.Reader, importOptions *ImportOptions) (*ImportReport, error) {
+	if importOptions == nil {
+		importOptions = &ImportOptions{}
+	}
+	batchSize := importOptions.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+
+	var next func() (interface{}, error)
+	switch importOptions.Format {
+	case "", ImportNDJSON:
+		next = newNDJSONReader(r)
+	case ImportCSV:
+		next = newCSVReader(r, importOptions.Headers)
+	case ImportBSON:
+		next = newBSONReader(r)
+	default:
+		return nil, errors.New("Import format must be ndjson, csv or bson")
+	}
+
+	report := &ImportReport{Rejected: []ImportRejection{}}
+	batch := make([]importRow, 0, batchSize)
+	var row int64
+	for {
+		document, err := next()
+		if err == io.EOF {
+			break
+		}
+		row++
+
+		var parseErr *importParseError
+		if errors.As(err, &parseErr) {
+			report.Rejected = append(report.Rejected, ImportRejection{Row: row, Error: parseErr.Error()})
+			if importOptions.Ordered {
+				return report, m.importBatch(databaseName, collectionName, batch, importOptions.Ordered, report)
+			}
+			continue
+		}
+		if err != nil {
+			log.Println("Unable to read import input: ", err)
+			return report, err
+		}
+
+		batch = append(batch, importRow{row, document})
+		if len(batch) == batchSize {
+			if err := m.importBatch(databaseName, collectionName, batch, importOptions.Ordered, report); err != nil {
+				return report, err
+			}
+			if importOptions.Ordered && len(report.Rejected) > 0 {
+				return report, nil
+			}
+			batch = batch[:0]
+		}
+	}
+
+	return report, m.importBatch(databaseName, collectionName, batch, importOptions.Ordered, report)
+}
+
+// importBatch insert the rows and add the rows rejected by the server to report
+func (m *MongoClient) importBatch(databaseName, collectionName string, batch []importRow, ordered bool, report *ImportReport) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := m.waitHealthy(); err != nil {
+		return err
+	}
+
+	documents := make([]interface{}, 0, len(batch))
+	for _, row := range batch {
+		documents = append(documents, row.document)
+	}
+
+	var result *mongo.InsertManyResult
+	err := m.executeWithoutTransaction(func(sc context.Context) (err error) {
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+		result, err = collection.InsertMany(sc, documents, options.InsertMany().SetOrdered(ordered))
+		return err
+	})
+
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && len(bulkErr.WriteErrors) > 0 {
+		for _, writeErr := range bulkErr.WriteErrors {
+			report.Rejected = append(report.Rejected, ImportRejection{Row: batch[writeErr.Index].row, Error: writeErr.Message})
+		}
+		inserted := int64(len(batch) - len(bulkErr.WriteErrors))
+		if ordered {
+			// Ordered inserts stop at the first error
+			inserted = int64(bulkErr.WriteErrors[0].Index)
+		}
+		report.Inserted += inserted
+		return nil
+	}
+	if err != nil {
+		log.Println("Unable to import documents: ", err)
+		return err
+	}
+
+	report.Inserted += int64(len(result.InsertedIDs))
+	return nil
+}
+
+// importParseError is returned by import readers when a row can not be decoded, the import continue with the next row
+type importParseError struct {
+	err error
+}
+
+// Error implement error interface
+func (e *importParseError) Error() string {
+	return e.err.Error()
+}
+
+// newNDJSONReader return the reader of Extended JSON documents, one per line, empty lines are skipped
+func newNDJSONReader(r io.Reader) func() (interface{}, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBSONDocumentSize)
+
+	return func() (interface{}, error) {
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+
+			var document bson.D
+			if err := bson.UnmarshalExtJSON(line, false, &document); err != nil {
+				return nil, &importParseError{err}
+			}
+
+			return document, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, io.EOF
+	}
+}
+
+// newCSVReader return the reader of CSV rows, values are imported as strings and empty values are skipped
+func newCSVReader(r io.Reader, headers map[string]string) func() (interface{}, error) {
+	reader := csv.NewReader(r)
+	var fields []string
+
+	return func() (interface{}, error) {
+		if fields == nil {
+			header, err := reader.Read()
+			if err != nil {
+				return nil, err
+			}
+			fields = make([]string, len(header))
+			for i, column := range header {
+				fields[i] = column
+				if field, ok := headers[column]; ok {
+					fields[i] = field
+				}
+			}
+		}
+
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		var csvErr *csv.ParseError
+		if errors.As(err, &csvErr) {
+			return nil, &importParseError{err}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		document := bson.M{}
+		for i, value := range record {
+			if value == "" || fields[i] == "" {
+				continue
+			}
+			if err := setField(document, fields[i], value); err != nil {
+				return nil, &importParseError{err}
+			}
+		}
+
+		return document, nil
+	}
+}
+
+// newBSONReader return the reader of length-prefixed BSON documents, as written by mongodump
+func newBSONReader(r io.Reader) func() (interface{}, error) {
+	reader := bufio.NewReader(r)
+
+	return func() (interface{}, error) {
+		prefix := make([]byte, 4)
+		if _, err := io.ReadFull(reader, prefix); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return nil, errors.New("Truncated BSON document")
+			}
+			return nil, err
+		}
+
+		size := int32(binary.LittleEndian.Uint32(prefix))
+		if size < 5 || size > maxBSONDocumentSize {
+			// The stream can not be resynchronized after an invalid size
+			return nil, fmt.Errorf("Invalid BSON document size %d", size)
+		}
+
+		document := make(bson.Raw, size)
+		copy(document, prefix)
+		if _, err := io.ReadFull(reader, document[4:]); err != nil {
+			return nil, errors.New("Truncated BSON document")
+		}
+
+		if err := document.Validate(); err != nil {
+			return nil, &importParseError{err}
+		}
+
+		return document, nil
+	}
+}
+
+// setField set value at the dot notation path of document
+func setField(document bson.M, path string, value interface{}) error {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := document[key]
+		if !ok {
+			child = bson.M{}
+			document[key] = child
+		}
+
+		childDocument, ok := child.(bson.M)
+		if !ok {
+			return fmt.Errorf("Field %s is not a document", key)
+		}
+		document = childDocument
+	}
+	document[keys[len(keys)-1]] = value
+
+	return nil
+}
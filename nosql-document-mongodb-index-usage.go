@@ -0,0 +1,291 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// QueryShape model for the fields of a query used to suggest indexes, like the shapes of slow queries
+type QueryShape struct {
+	Equality []string // fields compared by equality or $in, in any order
+	Sort     bson.D   // sort fields in order with 1 or -1
+	Range    []string // fields compared by range or other operators, in any order
+}
+
+// indexStats private model for $indexStats stage result
+type indexStats struct {
+	Name     string `bson:"name"`
+	Key      bson.D `bson:"key"`
+	Accesses struct {
+		Ops   int64     `bson:"ops"`
+		Since time.Time `bson:"since"`
+	} `bson:"accesses"`
+}
+
+// GetQueryShape return the shape of the query of filter and sort, top-level fields compared to a value, by $eq or $in
+// are equality fields and the fields of other operators are range fields
+func GetQueryShape(filter, sort interface{}) (QueryShape, error) {
+	shape := QueryShape{}
+
+	if filter != nil {
+		document, err := toDocument(filter)
+		if err != nil {
+			return shape, err
+		}
+
+		for _, element := range document {
+			if strings.HasPrefix(element.Key, "$") {
+				continue
+			}
+
+			operators, ok := element.Value.(bson.D)
+			if !ok || len(operators) == 0 || !strings.HasPrefix(operators[0].Key, "$") {
+				shape.Equality = append(shape.Equality, element.Key)
+				continue
+			}
+			if len(operators) == 1 && (operators[0].Key == "$eq" || operators[0].Key == "$in") {
+				shape.Equality = append(shape.Equality, element.Key)
+				continue
+			}
+			shape.Range = append(shape.Range, element.Key)
+		}
+	}
+
+	if sort != nil {
+		document, err := toDocument(sort)
+		if err != nil {
+			return shape, err
+		}
+		shape.Sort = document
+	}
+
+	return shape, nil
+}
+
+// IndexUsage return the number of accesses of the indexes of the collection, summed over the members, and flag the
+// indexes without access for unusedFor, 0 flags every index without access. The counters reset when a server restarts,
+// so an index is only flagged when the counters of every member are older than unusedFor. The _id index is never flagged.
+func (m *MongoClient) IndexUsage(databaseName, collectionName string, unusedFor time.Duration) ([]IndexUsage, error) {
+	release, err := m.admit(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var stats []indexStats
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+		cur, err := collection.Aggregate(sc, mongo.Pipeline{bson.D{primitive.E{Key: "$indexStats", Value: bson.M{}}}})
+		if err != nil {
+			return err
+		}
+
+		return cur.All(sc, &stats)
+	}); err != nil {
+		log.Println("Unable to get index stats: ", err)
+		return nil, err
+	}
+
+	var usages []IndexUsage
+	byName := map[string]int{}
+	for _, stat := range stats {
+		i, ok := byName[stat.Name]
+		if !ok {
+			byName[stat.Name] = len(usages)
+			usages = append(usages, IndexUsage{Name: stat.Name, Keys: stat.Key, Since: stat.Accesses.Since})
+			i = len(usages) - 1
+		}
+
+		usages[i].Ops += stat.Accesses.Ops
+		// The most recent reset bounds the period observed by every member
+		if stat.Accesses.Since.After(usages[i].Since) {
+			usages[i].Since = stat.Accesses.Since
+		}
+	}
+
+	for i := range usages {
+		usages[i].Unused = usages[i].Name != "_id_" && usages[i].Ops == 0 && time.Since(usages[i].Since) >= unusedFor
+	}
+
+	return usages, nil
+}
+
+// SuggestIndexes return the indexes to create for the query shapes not served by the indexes of the collection,
+// keys follow the equality, sort and range rule and shapes with the same suggestion are merged
+func (m *MongoClient) SuggestIndexes(databaseName, collectionName string, shapes ...QueryShape) ([]IndexSuggestion, error) {
+	release, err := m.admit(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var indexes []struct {
+		Key bson.D `bson:"key"`
+	}
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		cur, err := m.getClient().Database(databaseName).Collection(collectionName).Indexes().List(sc)
+		if err != nil {
+			return err
+		}
+
+		return cur.All(sc, &indexes)
+	}); err != nil {
+		log.Println("Unable to list indexes: ", err)
+		return nil, err
+	}
+
+	var suggestions []IndexSuggestion
+	bySignature := map[string]int{}
+	for _, shape := range shapes {
+		keys := getShapeKeys(shape)
+		if len(keys) == 0 {
+			continue
+		}
+
+		served := false
+		for _, index := range indexes {
+			if isShapeServed(shape, index.Key) {
+				served = true
+				break
+			}
+		}
+		if served {
+			continue
+		}
+
+		signature := fmt.Sprint(keys)
+		if i, ok := bySignature[signature]; ok {
+			suggestions[i].Shapes++
+			continue
+		}
+		bySignature[signature] = len(suggestions)
+		suggestions = append(suggestions, IndexSuggestion{Keys: keys, Shapes: 1})
+	}
+
+	return suggestions, nil
+}
+
+// getShapeKeys return the index keys of shape, equality then sort then range fields
+func getShapeKeys(shape QueryShape) bson.D {
+	var keys bson.D
+	seen := map[string]bool{}
+	add := func(field string, direction interface{}) {
+		if !seen[field] {
+			seen[field] = true
+			keys = append(keys, primitive.E{Key: field, Value: direction})
+		}
+	}
+
+	for _, field := range shape.Equality {
+		add(field, 1)
+	}
+	for _, element := range shape.Sort {
+		add(element.Key, element.Value)
+	}
+	for _, field := range shape.Range {
+		add(field, 1)
+	}
+
+	return keys
+}
+
+// isShapeServed return true when the key prefix of the index has the equality fields, then the sort fields in the same
+// or the reverse directions, then the range fields
+func isShapeServed(shape QueryShape, index bson.D) bool {
+	position := 0
+	prefixOf := func(fields map[string]bool) bool {
+		for len(fields) > 0 {
+			if position >= len(index) || !fields[index[position].Key] {
+				return false
+			}
+			delete(fields, index[position].Key)
+			position++
+		}
+		return true
+	}
+
+	equality := map[string]bool{}
+	for _, field := range shape.Equality {
+		equality[field] = true
+	}
+	if !prefixOf(equality) {
+		return false
+	}
+
+	reverse := 0
+	for _, element := range shape.Sort {
+		if containsString(shape.Equality, element.Key) {
+			// Sorting on a field compared by equality is free
+			continue
+		}
+		if position >= len(index) || index[position].Key != element.Key {
+			return false
+		}
+
+		same := getDirection(index[position].Value) == getDirection(element.Value)
+		switch {
+		case reverse == 0 && same:
+			reverse = 1
+		case reverse == 0:
+			reverse = -1
+		case (reverse == 1) != same:
+			return false
+		}
+		position++
+	}
+
+	ranges := map[string]bool{}
+	for _, field := range shape.Range {
+		if !containsString(shape.Equality, field) && !isSortField(shape.Sort, field) {
+			ranges[field] = true
+		}
+	}
+
+	return prefixOf(ranges)
+}
+
+// isSortField return true when field is a key of sort
+func isSortField(sort bson.D, field string) bool {
+	for _, element := range sort {
+		if element.Key == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getDirection return 1 or -1 for the direction of index and sort keys, text and other special keys are 0
+func getDirection(value interface{}) int {
+	switch v := value.(type) {
+	case int32:
+		return getSign(float64(v))
+	case int64:
+		return getSign(float64(v))
+	case int:
+		return getSign(float64(v))
+	case float64:
+		return getSign(v)
+	}
+
+	return 0
+}
+
+// getSign return the sign of value
+func getSign(value float64) int {
+	if value < 0 {
+		return -1
+	}
+	if value > 0 {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IIndex interface for databases able to create indexes
+type IIndex interface {
+	CreateIndex(databaseName, collectionName string, keys interface{}, indexOptions *options.IndexOptions) (string, error)
+}
+
+// CreateIndex create index on collection based on keys like bson.D{{"email", 1}} and return its name, the index is
+// adapted to the service, see adaptIndex
+func (m *MongoClient) CreateIndex(databaseName, collectionName string, keys interface{}, indexOptions *options.IndexOptions) (string, error) {
+	config := m.getConfig()
+	indexOptions, err := adaptIndex(config, keys, indexOptions)
+	if err != nil {
+		log.Println("Unable to create index: ", err)
+		return "", err
+	}
+
+	var name string
+	// Index creation is not allowed inside multi-document transactions on every server version
+	if err := m.executeWithoutTransaction(func(sc context.Context) (err error) {
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+		if err := checkUniqueIndex(sc, config, collection, indexOptions); err != nil {
+			log.Println("Unable to create index: ", err)
+			return err
+		}
+
+		name, err = collection.Indexes().CreateOne(sc, mongo.IndexModel{Keys: keys, Options: indexOptions})
+		if err != nil {
+			log.Println("Unable to create index: ", err)
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		log.Println("Unable to execute MongoDB operation: ", err)
+		return "", err
+	}
+
+	return name, nil
+}
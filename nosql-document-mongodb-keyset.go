@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrInvalidCursor is returned when a page cursor can not be decoded
+	ErrInvalidCursor = errors.New("Invalid cursor")
+	// ErrInvalidPageSize is returned when the page limit is not positive or above the maxPageSize config
+	ErrInvalidPageSize = errors.New("Invalid page size")
+)
+
+// PageOptions model for ReadPage
+type PageOptions struct {
+	SortField  string // field of the order, _id when empty, it must be set on every document
+	Descending bool
+	Limit      int64  // number of documents of the page
+	After      string // Next cursor of the previous page, to read the next page
+	Before     string // Previous cursor of the next page, to read the previous page
+}
+
+// Page model for ReadPage result
+type Page struct {
+	Data     interface{} `json:"data"`               // pointer to a slice of the data model
+	Next     string      `json:"next,omitempty"`     // cursor of the next page, empty on the last page
+	Previous string      `json:"previous,omitempty"` // cursor of the previous page, empty on the first page
+}
+
+// keysetCursor private model for the position of a document in the page order
+type keysetCursor struct {
+	Value interface{} `bson:"v"`
+	ID    interface{} `bson:"_id"`
+}
+
+// EncodeKeysetCursor return the opaque cursor of a document by its sort field value and _id
+func EncodeKeysetCursor(value, id interface{}) (string, error) {
+	b, err := bson.MarshalExtJSON(keysetCursor{Value: value, ID: id}, true, false)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeKeysetCursor return the sort field value and _id of cursor
+func DecodeKeysetCursor(cursor string) (interface{}, interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	var decoded keysetCursor
+	if err := bson.UnmarshalExtJSON(b, true, &decoded); err != nil || decoded.ID == nil {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	return decoded.Value, decoded.ID, nil
+}
+
+// ReadPage return a page of the documents matching filter in the order of the sort field then _id, by keyset
+// pagination so the pages are stable while documents are written. Pages are read forward from After,
+// backward from Before, or from the start when both are empty. ErrOperatorInjection is returned when the sort field
+// is not a plain field path.
+func (m *MongoClient) ReadPage(databaseName, collectionName string, filter interface{}, pageOptions *PageOptions, dataModel reflect.Type) (*Page, error) {
+	if pageOptions == nil || pageOptions.Limit <= 0 {
+		return nil, ErrInvalidPageSize
+	}
+	if maxPageSize := m.getConfig().MaxPageSize; maxPageSize > 0 && pageOptions.Limit > maxPageSize {
+		return nil, ErrInvalidPageSize
+	}
+	if pageOptions.After != "" && pageOptions.Before != "" {
+		return nil, errors.New("After and before cursors cannot be used together")
+	}
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	sortField := pageOptions.SortField
+	if sortField == "" {
+		sortField = "_id"
+	}
+	if err := checkFieldPath(sortField); err != nil {
+		return nil, err
+	}
+
+	// Reading backward walk the order in reverse then restore it
+	backward := pageOptions.Before != ""
+	ascending := pageOptions.Descending == backward
+	cursor := pageOptions.After
+	if backward {
+		cursor = pageOptions.Before
+	}
+
+	pageFilter := filter
+	if cursor != "" {
+		value, id, err := DecodeKeysetCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		pageFilter = bson.M{"$and": bson.A{filter, getKeysetFilter(sortField, value, id, ascending)}}
+	}
+
+	direction := 1
+	if !ascending {
+		direction = -1
+	}
+	sort := bson.D{primitive.E{Key: sortField, Value: direction}}
+	if sortField != "_id" {
+		sort = append(sort, primitive.E{Key: "_id", Value: direction})
+	}
+
+	release, err := m.admit(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var documents []bson.Raw
+	timeout := m.getTimeout(OperationRead, databaseName, collectionName)
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		sc, cancel := withTimeout(sc, timeout)
+		defer cancel()
+
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+		findOptions := options.Find().SetSort(sort).SetLimit(pageOptions.Limit + 1)
+		if comment := m.getComment(); comment != "" {
+			findOptions.SetComment(comment)
+		}
+		cur, err := collection.Find(sc, pageFilter, findOptions)
+		if err != nil {
+			return err
+		}
+
+		return cur.All(sc, &documents)
+	}); err != nil {
+		log.Println("Unable to read page: ", err)
+		return nil, err
+	}
+
+	hasMore := int64(len(documents)) > pageOptions.Limit
+	if hasMore {
+		documents = documents[:pageOptions.Limit]
+	}
+	if backward {
+		for i, j := 0, len(documents)-1; i < j; i, j = i+1, j-1 {
+			documents[i], documents[j] = documents[j], documents[i]
+		}
+	}
+
+	results := reflect.MakeSlice(reflect.SliceOf(dataModel), len(documents), len(documents))
+	for i, document := range documents {
+		if err := bson.Unmarshal(document, results.Index(i).Addr().Interface()); err != nil {
+			log.Println("Unable to decode document: ", err)
+			return nil, err
+		}
+	}
+	resultsPointer := reflect.New(results.Type())
+	resultsPointer.Elem().Set(results)
+
+	page := &Page{Data: resultsPointer.Interface()}
+	if len(documents) == 0 {
+		return page, nil
+	}
+
+	// A page read forward has a previous page when it started from a cursor and a next page when more documents
+	// were found, the other way round backward
+	hasNext, hasPrevious := hasMore, cursor != ""
+	if backward {
+		hasNext, hasPrevious = true, hasMore
+	}
+	if hasNext {
+		if page.Next, err = getKeysetCursor(documents[len(documents)-1], sortField); err != nil {
+			return nil, err
+		}
+	}
+	if hasPrevious {
+		if page.Previous, err = getKeysetCursor(documents[0], sortField); err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+// getKeysetFilter return the filter of the documents after the position in the order, the value of the cursor is
+// compared with $eq so a document value holding operators is matched as a literal
+func getKeysetFilter(sortField string, value, id interface{}, ascending bool) bson.M {
+	operator := "$gt"
+	if !ascending {
+		operator = "$lt"
+	}
+
+	if sortField == "_id" {
+		return bson.M{"_id": bson.M{operator: id}}
+	}
+
+	return bson.M{"$or": bson.A{
+		bson.M{sortField: bson.M{operator: value}},
+		bson.M{sortField: bson.M{"$eq": value}, "_id": bson.M{operator: id}},
+	}}
+}
+
+// getKeysetCursor return the cursor of document
+func getKeysetCursor(document bson.Raw, sortField string) (string, error) {
+	var value interface{}
+	if sortField != "_id" {
+		if rawValue, err := document.LookupErr(strings.Split(sortField, ".")...); err == nil {
+			value = rawValue
+		}
+	}
+
+	return EncodeKeysetCursor(value, document.Lookup("_id"))
+}
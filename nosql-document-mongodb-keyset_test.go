@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestKeysetCursorRoundTrip(t *testing.T) {
+	objectID := primitive.NewObjectID()
+	decimal, _ := primitive.ParseDecimal128("12.50")
+
+	tests := []struct {
+		name  string
+		value interface{}
+		id    interface{}
+	}{
+		{name: "string", value: "alice", id: objectID},
+		{name: "int32", value: int32(42), id: int32(7)},
+		{name: "int64", value: int64(1) << 40, id: "user-1"},
+		{name: "double", value: 1.5, id: objectID},
+		{name: "decimal", value: decimal, id: objectID},
+		{name: "date", value: primitive.NewDateTimeFromTime(time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)), id: objectID},
+		{name: "object ID", value: objectID, id: objectID},
+		{name: "null", value: nil, id: objectID},
+		{name: "document", value: bson.D{{Key: "$ne", Value: nil}}, id: objectID},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cursor, err := EncodeKeysetCursor(test.value, test.id)
+			if err != nil {
+				t.Fatalf("EncodeKeysetCursor() error = %v", err)
+			}
+			if _, err := base64.RawURLEncoding.DecodeString(cursor); err != nil {
+				t.Errorf("EncodeKeysetCursor() = %q, want URL safe base64", cursor)
+			}
+
+			value, id, err := DecodeKeysetCursor(cursor)
+			if err != nil {
+				t.Fatalf("DecodeKeysetCursor() error = %v", err)
+			}
+			if !reflect.DeepEqual(value, test.value) {
+				t.Errorf("DecodeKeysetCursor() value = %#v, want %#v", value, test.value)
+			}
+			if !reflect.DeepEqual(id, test.id) {
+				t.Errorf("DecodeKeysetCursor() id = %#v, want %#v", id, test.id)
+			}
+		})
+	}
+}
+
+func TestDecodeKeysetCursorInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "not a cursor!"},
+		{name: "padded base64", cursor: base64.URLEncoding.EncodeToString([]byte(`{"v":1,"_id":12}`))},
+		{name: "not JSON", cursor: encode("v=1&_id=1")},
+		{name: "missing _id", cursor: encode(`{"v":{"$numberInt":"1"}}`)},
+		{name: "null _id", cursor: encode(`{"v":{"$numberInt":"1"},"_id":null}`)},
+		{name: "invalid Extended JSON", cursor: encode(`{"v":{"$numberInt":"x"},"_id":{"$numberInt":"1"}}`)},
+		{name: "empty", cursor: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, err := DecodeKeysetCursor(test.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeKeysetCursor() error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestGetKeysetCursor(t *testing.T) {
+	objectID := primitive.NewObjectID()
+	document, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: objectID},
+		{Key: "name", Value: "alice"},
+		{Key: "profile", Value: bson.D{{Key: "age", Value: int32(30)}}},
+	})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		sortField string
+		value     interface{}
+	}{
+		{name: "_id", sortField: "_id", value: nil},
+		{name: "field", sortField: "name", value: "alice"},
+		{name: "nested field", sortField: "profile.age", value: int32(30)},
+		{name: "missing field", sortField: "email", value: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cursor, err := getKeysetCursor(document, test.sortField)
+			if err != nil {
+				t.Fatalf("getKeysetCursor() error = %v", err)
+			}
+
+			value, id, err := DecodeKeysetCursor(cursor)
+			if err != nil {
+				t.Fatalf("DecodeKeysetCursor() error = %v", err)
+			}
+			if !reflect.DeepEqual(value, test.value) || id != objectID {
+				t.Errorf("DecodeKeysetCursor() = %#v, %#v, want %#v, %#v", value, id, test.value, objectID)
+			}
+		})
+	}
+}
+
+func TestGetKeysetFilter(t *testing.T) {
+	operatorValue := bson.D{{Key: "$ne", Value: nil}}
+
+	tests := []struct {
+		name      string
+		sortField string
+		value     interface{}
+		id        interface{}
+		ascending bool
+		want      bson.M
+	}{
+		{
+			name:      "_id ascending",
+			sortField: "_id",
+			id:        1,
+			ascending: true,
+			want:      bson.M{"_id": bson.M{"$gt": 1}},
+		},
+		{
+			name:      "field descending",
+			sortField: "name",
+			value:     "alice",
+			id:        1,
+			want: bson.M{"$or": bson.A{
+				bson.M{"name": bson.M{"$lt": "alice"}},
+				bson.M{"name": bson.M{"$eq": "alice"}, "_id": bson.M{"$lt": 1}},
+			}},
+		},
+		{
+			name:      "operator value is compared as a literal",
+			sortField: "name",
+			value:     operatorValue,
+			id:        1,
+			ascending: true,
+			want: bson.M{"$or": bson.A{
+				bson.M{"name": bson.M{"$gt": operatorValue}},
+				bson.M{"name": bson.M{"$eq": operatorValue}, "_id": bson.M{"$gt": 1}},
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := getKeysetFilter(test.sortField, test.value, test.id, test.ascending); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("getKeysetFilter() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// killableCommands are the commands stopped by OperationWatchdog, other commands like index builds are left running
+	killableCommands = []string{"aggregate", "find", "count", "distinct", "getMore", "mapReduce", "update", "delete", "findAndModify"}
+	// defaultWatchdogOps are the operation types checked by OperationWatchdog by default
+	defaultWatchdogOps = []string{"query", "getmore", "command", "update", "remove"}
+)
+
+// OperationFilter model for ListRunningOperations conditions, zero values match every operation
+type OperationFilter struct {
+	MinDuration time.Duration // minimum running time, precise to the second
+	Namespace   string        // database or database.collection
+	Op          string        // query, getmore, insert, update, remove, command...
+}
+
+// WatchdogOptions model for OperationWatchdog
+type WatchdogOptions struct {
+	Budget    time.Duration                    // operations running longer are killed
+	Interval  time.Duration                    // time between two checks, default 10 seconds
+	Namespace string                           // database or database.collection watched, every non system database when it is empty
+	Ops       []string                         // operation types killed, default query, getmore, command, update and remove
+	OnKill    func(operation RunningOperation) // called for every killed operation
+}
+
+// currentOp private model for an operation of the currentOp command result
+type currentOp struct {
+	OpID             interface{}            `bson:"opid"`
+	Op               string                 `bson:"op"`
+	Namespace        string                 `bson:"ns"`
+	Command          map[string]interface{} `bson:"command"`
+	MicrosecsRunning int64                  `bson:"microsecs_running"`
+	Client           string                 `bson:"client"`
+	Description      string                 `bson:"desc"`
+	PlanSummary      string                 `bson:"planSummary"`
+}
+
+// ListRunningOperations return the active operations of the deployment matching filter,
+// their OpID are the ones expected by KillOperation
+func (m *MongoClient) ListRunningOperations(filter *OperationFilter) ([]RunningOperation, error) {
+	if filter == nil {
+		filter = &OperationFilter{}
+	}
+	if filter.MinDuration < 0 {
+		return nil, &InvalidArgumentError{Argument: "minDuration", Reason: "cannot be negative"}
+	}
+
+	command := bson.D{
+		primitive.E{Key: "currentOp", Value: 1},
+		primitive.E{Key: "active", Value: true},
+	}
+	if filter.MinDuration > 0 {
+		command = append(command, primitive.E{Key: "secs_running", Value: bson.M{"$gte": int64(filter.MinDuration / time.Second)}})
+	}
+	if filter.Namespace != "" {
+		command = append(command, primitive.E{Key: "ns", Value: primitive.Regex{Pattern: "^" + regexp.QuoteMeta(filter.Namespace) + `(\.|$)`}})
+	}
+	if filter.Op != "" {
+		command = append(command, primitive.E{Key: "op", Value: filter.Op})
+	}
+
+	var result struct {
+		InProgress []currentOp `bson:"inprog"`
+	}
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		return m.getClient().Database("admin").RunCommand(sc, command).Decode(&result)
+	}); err != nil {
+		log.Println("Unable to list running operations: ", err)
+		return nil, err
+	}
+
+	operations := make([]RunningOperation, 0, len(result.InProgress))
+	for _, op := range result.InProgress {
+		duration := time.Duration(op.MicrosecsRunning) * time.Microsecond
+		if duration < filter.MinDuration {
+			continue
+		}
+
+		operations = append(operations, RunningOperation{
+			OpID:        op.OpID,
+			Op:          op.Op,
+			Namespace:   op.Namespace,
+			Command:     op.Command,
+			Duration:    duration,
+			Client:      op.Client,
+			Description: op.Description,
+			PlanSummary: op.PlanSummary,
+		})
+	}
+
+	return operations, nil
+}
+
+// KillOperation terminate the operation, opID is the OpID of ListRunningOperations, a number or "shard:number" on sharded clusters
+func (m *MongoClient) KillOperation(opID interface{}) error {
+	if err := checkNotNil("opID", opID); err != nil {
+		return err
+	}
+
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		return m.getClient().Database("admin").RunCommand(sc, bson.D{
+			primitive.E{Key: "killOp", Value: 1},
+			primitive.E{Key: "op", Value: opID},
+		}).Err()
+	}); err != nil {
+		log.Println("Unable to kill operation: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// OperationWatchdog kill the read and write operations running longer than a budget, to stop runaway queries.
+// Operations of the admin, config and local databases and commands like index builds are never killed.
+type OperationWatchdog struct {
+	client  *MongoClient
+	options WatchdogOptions
+	mu      sync.RWMutex
+	stats   OperationWatchdogStats
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewOperationWatchdog init new watchdog, call Start to run it
+func NewOperationWatchdog(client *MongoClient, watchdogOptions WatchdogOptions) (*OperationWatchdog, error) {
+	if watchdogOptions.Budget <= 0 {
+		return nil, &InvalidArgumentError{Argument: "budget", Reason: "must be positive"}
+	}
+	if watchdogOptions.Interval <= 0 {
+		watchdogOptions.Interval = 10 * time.Second
+	}
+	if len(watchdogOptions.Ops) == 0 {
+		watchdogOptions.Ops = defaultWatchdogOps
+	}
+
+	return &OperationWatchdog{client: client, options: watchdogOptions}, nil
+}
+
+// Start check the operations every interval, until Stop
+func (w *OperationWatchdog) Start() {
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(w.options.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				if _, err := w.Check(); err != nil {
+					log.Println("Unable to check running operations: ", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop the checks and wait for the running one
+func (w *OperationWatchdog) Stop() {
+	if w.stop == nil {
+		return
+	}
+
+	close(w.stop)
+	<-w.done
+	w.stop = nil
+}
+
+// Stats return the watchdog metrics
+func (w *OperationWatchdog) Stats() OperationWatchdogStats {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.stats
+}
+
+// Check kill the operations over budget now and return them
+func (w *OperationWatchdog) Check() ([]RunningOperation, error) {
+	operations, err := w.client.ListRunningOperations(&OperationFilter{MinDuration: w.options.Budget, Namespace: w.options.Namespace})
+
+	w.mu.Lock()
+	w.stats.Checks++
+	w.stats.LastError = ""
+	if err != nil {
+		w.stats.LastError = err.Error()
+	}
+	w.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var killed []RunningOperation
+	for _, operation := range operations {
+		if operation.Duration < w.options.Budget || !w.isKillable(operation) {
+			continue
+		}
+
+		if err := w.client.KillOperation(operation.OpID); err != nil {
+			w.mu.Lock()
+			w.stats.LastError = err.Error()
+			w.mu.Unlock()
+			continue
+		}
+		log.Printf("Killed operation %v on %s running for %v\n", operation.OpID, operation.Namespace, operation.Duration)
+
+		w.mu.Lock()
+		w.stats.Killed++
+		w.mu.Unlock()
+
+		killed = append(killed, operation)
+		if w.options.OnKill != nil {
+			w.options.OnKill(operation)
+		}
+	}
+
+	return killed, nil
+}
+
+// isKillable return true for the watched operation types outside of the system databases, commands must be reads or writes
+func (w *OperationWatchdog) isKillable(operation RunningOperation) bool {
+	databaseName := strings.SplitN(operation.Namespace, ".", 2)[0]
+	if databaseName == "" || containsString(systemDatabases, databaseName) || !containsString(w.options.Ops, operation.Op) {
+		return false
+	}
+
+	if operation.Op != "command" {
+		return true
+	}
+	for _, command := range killableCommands {
+		if _, ok := operation.Command[command]; ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultOrphanBatchSize is the number of referencing documents checked per batch
+	defaultOrphanBatchSize = 1000
+	// defaultOrphanMaxIDs is the number of orphan _id reported per reference
+	defaultOrphanMaxIDs = 1000
+)
+
+// OrphanOptions model for FindOrphans and CleanOrphans
+type OrphanOptions struct {
+	BatchSize    int           // referencing documents checked per batch, default 1000
+	PauseBetween time.Duration // sleep after each batch so large collections don't hold the cluster
+	MaxIDs       int           // orphan _id reported per reference, default 1000, every orphan is counted
+}
+
+// orphanCandidate private model for a referencing document and the number of its referenced documents
+type orphanCandidate struct {
+	ID      interface{} `bson:"_id"`
+	Parents int         `bson:"parents"`
+}
+
+// FindOrphans report for every declared reference the documents whose referenced documents no longer exist, like
+// orders of deleted users. The documents referencing several documents by an array are orphans when none exist.
+func (r *ReferentialDatabase) FindOrphans(orphanOptions *OrphanOptions) ([]OrphanReport, error) {
+	return r.scanOrphans(orphanOptions, false)
+}
+
+// CleanOrphans delete the orphans found like FindOrphans by batches, the deletions follow the references of the
+// orphans so they restrict or cascade like Delete
+func (r *ReferentialDatabase) CleanOrphans(orphanOptions *OrphanOptions) ([]OrphanReport, error) {
+	return r.scanOrphans(orphanOptions, true)
+}
+
+// scanOrphans find the orphans of every reference and delete them when clean is true
+func (r *ReferentialDatabase) scanOrphans(orphanOptions *OrphanOptions, clean bool) ([]OrphanReport, error) {
+	if orphanOptions == nil {
+		orphanOptions = &OrphanOptions{}
+	}
+	batchSize := orphanOptions.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultOrphanBatchSize
+	}
+	maxIDs := orphanOptions.MaxIDs
+	if maxIDs <= 0 {
+		maxIDs = defaultOrphanMaxIDs
+	}
+
+	var errs *multierror.Error
+	reports := make([]OrphanReport, 0, len(r.references))
+	for _, reference := range r.references {
+		report := OrphanReport{
+			Database:             reference.Database,
+			Collection:           reference.Collection,
+			Field:                reference.Field,
+			ReferencedCollection: reference.ReferencedCollection,
+			IDs:                  []interface{}{},
+		}
+		if err := r.scanReferenceOrphans(reference, batchSize, maxIDs, orphanOptions.PauseBetween, clean, &report); err != nil {
+			log.Printf("Unable to scan orphans of %s.%s: %v\n", reference.Collection, reference.Field, err)
+			errs = multierror.Append(errs, err)
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, errs.ErrorOrNil()
+}
+
+// scanReferenceOrphans find the orphans of reference by batches in _id order and add them to report
+func (r *ReferentialDatabase) scanReferenceOrphans(reference Reference, batchSize, maxIDs int, pauseBetween time.Duration, clean bool, report *OrphanReport) error {
+	var lastID interface{}
+	for {
+		if err := r.client.waitHealthy(); err != nil {
+			return err
+		}
+
+		candidates, err := r.getOrphanCandidates(reference, batchSize, lastID)
+		if err != nil {
+			return err
+		}
+		if len(candidates) == 0 {
+			return nil
+		}
+		lastID = candidates[len(candidates)-1].ID
+
+		var orphans []interface{}
+		for _, candidate := range candidates {
+			if candidate.Parents == 0 {
+				orphans = append(orphans, candidate.ID)
+			}
+		}
+		report.Checked += int64(len(candidates))
+		report.Orphans += int64(len(orphans))
+		for _, id := range orphans {
+			if len(report.IDs) < maxIDs {
+				report.IDs = append(report.IDs, id)
+			}
+		}
+
+		if clean && len(orphans) > 0 {
+			// The referenced documents may have been created since the check
+			existing, err := r.getExistingValues(reference, orphans)
+			if err != nil {
+				return err
+			}
+			filter := bson.M{"_id": bson.M{"$in": orphans}, reference.Field: bson.M{"$nin": existing}}
+			result, err := r.Delete(reference.Database, reference.Collection, filter)
+			if err != nil {
+				return err
+			}
+			if deleteResult, ok := result.(*mongo.DeleteResult); ok {
+				report.Deleted += deleteResult.DeletedCount
+			}
+		}
+
+		if len(candidates) < batchSize {
+			return nil
+		}
+		if pauseBetween > 0 {
+			time.Sleep(pauseBetween)
+		}
+	}
+}
+
+// getOrphanCandidates return the next batchSize documents referencing a document after lastID with the number of
+// their referenced documents found by $lookup
+func (r *ReferentialDatabase) getOrphanCandidates(reference Reference, batchSize int, lastID interface{}) ([]orphanCandidate, error) {
+	match := bson.M{reference.Field: bson.M{"$exists": true, "$nin": bson.A{nil, bson.A{}}}}
+	if lastID != nil {
+		match = bson.M{"$and": bson.A{match, bson.M{"_id": bson.M{"$gt": lastID}}}}
+	}
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$sort": bson.M{"_id": 1}},
+		bson.M{"$limit": batchSize},
+		bson.M{"$lookup": bson.M{
+			"from":         reference.ReferencedCollection,
+			"localField":   reference.Field,
+			"foreignField": reference.ReferencedField,
+			"as":           "_parents",
+		}},
+		bson.M{"$project": bson.M{"_id": 1, "parents": bson.M{"$size": "$_parents"}}},
+	}
+	if err := checkPipelineCompatibility(r.client.getConfig(), pipeline); err != nil {
+		return nil, err
+	}
+
+	var candidates []orphanCandidate
+	if err := r.client.executeWithoutTransaction(func(sc context.Context) error {
+		collection := r.client.getClient().Database(reference.Database).Collection(reference.Collection)
+		cur, err := collection.Aggregate(sc, pipeline, options.Aggregate().SetAllowDiskUse(true))
+		if err != nil {
+			return err
+		}
+
+		return cur.All(sc, &candidates)
+	}); err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+// getExistingValues return the referenced values of the orphans which exist now
+func (r *ReferentialDatabase) getExistingValues(reference Reference, orphans []interface{}) (bson.A, error) {
+	existing := bson.A{}
+	if err := r.client.executeWithoutTransaction(func(sc context.Context) error {
+		database := r.client.getClient().Database(reference.Database)
+		values, err := database.Collection(reference.Collection).Distinct(sc, reference.Field, bson.M{"_id": bson.M{"$in": orphans}})
+		if err != nil || len(values) == 0 {
+			return err
+		}
+
+		found, err := database.Collection(reference.ReferencedCollection).Distinct(sc, reference.ReferencedField, bson.M{reference.ReferencedField: bson.M{"$in": values}})
+		existing = append(existing, found...)
+		return err
+	}); err != nil {
+		log.Println("Unable to check referenced documents: ", err)
+		return nil, err
+	}
+
+	return existing, nil
+}
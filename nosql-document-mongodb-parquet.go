@@ -0,0 +1,312 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"sort"
+
+	"github.com/parquet-go/parquet-go"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultParquetSampleSize is the number of documents read to infer the Parquet schema
+	defaultParquetSampleSize = 100
+)
+
+// ParquetOptions model for ExportParquet
+type ParquetOptions struct {
+	Model      reflect.Type // struct with parquet tags the schema is derived from, the schema is inferred when it is nil
+	SampleSize int          // documents read to infer the schema, default is 100
+	BatchSize  int32        // documents fetched per cursor batch, default is 1000
+	Strict     bool         // fail on the first value not matching the type of its inferred column instead of writing null
+}
+
+// ExportParquet stream the documents matching filter to w as a Parquet file and return the number of documents exported.
+// The inferred schema has one optional column per top-level field of the sampled documents: strings, integers, doubles,
+// booleans and dates keep their type, ObjectIDs are written as hex strings, documents and arrays as Extended JSON and
+// fields with types conflicting between the sampled documents as strings. The values of the next documents not
+// matching their column are written as null and counted in the report, or fail the export in strict mode. Use
+// UploadExport or UploadObjectExport to write the file to a storage backend, like S3 or GCS.
+func (m *MongoClient) ExportParquet(databaseName, collectionName string, filter interface{}, w io.Writer, parquetOptions *ParquetOptions) (*ParquetReport, error) {
+	if parquetOptions == nil {
+		parquetOptions = &ParquetOptions{}
+	}
+	if parquetOptions.Model != nil && parquetOptions.Model.Kind() != reflect.Struct {
+		return nil, errors.New("Parquet model must be a struct")
+	}
+	sampleSize := parquetOptions.SampleSize
+	if sampleSize <= 0 {
+		sampleSize = defaultParquetSampleSize
+	}
+	batchSize := parquetOptions.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultExportBatchSize
+	}
+
+	var report *ParquetReport
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		report = &ParquetReport{Rejected: map[string]int64{}}
+
+		findOptions := options.Find()
+		findOptions.SetBatchSize(batchSize)
+		findOptions.SetSort(bson.D{primitive.E{Key: "_id", Value: 1}})
+
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+		cur, err := collection.Find(sc, filter, findOptions)
+		if err != nil {
+			log.Println("Unable to read document: ", err)
+			return err
+		}
+		defer cur.Close(sc)
+
+		if parquetOptions.Model != nil {
+			report.Exported, err = writeParquetModel(sc, cur, w, parquetOptions.Model)
+		} else {
+			err = writeParquetInferred(sc, cur, w, sampleSize, parquetOptions.Strict, report)
+		}
+		if err != nil {
+			log.Println("Unable to export document: ", err)
+		}
+
+		return err
+	}); err != nil {
+		log.Println("Unable to execute MongoDB operation: ", err)
+		return report, err
+	}
+
+	return report, nil
+}
+
+// writeParquetModel write the documents of the cursor decoded to model
+func writeParquetModel(sc context.Context, cur *mongo.Cursor, w io.Writer, model reflect.Type) (int64, error) {
+	writer := parquet.NewWriter(w, parquet.SchemaOf(reflect.New(model).Interface()))
+
+	var count int64
+	for cur.Next(sc) {
+		row := reflect.New(model).Interface()
+		if err := cur.Decode(row); err != nil {
+			return count, err
+		}
+		if err := writer.Write(row); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := cur.Err(); err != nil {
+		return count, err
+	}
+
+	return count, writer.Close()
+}
+
+// writeParquetInferred write the documents of the cursor with a schema inferred from the first sampleSize documents,
+// the exported documents and the rejected values are added to report
+func writeParquetInferred(sc context.Context, cur *mongo.Cursor, w io.Writer, sampleSize int, strict bool, report *ParquetReport) error {
+	sample := make([]bson.Raw, 0, sampleSize)
+	for len(sample) < sampleSize && cur.Next(sc) {
+		sample = append(sample, append(bson.Raw(nil), cur.Current...))
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+
+	columns, err := inferParquetColumns(sample)
+	if err != nil {
+		return err
+	}
+
+	group := parquet.Group{}
+	for _, column := range columns {
+		group[column.name] = parquet.Optional(getParquetNode(column.kind))
+	}
+	writer := parquet.NewWriter(w, parquet.NewSchema("document", group))
+
+	write := func(document bson.Raw) error {
+		row, rejected := getParquetRow(document, columns)
+		for _, column := range rejected {
+			if strict {
+				return fmt.Errorf("Value of %s in document %v does not match its column type %v", column.name, document.Lookup("_id"), column.kind)
+			}
+			report.Rejected[column.name]++
+		}
+
+		if _, err := writer.WriteRows([]parquet.Row{row}); err != nil {
+			return err
+		}
+		report.Exported++
+
+		return nil
+	}
+
+	for _, document := range sample {
+		if err := write(document); err != nil {
+			return err
+		}
+	}
+	for cur.Next(sc) {
+		if err := write(cur.Current); err != nil {
+			return err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// parquetColumn is an inferred column, columns are sorted by name like the fields of a Parquet group
+type parquetColumn struct {
+	name string
+	kind bsontype.Type
+}
+
+// inferParquetColumns return the columns of the top-level fields of documents
+func inferParquetColumns(documents []bson.Raw) ([]parquetColumn, error) {
+	kinds := map[string]bsontype.Type{}
+	for _, document := range documents {
+		elements, err := document.Elements()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, element := range elements {
+			kind := getParquetKind(element.Value().Type)
+			if kind == bsontype.Null {
+				if _, ok := kinds[element.Key()]; !ok {
+					kinds[element.Key()] = bsontype.Null
+				}
+				continue
+			}
+
+			current, ok := kinds[element.Key()]
+			switch {
+			case !ok || current == bsontype.Null:
+				kinds[element.Key()] = kind
+			case current == kind:
+			case isParquetNumber(current) && isParquetNumber(kind):
+				kinds[element.Key()] = bsontype.Double
+			default:
+				kinds[element.Key()] = bsontype.String
+			}
+		}
+	}
+
+	columns := make([]parquetColumn, 0, len(kinds))
+	for name, kind := range kinds {
+		if kind == bsontype.Null {
+			kind = bsontype.String
+		}
+		columns = append(columns, parquetColumn{name, kind})
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].name < columns[j].name })
+
+	return columns, nil
+}
+
+// getParquetKind return the column type of a BSON type, Null for missing values
+func getParquetKind(kind bsontype.Type) bsontype.Type {
+	switch kind {
+	case bsontype.Int32, bsontype.Int64, bsontype.Double, bsontype.Boolean, bsontype.DateTime, bsontype.Null:
+		return kind
+	case bsontype.Undefined:
+		return bsontype.Null
+	}
+
+	return bsontype.String
+}
+
+// isParquetNumber return true for numeric column types
+func isParquetNumber(kind bsontype.Type) bool {
+	return kind == bsontype.Int32 || kind == bsontype.Int64 || kind == bsontype.Double
+}
+
+// getParquetNode return the Parquet node of a column type
+func getParquetNode(kind bsontype.Type) parquet.Node {
+	switch kind {
+	case bsontype.Int32:
+		return parquet.Int(32)
+	case bsontype.Int64:
+		return parquet.Int(64)
+	case bsontype.Double:
+		return parquet.Leaf(parquet.DoubleType)
+	case bsontype.Boolean:
+		return parquet.Leaf(parquet.BooleanType)
+	case bsontype.DateTime:
+		return parquet.Timestamp(parquet.Millisecond)
+	}
+
+	return parquet.String()
+}
+
+// getParquetRow return the row of document and the columns whose value was rejected, values not matching the column
+// type are converted when possible or rejected and written as null
+func getParquetRow(document bson.Raw, columns []parquetColumn) (parquet.Row, []parquetColumn) {
+	row := make(parquet.Row, len(columns))
+	var rejected []parquetColumn
+	for i, column := range columns {
+		value, err := document.LookupErr(column.name)
+		if err != nil || value.Type == bsontype.Null || value.Type == bsontype.Undefined {
+			row[i] = parquet.NullValue().Level(0, 0, i)
+			continue
+		}
+
+		parquetValue, ok := getParquetValue(value, column.kind)
+		if !ok {
+			rejected = append(rejected, column)
+			row[i] = parquet.NullValue().Level(0, 0, i)
+			continue
+		}
+		row[i] = parquetValue.Level(0, 1, i)
+	}
+
+	return row, rejected
+}
+
+// getParquetValue convert value to the column type
+func getParquetValue(value bson.RawValue, kind bsontype.Type) (parquet.Value, bool) {
+	if value.Type == bsontype.Null || value.Type == bsontype.Undefined {
+		return parquet.Value{}, false
+	}
+
+	switch kind {
+	case bsontype.Int32:
+		if v, ok := value.Int32OK(); ok {
+			return parquet.Int32Value(v), true
+		}
+	case bsontype.Int64:
+		if v, ok := value.AsInt64OK(); ok {
+			return parquet.Int64Value(v), true
+		}
+	case bsontype.Double:
+		switch value.Type {
+		case bsontype.Double:
+			return parquet.DoubleValue(value.Double()), true
+		case bsontype.Int32:
+			return parquet.DoubleValue(float64(value.Int32())), true
+		case bsontype.Int64:
+			return parquet.DoubleValue(float64(value.Int64())), true
+		}
+	case bsontype.Boolean:
+		if v, ok := value.BooleanOK(); ok {
+			return parquet.BooleanValue(v), true
+		}
+	case bsontype.DateTime:
+		if v, ok := value.DateTimeOK(); ok {
+			return parquet.Int64Value(v), true
+		}
+	default:
+		return parquet.ByteArrayValue([]byte(formatCSVValue(value))), true
+	}
+
+	return parquet.Value{}, false
+}
@@ -0,0 +1,301 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrInvalidPatch is returned when the patch is neither a JSON Patch nor a JSON Merge Patch document
+	ErrInvalidPatch = errors.New("Invalid patch")
+	// ErrPatchPathNotFound is returned when a JSON Patch operation target a missing value
+	ErrPatchPathNotFound = errors.New("Patch path not found")
+	// ErrPatchTestFailed is returned when a JSON Patch test operation does not match the document
+	ErrPatchTestFailed = errors.New("Patch test failed")
+)
+
+// patchOperation private model for a JSON Patch operation
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// ApplyPatch apply a JSON Patch (RFC 6902, a JSON array) or a JSON Merge Patch (RFC 7396, a JSON object)
+// to the document based on its ID. Patches are translated to $set, $unset and array operations and all the
+// operations of a patch run in one transaction when the deployment supports them. Values are relaxed extended JSON.
+func (m *MongoClient) ApplyPatch(databaseName, collectionName string, id interface{}, patch []byte) error {
+	patch = bytes.TrimSpace(patch)
+	if len(patch) == 0 {
+		return ErrInvalidPatch
+	}
+
+	var operations []patchOperation
+	var mergePatch bson.Raw
+	switch patch[0] {
+	case '[':
+		if err := json.Unmarshal(patch, &operations); err != nil {
+			return ErrInvalidPatch
+		}
+	case '{':
+		if err := bson.UnmarshalExtJSON(patch, false, &mergePatch); err != nil {
+			return ErrInvalidPatch
+		}
+	default:
+		return ErrInvalidPatch
+	}
+
+	release, err := m.admit(databaseName, collectionName)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	timeout := m.getTimeout(OperationUpdate, databaseName, collectionName)
+	if err := m.execute(func(sc context.Context) error {
+		sc, cancel := withTimeout(sc, timeout)
+		defer cancel()
+
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+
+		if mergePatch != nil {
+			set, unset := bson.M{}, bson.M{}
+			if err := getMergePatchUpdate(mergePatch, "", set, unset); err != nil {
+				return err
+			}
+
+			update := bson.M{}
+			if len(set) > 0 {
+				update["$set"] = set
+			}
+			if len(unset) > 0 {
+				update["$unset"] = unset
+			}
+			if len(update) == 0 {
+				return nil
+			}
+
+			return updatePatchedDocument(sc, collection, id, update)
+		}
+
+		for _, operation := range operations {
+			if err := applyPatchOperation(sc, collection, id, operation); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		log.Println("Unable to apply patch: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// getMergePatchUpdate collect the fields set and removed by the merge patch, objects are merged recursively
+func getMergePatchUpdate(patch bson.Raw, prefix string, set, unset bson.M) error {
+	elements, err := patch.Elements()
+	if err != nil {
+		return err
+	}
+
+	for _, element := range elements {
+		if !isPatchKey(element.Key()) || (prefix == "" && element.Key() == "_id") {
+			return ErrInvalidPatch
+		}
+
+		path := prefix + element.Key()
+		value := element.Value()
+		switch value.Type {
+		case bsontype.Null:
+			unset[path] = ""
+		case bsontype.EmbeddedDocument:
+			if err := getMergePatchUpdate(value.Document(), path+".", set, unset); err != nil {
+				return err
+			}
+		default:
+			set[path] = value
+		}
+	}
+
+	return nil
+}
+
+// applyPatchOperation apply one JSON Patch operation to the current document
+func applyPatchOperation(sc context.Context, collection *mongo.Collection, id interface{}, operation patchOperation) error {
+	path, err := getPatchPath(operation.Path)
+	if err != nil {
+		return err
+	}
+
+	document, err := collection.FindOne(sc, bson.M{"_id": id}).DecodeBytes()
+	if err == mongo.ErrNoDocuments {
+		return ErrDocumentNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	switch operation.Op {
+	case "add", "replace", "test":
+		value, err := getPatchValue(operation.Value)
+		if err != nil {
+			return err
+		}
+
+		switch operation.Op {
+		case "add":
+			return addPatchValue(sc, collection, id, document, path, value)
+		case "replace":
+			if _, err := document.LookupErr(path...); err != nil {
+				return ErrPatchPathNotFound
+			}
+			return updatePatchedDocument(sc, collection, id, bson.M{"$set": bson.M{strings.Join(path, "."): value}})
+		}
+
+		current, err := document.LookupErr(path...)
+		if err != nil || getValueKey(current) != getValueKey(value) {
+			return ErrPatchTestFailed
+		}
+		return nil
+	case "remove":
+		return removePatchValue(sc, collection, id, document, path)
+	case "move", "copy":
+		from, err := getPatchPath(operation.From)
+		if err != nil {
+			return err
+		}
+		value, err := document.LookupErr(from...)
+		if err != nil {
+			return ErrPatchPathNotFound
+		}
+
+		if operation.Op == "move" {
+			if err := removePatchValue(sc, collection, id, document, from); err != nil {
+				return err
+			}
+			if document, err = collection.FindOne(sc, bson.M{"_id": id}).DecodeBytes(); err != nil {
+				return err
+			}
+		}
+
+		return addPatchValue(sc, collection, id, document, path, value)
+	}
+
+	return ErrInvalidPatch
+}
+
+// addPatchValue insert value in the array or set the field of path
+func addPatchValue(sc context.Context, collection *mongo.Collection, id interface{}, document bson.Raw, path []string, value interface{}) error {
+	parent, last := strings.Join(path[:len(path)-1], "."), path[len(path)-1]
+	if isPatchArray(document, path[:len(path)-1]) {
+		if last == "-" {
+			return updatePatchedDocument(sc, collection, id, bson.M{"$push": bson.M{parent: value}})
+		}
+
+		position, err := strconv.Atoi(last)
+		if err != nil || position < 0 {
+			return ErrPatchPathNotFound
+		}
+		return updatePatchedDocument(sc, collection, id, bson.M{"$push": bson.M{parent: bson.M{"$each": bson.A{value}, "$position": position}}})
+	}
+
+	return updatePatchedDocument(sc, collection, id, bson.M{"$set": bson.M{strings.Join(path, "."): value}})
+}
+
+// removePatchValue remove the array element or unset the field of path
+func removePatchValue(sc context.Context, collection *mongo.Collection, id interface{}, document bson.Raw, path []string) error {
+	if _, err := document.LookupErr(path...); err != nil {
+		return ErrPatchPathNotFound
+	}
+
+	if !isPatchArray(document, path[:len(path)-1]) {
+		return updatePatchedDocument(sc, collection, id, bson.M{"$unset": bson.M{strings.Join(path, "."): ""}})
+	}
+
+	position, err := strconv.Atoi(path[len(path)-1])
+	if err != nil {
+		return ErrPatchPathNotFound
+	}
+
+	// Array elements are removed by index with an aggregation pipeline update
+	parent := strings.Join(path[:len(path)-1], ".")
+	return updatePatchedDocument(sc, collection, id, mongo.Pipeline{bson.D{primitive.E{Key: "$set", Value: bson.M{
+		parent: bson.M{"$concatArrays": bson.A{
+			bson.M{"$slice": bson.A{"$" + parent, position}},
+			bson.M{"$slice": bson.A{"$" + parent, position + 1, bson.M{"$size": "$" + parent}}},
+		}},
+	}}}})
+}
+
+// updatePatchedDocument update the document based on its ID
+func updatePatchedDocument(sc context.Context, collection *mongo.Collection, id, update interface{}) error {
+	result, err := collection.UpdateOne(sc, bson.M{"_id": id}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrDocumentNotFound
+	}
+
+	return nil
+}
+
+// isPatchArray return true when the value of path in document is an array
+func isPatchArray(document bson.Raw, path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	value, err := document.LookupErr(path...)
+	return err == nil && value.Type == bsontype.Array
+}
+
+// getPatchPath convert a JSON Pointer (RFC 6901) to the path segments of a field, the document root is not allowed
+func getPatchPath(pointer string) ([]string, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, ErrInvalidPatch
+	}
+
+	segments := strings.Split(pointer[1:], "/")
+	for i, segment := range segments {
+		segment = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+		if !isPatchKey(segment) || (i == 0 && segment == "_id") {
+			return nil, ErrInvalidPatch
+		}
+		segments[i] = segment
+	}
+
+	return segments, nil
+}
+
+// getPatchValue decode the relaxed extended JSON value of a JSON Patch operation
+func getPatchValue(value json.RawMessage) (bson.RawValue, error) {
+	if len(value) == 0 {
+		return bson.RawValue{}, ErrInvalidPatch
+	}
+
+	var document bson.Raw
+	if err := bson.UnmarshalExtJSON([]byte(`{"value":`+string(value)+`}`), false, &document); err != nil {
+		return bson.RawValue{}, ErrInvalidPatch
+	}
+
+	return document.Lookup("value"), nil
+}
+
+// isPatchKey return true when key can be a field name of an update path
+func isPatchKey(key string) bool {
+	return key != "" && !strings.Contains(key, ".") && !strings.HasPrefix(key, "$")
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// ProfilingOff disable the profiler
+	ProfilingOff = 0
+	// ProfilingSlowOperations profile the operations slower than slowms
+	ProfilingSlowOperations = 1
+	// ProfilingAll profile every operation
+	ProfilingAll = 2
+	// profileCollection is the collection of the profiler entries of each database
+	profileCollection = "system.profile"
+)
+
+// ProfileQuery model for ReadProfile conditions, zero values match every entry
+type ProfileQuery struct {
+	Collection string    // collection of the operations
+	Op         string    // query, insert, update, remove, command, getmore...
+	MinMillis  int64     // minimum duration of the operations
+	Since      time.Time // inclusive
+	Limit      int64
+}
+
+// profileStatus private model for profile command result
+type profileStatus struct {
+	Was        int     `bson:"was"`
+	SlowMS     int64   `bson:"slowms"`
+	SampleRate float64 `bson:"sampleRate"`
+}
+
+// GetProfilingLevel return the profiling level, slowms and sample rate of the database
+func (m *MongoClient) GetProfilingLevel(databaseName string) (*ProfilingStatus, error) {
+	return m.profile(databaseName, bson.D{primitive.E{Key: "profile", Value: -1}})
+}
+
+// SetProfilingLevel set the profiling level and the slowms threshold of the database, slowMS 0 keeps the current threshold.
+// It return the previous status to restore it once the investigation is done.
+func (m *MongoClient) SetProfilingLevel(databaseName string, level int, slowMS int64) (*ProfilingStatus, error) {
+	if level < ProfilingOff || level > ProfilingAll {
+		return nil, &InvalidArgumentError{Argument: "level", Reason: "must be 0, 1 or 2"}
+	}
+	if slowMS < 0 {
+		return nil, &InvalidArgumentError{Argument: "slowMS", Reason: "cannot be negative"}
+	}
+
+	command := bson.D{primitive.E{Key: "profile", Value: level}}
+	if slowMS > 0 {
+		command = append(command, primitive.E{Key: "slowms", Value: slowMS})
+	}
+
+	return m.profile(databaseName, command)
+}
+
+// profile run the profile command on the database and return the status before the command
+func (m *MongoClient) profile(databaseName string, command bson.D) (*ProfilingStatus, error) {
+	if databaseName == "" {
+		return nil, &InvalidArgumentError{Argument: "databaseName", Reason: "cannot be empty"}
+	}
+
+	var result profileStatus
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		return m.getClient().Database(databaseName).RunCommand(sc, command).Decode(&result)
+	}); err != nil {
+		log.Println("Unable to run profile command: ", err)
+		return nil, err
+	}
+
+	return &ProfilingStatus{Level: result.Was, SlowMS: result.SlowMS, SampleRate: result.SampleRate}, nil
+}
+
+// ReadProfile return the profiler entries of the database matching query, most recent first.
+// GetQueryShape of the filter and sort of the command gives the shapes of slow queries for SuggestIndexes.
+func (m *MongoClient) ReadProfile(databaseName string, query *ProfileQuery) ([]ProfileEntry, error) {
+	if query == nil {
+		query = &ProfileQuery{}
+	}
+	if err := checkLimit(query.Limit); err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{}
+	if query.Collection != "" {
+		filter["ns"] = databaseName + "." + query.Collection
+	}
+	if query.Op != "" {
+		filter["op"] = query.Op
+	}
+	if query.MinMillis > 0 {
+		filter["millis"] = bson.M{"$gte": query.MinMillis}
+	}
+	if !query.Since.IsZero() {
+		filter["ts"] = bson.M{"$gte": query.Since}
+	}
+
+	release, err := m.admit(databaseName, profileCollection)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var entries []ProfileEntry
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		findOptions := options.Find().SetSort(bson.D{primitive.E{Key: "ts", Value: -1}}).SetLimit(query.Limit)
+
+		cur, err := m.getClient().Database(databaseName).Collection(profileCollection).Find(sc, filter, findOptions)
+		if err != nil {
+			return err
+		}
+
+		return cur.All(sc, &entries)
+	}); err != nil {
+		log.Println("Unable to read profile: ", err)
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,336 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// QueryParam is the placeholder of an argument in a named query, like bson.M{"status": QueryParam("status")}
+type QueryParam string
+
+// QueryRegistry keep the named filters and pipelines of the application, they are registered and validated at startup
+// and executed by name with arguments. The bound queries of a name share their shape, so the server reuses their
+// cached plans, and no query is built from strings at runtime. The arguments of the fields of filters and $match
+// stages are compared with $eq, so {"age": QueryParam("age")} bound with {"$ne": null} match this literal document
+// instead of every document, the arguments of operators like {"$gt": QueryParam("min")} are bound as they are.
+type QueryRegistry struct {
+	mu      sync.RWMutex
+	client  *MongoClient
+	queries map[string]*namedQuery
+}
+
+// namedQuery private model for a compiled named query
+type namedQuery struct {
+	databaseName   string
+	collectionName string
+	template       interface{} // normalized to bson.D, bson.A and QueryParam
+	params         []string
+	pipeline       bool
+}
+
+// NewQueryRegistry init new registry executing queries on client
+func NewQueryRegistry(client *MongoClient) *QueryRegistry {
+	return &QueryRegistry{client: client, queries: make(map[string]*namedQuery)}
+}
+
+// RegisterFilter register the filter template under name, run it with Execute
+func (qr *QueryRegistry) RegisterFilter(name, databaseName, collectionName string, filter interface{}) error {
+	return qr.register(name, databaseName, collectionName, filter, false)
+}
+
+// RegisterPipeline register the aggregation pipeline template under name, run it with Execute
+func (qr *QueryRegistry) RegisterPipeline(name, databaseName, collectionName string, pipeline interface{}) error {
+	if err := checkPipelineCompatibility(qr.client.getConfig(), pipeline); err != nil {
+		return err
+	}
+
+	return qr.register(name, databaseName, collectionName, pipeline, true)
+}
+
+// register compile the template and keep it under name
+func (qr *QueryRegistry) register(name, databaseName, collectionName string, template interface{}, pipeline bool) error {
+	if name == "" || databaseName == "" || collectionName == "" {
+		return fmt.Errorf("Name, database and collection of query cannot be empty")
+	}
+
+	params := make(map[string]bool)
+	query := &namedQuery{
+		databaseName:   databaseName,
+		collectionName: collectionName,
+		template:       compileQuery(template, params),
+		pipeline:       pipeline,
+	}
+	if pipeline {
+		if err := checkPipelineTemplate(query.template); err != nil {
+			return fmt.Errorf("Invalid pipeline %q: %w", name, err)
+		}
+	}
+	for param := range params {
+		query.params = append(query.params, param)
+	}
+	sort.Strings(query.params)
+
+	qr.mu.Lock()
+	defer qr.mu.Unlock()
+
+	if _, ok := qr.queries[name]; ok {
+		return fmt.Errorf("Query %q is already registered", name)
+	}
+	qr.queries[name] = query
+
+	return nil
+}
+
+// Params return the sorted argument names of the named query
+func (qr *QueryRegistry) Params(name string) ([]string, error) {
+	query, err := qr.get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return query.params, nil
+}
+
+// Execute run the named query with args bound to its placeholders, limit only applies to filters
+func (qr *QueryRegistry) Execute(name string, args map[string]interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	query, err := qr.get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	bound, err := query.bind(name, args)
+	if err != nil {
+		return nil, err
+	}
+	if query.pipeline {
+		return qr.client.Aggregate(query.databaseName, query.collectionName, bound, dataModel)
+	}
+
+	return qr.client.Read(query.databaseName, query.collectionName, bound, limit, dataModel)
+}
+
+// Validate check the named query on the server with example args bound to its placeholders, the query is planned
+// by explain but not run, so operators, stages and field paths errors are found before the first request
+func (qr *QueryRegistry) Validate(name string, args map[string]interface{}) error {
+	query, err := qr.get(name)
+	if err != nil {
+		return err
+	}
+
+	bound, err := query.bind(name, args)
+	if err != nil {
+		return err
+	}
+
+	command := bson.D{primitive.E{Key: "find", Value: query.collectionName}, primitive.E{Key: "filter", Value: bound}}
+	if query.pipeline {
+		command = bson.D{
+			primitive.E{Key: "aggregate", Value: query.collectionName},
+			primitive.E{Key: "pipeline", Value: bound},
+			primitive.E{Key: "cursor", Value: bson.M{}},
+		}
+	}
+
+	release, err := qr.client.admit(query.databaseName, query.collectionName)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := qr.client.executeWithoutTransaction(func(sc context.Context) error {
+		return qr.client.getClient().Database(query.databaseName).RunCommand(sc, bson.D{
+			primitive.E{Key: "explain", Value: command},
+			primitive.E{Key: "verbosity", Value: "queryPlanner"},
+		}).Err()
+	}); err != nil {
+		return fmt.Errorf("Invalid query %q: %w", name, err)
+	}
+
+	return nil
+}
+
+// ValidateAll check every registered query like Validate, once at startup, examples hold the args of the queries
+// by name and can skip the queries without placeholders. It return the errors of every invalid query.
+func (qr *QueryRegistry) ValidateAll(examples map[string]map[string]interface{}) error {
+	qr.mu.RLock()
+	names := make([]string, 0, len(qr.queries))
+	for name := range qr.queries {
+		names = append(names, name)
+	}
+	qr.mu.RUnlock()
+	sort.Strings(names)
+
+	var errs *multierror.Error
+	for name := range examples {
+		if !containsString(names, name) {
+			errs = multierror.Append(errs, fmt.Errorf("Query %q is not registered", name))
+		}
+	}
+	for _, name := range names {
+		errs = multierror.Append(errs, qr.Validate(name, examples[name]))
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// bind check args against the placeholders of the query and return the template with args bound
+func (q *namedQuery) bind(name string, args map[string]interface{}) (interface{}, error) {
+	for _, param := range q.params {
+		if _, ok := args[param]; !ok {
+			return nil, fmt.Errorf("Missing argument %q of query %q", param, name)
+		}
+	}
+	for arg := range args {
+		if !containsString(q.params, arg) {
+			return nil, fmt.Errorf("Unknown argument %q of query %q", arg, name)
+		}
+	}
+
+	if !q.pipeline {
+		return bindQuery(q.template, args, true), nil
+	}
+
+	// the template is checked by checkPipelineTemplate, every stage is a document with one operator
+	stages := q.template.(bson.A)
+	bound := make(bson.A, 0, len(stages))
+	for _, stage := range stages {
+		operator := stage.(bson.D)[0]
+		bound = append(bound, bson.D{primitive.E{Key: operator.Key, Value: bindQuery(operator.Value, args, operator.Key == "$match")}})
+	}
+
+	return bound, nil
+}
+
+// checkPipelineTemplate return an error when the compiled pipeline is not a list of stages with one $ operator each
+func checkPipelineTemplate(template interface{}) error {
+	stages, ok := template.(bson.A)
+	if !ok {
+		return fmt.Errorf("pipeline must be a list of stages")
+	}
+
+	for i, stage := range stages {
+		document, ok := stage.(bson.D)
+		if !ok || len(document) != 1 || !strings.HasPrefix(document[0].Key, "$") {
+			return fmt.Errorf("stage %d must be a document with one $ operator", i)
+		}
+	}
+
+	return nil
+}
+
+// get return the named query
+func (qr *QueryRegistry) get(name string) (*namedQuery, error) {
+	qr.mu.RLock()
+	defer qr.mu.RUnlock()
+
+	query, ok := qr.queries[name]
+	if !ok {
+		return nil, fmt.Errorf("Query %q is not registered", name)
+	}
+
+	return query, nil
+}
+
+// compileQuery normalize documents to bson.D and lists to bson.A, and collect the placeholders
+func compileQuery(value interface{}, params map[string]bool) interface{} {
+	switch v := value.(type) {
+	case QueryParam:
+		params[string(v)] = true
+		return v
+	case bson.D:
+		document := make(bson.D, 0, len(v))
+		for _, element := range v {
+			document = append(document, primitive.E{Key: element.Key, Value: compileQuery(element.Value, params)})
+		}
+		return document
+	case primitive.E:
+		return primitive.E{Key: v.Key, Value: compileQuery(v.Value, params)}
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return value
+		}
+
+		document := make(bson.D, 0, rv.Len())
+		for _, key := range rv.MapKeys() {
+			document = append(document, primitive.E{Key: key.String(), Value: compileQuery(rv.MapIndex(key).Interface(), params)})
+		}
+		sort.Slice(document, func(i, j int) bool { return document[i].Key < document[j].Key })
+		return document
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return value
+		}
+
+		list := make(bson.A, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			list = append(list, compileQuery(rv.Index(i).Interface(), params))
+		}
+		return list
+	}
+
+	return value
+}
+
+// bindQuery return a copy of the compiled template with placeholders replaced by args, filter is true when value is
+// a query filter so the args of its fields are compared with $eq
+func bindQuery(value interface{}, args map[string]interface{}, filter bool) interface{} {
+	switch v := value.(type) {
+	case QueryParam:
+		return args[string(v)]
+	case bson.D:
+		document := make(bson.D, 0, len(v))
+		for _, element := range v {
+			document = append(document, primitive.E{Key: element.Key, Value: bindElement(element, args, filter)})
+		}
+		return document
+	case primitive.E:
+		return primitive.E{Key: v.Key, Value: bindQuery(v.Value, args, false)}
+	case bson.A:
+		list := make(bson.A, 0, len(v))
+		for _, item := range v {
+			list = append(list, bindQuery(item, args, filter))
+		}
+		return list
+	}
+
+	return value
+}
+
+// bindElement return the value of the element with placeholders replaced by args, in a filter the arg of a field is
+// wrapped in $eq and the logical operators, the operators of the fields and $elemMatch hold filters too
+func bindElement(element primitive.E, args map[string]interface{}, filter bool) interface{} {
+	if !filter {
+		return bindQuery(element.Value, args, false)
+	}
+
+	switch element.Key {
+	case "$and", "$or", "$nor", "$elemMatch":
+		return bindQuery(element.Value, args, true)
+	}
+	if strings.HasPrefix(element.Key, "$") {
+		return bindQuery(element.Value, args, false)
+	}
+
+	switch v := element.Value.(type) {
+	case QueryParam:
+		return bson.D{primitive.E{Key: "$eq", Value: args[string(v)]}}
+	case bson.D:
+		if len(v) > 0 && strings.HasPrefix(v[0].Key, "$") {
+			return bindQuery(v, args, true)
+		}
+	}
+
+	return bindQuery(element.Value, args, false)
+}
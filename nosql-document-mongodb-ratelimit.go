@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	// ErrRateLimited is returned in fail fast mode when the rate limit of the operation is reached
+	ErrRateLimited = errors.New("Rate limit reached")
+)
+
+// rateLimiter hold the token buckets of the client and of its collections
+type rateLimiter struct {
+	global      *rate.Limiter
+	collections map[string]*rate.Limiter
+	failFast    bool
+}
+
+// newRateLimiter init the token buckets based on config, nil when no limit is set
+func newRateLimiter(config *MongoDBRateLimit) *rateLimiter {
+	if config.Global <= 0 && len(config.Collections) == 0 {
+		return nil
+	}
+
+	newLimiter := func(limit float64) *rate.Limiter {
+		burst := config.Burst
+		if burst == 0 {
+			burst = int(math.Ceil(limit))
+		}
+		return rate.NewLimiter(rate.Limit(limit), burst)
+	}
+
+	limiter := &rateLimiter{collections: map[string]*rate.Limiter{}, failFast: config.FailFast}
+	if config.Global > 0 {
+		limiter.global = newLimiter(config.Global)
+	}
+	for collection, limit := range config.Collections {
+		limiter.collections[collection] = newLimiter(limit)
+	}
+
+	return limiter
+}
+
+// wait take a token of the collection bucket then of the global bucket, waiting for them until c is done unless in
+// fail fast mode
+func (r *rateLimiter) wait(c context.Context, databaseName, collectionName string) error {
+	limiters := []*rate.Limiter{r.collections[databaseName+"."+collectionName], r.global}
+	if r.failFast {
+		return allow(limiters)
+	}
+
+	for _, limiter := range limiters {
+		if limiter == nil {
+			continue
+		}
+		if err := limiter.Wait(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// allow take a token of every limiter without waiting, no token is taken and ErrRateLimited is returned when one of
+// them has none available
+func allow(limiters []*rate.Limiter) error {
+	now := time.Now()
+	reservations := make([]*rate.Reservation, 0, len(limiters))
+	for _, limiter := range limiters {
+		if limiter == nil {
+			continue
+		}
+
+		reservation := limiter.ReserveN(now, 1)
+		if !reservation.OK() || reservation.DelayFrom(now) > 0 {
+			reservation.CancelAt(now)
+			for _, reserved := range reservations {
+				reserved.CancelAt(now)
+			}
+			return ErrRateLimited
+		}
+		reservations = append(reservations, reservation)
+	}
+
+	return nil
+}
+
+// admit check the namespace then apply the rate limits and the concurrency limit of the client to an operation
+// on the collection, call the returned function once the operation completes
+func (m *MongoClient) admit(databaseName, collectionName string) (func(), error) {
+	if err := checkNamespace(databaseName, collectionName); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	limiter, bulkhead := m.rateLimiter, m.bulkhead
+	m.mu.RUnlock()
+
+	if limiter != nil {
+		if err := limiter.wait(m.Context(), databaseName, collectionName); err != nil {
+			return nil, err
+		}
+	}
+	if bulkhead == nil {
+		return func() {}, nil
+	}
+
+	return bulkhead.acquire(m.Context())
+}
@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/tag"
+)
+
+// SecondaryReadPreference return the read preference for heavy analytical reads: secondaries when available,
+// optionally restricted by replica tag sets like {"dc": "eu"}
+func SecondaryReadPreference(tagSets ...map[string]string) *readpref.ReadPref {
+	if len(tagSets) == 0 {
+		return readpref.SecondaryPreferred()
+	}
+
+	return readpref.SecondaryPreferred(readpref.WithTagSets(tag.NewTagSetsFromMaps(tagSets)...))
+}
+
+// ReadWithPreference read documents from collection like Read, but route the query based on readPreference.
+// Writes and Read always stay on primary.
+func (m *MongoClient) ReadWithPreference(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type, readPreference *readpref.ReadPref) (interface{}, error) {
+	return m.read(databaseName, collectionName, filter, limit, dataModel, readPreference)
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// defaultRedactionMask replace the values of sensitive fields
+	defaultRedactionMask = "***"
+)
+
+// redactor mask the values of sensitive fields in documents and error messages
+type redactor struct {
+	fields  map[string]bool // lower case field names
+	pattern *regexp.Regexp  // field: value pairs of error messages, like dup key: { email: "john@example.com" }
+	mask    string
+}
+
+// redactedError keep the original error while its message has masked values
+type redactedError struct {
+	err     error
+	message string
+}
+
+// Error return the redacted message
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+// Unwrap return the original error, for errors.Is and errors.As
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// newRedactor init the redactor of config, nil when no field is sensitive
+func newRedactor(config *MongoDBRedaction) *redactor {
+	if len(config.Fields) == 0 {
+		return nil
+	}
+
+	r := &redactor{fields: map[string]bool{}, mask: config.Mask}
+	if r.mask == "" {
+		r.mask = defaultRedactionMask
+	}
+
+	names := make([]string, 0, len(config.Fields))
+	for _, field := range config.Fields {
+		r.fields[strings.ToLower(field)] = true
+		names = append(names, regexp.QuoteMeta(field))
+	}
+	r.pattern = regexp.MustCompile(`(?i)("?\b(?:` + strings.Join(names, "|") + `)"?\s*:\s*)("(?:[^"\\]|\\.)*"|'[^']*'|[^,}\s]+)`)
+
+	return r
+}
+
+// redactError return err with the values of sensitive fields masked in its message
+func (r *redactor) redactError(err error) error {
+	if r == nil || err == nil {
+		return err
+	}
+
+	message := err.Error()
+	redacted := r.pattern.ReplaceAllString(message, `${1}"`+r.mask+`"`)
+	if redacted == message {
+		return err
+	}
+
+	return &redactedError{err: err, message: redacted}
+}
+
+// redactValue return value as relaxed extended JSON with the values of sensitive fields masked at any depth
+func (r *redactor) redactValue(value interface{}) string {
+	raw, err := bson.Marshal(bson.M{"v": value})
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	var document bson.M
+	if err := bson.Unmarshal(raw, &document); err != nil {
+		return fmt.Sprint(value)
+	}
+	if r != nil {
+		document["v"] = r.redactTree(document["v"])
+	}
+
+	b, err := bson.MarshalExtJSON(document, false, false)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	// Remove the {"v": wrapper of the value
+	return strings.TrimSuffix(strings.TrimPrefix(string(b), `{"v":`), "}")
+}
+
+// redactTree mask the values of sensitive fields of the decoded value
+func (r *redactor) redactTree(value interface{}) interface{} {
+	switch value := value.(type) {
+	case bson.M:
+		for key, item := range value {
+			if r.fields[strings.ToLower(key)] {
+				value[key] = r.mask
+			} else {
+				value[key] = r.redactTree(item)
+			}
+		}
+	case bson.D:
+		for i, element := range value {
+			if r.fields[strings.ToLower(element.Key)] {
+				value[i].Value = r.mask
+			} else {
+				value[i].Value = r.redactTree(element.Value)
+			}
+		}
+	case bson.A:
+		for i, item := range value {
+			value[i] = r.redactTree(item)
+		}
+	}
+
+	return value
+}
+
+// getRedactor return the redactor of the current config
+func (m *MongoClient) getRedactor() *redactor {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.redactor
+}
+
+// Redact return filters, updates or documents as extended JSON with the values of the redaction fields masked,
+// to log them safely
+func (m *MongoClient) Redact(value interface{}) string {
+	return m.getRedactor().redactValue(value)
+}
+
+// RedactError return err with the values of the redaction fields masked in its message, errors.Is and errors.As
+// still match the original error
+func (m *MongoClient) RedactError(err error) error {
+	return m.getRedactor().redactError(err)
+}
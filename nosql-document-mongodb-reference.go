@@ -0,0 +1,435 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// ReferenceRestrict refuse to delete documents which are still referenced
+	ReferenceRestrict = "restrict"
+	// ReferenceCascade delete the referencing documents with the referenced ones
+	ReferenceCascade = "cascade"
+)
+
+var (
+	// ErrReferenceNotFound is returned when a created or updated document reference a missing document
+	ErrReferenceNotFound = errors.New("Referenced document not found")
+	// ErrReferenceRestricted is returned when a deleted document is referenced by a restrict reference
+	ErrReferenceRestricted = errors.New("Document is still referenced")
+)
+
+// Reference model for a reference between two collections of the same database, like orders.userId -> users._id
+type Reference struct {
+	Database             string
+	Collection           string // referencing collection, like orders
+	Field                string // referencing field, like userId, arrays reference each of their values
+	ReferencedCollection string // like users
+	ReferencedField      string // default is _id
+	OnDelete             string // restrict (default) or cascade
+}
+
+// ReferentialDatabase decorate MongoClient with the enforcement of references, Create and Update check that
+// the referenced documents exist and Delete restrict or cascade to the referencing documents.
+// The checks and the writes run in one transaction when the deployment supports them.
+type ReferentialDatabase struct {
+	client     *MongoClient
+	references []Reference
+}
+
+// NewReferentialDatabase init new referential database enforcing references
+func NewReferentialDatabase(client *MongoClient, references ...Reference) (*ReferentialDatabase, error) {
+	var errs *multierror.Error
+	for i := range references {
+		reference := &references[i]
+		if reference.Database == "" || reference.Collection == "" || reference.Field == "" || reference.ReferencedCollection == "" {
+			errs = multierror.Append(errs, errors.New("reference: database, collection, field and referencedCollection are required"))
+		}
+		if reference.ReferencedField == "" {
+			reference.ReferencedField = "_id"
+		}
+		switch reference.OnDelete {
+		case "":
+			reference.OnDelete = ReferenceRestrict
+		case ReferenceRestrict, ReferenceCascade:
+		default:
+			errs = multierror.Append(errs, fmt.Errorf("reference: unknown onDelete %q", reference.OnDelete))
+		}
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+
+	return &ReferentialDatabase{client: client, references: references}, nil
+}
+
+// Create the documents after checking their references
+func (r *ReferentialDatabase) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	release, err := r.client.admit(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var result interface{}
+	if err := r.client.execute(func(sc context.Context) (err error) {
+		for _, reference := range r.getReferences(databaseName, collectionName) {
+			var values []interface{}
+			for _, document := range documents {
+				raw, err := bson.Marshal(document)
+				if err != nil {
+					return err
+				}
+				values = append(values, getReferenceValues(bson.Raw(raw), reference.Field)...)
+			}
+
+			if err := r.checkReference(sc, reference, values); err != nil {
+				return err
+			}
+		}
+
+		collection := r.client.getClient().Database(databaseName).Collection(collectionName)
+		result, err = collection.InsertMany(sc, documents)
+		return err
+	}); err != nil {
+		log.Println("Unable to create document: ", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Read documents from collection based on filter
+func (r *ReferentialDatabase) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	return r.client.Read(databaseName, collectionName, filter, limit, dataModel)
+}
+
+// Update the documents matching filter after checking the references set by the $set operator or the replacement
+func (r *ReferentialDatabase) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	release, err := r.client.admit(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var result interface{}
+	if err := r.client.execute(func(sc context.Context) (err error) {
+		if references := r.getReferences(databaseName, collectionName); len(references) > 0 {
+			values, err := getUpdatedValues(update)
+			if err != nil {
+				return err
+			}
+
+			for _, reference := range references {
+				if err := r.checkReference(sc, reference, getReferenceValues(values, reference.Field)); err != nil {
+					return err
+				}
+			}
+		}
+
+		collection := r.client.getClient().Database(databaseName).Collection(collectionName)
+		result, err = collection.UpdateMany(sc, filter, update)
+		return err
+	}); err != nil {
+		log.Println("Unable to update: ", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Delete the documents matching filter, failing when they are referenced by a restrict reference
+// and deleting the documents referencing them by a cascade reference
+func (r *ReferentialDatabase) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	release, err := r.client.admit(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var result interface{}
+	if err := r.client.execute(func(sc context.Context) (err error) {
+		result, err = r.delete(sc, databaseName, collectionName, filter)
+		return err
+	}); err != nil {
+		log.Println("Unable to delete: ", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// delete the documents matching filter and the documents referencing them
+func (r *ReferentialDatabase) delete(sc context.Context, databaseName, collectionName string, filter interface{}) (*mongo.DeleteResult, error) {
+	database := r.client.getClient().Database(databaseName)
+
+	var cascades []Reference
+	var cascadeValues [][]interface{}
+	for _, reference := range r.getReferencedBy(databaseName, collectionName) {
+		values, err := database.Collection(collectionName).Distinct(sc, reference.ReferencedField, filter)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		if reference.OnDelete == ReferenceCascade {
+			cascades = append(cascades, reference)
+			cascadeValues = append(cascadeValues, values)
+			continue
+		}
+
+		count, err := database.Collection(reference.Collection).CountDocuments(sc, bson.M{reference.Field: bson.M{"$in": values}})
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			log.Printf("Unable to delete %s.%s: referenced by %d documents of %s\n", databaseName, collectionName, count, reference.Collection)
+			return nil, ErrReferenceRestricted
+		}
+	}
+
+	result, err := database.Collection(collectionName).DeleteMany(sc, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, reference := range cascades {
+		if _, err := r.delete(sc, databaseName, reference.Collection, bson.M{reference.Field: bson.M{"$in": cascadeValues[i]}}); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// checkReference return ErrReferenceNotFound when a value is not in the referenced collection
+func (r *ReferentialDatabase) checkReference(sc context.Context, reference Reference, values []interface{}) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	wanted := map[string]interface{}{}
+	for _, value := range values {
+		wanted[getValueKey(value)] = value
+	}
+
+	collection := r.client.getClient().Database(reference.Database).Collection(reference.ReferencedCollection)
+	found, err := collection.Distinct(sc, reference.ReferencedField, bson.M{reference.ReferencedField: bson.M{"$in": values}})
+	if err != nil {
+		return err
+	}
+	for _, value := range found {
+		delete(wanted, getValueKey(value))
+	}
+
+	if len(wanted) > 0 {
+		log.Printf("Unable to find %d %s.%s referenced by %s\n", len(wanted), reference.ReferencedCollection, reference.ReferencedField, reference.Field)
+		return ErrReferenceNotFound
+	}
+
+	return nil
+}
+
+// getReferences return the references declared by the collection
+func (r *ReferentialDatabase) getReferences(databaseName, collectionName string) []Reference {
+	var references []Reference
+	for _, reference := range r.references {
+		if reference.Database == databaseName && reference.Collection == collectionName {
+			references = append(references, reference)
+		}
+	}
+
+	return references
+}
+
+// getReferencedBy return the references to the collection
+func (r *ReferentialDatabase) getReferencedBy(databaseName, collectionName string) []Reference {
+	var references []Reference
+	for _, reference := range r.references {
+		if reference.Database == databaseName && reference.ReferencedCollection == collectionName {
+			references = append(references, reference)
+		}
+	}
+
+	return references
+}
+
+// getReferenceValues return the values of the dotted field of document, null and missing values are skipped
+func getReferenceValues(document bson.Raw, field string) []interface{} {
+	if document == nil {
+		return nil
+	}
+
+	value, err := document.LookupErr(strings.Split(field, ".")...)
+	if err != nil {
+		// Fields set with a dotted key by $set are not nested
+		if value, err = document.LookupErr(field); err != nil {
+			return nil
+		}
+	}
+
+	switch value.Type {
+	case bsontype.Null, bsontype.Undefined:
+		return nil
+	case bsontype.Array:
+		items, err := value.Array().Values()
+		if err != nil {
+			return nil
+		}
+		values := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			if item.Type != bsontype.Null {
+				values = append(values, item)
+			}
+		}
+		return values
+	}
+
+	return []interface{}{value}
+}
+
+// getUpdatedValues return the fields set by the $set operator of update, or the replacement document,
+// nil for aggregation pipeline updates
+func getUpdatedValues(update interface{}) (bson.Raw, error) {
+	switch update.(type) {
+	case mongo.Pipeline, bson.A, []bson.D, []bson.M, []interface{}:
+		return nil, nil
+	}
+
+	raw, err := bson.Marshal(update)
+	if err != nil {
+		return nil, err
+	}
+	document := bson.Raw(raw)
+
+	elements, err := document.Elements()
+	if err != nil {
+		return nil, err
+	}
+	if len(elements) == 0 || !strings.HasPrefix(elements[0].Key(), "$") {
+		return document, nil
+	}
+
+	set, ok := document.Lookup("$set").DocumentOK()
+	if !ok {
+		return nil, nil
+	}
+
+	return set, nil
+}
+
+// getValueKey return the BSON type and bytes of value, to compare decoded and raw values
+func getValueKey(value interface{}) string {
+	kind, data, err := bson.MarshalValue(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(kind) + string(data)
+}
+
+// DeleteCascade delete the documents matching filter and, through every declared reference whatever its OnDelete,
+// the documents depending on them, in one transaction. The dry run only report the documents which would be deleted.
+func (r *ReferentialDatabase) DeleteCascade(databaseName, collectionName string, filter interface{}, dryRun bool) (*CascadeReport, error) {
+	if transactional, _ := r.client.getTransaction(); !transactional && !dryRun {
+		return nil, ErrTransactionsUnsupported
+	}
+
+	var report *CascadeReport
+	if err := r.client.execute(func(sc context.Context) error {
+		report = &CascadeReport{Database: databaseName, DryRun: dryRun, IDs: map[string][]interface{}{}, Deleted: map[string]int64{}}
+		seen := map[string]map[string]bool{}
+		if err := r.collectDependents(sc, databaseName, collectionName, filter, report, seen); err != nil {
+			return err
+		}
+
+		for collection, ids := range report.IDs {
+			if dryRun {
+				report.Deleted[collection] = int64(len(ids))
+				continue
+			}
+
+			result, err := r.client.getClient().Database(databaseName).Collection(collection).DeleteMany(sc, bson.M{"_id": bson.M{"$in": ids}})
+			if err != nil {
+				return err
+			}
+			report.Deleted[collection] = result.DeletedCount
+		}
+
+		return nil
+	}); err != nil {
+		log.Println("Unable to delete cascade: ", err)
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// collectDependents add the _id of the documents matching filter and of their dependents to the report,
+// documents already seen are skipped so reference cycles end
+func (r *ReferentialDatabase) collectDependents(sc context.Context, databaseName, collectionName string, filter interface{}, report *CascadeReport, seen map[string]map[string]bool) error {
+	referencedBy := r.getReferencedBy(databaseName, collectionName)
+	projection := bson.M{"_id": 1}
+	for _, reference := range referencedBy {
+		projection[reference.ReferencedField] = 1
+	}
+
+	if seen[collectionName] == nil {
+		seen[collectionName] = map[string]bool{}
+	}
+	if ids := report.IDs[collectionName]; len(ids) > 0 {
+		filter = bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$nin": ids}}}}
+	}
+
+	collection := r.client.getClient().Database(databaseName).Collection(collectionName)
+	cur, err := collection.Find(sc, filter, options.Find().SetProjection(projection))
+	if err != nil {
+		return err
+	}
+
+	var documents []bson.Raw
+	if err := cur.All(sc, &documents); err != nil {
+		return err
+	}
+
+	var found []bson.Raw
+	for _, document := range documents {
+		id := document.Lookup("_id")
+		if key := getValueKey(id); !seen[collectionName][key] {
+			seen[collectionName][key] = true
+			report.IDs[collectionName] = append(report.IDs[collectionName], id)
+			found = append(found, document)
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+
+	for _, reference := range referencedBy {
+		var values []interface{}
+		for _, document := range found {
+			values = append(values, getReferenceValues(document, reference.ReferencedField)...)
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		if err := r.collectDependents(sc, databaseName, reference.Collection, bson.M{reference.Field: bson.M{"$in": values}}, report, seen); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
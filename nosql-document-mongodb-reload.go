@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// Reload apply new config on the live client: a new connection is established with the new
+// credentials and tunables, then it replaces the current one once in-flight operations complete
+func (m *MongoClient) Reload(config *MongoDB) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	if err := m.reconnect(config); err != nil {
+		log.Println("Unable to reload MongoDB configuration: ", err)
+		return err
+	}
+
+	log.Println("Reloaded MongoDB configuration")
+	return nil
+}
+
+// WatchConfigFile check the config file (see LoadConfigFromFile) every interval and reload the client when it changes.
+// Call the returned function to stop watching.
+func (m *MongoClient) WatchConfigFile(path string, interval time.Duration) func() {
+	done := make(chan struct{})
+
+	go func() {
+		var lastModified time.Time
+		if info, err := os.Stat(path); err == nil {
+			lastModified = info.ModTime()
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				info, err := os.Stat(path)
+				if err != nil {
+					log.Println("Unable to check config file: ", err)
+					continue
+				}
+				if !info.ModTime().After(lastModified) {
+					continue
+				}
+				lastModified = info.ModTime()
+
+				config, err := LoadConfigFromFile(path)
+				if err != nil {
+					log.Println("Unable to load config file: ", err)
+					continue
+				}
+				m.Reload(&config.MongoDB)
+
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
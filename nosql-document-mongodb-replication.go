@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// replicationLagMonitor track secondary replication lag of MongoDB replica set
+type replicationLagMonitor struct {
+	mu              sync.RWMutex
+	config          *MongoDBReplicationLag
+	interval        time.Duration
+	stats           ReplicationLagStats
+	primaryFallback uint64
+	cancel          context.CancelFunc // stop the periodic checks
+}
+
+// replicaSetStatus private model for replSetGetStatus command result
+type replicaSetStatus struct {
+	Members []struct {
+		Name       string    `bson:"name"`
+		State      int       `bson:"state"`
+		OptimeDate time.Time `bson:"optimeDate"`
+	} `bson:"members"`
+}
+
+const (
+	replicaSetPrimaryState   = 1
+	replicaSetSecondaryState = 2
+)
+
+// newReplicationLagMonitor init new instance and check the lag of client periodically until stop is called, the
+// monitor of a renewed connection is replaced
+func newReplicationLagMonitor(client *mongo.Client, config *MongoDBReplicationLag) *replicationLagMonitor {
+	monitorCtx, cancel := context.WithCancel(context.Background())
+	interval := config.CheckInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	monitor := &replicationLagMonitor{config: config, interval: interval, cancel: cancel}
+
+	monitor.check(monitorCtx, client)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				monitor.check(monitorCtx, client)
+			case <-monitorCtx.Done():
+				return
+			}
+		}
+	}()
+
+	return monitor
+}
+
+// stop the periodic checks and interrupt the running one
+func (rm *replicationLagMonitor) stop() {
+	rm.cancel()
+}
+
+// check replication lag of every secondary against primary, a failed check keeps the previous lag and is marked failed
+func (rm *replicationLagMonitor) check(monitorCtx context.Context, client *mongo.Client) {
+	var status replicaSetStatus
+	if err := client.Database("admin").RunCommand(monitorCtx, bson.D{primitive.E{Key: "replSetGetStatus", Value: 1}}).Decode(&status); err != nil {
+		if monitorCtx.Err() == nil {
+			log.Println("Unable to get replica set status: ", err)
+		}
+		rm.mu.Lock()
+		rm.stats.Failed = true
+		rm.mu.Unlock()
+		return
+	}
+
+	var primaryOptime time.Time
+	for _, member := range status.Members {
+		if member.State == replicaSetPrimaryState {
+			primaryOptime = member.OptimeDate
+		}
+	}
+
+	var maxLag time.Duration
+	members := make(map[string]time.Duration)
+	for _, member := range status.Members {
+		if member.State != replicaSetSecondaryState || primaryOptime.IsZero() {
+			continue
+		}
+
+		lag := primaryOptime.Sub(member.OptimeDate)
+		if lag < 0 {
+			lag = 0
+		}
+		if lag > maxLag {
+			maxLag = lag
+		}
+		members[member.Name] = lag
+	}
+
+	rm.mu.Lock()
+	rm.stats.MaxLag = maxLag
+	rm.stats.Members = members
+	rm.stats.CheckedAt = time.Now()
+	rm.stats.Failed = false
+	rm.mu.Unlock()
+}
+
+// readPreference return primary when secondaries lag more than the threshold, otherwise readPreference is unchanged.
+// The lag is unknown, so over the threshold, when the last check failed or no check succeeded for two intervals.
+func (rm *replicationLagMonitor) readPreference(readPreference *readpref.ReadPref) *readpref.ReadPref {
+	if readPreference == nil || readPreference.Mode() == readpref.PrimaryMode {
+		return readPreference
+	}
+
+	rm.mu.RLock()
+	maxLag := rm.stats.MaxLag
+	unknown := rm.stats.Failed || time.Since(rm.stats.CheckedAt) > 2*rm.interval
+	rm.mu.RUnlock()
+
+	if !unknown && maxLag <= rm.config.MaxLag {
+		return readPreference
+	}
+
+	atomic.AddUint64(&rm.primaryFallback, 1)
+	return readpref.Primary()
+}
+
+// ReplicationLag return the replication lag metrics, nil when the monitor is disabled
+func (m *MongoClient) ReplicationLag() *ReplicationLagStats {
+	monitor := m.getReplicationLag()
+	if monitor == nil {
+		return nil
+	}
+
+	monitor.mu.RLock()
+	defer monitor.mu.RUnlock()
+
+	stats := monitor.stats
+	stats.Members = make(map[string]time.Duration, len(monitor.stats.Members))
+	for name, lag := range monitor.stats.Members {
+		stats.Members[name] = lag
+	}
+	stats.PrimaryFallback = atomic.LoadUint64(&monitor.primaryFallback)
+
+	return &stats
+}
+
+// getReplicationLag return the replication lag monitor of the current connection, nil when it is disabled
+func (m *MongoClient) getReplicationLag() *replicationLagMonitor {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.replicationLag
+}
@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// RetentionDelete delete the expired documents
+	RetentionDelete = "delete"
+	// RetentionArchive move the expired documents to the archive collection
+	RetentionArchive = "archive"
+
+	// defaultRetentionBatchSize is the number of documents deleted or archived per batch
+	defaultRetentionBatchSize = 1000
+	// indexOptionsConflictCode is returned when an index exists with different options
+	indexOptionsConflictCode = 85
+	// duplicateKeyCode is returned when a document with the same unique key exists
+	duplicateKeyCode = 11000
+)
+
+// RetentionRule model for a RetentionPolicy rule, documents whose Field is older than MaxAge are expired
+type RetentionRule struct {
+	Database          string
+	Collection        string
+	Field             string        // date field
+	MaxAge            time.Duration // nanosecond
+	Action            string        // delete (default) or archive
+	ArchiveCollection string        // collection of the archived documents in the same database, default is <collection>_archive
+	TTLIndex          bool          // delete rules create a TTL index, the server remove the expired documents itself
+	BatchSize         int           // documents processed per batch, default is 1000
+	RateLimit         int           // maximum documents processed per second, 0 means no limit
+}
+
+// RetentionPolicy enforce retention rules on collections by TTL indexes or batched deletes and archives
+type RetentionPolicy struct {
+	client *MongoClient
+	rules  []RetentionRule
+}
+
+// NewRetentionPolicy init new retention policy based on rules
+func NewRetentionPolicy(client *MongoClient, rules ...RetentionRule) (*RetentionPolicy, error) {
+	var errs *multierror.Error
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Database == "" || rule.Collection == "" || rule.Field == "" {
+			errs = multierror.Append(errs, errors.New("retention: database, collection and field are required"))
+		}
+		if rule.MaxAge <= 0 {
+			errs = multierror.Append(errs, errors.New("retention: maxAge must be positive"))
+		}
+		if rule.Action == "" {
+			rule.Action = RetentionDelete
+		}
+		if rule.Action != RetentionDelete && rule.Action != RetentionArchive {
+			errs = multierror.Append(errs, errors.New("retention: action must be delete or archive"))
+		}
+		if rule.TTLIndex && rule.Action != RetentionDelete {
+			errs = multierror.Append(errs, errors.New("retention: TTL index is only available for delete rules"))
+		}
+		if rule.ArchiveCollection == "" {
+			rule.ArchiveCollection = rule.Collection + "_archive"
+		}
+		if rule.BatchSize <= 0 {
+			rule.BatchSize = defaultRetentionBatchSize
+		}
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+
+	return &RetentionPolicy{client: client, rules: rules}, nil
+}
+
+// Apply ensure the TTL indexes and run the batched rules once, it returns the number of documents
+// deleted or archived per database.collection
+func (p *RetentionPolicy) Apply() (map[string]int64, error) {
+	var errs *multierror.Error
+	results := map[string]int64{}
+	for _, rule := range p.rules {
+		if rule.TTLIndex {
+			errs = multierror.Append(errs, p.ensureTTLIndex(rule))
+			continue
+		}
+
+		count, err := p.enforce(rule, time.Now().Add(-rule.MaxAge))
+		results[rule.Database+"."+rule.Collection] += count
+		errs = multierror.Append(errs, err)
+	}
+
+	return results, errs.ErrorOrNil()
+}
+
+// Start run Apply every interval. Call the returned function to stop it.
+func (p *RetentionPolicy) Start(interval time.Duration) func() {
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := p.Apply(); err != nil {
+				log.Println("Unable to apply retention policy: ", err)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
+
+// ensureTTLIndex create the TTL index of the rule or update its expiration when it already exists
+func (p *RetentionPolicy) ensureTTLIndex(rule RetentionRule) error {
+	keys := bson.D{primitive.E{Key: rule.Field, Value: 1}}
+	expireAfterSeconds := int32(rule.MaxAge / time.Second)
+
+	_, err := p.client.CreateIndex(rule.Database, rule.Collection, keys, options.Index().SetExpireAfterSeconds(expireAfterSeconds))
+	var commandErr mongo.CommandError
+	if !errors.As(err, &commandErr) || commandErr.Code != indexOptionsConflictCode {
+		return err
+	}
+
+	return p.client.executeWithoutTransaction(func(sc context.Context) error {
+		return p.client.getClient().Database(rule.Database).RunCommand(sc, bson.D{
+			primitive.E{Key: "collMod", Value: rule.Collection},
+			primitive.E{Key: "index", Value: bson.D{
+				primitive.E{Key: "keyPattern", Value: keys},
+				primitive.E{Key: "expireAfterSeconds", Value: expireAfterSeconds},
+			}},
+		}).Err()
+	})
+}
+
+// enforce delete or archive the documents of the rule expired before cutoff, batch by batch
+func (p *RetentionPolicy) enforce(rule RetentionRule, cutoff time.Time) (int64, error) {
+	filter := bson.M{rule.Field: bson.M{"$lt": cutoff}}
+
+	var count int64
+	for {
+		start := time.Now()
+
+		processed, err := p.enforceBatch(rule, filter)
+		count += processed
+		if err != nil {
+			log.Println("Unable to enforce retention rule: ", err)
+			return count, err
+		}
+		if processed < int64(rule.BatchSize) {
+			return count, nil
+		}
+
+		if rule.RateLimit > 0 {
+			wait := time.Duration(processed)*time.Second/time.Duration(rule.RateLimit) - time.Since(start)
+			if wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+	}
+}
+
+// enforceBatch delete or archive the next batch of expired documents, the archive copy and the delete run
+// in the same transaction when the deployment supports it
+func (p *RetentionPolicy) enforceBatch(rule RetentionRule, filter bson.M) (int64, error) {
+	var count int64
+	err := p.client.execute(func(sc context.Context) error {
+		count = 0
+		database := p.client.getClient().Database(rule.Database)
+		collection := database.Collection(rule.Collection)
+
+		findOptions := options.Find().SetLimit(int64(rule.BatchSize)).SetSort(bson.D{primitive.E{Key: "_id", Value: 1}})
+		if rule.Action == RetentionDelete {
+			findOptions.SetProjection(bson.M{"_id": 1})
+		}
+		cur, err := collection.Find(sc, filter, findOptions)
+		if err != nil {
+			return err
+		}
+
+		var documents []bson.Raw
+		if err := cur.All(sc, &documents); err != nil {
+			return err
+		}
+		if len(documents) == 0 {
+			return nil
+		}
+
+		ids := make(bson.A, 0, len(documents))
+		for _, document := range documents {
+			ids = append(ids, document.Lookup("_id"))
+		}
+
+		if rule.Action == RetentionArchive {
+			archive := make([]interface{}, 0, len(documents))
+			for _, document := range documents {
+				archive = append(archive, document)
+			}
+			// Documents already archived by an interrupted run are skipped
+			_, err := database.Collection(rule.ArchiveCollection).InsertMany(sc, archive, options.InsertMany().SetOrdered(false))
+			if err != nil && !isDuplicateKeyOnly(err) {
+				return err
+			}
+		}
+
+		result, err := collection.DeleteMany(sc, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return err
+		}
+		count = result.DeletedCount
+
+		return nil
+	})
+
+	return count, err
+}
+
+// isDuplicateKeyOnly return true when every write error of the bulk write is a duplicate key error
+func isDuplicateKeyOnly(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil || len(bulkErr.WriteErrors) == 0 {
+		return false
+	}
+
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != duplicateKeyCode {
+			return false
+		}
+	}
+
+	return true
+}
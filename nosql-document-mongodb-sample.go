@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Sample return n random documents matching filter decoded based on dataModel, a document may be returned
+// more than once when n is below 5% of the collection
+func (m *MongoClient) Sample(databaseName, collectionName string, n int64, filter interface{}, dataModel reflect.Type) (interface{}, error) {
+	pipeline := mongo.Pipeline{}
+	if filter != nil {
+		pipeline = append(pipeline, bson.D{primitive.E{Key: "$match", Value: filter}})
+	}
+	pipeline = append(pipeline, bson.D{primitive.E{Key: "$sample", Value: bson.M{"size": n}}})
+
+	return m.Aggregate(databaseName, collectionName, pipeline, dataModel)
+}
@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// defaultScanWorkers is the number of ranges read at once
+	defaultScanWorkers = 4
+	// scanSamplesPerPartition is the number of _id sampled per range to find the range bounds
+	scanSamplesPerPartition = 10
+)
+
+// ParallelScanOptions model for ParallelScan
+type ParallelScanOptions struct {
+	Filter     interface{} // documents to scan, all documents when nil
+	Workers    int         // ranges read at once, default is 4
+	Partitions int         // number of _id ranges, default is 4 ranges per worker
+	BatchSize  int32       // documents fetched per cursor batch, default is 1000
+}
+
+// ParallelScan split the collection into _id ranges based on a sample of the _id values and read them with a pool
+// of workers, fn is called for each document from several goroutines at once and the document is only valid during
+// the call. The scan stops at the first error. Use a channel in fn to process the documents elsewhere.
+func (m *MongoClient) ParallelScan(databaseName, collectionName string, scanOptions *ParallelScanOptions, fn func(document bson.Raw) error) (int64, error) {
+	if scanOptions == nil {
+		scanOptions = &ParallelScanOptions{}
+	}
+	workers := scanOptions.Workers
+	if workers <= 0 {
+		workers = defaultScanWorkers
+	}
+	partitions := scanOptions.Partitions
+	if partitions <= 0 {
+		partitions = workers * 4
+	}
+	batchSize := scanOptions.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultExportBatchSize
+	}
+	filter := scanOptions.Filter
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	collection := m.getClient().Database(databaseName).Collection(collectionName)
+	ranges, err := m.getScanRanges(collection, partitions, m.getTimeout(OperationAggregate, databaseName, collectionName))
+	if err != nil {
+		log.Println("Unable to split collection: ", err)
+		return 0, err
+	}
+
+	var count int64
+	timeout := m.getTimeout(OperationRead, databaseName, collectionName)
+	group, groupCtx := errgroup.WithContext(m.Context())
+	queue := make(chan bson.M)
+	for i := 0; i < workers; i++ {
+		group.Go(func() error {
+			for idRange := range queue {
+				if err := scanRange(groupCtx, timeout, collection, bson.M{"$and": bson.A{filter, idRange}}, batchSize, fn, &count); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	}
+
+	group.Go(func() error {
+		defer close(queue)
+		for _, idRange := range ranges {
+			select {
+			case queue <- idRange:
+			case <-groupCtx.Done():
+				return groupCtx.Err()
+			}
+		}
+		return nil
+	})
+
+	if err := group.Wait(); err != nil {
+		log.Println("Unable to scan collection: ", err)
+		return atomic.LoadInt64(&count), err
+	}
+
+	return count, nil
+}
+
+// scanRange call fn for each document of collection matching filter and count them, the read timeout bound the scan
+// of each range
+func scanRange(groupCtx context.Context, timeout time.Duration, collection *mongo.Collection, filter interface{}, batchSize int32, fn func(document bson.Raw) error, count *int64) error {
+	sc, cancel := withTimeout(groupCtx, timeout)
+	defer cancel()
+
+	cur, err := collection.Find(sc, filter, options.Find().SetBatchSize(batchSize))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(sc)
+
+	for cur.Next(sc) {
+		if err := fn(cur.Current); err != nil {
+			return err
+		}
+		atomic.AddInt64(count, 1)
+	}
+
+	return cur.Err()
+}
+
+// getScanRanges return the _id filters of the ranges covering the collection. Range queries only match values of
+// the type of their bounds, so the last range select the _id of other types.
+func (m *MongoClient) getScanRanges(collection *mongo.Collection, partitions int, timeout time.Duration) ([]bson.M, error) {
+	if partitions == 1 {
+		return []bson.M{{}}, nil
+	}
+
+	sc, cancel := withTimeout(m.Context(), timeout)
+	defer cancel()
+	cur, err := collection.Aggregate(sc, mongo.Pipeline{
+		bson.D{primitive.E{Key: "$sample", Value: bson.M{"size": partitions * scanSamplesPerPartition}}},
+		bson.D{primitive.E{Key: "$project", Value: bson.M{"_id": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []struct {
+		ID bson.RawValue `bson:"_id"`
+	}
+	if err := cur.All(sc, &samples); err != nil {
+		return nil, err
+	}
+	if len(samples) < partitions {
+		return []bson.M{{}}, nil
+	}
+
+	idType := samples[0].ID.Type
+	var ids []bson.RawValue
+	for _, sample := range samples {
+		if sample.ID.Type == idType {
+			ids = append(ids, sample.ID)
+		}
+	}
+
+	var compare func(a, b bson.RawValue) int
+	var typeAlias interface{} = int32(idType)
+	switch idType {
+	case bsontype.ObjectID:
+		compare = func(a, b bson.RawValue) int { return bytes.Compare(a.Value, b.Value) }
+	case bsontype.Int32, bsontype.Int64:
+		compare = compareVersion
+		typeAlias = "number"
+	case bsontype.String, bsontype.DateTime:
+		compare = compareVersion
+	default:
+		return []bson.M{{}}, nil
+	}
+	sort.Slice(ids, func(i, j int) bool { return compare(ids[i], ids[j]) < 0 })
+
+	var bounds []bson.RawValue
+	for i := 1; i < partitions; i++ {
+		bound := ids[i*len(ids)/partitions]
+		if len(bounds) == 0 || compare(bound, bounds[len(bounds)-1]) != 0 {
+			bounds = append(bounds, bound)
+		}
+	}
+
+	ranges := []bson.M{{"_id": bson.M{"$lt": bounds[0]}}}
+	for i := 1; i < len(bounds); i++ {
+		ranges = append(ranges, bson.M{"_id": bson.M{"$gte": bounds[i-1], "$lt": bounds[i]}})
+	}
+	ranges = append(ranges, bson.M{"_id": bson.M{"$gte": bounds[len(bounds)-1]}})
+	ranges = append(ranges, bson.M{"_id": bson.M{"$not": bson.M{"$type": typeAlias}}})
+
+	return ranges, nil
+}
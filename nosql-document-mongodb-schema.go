@@ -0,0 +1,385 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// SchemaUnknownField is a sampled field without struct field
+	SchemaUnknownField = "unknownField"
+	// SchemaTypeMismatch is a sampled field whose type can not be decoded into the struct field
+	SchemaTypeMismatch = "typeMismatch"
+	// SchemaMissingField is a struct field without omitempty which is in no sampled document
+	SchemaMissingField = "missingField"
+
+	// defaultSchemaSampleSize is the number of documents sampled by AnalyzeSchema
+	defaultSchemaSampleSize = 1000
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	dateTimeType = reflect.TypeOf(primitive.DateTime(0))
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+	decimalType  = reflect.TypeOf(primitive.Decimal128{})
+	bytesType    = reflect.TypeOf([]byte(nil))
+)
+
+// SchemaRegistry keep the Go structs of the collections, AnalyzeSchema compare the collections to them
+type SchemaRegistry struct {
+	mu      sync.RWMutex
+	client  *MongoClient
+	schemas map[string]reflect.Type
+}
+
+// schemaField private model for the expected type of a struct field
+type schemaField struct {
+	types     []string // type aliases, nil accept any type
+	omitEmpty bool
+	open      bool // sub fields are not checked, for maps and interfaces
+}
+
+// NewSchemaRegistry init new registry analyzing collections of client
+func NewSchemaRegistry(client *MongoClient) *SchemaRegistry {
+	return &SchemaRegistry{client: client, schemas: make(map[string]reflect.Type)}
+}
+
+// Register keep the struct type of model as the schema of databaseName.collectionName
+func (sr *SchemaRegistry) Register(databaseName, collectionName string, model interface{}) error {
+	modelType := reflect.TypeOf(model)
+	for modelType != nil && modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	if modelType == nil || modelType.Kind() != reflect.Struct {
+		return errors.New("Schema model must be a struct")
+	}
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.schemas[databaseName+"."+collectionName] = modelType
+
+	return nil
+}
+
+// AnalyzeSchema sample the collection and report its drift from the registered struct
+func (sr *SchemaRegistry) AnalyzeSchema(databaseName, collectionName string, sampleSize int) (*SchemaReport, error) {
+	sr.mu.RLock()
+	modelType, ok := sr.schemas[databaseName+"."+collectionName]
+	sr.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("No schema registered for %s.%s", databaseName, collectionName)
+	}
+
+	return sr.client.AnalyzeSchema(databaseName, collectionName, sampleSize, reflect.New(modelType).Interface())
+}
+
+// AnalyzeSchema sample sampleSize documents of the collection and infer the types and optionality of their fields,
+// nested fields are dotted paths. The drift from the struct of model is reported when model is not nil.
+func (m *MongoClient) AnalyzeSchema(databaseName, collectionName string, sampleSize int, model interface{}) (*SchemaReport, error) {
+	if sampleSize <= 0 {
+		sampleSize = defaultSchemaSampleSize
+	}
+
+	var documents []bson.Raw
+	err := m.executeWithoutTransaction(func(sc context.Context) error {
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+		cur, err := collection.Aggregate(sc, mongo.Pipeline{bson.D{primitive.E{Key: "$sample", Value: bson.M{"size": sampleSize}}}})
+		if err != nil {
+			return err
+		}
+
+		return cur.All(sc, &documents)
+	})
+	if err != nil {
+		log.Println("Unable to sample collection: ", err)
+		return nil, err
+	}
+
+	fields := map[string]*SchemaField{}
+	for _, document := range documents {
+		if err := inferSchema(document, "", fields); err != nil {
+			log.Println("Unable to infer schema: ", err)
+			return nil, err
+		}
+	}
+
+	report := &SchemaReport{Database: databaseName, Collection: collectionName, Sampled: int64(len(documents))}
+	for _, field := range fields {
+		field.Optional = field.Count < report.Sampled
+		report.Fields = append(report.Fields, *field)
+	}
+	sort.Slice(report.Fields, func(i, j int) bool { return report.Fields[i].Name < report.Fields[j].Name })
+
+	if model != nil {
+		modelType := reflect.TypeOf(model)
+		for modelType.Kind() == reflect.Ptr {
+			modelType = modelType.Elem()
+		}
+		expected := map[string]schemaField{}
+		getSchemaFields(modelType, "", expected)
+		report.Drift = getSchemaDrift(report.Fields, expected)
+	}
+
+	return report, nil
+}
+
+// inferSchema count the fields and types of document, sub documents and documents in arrays are walked with
+// the dotted path of their field
+func inferSchema(document bson.Raw, prefix string, fields map[string]*SchemaField) error {
+	elements, err := document.Elements()
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for _, element := range elements {
+		name := prefix + element.Key()
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		field, ok := fields[name]
+		if !ok {
+			field = &SchemaField{Name: name, Types: map[string]int64{}}
+			fields[name] = field
+		}
+		field.Count++
+
+		value := element.Value()
+		field.Types[getTypeAlias(value.Type)]++
+
+		switch value.Type {
+		case bsontype.EmbeddedDocument:
+			if err := inferSchema(value.Document(), name+".", fields); err != nil {
+				return err
+			}
+		case bsontype.Array:
+			items, err := value.Array().Values()
+			if err != nil {
+				return err
+			}
+			for _, item := range items {
+				if item.Type == bsontype.EmbeddedDocument {
+					if err := inferSchema(item.Document(), name+".", fields); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// getSchemaFields collect the expected fields of the struct type with their bson names
+func getSchemaFields(structType reflect.Type, prefix string, fields map[string]schemaField) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("bson"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		var omitEmpty, inline bool
+		for _, flag := range tag[1:] {
+			omitEmpty = omitEmpty || flag == "omitempty"
+			inline = inline || flag == "inline"
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+			omitEmpty = true
+		}
+		if inline && fieldType.Kind() == reflect.Struct {
+			getSchemaFields(fieldType, prefix, fields)
+			continue
+		}
+
+		expected := getSchemaType(fieldType)
+		expected.omitEmpty = omitEmpty
+		fields[prefix+name] = expected
+
+		if fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Array {
+			fieldType = fieldType.Elem()
+			for fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+		}
+		if fieldType.Kind() == reflect.Struct && !expected.open && getSchemaType(fieldType).types[0] == "object" {
+			getSchemaFields(fieldType, prefix+name+".", fields)
+		}
+	}
+}
+
+// getSchemaType return the type aliases decoded into a Go type
+func getSchemaType(fieldType reflect.Type) schemaField {
+	switch fieldType {
+	case timeType, dateTimeType:
+		return schemaField{types: []string{"date"}}
+	case objectIDType:
+		return schemaField{types: []string{"objectId"}}
+	case decimalType:
+		return schemaField{types: []string{"decimal"}}
+	case bytesType:
+		return schemaField{types: []string{"binData"}}
+	}
+
+	switch fieldType.Kind() {
+	case reflect.String:
+		return schemaField{types: []string{"string", "symbol"}}
+	case reflect.Bool:
+		return schemaField{types: []string{"bool"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schemaField{types: []string{"int", "long"}}
+	case reflect.Float32, reflect.Float64:
+		return schemaField{types: []string{"double", "int", "long"}}
+	case reflect.Slice, reflect.Array:
+		return schemaField{types: []string{"array"}}
+	case reflect.Map:
+		return schemaField{types: []string{"object"}, open: true}
+	case reflect.Struct:
+		return schemaField{types: []string{"object"}}
+	}
+
+	return schemaField{open: true}
+}
+
+// getSchemaDrift compare the inferred fields to the expected ones
+func getSchemaDrift(fields []SchemaField, expected map[string]schemaField) []SchemaDrift {
+	var drift []SchemaDrift
+
+	seen := map[string]bool{}
+	for _, field := range fields {
+		seen[field.Name] = true
+
+		expectedField, ok := expected[field.Name]
+		if !ok {
+			if !isOpenSchemaField(field.Name, expected) {
+				drift = append(drift, SchemaDrift{Field: field.Name, Kind: SchemaUnknownField, Actual: getTypeNames(field.Types)})
+			}
+			continue
+		}
+		if expectedField.types == nil {
+			continue
+		}
+
+		mismatch := map[string]int64{}
+		for typeName, count := range field.Types {
+			if typeName != "null" && !containsString(expectedField.types, typeName) {
+				mismatch[typeName] = count
+			}
+		}
+		if len(mismatch) > 0 {
+			drift = append(drift, SchemaDrift{
+				Field:    field.Name,
+				Kind:     SchemaTypeMismatch,
+				Expected: strings.Join(expectedField.types, ","),
+				Actual:   getTypeNames(mismatch),
+			})
+		}
+	}
+
+	var missing []string
+	for name, expectedField := range expected {
+		if !seen[name] && !expectedField.omitEmpty && !isOpenSchemaField(name, expected) {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		drift = append(drift, SchemaDrift{Field: name, Kind: SchemaMissingField, Expected: strings.Join(expected[name].types, ",")})
+	}
+
+	return drift
+}
+
+// isOpenSchemaField return true when a parent of the field is a map or an interface
+func isOpenSchemaField(name string, expected map[string]schemaField) bool {
+	for i := strings.LastIndex(name, "."); i > 0; i = strings.LastIndex(name[:i], ".") {
+		if parent, ok := expected[name[:i]]; ok {
+			return parent.open
+		}
+	}
+
+	return false
+}
+
+// getTypeNames return the sorted type aliases of types
+func getTypeNames(types map[string]int64) string {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ",")
+}
+
+// getTypeAlias return the $type alias of a BSON type
+func getTypeAlias(kind bsontype.Type) string {
+	switch kind {
+	case bsontype.Double:
+		return "double"
+	case bsontype.String:
+		return "string"
+	case bsontype.EmbeddedDocument:
+		return "object"
+	case bsontype.Array:
+		return "array"
+	case bsontype.Binary:
+		return "binData"
+	case bsontype.Undefined:
+		return "undefined"
+	case bsontype.ObjectID:
+		return "objectId"
+	case bsontype.Boolean:
+		return "bool"
+	case bsontype.DateTime:
+		return "date"
+	case bsontype.Null:
+		return "null"
+	case bsontype.Regex:
+		return "regex"
+	case bsontype.JavaScript, bsontype.CodeWithScope:
+		return "javascript"
+	case bsontype.Symbol:
+		return "symbol"
+	case bsontype.Int32:
+		return "int"
+	case bsontype.Timestamp:
+		return "timestamp"
+	case bsontype.Int64:
+		return "long"
+	case bsontype.Decimal128:
+		return "decimal"
+	case bsontype.MinKey:
+		return "minKey"
+	case bsontype.MaxKey:
+		return "maxKey"
+	}
+
+	return kind.String()
+}
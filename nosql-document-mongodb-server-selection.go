@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultHeartbeatInterval is the interval between two checks of each server, the driver default is 10 seconds
+	// so a failover can go unnoticed that long
+	defaultHeartbeatInterval = 2 * time.Second
+	// defaultServerSelectionTimeout is how long an operation waits for a suitable server, like a new primary during a
+	// failover, the driver default is 30 seconds
+	defaultServerSelectionTimeout = 5 * time.Second
+	// defaultLocalThreshold is the latency window of the servers eligible for reads, the driver default
+	defaultLocalThreshold = 15 * time.Millisecond
+	// minHeartbeatInterval is the minimum interval between two checks of a server allowed by the driver
+	minHeartbeatInterval = 500 * time.Millisecond
+)
+
+// applyServerSelection set the heartbeat interval, the server selection timeout and the local threshold of config.
+// The values of config win over the ones of the connection string options, which win over the defaults.
+func applyServerSelection(config *MongoDBServerSelection, clientOptions *options.ClientOptions) {
+	if config.HeartbeatInterval > 0 {
+		clientOptions.SetHeartbeatInterval(config.HeartbeatInterval)
+	} else if clientOptions.HeartbeatInterval == nil {
+		clientOptions.SetHeartbeatInterval(defaultHeartbeatInterval)
+	}
+
+	if config.ServerSelectionTimeout > 0 {
+		clientOptions.SetServerSelectionTimeout(config.ServerSelectionTimeout)
+	} else if clientOptions.ServerSelectionTimeout == nil {
+		clientOptions.SetServerSelectionTimeout(defaultServerSelectionTimeout)
+	}
+
+	if config.LocalThreshold > 0 {
+		clientOptions.SetLocalThreshold(config.LocalThreshold)
+	} else if clientOptions.LocalThreshold == nil {
+		clientOptions.SetLocalThreshold(defaultLocalThreshold)
+	}
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// StartCausalSession start a causally consistent session and return a handle of the client bound to it, see
+// WithContext. A read of the handle after one of its writes is guaranteed to observe it, even on a secondary, while the
+// operations of the client and its other handles run outside the session. Sessions are not safe for concurrent use,
+// so the handle must not be shared between goroutines and is ended with EndCausalSession.
+func (m *MongoClient) StartCausalSession(parent context.Context) (*MongoClient, error) {
+	sessionOptions := options.Session().
+		SetCausalConsistency(true).
+		SetDefaultReadConcern(readconcern.Majority()).
+		SetDefaultWriteConcern(writeconcern.New(writeconcern.WMajority()))
+
+	session, err := m.getClient().StartSession(sessionOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.WithContext(mongo.NewSessionContext(parent, session)), nil
+}
+
+// EndCausalSession end the session of the handle returned from StartCausalSession
+func (m *MongoClient) EndCausalSession(session *MongoClient) {
+	sc := session.Context()
+	if mongoSession := mongo.SessionFromContext(sc); mongoSession != nil {
+		mongoSession.EndSession(sc)
+	}
+}
+
+// WithSnapshot run fn where every read operation see the same point-in-time view of the data,
+// it's useful for reports built from many collections. The reads run at snapshot read concern
+// inside one transaction of tx, so fn should not write.
+func (m *MongoClient) WithSnapshot(fn func(tx *MongoClient) error) error {
+	return m.WithTransaction(fn, options.Transaction().
+		SetReadConcern(readconcern.Snapshot()).
+		SetWriteConcern(writeconcern.New(writeconcern.WMajority())))
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// maxReportedFieldSizes is the number of fields reported by DocumentSizeError
+	maxReportedFieldSizes = 5
+)
+
+// FieldSize model for the encoded size of a top level field of a document
+type FieldSize struct {
+	Field string
+	Size  int64
+}
+
+// DocumentSizeError is returned by Create when a document is above MaxDocumentSize of the config, before it is sent
+type DocumentSizeError struct {
+	Index  int   // position of the document in the created documents
+	Size   int64 // encoded size in bytes
+	Limit  int64
+	Fields []FieldSize // largest top level fields, largest first
+}
+
+// Error return the message of the document size error with its largest fields
+func (e *DocumentSizeError) Error() string {
+	fields := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		fields[i] = fmt.Sprintf("%s (%s)", field.Field, formatSize(field.Size))
+	}
+
+	return fmt.Sprintf("Document %d is %s, above the limit of %s, largest fields: %s", e.Index, formatSize(e.Size), formatSize(e.Limit), strings.Join(fields, ", "))
+}
+
+// checkDocumentSizes return a DocumentSizeError for the first document above limit, 0 disable the check. The documents
+// which can not be encoded are left to the driver.
+func checkDocumentSizes(documents []interface{}, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+
+	for i, document := range documents {
+		data, err := bson.Marshal(document)
+		if err != nil || int64(len(data)) <= limit {
+			continue
+		}
+
+		return &DocumentSizeError{Index: i, Size: int64(len(data)), Limit: limit, Fields: getFieldSizes(bson.Raw(data))}
+	}
+
+	return nil
+}
+
+// getFieldSizes return the largest top level fields of document
+func getFieldSizes(document bson.Raw) []FieldSize {
+	elements, err := document.Elements()
+	if err != nil {
+		return nil
+	}
+
+	fields := make([]FieldSize, 0, len(elements))
+	for _, element := range elements {
+		fields = append(fields, FieldSize{Field: element.Key(), Size: int64(len(element))})
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Size > fields[j].Size })
+	if len(fields) > maxReportedFieldSizes {
+		fields = fields[:maxReportedFieldSizes]
+	}
+
+	return fields
+}
+
+// formatSize return size in bytes, KB or MB
+func formatSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1fKB", float64(size)/1024)
+	}
+
+	return fmt.Sprintf("%dB", size)
+}
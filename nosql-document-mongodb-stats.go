@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// systemDatabases are the databases skipped by StatsCollector when no database is configured
+	systemDatabases = []string{"admin", "config", "local"}
+)
+
+// StatsCollectorOptions model for StatsCollector
+type StatsCollectorOptions struct {
+	Interval  time.Duration             // time between two collections, default 1 minute
+	Databases []string                  // databases to collect, every non system database when it is empty
+	Report    func(stats *StorageStats) // called after every collection, to feed a metrics backend
+}
+
+// collStats private model for collStats command result
+type collStats struct {
+	Count          int64              `bson:"count,truncate"`
+	Size           int64              `bson:"size,truncate"`
+	AvgObjSize     float64            `bson:"avgObjSize"`
+	StorageSize    int64              `bson:"storageSize,truncate"`
+	NIndexes       int64              `bson:"nindexes"`
+	TotalIndexSize int64              `bson:"totalIndexSize,truncate"`
+	IndexSizes     map[string]float64 `bson:"indexSizes"`
+}
+
+// dbStats private model for dbStats command result
+type dbStats struct {
+	Collections int64   `bson:"collections"`
+	Views       int64   `bson:"views"`
+	Objects     int64   `bson:"objects,truncate"`
+	AvgObjSize  float64 `bson:"avgObjSize"`
+	DataSize    int64   `bson:"dataSize,truncate"`
+	StorageSize int64   `bson:"storageSize,truncate"`
+	Indexes     int64   `bson:"indexes"`
+	IndexSize   int64   `bson:"indexSize,truncate"`
+}
+
+// CollectionStats return the document count, storage size and index sizes of the collection, sizes are in byte
+func (m *MongoClient) CollectionStats(databaseName, collectionName string) (*CollectionStats, error) {
+	release, err := m.admit(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var result collStats
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		return m.getClient().Database(databaseName).RunCommand(sc, bson.D{primitive.E{Key: "collStats", Value: collectionName}}).Decode(&result)
+	}); err != nil {
+		log.Println("Unable to get collection stats: ", err)
+		return nil, err
+	}
+
+	indexSizes := make(map[string]int64, len(result.IndexSizes))
+	for name, size := range result.IndexSizes {
+		indexSizes[name] = int64(size)
+	}
+
+	return &CollectionStats{
+		Database:       databaseName,
+		Collection:     collectionName,
+		Count:          result.Count,
+		Size:           result.Size,
+		AvgObjSize:     int64(result.AvgObjSize),
+		StorageSize:    result.StorageSize,
+		Indexes:        result.NIndexes,
+		TotalIndexSize: result.TotalIndexSize,
+		IndexSizes:     indexSizes,
+	}, nil
+}
+
+// DatabaseStats return the collection, document and index counts and sizes of the database, sizes are in byte
+func (m *MongoClient) DatabaseStats(databaseName string) (*DatabaseStats, error) {
+	if databaseName == "" {
+		return nil, &InvalidArgumentError{Argument: "databaseName", Reason: "cannot be empty"}
+	}
+
+	var result dbStats
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		return m.getClient().Database(databaseName).RunCommand(sc, bson.D{primitive.E{Key: "dbStats", Value: 1}}).Decode(&result)
+	}); err != nil {
+		log.Println("Unable to get database stats: ", err)
+		return nil, err
+	}
+
+	return &DatabaseStats{
+		Database:    databaseName,
+		Collections: result.Collections,
+		Views:       result.Views,
+		Objects:     result.Objects,
+		AvgObjSize:  int64(result.AvgObjSize),
+		DataSize:    result.DataSize,
+		StorageSize: result.StorageSize,
+		Indexes:     result.Indexes,
+		IndexSize:   result.IndexSize,
+	}, nil
+}
+
+// StatsCollector collect the stats of databases and their collections periodically, for capacity planning
+type StatsCollector struct {
+	client  *MongoClient
+	options StatsCollectorOptions
+	mu      sync.RWMutex
+	stats   *StorageStats
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewStatsCollector init new stats collector, call Start to run it
+func NewStatsCollector(client *MongoClient, collectorOptions StatsCollectorOptions) *StatsCollector {
+	if collectorOptions.Interval <= 0 {
+		collectorOptions.Interval = time.Minute
+	}
+
+	return &StatsCollector{client: client, options: collectorOptions}
+}
+
+// Start collect the stats now and then every interval, until Stop
+func (s *StatsCollector) Start() {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.options.Interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := s.Collect(); err != nil {
+				log.Println("Unable to collect storage stats: ", err)
+			}
+
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop the collection and wait for the running one
+func (s *StatsCollector) Stop() {
+	if s.stop == nil {
+		return
+	}
+
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+}
+
+// Stats return the last collected stats, nil before the first collection
+func (s *StatsCollector) Stats() *StorageStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.stats
+}
+
+// Collect the stats of the databases and their collections now, views are skipped
+func (s *StatsCollector) Collect() (*StorageStats, error) {
+	databases := s.options.Databases
+	if len(databases) == 0 {
+		var err error
+		if databases, err = s.getDatabases(); err != nil {
+			return nil, err
+		}
+	}
+
+	stats := &StorageStats{
+		Databases:   map[string]DatabaseStats{},
+		Collections: map[string]CollectionStats{},
+		CollectedAt: time.Now(),
+	}
+	for _, databaseName := range databases {
+		databaseStats, err := s.client.DatabaseStats(databaseName)
+		if err != nil {
+			return nil, err
+		}
+		stats.Databases[databaseName] = *databaseStats
+
+		var collections []string
+		if err := s.client.executeWithoutTransaction(func(sc context.Context) (err error) {
+			collections, err = s.client.getClient().Database(databaseName).ListCollectionNames(sc, bson.M{"type": "collection"})
+			return err
+		}); err != nil {
+			return nil, err
+		}
+
+		for _, collectionName := range collections {
+			collectionStats, err := s.client.CollectionStats(databaseName, collectionName)
+			if err != nil {
+				return nil, err
+			}
+			stats.Collections[databaseName+"."+collectionName] = *collectionStats
+		}
+	}
+
+	s.mu.Lock()
+	s.stats = stats
+	s.mu.Unlock()
+
+	if s.options.Report != nil {
+		s.options.Report(stats)
+	}
+
+	return stats, nil
+}
+
+// getDatabases return the names of the non system databases
+func (s *StatsCollector) getDatabases() ([]string, error) {
+	var names []string
+	if err := s.client.executeWithoutTransaction(func(sc context.Context) (err error) {
+		names, err = s.client.getClient().ListDatabaseNames(sc, bson.M{})
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	databases := make([]string, 0, len(names))
+	for _, name := range names {
+		if !containsString(systemDatabases, name) {
+			databases = append(databases, name)
+		}
+	}
+
+	return databases, nil
+}
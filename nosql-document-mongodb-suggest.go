@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"errors"
+	"log"
+	"reflect"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// unrecognizedStageCode is returned when the server does not support a pipeline stage, like $search outside Atlas
+	unrecognizedStageCode = 40324
+	// defaultSuggestLimit is the number of suggestions when limit is not provided
+	defaultSuggestLimit = 10
+)
+
+// SuggestOptions model for Suggest
+type SuggestOptions struct {
+	SearchIndex     string      // Atlas Search index with an autocomplete mapping of the field, regex prefix matching is used when empty or unsupported
+	Filter          interface{} // documents to suggest, all documents when nil
+	CaseInsensitive bool        // regex prefix matching ignores case, then the index of the field can not bound the scan
+}
+
+// Suggest return at most limit documents whose field starts with prefix, for search-box suggestions.
+// Atlas Search autocomplete results are ordered by score, regex results by field value using its index.
+func (m *MongoClient) Suggest(databaseName, collectionName, field, prefix string, limit int64, suggestOptions *SuggestOptions, dataModel reflect.Type) (interface{}, error) {
+	if suggestOptions == nil {
+		suggestOptions = &SuggestOptions{}
+	}
+	if limit <= 0 {
+		limit = defaultSuggestLimit
+	}
+	if prefix == "" {
+		return reflect.New(reflect.SliceOf(dataModel)).Interface(), nil
+	}
+
+	if suggestOptions.SearchIndex != "" {
+		pipeline := mongo.Pipeline{bson.D{primitive.E{Key: "$search", Value: bson.M{
+			"index":        suggestOptions.SearchIndex,
+			"autocomplete": bson.M{"query": prefix, "path": field},
+		}}}}
+		if suggestOptions.Filter != nil {
+			pipeline = append(pipeline, bson.D{primitive.E{Key: "$match", Value: suggestOptions.Filter}})
+		}
+		pipeline = append(pipeline, bson.D{primitive.E{Key: "$limit", Value: limit}})
+
+		results, err := m.Aggregate(databaseName, collectionName, pipeline, dataModel)
+		var commandErr mongo.CommandError
+		if err == nil || !errors.As(err, &commandErr) || commandErr.Code != unrecognizedStageCode {
+			return results, err
+		}
+		log.Println("Atlas Search is not available, falling back to regex suggestions")
+	}
+
+	pattern := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(prefix)}
+	if suggestOptions.CaseInsensitive {
+		pattern.Options = "i"
+	}
+
+	var filter interface{} = bson.M{field: pattern}
+	if suggestOptions.Filter != nil {
+		filter = bson.M{"$and": bson.A{suggestOptions.Filter, filter}}
+	}
+
+	return m.Aggregate(databaseName, collectionName, mongo.Pipeline{
+		bson.D{primitive.E{Key: "$match", Value: filter}},
+		bson.D{primitive.E{Key: "$sort", Value: bson.D{primitive.E{Key: field, Value: 1}}}},
+		bson.D{primitive.E{Key: "$limit", Value: limit}},
+	}, dataModel)
+}
@@ -0,0 +1,491 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// SyncSourceWins overwrite the target documents with the source documents
+	SyncSourceWins = "sourceWins"
+	// SyncNewerWins overwrite the target document only when the version field of the source document is not older
+	SyncNewerWins = "newerWins"
+	// SyncSkipExisting never overwrite documents already in the target, deletes are still applied
+	SyncSkipExisting = "skipExisting"
+
+	// defaultSyncCheckpointInterval is the interval between resume token checkpoints
+	defaultSyncCheckpointInterval = 10 * time.Second
+)
+
+var (
+	// ErrSyncInvalidated is returned when the change stream is invalidated by a drop or rename of the source
+	ErrSyncInvalidated = errors.New("Change stream invalidated, the initial copy must run again")
+)
+
+// ISyncTarget interface of the destination of Sync
+type ISyncTarget interface {
+	Get(databaseName, collectionName string, id interface{}) (bson.Raw, error) // nil when the document does not exist
+	Upsert(databaseName, collectionName string, id interface{}, document bson.Raw) error
+	Remove(databaseName, collectionName string, id interface{}) error
+}
+
+// SyncOptions model for Sync
+type SyncOptions struct {
+	Name               string        // name of the resume token checkpoint, default is <source database>-><target database>
+	SourceDatabase     string        // database to sync
+	TargetDatabase     string        // database written on the target, default is SourceDatabase
+	Collections        []string      // collections to sync, all collections when empty
+	ConflictPolicy     string        // sourceWins (default), newerWins or skipExisting
+	VersionField       string        // field compared by newerWins, like updatedAt or version
+	CheckpointInterval time.Duration // nanosecond, default is 10 seconds
+}
+
+// Sync copy a database to a target then apply the change stream of the source to keep the target in sync,
+// the resume token is checkpointed so a restarted Sync continue where it stopped
+type Sync struct {
+	client     *MongoClient
+	target     ISyncTarget
+	checkpoint ICheckpoint
+	options    SyncOptions
+	mu         sync.RWMutex
+	stats      SyncStats
+}
+
+// NewSync init new sync from client to target
+func NewSync(client *MongoClient, target ISyncTarget, checkpoint ICheckpoint, syncOptions SyncOptions) (*Sync, error) {
+	if syncOptions.SourceDatabase == "" {
+		return nil, errors.New("Sync source database is required")
+	}
+	if syncOptions.TargetDatabase == "" {
+		syncOptions.TargetDatabase = syncOptions.SourceDatabase
+	}
+	if syncOptions.Name == "" {
+		syncOptions.Name = syncOptions.SourceDatabase + "->" + syncOptions.TargetDatabase
+	}
+	if syncOptions.ConflictPolicy == "" {
+		syncOptions.ConflictPolicy = SyncSourceWins
+	}
+	switch syncOptions.ConflictPolicy {
+	case SyncSourceWins, SyncSkipExisting:
+	case SyncNewerWins:
+		if syncOptions.VersionField == "" {
+			return nil, errors.New("Sync version field is required by newerWins")
+		}
+	default:
+		return nil, errors.New("Sync conflict policy must be sourceWins, newerWins or skipExisting")
+	}
+	if syncOptions.CheckpointInterval <= 0 {
+		syncOptions.CheckpointInterval = defaultSyncCheckpointInterval
+	}
+
+	return &Sync{client: client, target: target, checkpoint: checkpoint, options: syncOptions}, nil
+}
+
+// Stats return the sync metrics
+func (s *Sync) Stats() SyncStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.stats
+}
+
+// Start run the sync in background until the returned function is called
+func (s *Sync) Start() func() {
+	runCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		if err := s.Run(runCtx); err != nil && runCtx.Err() == nil {
+			log.Println("Unable to sync: ", err)
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Run the initial copy when no resume token is checkpointed, then apply the change events until runCtx is done
+func (s *Sync) Run(runCtx context.Context) error {
+	token, err := s.checkpoint.Load(s.options.Name)
+	if err != nil {
+		log.Println("Unable to load sync checkpoint: ", err)
+		return err
+	}
+
+	// TryNext wait up to MaxAwaitTime for new events
+	streamOptions := options.ChangeStream().SetFullDocument(options.UpdateLookup).SetMaxAwaitTime(time.Second)
+	if token != nil {
+		streamOptions.SetResumeAfter(token)
+	}
+
+	// The stream is opened before the initial copy so the changes made during the copy are applied after it
+	stream, err := s.client.getClient().Database(s.options.SourceDatabase).Watch(runCtx, s.getPipeline(), streamOptions)
+	if err != nil {
+		log.Println("Unable to watch source database: ", err)
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	if token == nil {
+		if err := s.copy(runCtx); err != nil {
+			log.Println("Unable to copy source database: ", err)
+			return err
+		}
+		if err := s.save(stream.ResumeToken()); err != nil {
+			return err
+		}
+	}
+
+	return s.apply(runCtx, stream)
+}
+
+// getPipeline return the change stream pipeline selecting the synced collections
+func (s *Sync) getPipeline() mongo.Pipeline {
+	if len(s.options.Collections) == 0 {
+		return mongo.Pipeline{}
+	}
+
+	return mongo.Pipeline{bson.D{primitive.E{Key: "$match", Value: bson.M{
+		"$or": bson.A{
+			bson.M{"ns.coll": bson.M{"$in": s.options.Collections}},
+			bson.M{"operationType": bson.M{"$in": bson.A{"dropDatabase", "invalidate"}}},
+		},
+	}}}}
+}
+
+// copy write all the documents of the synced collections to the target
+func (s *Sync) copy(runCtx context.Context) error {
+	collections := s.options.Collections
+	if len(collections) == 0 {
+		names, err := s.client.getClient().Database(s.options.SourceDatabase).ListCollectionNames(runCtx, bson.M{"type": "collection"})
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if !matchCollection(name, nil, []string{"system.*"}) {
+				continue
+			}
+			collections = append(collections, name)
+		}
+	}
+
+	for _, collectionName := range collections {
+		collection := s.client.getClient().Database(s.options.SourceDatabase).Collection(collectionName)
+		cur, err := collection.Find(runCtx, bson.M{}, options.Find().SetBatchSize(defaultExportBatchSize))
+		if err != nil {
+			return err
+		}
+
+		for cur.Next(runCtx) {
+			document := append(bson.Raw(nil), cur.Current...)
+			written, err := s.write(collectionName, document.Lookup("_id"), document)
+			if err != nil {
+				cur.Close(runCtx)
+				return err
+			}
+
+			s.mu.Lock()
+			if written {
+				s.stats.Copied++
+			} else {
+				s.stats.Skipped++
+			}
+			s.mu.Unlock()
+		}
+		err = cur.Err()
+		cur.Close(runCtx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// changeEvent model of a change stream event
+type changeEvent struct {
+	ID            bson.Raw `bson:"_id"`
+	OperationType string   `bson:"operationType"`
+	Namespace     struct {
+		DB   string `bson:"db"`
+		Coll string `bson:"coll"`
+	} `bson:"ns"`
+	DocumentKey       bson.Raw            `bson:"documentKey"`
+	FullDocument      bson.Raw            `bson:"fullDocument"`
+	UpdateDescription bson.Raw            `bson:"updateDescription"`
+	ClusterTime       primitive.Timestamp `bson:"clusterTime"`
+}
+
+// apply write the change events to the target and checkpoint the resume token every CheckpointInterval
+func (s *Sync) apply(runCtx context.Context, stream *mongo.ChangeStream) error {
+	lastCheckpoint := time.Now()
+	for {
+		if !stream.TryNext(runCtx) {
+			if runCtx.Err() != nil {
+				// Keep the position of the last event applied
+				return s.save(stream.ResumeToken())
+			}
+			if err := stream.Err(); err != nil {
+				return err
+			}
+		} else {
+			var event changeEvent
+			if err := stream.Decode(&event); err != nil {
+				return err
+			}
+			if err := s.applyEvent(&event); err != nil {
+				return err
+			}
+		}
+
+		if time.Since(lastCheckpoint) >= s.options.CheckpointInterval {
+			if err := s.save(stream.ResumeToken()); err != nil {
+				return err
+			}
+			lastCheckpoint = time.Now()
+		}
+	}
+}
+
+// applyEvent write the change of the event to the target
+func (s *Sync) applyEvent(event *changeEvent) error {
+	var written bool
+	var err error
+	switch event.OperationType {
+	case "insert", "update", "replace":
+		if event.FullDocument == nil {
+			// The document was deleted after the change, the delete event follows
+			return nil
+		}
+		written, err = s.write(event.Namespace.Coll, event.DocumentKey.Lookup("_id"), event.FullDocument)
+	case "delete":
+		err = s.target.Remove(s.options.TargetDatabase, event.Namespace.Coll, event.DocumentKey.Lookup("_id"))
+		written = true
+	case "drop", "rename", "dropDatabase", "invalidate":
+		return ErrSyncInvalidated
+	default:
+		return nil
+	}
+	if err != nil {
+		log.Println("Unable to apply change event: ", err)
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if written {
+		s.stats.Applied++
+	} else {
+		s.stats.Skipped++
+	}
+	s.stats.LastEventAt = time.Unix(int64(event.ClusterTime.T), 0)
+
+	return nil
+}
+
+// write upsert document to the target based on the conflict policy and return false when it is skipped
+func (s *Sync) write(collectionName string, id interface{}, document bson.Raw) (bool, error) {
+	if s.options.ConflictPolicy != SyncSourceWins {
+		current, err := s.target.Get(s.options.TargetDatabase, collectionName, id)
+		if err != nil {
+			return false, err
+		}
+		if current != nil && !s.isNewer(document, current) {
+			return false, nil
+		}
+	}
+
+	return true, s.target.Upsert(s.options.TargetDatabase, collectionName, id, document)
+}
+
+// isNewer return true when the source document can overwrite the target document
+func (s *Sync) isNewer(source, target bson.Raw) bool {
+	if s.options.ConflictPolicy == SyncSkipExisting {
+		return false
+	}
+
+	sourceVersion, sourceErr := source.LookupErr(s.options.VersionField)
+	targetVersion, targetErr := target.LookupErr(s.options.VersionField)
+	if targetErr != nil {
+		return true
+	}
+	if sourceErr != nil {
+		return false
+	}
+
+	return compareVersion(sourceVersion, targetVersion) >= 0
+}
+
+// compareVersion compare numbers, dates, timestamps and strings, values of different types are equal
+func compareVersion(a, b bson.RawValue) int {
+	if aNumber, ok := a.AsInt64OK(); ok {
+		if bNumber, ok := b.AsInt64OK(); ok {
+			return compareInt64(aNumber, bNumber)
+		}
+	}
+	if a.Type == bsontype.Double && b.Type == bsontype.Double {
+		switch {
+		case a.Double() < b.Double():
+			return -1
+		case a.Double() > b.Double():
+			return 1
+		}
+		return 0
+	}
+	if a.Type != b.Type {
+		return 0
+	}
+
+	switch a.Type {
+	case bsontype.DateTime:
+		return compareInt64(a.DateTime(), b.DateTime())
+	case bsontype.Timestamp:
+		aT, aI := a.Timestamp()
+		bT, bI := b.Timestamp()
+		if aT != bT {
+			return compareInt64(int64(aT), int64(bT))
+		}
+		return compareInt64(int64(aI), int64(bI))
+	case bsontype.String:
+		switch {
+		case a.StringValue() < b.StringValue():
+			return -1
+		case a.StringValue() > b.StringValue():
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// compareInt64 return -1, 0 or 1
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+// save checkpoint the resume token
+func (s *Sync) save(token bson.Raw) error {
+	if token == nil {
+		return nil
+	}
+
+	if err := s.checkpoint.Save(s.options.Name, token); err != nil {
+		log.Println("Unable to save sync checkpoint: ", err)
+		return err
+	}
+
+	s.mu.Lock()
+	s.stats.LastCheckpointAt = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// NewSyncTarget return the sync target writing to db, MongoDB targets replace documents atomically,
+// other backends delete and create them
+func NewSyncTarget(db INoSQLDocument) ISyncTarget {
+	if client, ok := db.(*MongoClient); ok {
+		return &mongoSyncTarget{client}
+	}
+
+	return &documentSyncTarget{db}
+}
+
+// mongoSyncTarget write synced documents to MongoDB
+type mongoSyncTarget struct {
+	client *MongoClient
+}
+
+// Get implement ISyncTarget
+func (t *mongoSyncTarget) Get(databaseName, collectionName string, id interface{}) (bson.Raw, error) {
+	var document bson.Raw
+	err := t.client.executeWithoutTransaction(func(sc context.Context) error {
+		collection := t.client.getClient().Database(databaseName).Collection(collectionName)
+		return collection.FindOne(sc, bson.M{"_id": id}).Decode(&document)
+	})
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+
+	return document, err
+}
+
+// Upsert implement ISyncTarget
+func (t *mongoSyncTarget) Upsert(databaseName, collectionName string, id interface{}, document bson.Raw) error {
+	return t.client.executeWithoutTransaction(func(sc context.Context) error {
+		collection := t.client.getClient().Database(databaseName).Collection(collectionName)
+		_, err := collection.ReplaceOne(sc, bson.M{"_id": id}, document, options.Replace().SetUpsert(true))
+		return err
+	})
+}
+
+// Remove implement ISyncTarget
+func (t *mongoSyncTarget) Remove(databaseName, collectionName string, id interface{}) error {
+	return t.client.executeWithoutTransaction(func(sc context.Context) error {
+		collection := t.client.getClient().Database(databaseName).Collection(collectionName)
+		_, err := collection.DeleteOne(sc, bson.M{"_id": id})
+		return err
+	})
+}
+
+// documentSyncTarget write synced documents to any INoSQLDocument backend
+type documentSyncTarget struct {
+	db INoSQLDocument
+}
+
+// Get implement ISyncTarget
+func (t *documentSyncTarget) Get(databaseName, collectionName string, id interface{}) (bson.Raw, error) {
+	results, err := t.db.Read(databaseName, collectionName, bson.M{"_id": id}, 1, reflect.TypeOf(bson.M{}))
+	if err != nil {
+		return nil, err
+	}
+
+	documents, ok := results.(*[]bson.M)
+	if !ok || len(*documents) == 0 {
+		return nil, nil
+	}
+
+	return bson.Marshal((*documents)[0])
+}
+
+// Upsert implement ISyncTarget
+func (t *documentSyncTarget) Upsert(databaseName, collectionName string, id interface{}, document bson.Raw) error {
+	if err := t.Remove(databaseName, collectionName, id); err != nil {
+		return err
+	}
+
+	var value bson.D
+	if err := bson.Unmarshal(document, &value); err != nil {
+		return err
+	}
+
+	_, err := t.db.Create(databaseName, collectionName, []interface{}{value})
+	return err
+}
+
+// Remove implement ISyncTarget
+func (t *documentSyncTarget) Remove(databaseName, collectionName string, id interface{}) error {
+	_, err := t.db.Delete(databaseName, collectionName, bson.M{"_id": id})
+	return err
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// getTimeout return the timeout of the operation on the collection, the override of the collection or the default,
+// 0 means no timeout. Operations are the names used by faults, create, update and delete are writes.
+func (m *MongoClient) getTimeout(operation, databaseName, collectionName string) time.Duration {
+	timeouts := &m.getConfig().Timeouts
+	if override, ok := timeouts.Collections[databaseName+"."+collectionName]; ok {
+		if timeout := getOperationTimeout(operation, override.Read, override.Write, override.Aggregate); timeout > 0 {
+			return timeout
+		}
+	}
+
+	return getOperationTimeout(operation, timeouts.Read, timeouts.Write, timeouts.Aggregate)
+}
+
+// getOperationTimeout return the timeout matching the operation
+func getOperationTimeout(operation string, read, write, aggregate time.Duration) time.Duration {
+	switch operation {
+	case OperationRead:
+		return read
+	case OperationAggregate:
+		return aggregate
+	case OperationCreate, OperationUpdate, OperationDelete:
+		return write
+	}
+
+	return 0
+}
+
+// withTimeout return sc with the deadline of timeout, the deadline of sc is kept when it is earlier.
+// The session of sc is kept, so operations in transactions are bounded too.
+func withTimeout(sc context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return sc, func() {}
+	}
+
+	return context.WithTimeout(sc, timeout)
+}
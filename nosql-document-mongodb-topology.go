@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/description"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// TopologyPrimaryElected is the event of a server becoming the primary of the replica set
+	TopologyPrimaryElected = "primaryElected"
+	// TopologyPrimaryLost is the event of the primary stepping down or becoming unreachable, writes fail until a
+	// new primary is elected
+	TopologyPrimaryLost = "primaryLost"
+	// TopologyServerAdded is the event of a server added to the monitored deployment
+	TopologyServerAdded = "serverAdded"
+	// TopologyServerRemoved is the event of a server removed from the monitored deployment
+	TopologyServerRemoved = "serverRemoved"
+	// TopologyPoolCleared is the event of the connections to a server closed after a network error or a stepdown
+	TopologyPoolCleared = "poolCleared"
+)
+
+// topologyNotifier deliver the topology events of the MongoDB client to the subscribers
+type topologyNotifier struct {
+	mu          sync.RWMutex
+	next        uint64
+	subscribers map[uint64]func(TopologyEvent)
+	generation  uint64 // generation of the last connection attempt
+	active      uint64 // generation of the current connection
+}
+
+// newTopologyNotifier init new instance without subscriber
+func newTopologyNotifier() *topologyNotifier {
+	return &topologyNotifier{subscribers: make(map[uint64]func(TopologyEvent))}
+}
+
+// Subscribe call fn with every topology event, like to log failovers, and return the function ending the
+// subscription. fn is called from the driver monitoring goroutines so it must not block. The subscriptions are kept
+// when the connection is renewed.
+func (m *MongoClient) Subscribe(fn func(event TopologyEvent)) (func(), error) {
+	if fn == nil {
+		return nil, &InvalidArgumentError{Argument: "fn", Reason: "cannot be nil"}
+	}
+
+	return m.topology.subscribe(fn), nil
+}
+
+// SubscribeChannel return a channel receiving the topology events and the function ending the subscription, which
+// closes the channel. The events are dropped when the buffer of the channel is full.
+func (m *MongoClient) SubscribeChannel(buffer int) (<-chan TopologyEvent, func()) {
+	var mu sync.Mutex
+	closed := false
+	events := make(chan TopologyEvent, buffer)
+
+	unsubscribe := m.topology.subscribe(func(topologyEvent TopologyEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if closed {
+			return
+		}
+		select {
+		case events <- topologyEvent:
+		default:
+			log.Printf("Topology event %s of %s dropped, the channel is full\n", topologyEvent.Type, topologyEvent.Address)
+		}
+	})
+
+	return events, func() {
+		unsubscribe()
+
+		mu.Lock()
+		defer mu.Unlock()
+		if !closed {
+			closed = true
+			close(events)
+		}
+	}
+}
+
+// subscribe add fn to the subscribers and return the function removing it
+func (n *topologyNotifier) subscribe(fn func(TopologyEvent)) func() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.next++
+	id := n.next
+	n.subscribers[id] = fn
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			delete(n.subscribers, id)
+		})
+	}
+}
+
+// apply set the monitors of the notifier on the options of a new connection and return its generation, to activate
+// once connected, and the function discarding its events when the connection fails. The events of the previous
+// connections, like the servers closed by their disconnection, are ignored once a newer connection is active.
+func (n *topologyNotifier) apply(clientOptions *options.ClientOptions) (uint64, func()) {
+	generation := atomic.AddUint64(&n.generation, 1)
+	var discarded int32
+	notify := func(eventType string, address string) {
+		if atomic.LoadInt32(&discarded) == 0 && generation >= atomic.LoadUint64(&n.active) {
+			n.notify(TopologyEvent{Type: eventType, Address: address, Time: time.Now().UTC()})
+		}
+	}
+
+	clientOptions.SetServerMonitor(&event.ServerMonitor{
+		ServerDescriptionChanged: func(changed *event.ServerDescriptionChangedEvent) {
+			wasPrimary := changed.PreviousDescription.Kind == description.RSPrimary
+			isPrimary := changed.NewDescription.Kind == description.RSPrimary
+			if !wasPrimary && isPrimary {
+				notify(TopologyPrimaryElected, changed.Address.String())
+			} else if wasPrimary && !isPrimary {
+				notify(TopologyPrimaryLost, changed.Address.String())
+			}
+		},
+		ServerOpening: func(opening *event.ServerOpeningEvent) {
+			notify(TopologyServerAdded, opening.Address.String())
+		},
+		ServerClosed: func(closed *event.ServerClosedEvent) {
+			notify(TopologyServerRemoved, closed.Address.String())
+		},
+	})
+
+	clientOptions.SetPoolMonitor(&event.PoolMonitor{
+		Event: func(poolEvent *event.PoolEvent) {
+			if poolEvent.Type == event.PoolCleared {
+				notify(TopologyPoolCleared, poolEvent.Address)
+			}
+		},
+	})
+
+	return generation, func() { atomic.StoreInt32(&discarded, 1) }
+}
+
+// activate ignore the events of the connections older than generation
+func (n *topologyNotifier) activate(generation uint64) {
+	atomic.StoreUint64(&n.active, generation)
+}
+
+// notify call the subscribers with topologyEvent
+func (n *topologyNotifier) notify(topologyEvent TopologyEvent) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	for _, fn := range n.subscribers {
+		fn(topologyEvent)
+	}
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+var (
+	// ErrTransactionsUnsupported is returned when the deployment can not run transactions
+	ErrTransactionsUnsupported = errors.New("Transactions are not supported")
+)
+
+// getTransactionOptions return the default transaction options based on config
+func getTransactionOptions(config *MongoDBTransaction) (*options.TransactionOptions, error) {
+	opts := options.Transaction()
+
+	if config.ReadConcern != "" {
+		opts.SetReadConcern(readconcern.New(readconcern.Level(config.ReadConcern)))
+	}
+
+	if config.WriteConcern != "" {
+		opts.SetWriteConcern(getWriteConcern(config.WriteConcern))
+	}
+
+	if config.ReadPreference != "" {
+		readPreference, err := getReadPreference(config.ReadPreference)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetReadPreference(readPreference)
+	}
+
+	if config.MaxCommitTime > 0 {
+		maxCommitTime := config.MaxCommitTime
+		opts.SetMaxCommitTime(&maxCommitTime)
+	}
+
+	return opts, nil
+}
+
+// getWriteConcern return write concern based on "majority", number of nodes or tag set name
+func getWriteConcern(w string) *writeconcern.WriteConcern {
+	if w == "majority" {
+		return writeconcern.New(writeconcern.WMajority())
+	}
+
+	if n, err := strconv.Atoi(w); err == nil {
+		return writeconcern.New(writeconcern.W(n))
+	}
+
+	return writeconcern.New(writeconcern.WTagSet(w))
+}
+
+// getReadPreference return read preference based on mode name (primary, secondaryPreferred...)
+func getReadPreference(mode string, opts ...readpref.Option) (*readpref.ReadPref, error) {
+	readPreferenceMode, err := readpref.ModeFromString(mode)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid read preference %q: %v", mode, err)
+	}
+
+	return readpref.New(readPreferenceMode, opts...)
+}
+
+// WithTransaction run fn inside one transaction, the operations of tx, a handle of the client bound to the session
+// (see WithContext), join that transaction while the operations of the client and its other handles do not.
+// tx.Context() is the session context for direct driver calls. Called from tx, it run fn in the current transaction.
+// The options provided override the default transaction options from config.
+func (m *MongoClient) WithTransaction(fn func(tx *MongoClient) error, opts ...*options.TransactionOptions) error {
+	transactional, transactionOptions := m.getTransaction()
+	if !transactional {
+		return ErrTransactionsUnsupported
+	}
+
+	parent := m.Context()
+	if mongo.SessionFromContext(parent) != nil {
+		return fn(m)
+	}
+
+	session, err := m.getClient().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(parent)
+
+	_, err = session.WithTransaction(parent, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(m.WithContext(sc))
+	}, options.MergeTransactionOptions(append([]*options.TransactionOptions{transactionOptions}, opts...)...))
+
+	return err
+}
+
+// RunInTransaction implement ITransactional with WithTransaction and the default transaction options, tx is a
+// *MongoClient
+func (m *MongoClient) RunInTransaction(fn func(tx interface{}) error) error {
+	return m.WithTransaction(func(tx *MongoClient) error {
+		return fn(tx)
+	})
+}
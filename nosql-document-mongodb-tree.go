@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultTreeParentField is the field holding the _id of the parent node
+	defaultTreeParentField = "parent"
+)
+
+var (
+	// ErrTreeCycle is returned when a subtree is moved below itself
+	ErrTreeCycle = errors.New("Node cannot be moved below itself")
+)
+
+// Tree store hierarchical data, like category trees and org structures, as parent references in a collection,
+// root nodes have no or a null parent. Subtrees are walked by $graphLookup.
+type Tree struct {
+	client         *MongoClient
+	databaseName   string
+	collectionName string
+	parentField    string
+}
+
+// NewTree init new tree over databaseName.collectionName, parentField default is parent
+func NewTree(client *MongoClient, databaseName, collectionName, parentField string) *Tree {
+	if parentField == "" {
+		parentField = defaultTreeParentField
+	}
+
+	return &Tree{client: client, databaseName: databaseName, collectionName: collectionName, parentField: parentField}
+}
+
+// GetRoots return the nodes without parent
+func (t *Tree) GetRoots(dataModel reflect.Type) (interface{}, error) {
+	return t.client.Read(t.databaseName, t.collectionName, bson.M{t.parentField: nil}, 0, dataModel)
+}
+
+// GetChildren return the direct children of the node
+func (t *Tree) GetChildren(id interface{}, dataModel reflect.Type) (interface{}, error) {
+	return t.client.Read(t.databaseName, t.collectionName, bson.M{t.parentField: id}, 0, dataModel)
+}
+
+// GetAncestors return the ancestors of the node from its parent to the root
+func (t *Tree) GetAncestors(id interface{}, dataModel reflect.Type) (interface{}, error) {
+	return t.client.Aggregate(t.databaseName, t.collectionName, t.getLookupPipeline(id, "$"+t.parentField, t.parentField, "_id", -1), dataModel)
+}
+
+// GetDescendants return the descendants of the node breadth first, maxDepth 0 means no limit
+func (t *Tree) GetDescendants(id interface{}, maxDepth int, dataModel reflect.Type) (interface{}, error) {
+	return t.client.Aggregate(t.databaseName, t.collectionName, t.getLookupPipeline(id, "$_id", "_id", t.parentField, maxDepth-1), dataModel)
+}
+
+// MoveSubtree set newParentID as parent of the node, its descendants move with it.
+// A nil newParentID makes the node a root.
+func (t *Tree) MoveSubtree(id, newParentID interface{}) error {
+	if err := t.client.execute(func(sc context.Context) error {
+		collection := t.client.getClient().Database(t.databaseName).Collection(t.collectionName)
+
+		if newParentID != nil {
+			cur, err := collection.Aggregate(sc, t.getLookupPipeline(newParentID, "$"+t.parentField, t.parentField, "_id", -1))
+			if err != nil {
+				return err
+			}
+
+			var ancestors []struct {
+				ID interface{} `bson:"_id"`
+			}
+			if err := cur.All(sc, &ancestors); err != nil {
+				return err
+			}
+
+			if getValueKey(newParentID) == getValueKey(id) {
+				return ErrTreeCycle
+			}
+			for _, ancestor := range ancestors {
+				if getValueKey(ancestor.ID) == getValueKey(id) {
+					return ErrTreeCycle
+				}
+			}
+
+			count, err := collection.CountDocuments(sc, bson.M{"_id": newParentID}, options.Count().SetLimit(1))
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				return mongo.ErrNoDocuments
+			}
+		}
+
+		result, err := collection.UpdateOne(sc, bson.M{"_id": id}, bson.M{"$set": bson.M{t.parentField: newParentID}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
+
+		return nil
+	}); err != nil {
+		log.Println("Unable to move subtree: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// getLookupPipeline return the pipeline walking the tree from the node, the found nodes are sorted by depth.
+// maxDepth below 0 means no limit.
+func (t *Tree) getLookupPipeline(id interface{}, startWith, connectFromField, connectToField string, maxDepth int) mongo.Pipeline {
+	graphLookup := bson.D{
+		primitive.E{Key: "from", Value: t.collectionName},
+		primitive.E{Key: "startWith", Value: startWith},
+		primitive.E{Key: "connectFromField", Value: connectFromField},
+		primitive.E{Key: "connectToField", Value: connectToField},
+		primitive.E{Key: "as", Value: "_nodes"},
+		primitive.E{Key: "depthField", Value: "_depth"},
+	}
+	if maxDepth >= 0 {
+		graphLookup = append(graphLookup, primitive.E{Key: "maxDepth", Value: maxDepth})
+	}
+
+	return mongo.Pipeline{
+		bson.D{primitive.E{Key: "$match", Value: bson.M{"_id": id}}},
+		bson.D{primitive.E{Key: "$graphLookup", Value: graphLookup}},
+		bson.D{primitive.E{Key: "$unwind", Value: "$_nodes"}},
+		bson.D{primitive.E{Key: "$sort", Value: bson.D{primitive.E{Key: "_nodes._depth", Value: 1}, primitive.E{Key: "_nodes._id", Value: 1}}}},
+		bson.D{primitive.E{Key: "$replaceRoot", Value: bson.M{"newRoot": "$_nodes"}}},
+		bson.D{primitive.E{Key: "$project", Value: bson.M{"_depth": 0}}},
+	}
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// ValidateNotNull reject documents whose field is missing or null
+	ValidateNotNull = "notNull"
+	// ValidateRange reject documents whose field is below Min or above Max
+	ValidateRange = "range"
+	// ValidateReference reject documents whose field value is not in the referenced collection
+	ValidateReference = "reference"
+
+	// defaultValidationMaxIDs is the number of violating _id reported per rule
+	defaultValidationMaxIDs = 1000
+)
+
+// ValidationRule model for a data quality rule of Validate
+type ValidationRule struct {
+	Name                string // report key, default is <field>.<kind>
+	Field               string
+	Kind                string      // notNull, range or reference
+	Min                 interface{} // range lower bound, nil means no bound
+	Max                 interface{} // range upper bound, nil means no bound
+	ReferenceCollection string      // referenced collection in the same database
+	ReferenceField      string      // referenced field, default is _id
+}
+
+// Validate run the rules on the documents matching filter and report the _id of the violating documents,
+// at most maxIDs per rule (default 1000) while every violation is counted. Null and missing values only
+// violate notNull rules.
+func (m *MongoClient) Validate(databaseName, collectionName string, filter interface{}, maxIDs int, rules ...ValidationRule) (*ValidationReport, error) {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	if maxIDs <= 0 {
+		maxIDs = defaultValidationMaxIDs
+	}
+
+	var errs *multierror.Error
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Field == "" {
+			errs = multierror.Append(errs, errors.New("validate: field is required"))
+		}
+		switch rule.Kind {
+		case ValidateNotNull:
+		case ValidateRange:
+			if rule.Min == nil && rule.Max == nil {
+				errs = multierror.Append(errs, fmt.Errorf("validate: min or max is required by range rule of %s", rule.Field))
+			}
+		case ValidateReference:
+			if rule.ReferenceCollection == "" {
+				errs = multierror.Append(errs, fmt.Errorf("validate: referenceCollection is required by reference rule of %s", rule.Field))
+			}
+			if rule.ReferenceField == "" {
+				rule.ReferenceField = "_id"
+			}
+		default:
+			errs = multierror.Append(errs, fmt.Errorf("validate: unknown rule kind %q", rule.Kind))
+		}
+		if rule.Name == "" {
+			rule.Name = rule.Field + "." + rule.Kind
+		}
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+
+	report := &ValidationReport{
+		Database:   databaseName,
+		Collection: collectionName,
+		Violations: make(map[string][]interface{}),
+		Counts:     make(map[string]int64),
+	}
+	for _, rule := range rules {
+		ids, count, err := m.validateRule(databaseName, collectionName, filter, rule, maxIDs)
+		if err != nil {
+			log.Println("Unable to validate rule: ", err)
+			return nil, err
+		}
+		report.Violations[rule.Name] = ids
+		report.Counts[rule.Name] = count
+	}
+
+	return report, nil
+}
+
+// validateRule return the _id and the number of documents violating rule
+func (m *MongoClient) validateRule(databaseName, collectionName string, filter interface{}, rule ValidationRule, maxIDs int) ([]interface{}, int64, error) {
+	pipeline := mongo.Pipeline{bson.D{primitive.E{Key: "$match", Value: filter}}}
+	switch rule.Kind {
+	case ValidateNotNull:
+		pipeline = append(pipeline, bson.D{primitive.E{Key: "$match", Value: bson.M{rule.Field: nil}}})
+	case ValidateRange:
+		var bounds bson.A
+		if rule.Min != nil {
+			bounds = append(bounds, bson.M{rule.Field: bson.M{"$lt": rule.Min}})
+		}
+		if rule.Max != nil {
+			bounds = append(bounds, bson.M{rule.Field: bson.M{"$gt": rule.Max}})
+		}
+		pipeline = append(pipeline, bson.D{primitive.E{Key: "$match", Value: bson.M{"$or": bounds}}})
+	case ValidateReference:
+		pipeline = append(pipeline,
+			bson.D{primitive.E{Key: "$match", Value: bson.M{rule.Field: bson.M{"$ne": nil}}}},
+			bson.D{primitive.E{Key: "$project", Value: bson.M{"_id": 1, rule.Field: 1}}},
+			bson.D{primitive.E{Key: "$lookup", Value: bson.M{
+				"from":         rule.ReferenceCollection,
+				"localField":   rule.Field,
+				"foreignField": rule.ReferenceField,
+				"as":           "_references",
+			}}},
+			bson.D{primitive.E{Key: "$match", Value: bson.M{"_references": bson.M{"$size": 0}}}},
+		)
+	}
+	pipeline = append(pipeline, bson.D{primitive.E{Key: "$project", Value: bson.M{"_id": 1}}})
+
+	var ids []interface{}
+	var count int64
+	err := m.executeWithoutTransaction(func(sc context.Context) error {
+		ids, count = nil, 0
+
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+		cur, err := collection.Aggregate(sc, pipeline, options.Aggregate().SetAllowDiskUse(true))
+		if err != nil {
+			return err
+		}
+		defer cur.Close(sc)
+
+		for cur.Next(sc) {
+			count++
+			if len(ids) < maxIDs {
+				var document struct {
+					ID interface{} `bson:"_id"`
+				}
+				if err := cur.Decode(&document); err != nil {
+					return err
+				}
+				ids = append(ids, document.ID)
+			}
+		}
+
+		return cur.Err()
+	})
+
+	return ids, count, err
+}
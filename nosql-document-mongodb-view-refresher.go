@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrUnknownView is returned when the materialized view is not registered
+	ErrUnknownView = errors.New("Materialized view is not registered")
+)
+
+// MaterializedView model for a view of ViewRefresher, the results of Pipeline on Source are merged into Target
+type MaterializedView struct {
+	Name           string      // registration key, default is <database>.<target>
+	Cron           string      // standard 5 fields cron expression or descriptor like @every 5m
+	Database       string      // database of the source and target collections
+	Source         string      // collection aggregated
+	Target         string      // collection written, read like any collection
+	Pipeline       interface{} // stages before $merge, like mongo.Pipeline or []bson.M
+	On             []string    // fields identifying the target documents, default is _id
+	WhenMatched    string      // replace (default), keepExisting, merge or fail
+	WhenNotMatched string      // insert (default), discard or fail
+	MaxStaleness   time.Duration
+}
+
+// materializedView private model for a registered view and its metrics
+type materializedView struct {
+	view      MaterializedView
+	stages    interface{}
+	running   sync.Mutex
+	stats     ViewRefreshStats
+	createdAt time.Time
+}
+
+// ViewRefresher re-run the pipelines of materialized views into their target collections with $merge on schedule, for
+// reports cheap to read and refreshed without external orchestration. Stats report how stale each view is.
+type ViewRefresher struct {
+	client *MongoClient
+	cron   *cron.Cron
+	mu     sync.RWMutex
+	views  map[string]*materializedView
+}
+
+// NewViewRefresher init new view refresher, register the views then call Start to run it
+func NewViewRefresher(client *MongoClient) *ViewRefresher {
+	return &ViewRefresher{client: client, cron: cron.New(), views: make(map[string]*materializedView)}
+}
+
+// Register the view and schedule its refresh, a view registered again replaces the previous definition on the next
+// refresh but keeps its schedule
+func (r *ViewRefresher) Register(view MaterializedView) error {
+	if err := checkNamespace(view.Database, view.Source); err != nil {
+		return err
+	}
+	if err := checkNamespace(view.Database, view.Target); err != nil {
+		return err
+	}
+	if view.Name == "" {
+		view.Name = view.Database + "." + view.Target
+	}
+	if err := checkPipelineCompatibility(r.client.getConfig(), view.Pipeline); err != nil {
+		return err
+	}
+
+	stages := bson.A{}
+	if view.Pipeline != nil {
+		var err error
+		if stages, err = getStages(view.Pipeline); err != nil {
+			return err
+		}
+	}
+	merge := bson.M{"into": view.Target}
+	if len(view.On) > 0 {
+		merge["on"] = view.On
+	}
+	if view.WhenMatched != "" {
+		merge["whenMatched"] = view.WhenMatched
+	}
+	if view.WhenNotMatched != "" {
+		merge["whenNotMatched"] = view.WhenNotMatched
+	}
+	stages = append(stages, bson.M{"$merge": merge})
+
+	r.mu.RLock()
+	registered, ok := r.views[view.Name]
+	r.mu.RUnlock()
+	if ok {
+		// Same lock order as Refresh
+		registered.running.Lock()
+		defer registered.running.Unlock()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		registered.view, registered.stages = view, stages
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.views[view.Name]; ok {
+		return fmt.Errorf("Materialized view %s is being registered", view.Name)
+	}
+
+	name := view.Name
+	if _, err := r.cron.AddFunc(view.Cron, func() {
+		if err := r.Refresh(name); err != nil {
+			log.Printf("Unable to refresh materialized view %s: %v\n", name, err)
+		}
+	}); err != nil {
+		return fmt.Errorf("Invalid cron of materialized view %s: %v", name, err)
+	}
+	r.views[name] = &materializedView{view: view, stages: stages, createdAt: time.Now().UTC()}
+
+	return nil
+}
+
+// Start refresh the views on schedule
+func (r *ViewRefresher) Start() {
+	r.cron.Start()
+}
+
+// Stop the schedule and wait for the running refreshes
+func (r *ViewRefresher) Stop() {
+	<-r.cron.Stop().Done()
+}
+
+// Refresh the view name now, the refreshes of a view never overlap
+func (r *ViewRefresher) Refresh(name string) error {
+	r.mu.RLock()
+	registered, ok := r.views[name]
+	r.mu.RUnlock()
+	if !ok {
+		return ErrUnknownView
+	}
+
+	registered.running.Lock()
+	defer registered.running.Unlock()
+
+	start := time.Now().UTC()
+	view := registered.view
+	err := r.client.waitHealthy()
+	if err == nil {
+		err = r.client.executeWithoutTransaction(func(sc context.Context) error {
+			collection := r.client.getClient().Database(view.Database).Collection(view.Source)
+			cur, err := collection.Aggregate(sc, registered.stages, options.Aggregate().SetAllowDiskUse(true))
+			if err != nil {
+				return err
+			}
+
+			return cur.Close(sc)
+		})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		registered.stats.LastError = err.Error()
+		registered.stats.Failures++
+		return err
+	}
+	registered.stats.LastRefresh = start
+	registered.stats.LastDuration = time.Since(start)
+	registered.stats.LastError = ""
+	registered.stats.Refreshes++
+
+	return nil
+}
+
+// Stats return the metrics of the views by name
+func (r *ViewRefresher) Stats() map[string]ViewRefreshStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now().UTC()
+	stats := make(map[string]ViewRefreshStats, len(r.views))
+	for name, registered := range r.views {
+		viewStats := registered.stats
+		if viewStats.LastRefresh.IsZero() {
+			viewStats.Staleness = now.Sub(registered.createdAt)
+		} else {
+			viewStats.Staleness = now.Sub(viewStats.LastRefresh)
+		}
+		viewStats.Stale = registered.view.MaxStaleness > 0 && viewStats.Staleness > registered.view.MaxStaleness
+		stats[name] = viewStats
+	}
+
+	return stats
+}
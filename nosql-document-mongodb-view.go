@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// namespaceExistsCode is returned when a collection or a view is created with the name of an existing one
+	namespaceExistsCode = 48
+)
+
+var (
+	// ErrViewNotFound is returned when the view does not exist or is a collection
+	ErrViewNotFound = errors.New("View not found")
+)
+
+// IView interface for databases able to create read-only views, views are read by Read and Aggregate like collections
+type IView interface {
+	CreateView(databaseName, viewName, sourceName string, pipeline interface{}) error
+	DropView(databaseName, viewName string) error
+	ListViews(databaseName string) ([]View, error)
+}
+
+// CreateView create the view of the source collection or view through pipeline, the definition of an existing view
+// is replaced so the views of a service can be created on every start. Read and Aggregate query views like
+// collections, the writes are refused by the server.
+func (m *MongoClient) CreateView(databaseName, viewName, sourceName string, pipeline interface{}) error {
+	if err := checkNamespace(databaseName, viewName); err != nil {
+		return err
+	}
+	if err := checkNamespace(databaseName, sourceName); err != nil {
+		return err
+	}
+	if err := checkPipelineCompatibility(m.getConfig(), pipeline); err != nil {
+		return err
+	}
+
+	stages := bson.A{}
+	if pipeline != nil {
+		var err error
+		if stages, err = getStages(pipeline); err != nil {
+			return err
+		}
+	}
+
+	// Views can not be created inside multi-document transactions
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		database := m.getClient().Database(databaseName)
+
+		err := database.CreateView(sc, viewName, sourceName, stages)
+		var commandErr mongo.CommandError
+		if !errors.As(err, &commandErr) || commandErr.Code != namespaceExistsCode {
+			return err
+		}
+
+		return database.RunCommand(sc, bson.D{
+			primitive.E{Key: "collMod", Value: viewName},
+			primitive.E{Key: "viewOn", Value: sourceName},
+			primitive.E{Key: "pipeline", Value: stages},
+		}).Err()
+	}); err != nil {
+		log.Println("Unable to create view: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// DropView drop the view, ErrViewNotFound is returned when it is not a view so collections are never dropped
+func (m *MongoClient) DropView(databaseName, viewName string) error {
+	if err := checkNamespace(databaseName, viewName); err != nil {
+		return err
+	}
+
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		database := m.getClient().Database(databaseName)
+
+		names, err := database.ListCollectionNames(sc, bson.M{"name": viewName, "type": "view"})
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return ErrViewNotFound
+		}
+
+		return database.Collection(viewName).Drop(sc)
+	}); err != nil {
+		log.Println("Unable to drop view: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// ListViews return the views of the database with their source and pipeline
+func (m *MongoClient) ListViews(databaseName string) ([]View, error) {
+	if databaseName == "" {
+		return nil, &InvalidArgumentError{Argument: "databaseName", Reason: "cannot be empty"}
+	}
+
+	var views []View
+	if err := m.executeWithoutTransaction(func(sc context.Context) error {
+		cur, err := m.getClient().Database(databaseName).ListCollections(sc, bson.M{"type": "view"})
+		if err != nil {
+			return err
+		}
+		defer cur.Close(sc)
+
+		for cur.Next(sc) {
+			var specification struct {
+				Name    string `bson:"name"`
+				Options struct {
+					ViewOn   string `bson:"viewOn"`
+					Pipeline bson.A `bson:"pipeline"`
+				} `bson:"options"`
+			}
+			if err := cur.Decode(&specification); err != nil {
+				return err
+			}
+
+			views = append(views, View{
+				Name:     specification.Name,
+				Source:   specification.Options.ViewOn,
+				Pipeline: specification.Options.Pipeline,
+			})
+		}
+
+		return cur.Err()
+	}); err != nil {
+		log.Println("Unable to list views: ", err)
+		return nil, err
+	}
+
+	return views, nil
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+const (
+	// defaultWarmUpTimeout is the budget of the warm-up when the config does not set one
+	defaultWarmUpTimeout = 10 * time.Second
+)
+
+// WarmUp establish connections to the primary by concurrent pings and return the number of connections checked,
+// so the first burst of traffic does not pay the connection establishment. It return an error counting the failed
+// pings when the budget is exceeded, the connections already established stay in the pool.
+func (m *MongoClient) WarmUp(connections int, timeout time.Duration) (int, error) {
+	if connections <= 0 {
+		return 0, &InvalidArgumentError{Argument: "connections", Reason: "must be positive"}
+	}
+
+	return warmUpClient(m.getClient(), connections, timeout)
+}
+
+// applyWarmUp keep the warm-up connections in the pool, the driver closes idle connections above minPoolSize
+func applyWarmUp(config *MongoDBWarmUp, clientOptions *options.ClientOptions) {
+	if config.Connections <= 0 {
+		return
+	}
+
+	minPoolSize := uint64(config.Connections)
+	if clientOptions.MinPoolSize == nil || *clientOptions.MinPoolSize < minPoolSize {
+		clientOptions.SetMinPoolSize(minPoolSize)
+	}
+}
+
+// warmUp run the warm-up of config on client after the connection and log the outcome, a failed warm-up does not
+// prevent the startup because the connections are established on demand anyway
+func warmUp(client *mongo.Client, config *MongoDBWarmUp) {
+	if config.Connections <= 0 {
+		return
+	}
+
+	established, err := warmUpClient(client, config.Connections, config.Timeout)
+	if err != nil {
+		log.Printf("Unable to warm up MongoDB connections, %d ready: %v\n", established, err)
+		return
+	}
+	log.Printf("Warmed up %d MongoDB connections\n", established)
+}
+
+// warmUpClient ping the primary from connections goroutines started at once, each ping in flight hold its own
+// pooled connection, and return the number of successful pings
+func warmUpClient(client *mongo.Client, connections int, timeout time.Duration) (int, error) {
+	if timeout <= 0 {
+		timeout = defaultWarmUpTimeout
+	}
+
+	warmUpCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var (
+		wg          sync.WaitGroup
+		mu          sync.Mutex
+		firstErr    error
+		established int
+	)
+	start := make(chan struct{})
+	for i := 0; i < connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			err := client.Ping(warmUpCtx, readpref.Primary())
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			established++
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if firstErr != nil {
+		return established, fmt.Errorf("%d of %d pings failed: %w", connections-established, connections, firstErr)
+	}
+
+	return established, nil
+}
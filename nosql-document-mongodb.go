@@ -7,6 +7,7 @@ import (
 	"log"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -23,6 +24,23 @@ type MongoClient struct {
 	Client *mongo.Client
 	Cancel context.CancelFunc
 	Config *MongoDB
+	*mongoConnection
+	context context.Context // context of the operations of a handle returned by WithContext, nil for the client
+}
+
+// mongoConnection private model for the connection state shared by a client and its handles, see WithContext
+type mongoConnection struct {
+	root               *MongoClient // client holding the current Client and Config
+	transactional      bool
+	transactionOptions *options.TransactionOptions
+	replicationLag     *replicationLagMonitor
+	rateLimiter        *rateLimiter
+	bulkhead           *bulkhead
+	redactor           *redactor
+	topology           *topologyNotifier
+	healthGate         *HealthGate
+	stopDiscovery      func() // stop the host refresh, nil when it is disabled
+	mu                 sync.RWMutex
 }
 
 var (
@@ -41,25 +59,37 @@ func newMongoDB(config *MongoDB) INoSQLDocument {
 
 	currentMongoSession := mongoClientSessionMapping[configAsString]
 	if currentMongoSession == nil {
-		currentMongoSession = &MongoClient{nil, nil, nil}
+		currentMongoSession = &MongoClient{mongoConnection: &mongoConnection{topology: newTopologyNotifier()}}
+		currentMongoSession.root = currentMongoSession
+		currentMongoSession.healthGate = newHealthGate(currentMongoSession.topology)
+
+		transactionOptions, err := getTransactionOptions(&config.Transaction)
+		if err != nil {
+			log.Fatalln("Unable to parse MongoDB transaction configuration: ", err)
+		}
 
 		// Establish MongoDB connection
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		client, err := mongo.Connect(ctx, options.Client().ApplyURI(getConnectionURI(config)))
+		client, err := connectMongoDB(ctx, config, currentMongoSession.topology)
 		if err != nil {
 			cancel()
 			log.Fatalln("Unable to connect to MongoDB: ", err)
 		}
 
-		// Check the connection status
-		if err = client.Ping(ctx, readpref.Primary()); err != nil {
-			cancel()
-			log.Fatalln("Unable to ping to MongoDB: ", err)
-		}
-
 		currentMongoSession.Client = client
 		currentMongoSession.Cancel = cancel
 		currentMongoSession.Config = config
+		currentMongoSession.transactional = isTransactional(ctx, config, client)
+		currentMongoSession.transactionOptions = transactionOptions
+		currentMongoSession.rateLimiter = newRateLimiter(&config.RateLimit)
+		currentMongoSession.bulkhead = newBulkhead(&config.Bulkhead)
+		currentMongoSession.redactor = newRedactor(&config.Redaction)
+		if config.ReplicationLag.MaxLag > 0 {
+			currentMongoSession.replicationLag = newReplicationLagMonitor(client, &config.ReplicationLag)
+		}
+		if config.Discovery.RefreshInterval > 0 {
+			currentMongoSession.stopDiscovery = currentMongoSession.startHostRefresh(config.Discovery.RefreshInterval)
+		}
 		mongoClientSessionMapping[configAsString] = currentMongoSession
 		log.Println("Connected to MongoDB")
 	}
@@ -67,6 +97,188 @@ func newMongoDB(config *MongoDB) INoSQLDocument {
 	return currentMongoSession
 }
 
+// connectMongoDB establish and check a new MongoDB connection based on config, its topology events are sent to
+// topology
+func connectMongoDB(ctx context.Context, config *MongoDB, topology *topologyNotifier) (*mongo.Client, error) {
+	clientOptions, err := getClientOptions(config)
+	if err != nil {
+		return nil, err
+	}
+	generation, discard := topology.apply(clientOptions)
+
+	client, err := mongo.Connect(ctx, clientOptions)
+	if err != nil {
+		discard()
+		return nil, err
+	}
+
+	// Check the connection status
+	if err = client.Ping(ctx, readpref.Primary()); err != nil {
+		discard()
+		client.Disconnect(ctx)
+		return nil, err
+	}
+	topology.activate(generation)
+	warmUp(client, &config.WarmUp)
+
+	return client, nil
+}
+
+// GetMongoDBClientOptions return the MongoDB client options of config, like to connect outside of the clients of New
+func GetMongoDBClientOptions(config *MongoDB) (*options.ClientOptions, error) {
+	return getClientOptions(config)
+}
+
+// getClientOptions return MongoDB client options based on config
+func getClientOptions(config *MongoDB) (*options.ClientOptions, error) {
+	uri := getConnectionURI(config)
+	if config.Discovery.enabled() {
+		hosts, err := discoverHosts(&config.Discovery)
+		if err != nil {
+			return nil, err
+		}
+		uri = getStandardURI(config, hosts)
+	} else if config.Compatibility == DocumentDBCompatibility || config.Compatibility == CosmosDBCompatibility {
+		// DocumentDB and Cosmos DB do not support SRV connection strings
+		uri = getStandardURI(config, config.Hosts)
+	}
+
+	clientOptions := options.Client().ApplyURI(uri)
+
+	if config.Proxy.enabled() {
+		dialer, err := getDialer(&config.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetDialer(dialer)
+	}
+
+	if err := applyCompatibility(config, clientOptions); err != nil {
+		return nil, err
+	}
+	applyWarmUp(&config.WarmUp, clientOptions)
+	applyAppName(config, clientOptions)
+	applyServerSelection(&config.ServerSelection, clientOptions)
+
+	return clientOptions, nil
+}
+
+// getClient return the current MongoDB client, it can be replaced when the connection is renewed
+func (m *MongoClient) getClient() *mongo.Client {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.root.Client
+}
+
+// getConfig return the current MongoDB config
+func (m *MongoClient) getConfig() *MongoDB {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.root.Config
+}
+
+// getTransaction return whether operations run inside transactions and the default transaction options
+func (m *MongoClient) getTransaction() (bool, *options.TransactionOptions) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.transactional, m.transactionOptions
+}
+
+// reconnect establish a new connection based on config and replace the current one,
+// the old connection is closed after in-flight operations complete
+func (m *MongoClient) reconnect(config *MongoDB) error {
+	transactionOptions, err := getTransactionOptions(&config.Transaction)
+	if err != nil {
+		return err
+	}
+
+	connectCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := connectMongoDB(connectCtx, config, m.topology)
+	if err != nil {
+		return err
+	}
+	transactional := isTransactional(connectCtx, config, client)
+	var replicationLag *replicationLagMonitor
+	if config.ReplicationLag.MaxLag > 0 {
+		replicationLag = newReplicationLagMonitor(client, &config.ReplicationLag)
+	}
+
+	m.mu.Lock()
+	oldClient, oldConfig, oldReplicationLag := m.root.Client, m.root.Config, m.replicationLag
+	m.replicationLag = replicationLag
+	m.root.Client = client
+	m.root.Config = config
+	m.transactional = transactional
+	m.transactionOptions = transactionOptions
+	m.rateLimiter = newRateLimiter(&config.RateLimit)
+	m.bulkhead = newBulkhead(&config.Bulkhead)
+	m.redactor = newRedactor(&config.Redaction)
+	// The host refresh is restarted when the discovery change, it keeps running when it is the one reconnecting
+	var oldStopDiscovery func()
+	if !reflect.DeepEqual(oldConfig.Discovery, config.Discovery) {
+		oldStopDiscovery, m.stopDiscovery = m.stopDiscovery, nil
+		if config.Discovery.RefreshInterval > 0 {
+			m.stopDiscovery = m.startHostRefresh(config.Discovery.RefreshInterval)
+		}
+	}
+	m.mu.Unlock()
+
+	// The monitor of the old connection would poll it until it is disconnected
+	if oldReplicationLag != nil {
+		oldReplicationLag.stop()
+	}
+	if oldStopDiscovery != nil {
+		oldStopDiscovery()
+	}
+
+	go func() {
+		disconnectCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		if err := oldClient.Disconnect(disconnectCtx); err != nil {
+			log.Println("Unable to disconnect old MongoDB connection: ", err)
+		}
+	}()
+
+	return nil
+}
+
+// Close stop the host refresh and the replication lag monitor then disconnect the client, New establish a new
+// connection for its config afterwards
+func (m *MongoClient) Close() error {
+	m.mu.Lock()
+	client, stopDiscovery, replicationLag := m.root.Client, m.stopDiscovery, m.replicationLag
+	m.stopDiscovery, m.replicationLag = nil, nil
+	m.mu.Unlock()
+
+	if stopDiscovery != nil {
+		stopDiscovery()
+	}
+	if replicationLag != nil {
+		replicationLag.stop()
+	}
+	for key, session := range mongoClientSessionMapping {
+		if session == m.root {
+			delete(mongoClientSessionMapping, key)
+		}
+	}
+
+	disconnectCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := client.Disconnect(disconnectCtx); err != nil {
+		log.Println("Unable to disconnect MongoDB connection: ", err)
+		return err
+	}
+
+	return nil
+}
+
 // getConnectionURL return mongo connection URI
 func getConnectionURI(config *MongoDB) (URI string) {
 	host := strings.Join(config.Hosts, ",")
@@ -79,103 +291,353 @@ func getConnectionURI(config *MongoDB) (URI string) {
 	return URI
 }
 
-// createSession return a new mongo session & transaction
-func (m *MongoClient) createSession() (session mongo.Session) {
-	session, err := m.Client.StartSession()
-	if err != nil {
-		log.Fatalln("Unable to init new session: ", err)
+// isTransactional return true when operations should run inside transactions based on config and deployment
+func isTransactional(ctx context.Context, config *MongoDB, client *mongo.Client) bool {
+	return !config.DisableTransaction && config.Compatibility != DocumentDBCompatibility && isTransactionSupported(ctx, client)
+}
+
+// isTransactionSupported check the deployment topology, transactions are only available on replica set and sharded cluster
+func isTransactionSupported(ctx context.Context, client *mongo.Client) bool {
+	var result bson.M
+	if err := client.Database("admin").RunCommand(ctx, bson.D{primitive.E{Key: "isMaster", Value: 1}}).Decode(&result); err != nil {
+		log.Println("Unable to detect MongoDB topology: ", err)
+		return false
+	}
+
+	if _, ok := result["setName"]; ok {
+		return true
+	}
+
+	return result["msg"] == "isdbgrid"
+}
+
+// WithContext return a handle of the client running its operations with c instead of the context set by SetContext,
+// like the context of a request carrying its deadline, comment or actor. The handle share the connection of the
+// client, so it is cheap to create per request. Its Client and Config are those of the client when it was created.
+func (m *MongoClient) WithContext(c context.Context) *MongoClient {
+	return &MongoClient{
+		Client:          m.getClient(),
+		Cancel:          m.Cancel,
+		Config:          m.getConfig(),
+		mongoConnection: m.mongoConnection,
+		context:         c,
+	}
+}
+
+// BindContext implement IContextBinder with WithContext
+func (m *MongoClient) BindContext(c context.Context) INoSQLDocument {
+	return m.WithContext(c)
+}
+
+// Context return the context of the operations, the context of the handle or the one set by SetContext
+func (m *MongoClient) Context() context.Context {
+	if m.context != nil {
+		return m.context
 	}
 
-	if err := session.StartTransaction(); err != nil {
-		log.Fatalln("Unable to start transaction: ", err)
+	return ctx
+}
+
+// execute run fn inside a new session & transaction, or directly when transactions are disabled
+// or the context of the operations already carries a session (see WithTransaction)
+func (m *MongoClient) execute(fn func(sc context.Context) error) error {
+	transactional, transactionOptions := m.getTransaction()
+	operationCtx := m.Context()
+	if !transactional || mongo.SessionFromContext(operationCtx) != nil {
+		return m.executeWithoutTransaction(fn)
+	}
+
+	return m.retry(func() error {
+		session, err := m.getClient().StartSession()
+		if err != nil {
+			log.Println("Unable to init new session: ", err)
+			return err
+		}
+		defer session.EndSession(operationCtx)
+
+		_, err = session.WithTransaction(operationCtx, func(sc mongo.SessionContext) (interface{}, error) {
+			return nil, fn(sc)
+		}, transactionOptions)
+
+		return err
+	})
+}
+
+// executeWithoutTransaction run fn directly with the context of the operations
+func (m *MongoClient) executeWithoutTransaction(fn func(sc context.Context) error) error {
+	return m.retry(func() error {
+		return fn(m.Context())
+	})
+}
+
+// retry run fn again when the service asks for it, see retryOnThrottling. Sensitive values are masked
+// in the message of the returned error.
+func (m *MongoClient) retry(fn func() error) error {
+	if m.getConfig().Compatibility == CosmosDBCompatibility {
+		return m.RedactError(retryOnThrottling(m.Context(), fn))
 	}
 
-	return session
+	return m.RedactError(fn())
 }
 
 // Create the list of document on collection
 func (m *MongoClient) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	if len(documents) == 0 {
+		return nil, &InvalidArgumentError{Argument: "documents", Reason: "cannot be empty"}
+	}
+	if err := checkDocumentSizes(documents, m.getConfig().MaxDocumentSize); err != nil {
+		log.Println("Unable to create document: ", err)
+		return nil, err
+	}
 
-	var result interface{}
-	session := m.createSession()
-	defer session.EndSession(ctx)
+	release, err := m.admit(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// the throttled inserts are retried, the documents get their _id first so the written ones are not created twice
+	var ids []interface{}
+	retried := m.getConfig().Compatibility == CosmosDBCompatibility
+	if retried {
+		if documents, ids, err = setDocumentIDs(documents); err != nil {
+			log.Println("Unable to create document: ", err)
+			return nil, err
+		}
+	}
 
-	if err := mongo.WithSession(ctx, session, func(sc mongo.SessionContext) (err error) {
+	timeout := m.getTimeout(OperationCreate, databaseName, collectionName)
+	pending := documents
+	var result interface{}
+	if err := m.execute(func(sc context.Context) (err error) {
+		sc, cancel := withTimeout(sc, timeout)
+		defer cancel()
 
-		collection := m.Client.Database(databaseName).Collection(collectionName)
-		result, err = collection.InsertMany(ctx, documents)
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+		result, err = collection.InsertMany(sc, pending)
 		if err != nil {
-			log.Println("Unable to create document: ", err)
+			log.Println("Unable to create document: ", m.RedactError(err))
+			if retried {
+				pending = getUnwrittenDocuments(pending, err)
+			}
 			return err
 		}
 
 		return nil
 	}); err != nil {
-		log.Println("Unable to execute mongo session: ", err)
+		log.Println("Unable to execute MongoDB operation: ", err)
 		return nil, err
 	}
+	if retried {
+		return &mongo.InsertManyResult{InsertedIDs: ids}, nil
+	}
 
 	return result, nil
 }
 
 // Read documents from collection based on filter
 func (m *MongoClient) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	return m.read(databaseName, collectionName, filter, limit, dataModel, nil)
+}
 
-	var results interface{}
-	session := m.createSession()
-	defer session.EndSession(ctx)
+// ReadInto read documents from collection based on filter like Read and decode them into results, a pointer to a slice.
+// The slice is emptied first and its capacity is reused.
+func (m *MongoClient) ReadInto(databaseName, collectionName string, filter interface{}, limit int64, results interface{}) error {
+	if err := checkResults(results); err != nil {
+		return err
+	}
 
-	if err := mongo.WithSession(ctx, session, func(sc mongo.SessionContext) (err error) {
+	return m.readInto(databaseName, collectionName, filter, limit, results, nil)
+}
+
+// read documents from collection based on filter, on primary or based on readPreference when it is provided
+func (m *MongoClient) read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type, readPreference *readpref.ReadPref) (interface{}, error) {
+	if err := checkDataModel(dataModel); err != nil {
+		return nil, err
+	}
+
+	results := reflect.New(reflect.SliceOf(dataModel)).Interface()
+	if err := m.readInto(databaseName, collectionName, filter, limit, results, readPreference); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// readInto read documents from collection based on filter into results, on primary or based on readPreference when it is provided
+func (m *MongoClient) readInto(databaseName, collectionName string, filter interface{}, limit int64, results interface{}, readPreference *readpref.ReadPref) error {
+	if err := checkLimit(limit); err != nil {
+		return err
+	}
+
+	release, err := m.admit(databaseName, collectionName)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	execute := m.execute
+	collectionOptions := options.Collection()
+	if monitor := m.getReplicationLag(); monitor != nil {
+		readPreference = monitor.readPreference(readPreference)
+	}
+	if readPreference != nil {
+		// Transactions always read from primary, so reads with a read preference run outside of them
+		execute = m.executeWithoutTransaction
+		collectionOptions.SetReadPreference(readPreference)
+	}
+
+	timeout := m.getTimeout(OperationRead, databaseName, collectionName)
+	if err := execute(func(sc context.Context) (err error) {
+		sc, cancel := withTimeout(sc, timeout)
+		defer cancel()
 
 		findOptions := options.Find()
 		findOptions.SetLimit(limit)
 		findOptions.SetSort(bson.D{primitive.E{Key: "_id", Value: 1}})
+		if timeout > 0 {
+			findOptions.SetMaxTime(timeout)
+		}
+		if comment := m.getComment(); comment != "" {
+			findOptions.SetComment(comment)
+		}
 
-		collection := m.Client.Database(databaseName).Collection(collectionName)
-		cur, err := collection.Find(ctx, filter, findOptions)
-		defer cur.Close(ctx)
+		collection := m.getClient().Database(databaseName).Collection(collectionName, collectionOptions)
+		cur, err := collection.Find(sc, filter, findOptions)
 		if err != nil {
-			log.Println("Unable to read document: ", err)
+			log.Println("Unable to read document: ", m.RedactError(err))
 			return err
 		}
+		defer cur.Close(sc)
+
+		return decodeCursorInto(sc, cur, results)
+	}); err != nil {
+		log.Println("Unable to execute MongoDB operation: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// decodeCursorInto decode all documents of the cursor into results, a pointer to a slice. The slice is sized
+// for the first batch unless it has a capacity and the documents are decoded in place, so results fitting
+// in one batch or in the capacity are decoded without growing the slice.
+func decodeCursorInto(sc context.Context, cur *mongo.Cursor, results interface{}) error {
+	slice := reflect.ValueOf(results).Elem()
+	if slice.Cap() == 0 {
+		slice.Set(reflect.MakeSlice(slice.Type(), 0, cur.RemainingBatchLength()))
+	} else {
+		// Documents are decoded over the elements, stale fields must not leak in the results
+		slice.Set(slice.Slice(0, slice.Cap()))
+		zero := reflect.Zero(slice.Type().Elem())
+		for i := 0; i < slice.Len(); i++ {
+			slice.Index(i).Set(zero)
+		}
+		slice.Set(slice.Slice(0, 0))
+	}
+
+	if err := cur.All(sc, results); err != nil {
+		log.Println("Unable to decode cursor: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// Aggregate run the aggregation pipeline on collection and decode the results based on dataModel
+func (m *MongoClient) Aggregate(databaseName, collectionName string, pipeline interface{}, dataModel reflect.Type) (interface{}, error) {
+	if err := checkDataModel(dataModel); err != nil {
+		return nil, err
+	}
+
+	results := reflect.New(reflect.SliceOf(dataModel)).Interface()
+	if err := m.AggregateInto(databaseName, collectionName, pipeline, results); err != nil {
+		return nil, err
+	}
 
-		// Decode cursor
-		dataModel := reflect.Zero(reflect.SliceOf(dataModel)).Type()
-		results = reflect.New(dataModel).Interface()
-		err = cur.All(ctx, results)
+	return results, nil
+}
+
+// AggregateInto run the aggregation pipeline on collection like Aggregate and decode the results into results,
+// a pointer to a slice. The slice is emptied first and its capacity is reused.
+func (m *MongoClient) AggregateInto(databaseName, collectionName string, pipeline interface{}, results interface{}) error {
+	if err := checkNotNil("pipeline", pipeline); err != nil {
+		return err
+	}
+	if err := checkResults(results); err != nil {
+		return err
+	}
+
+	release, err := m.admit(databaseName, collectionName)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err := checkPipelineCompatibility(m.getConfig(), pipeline); err != nil {
+		return err
+	}
+
+	timeout := m.getTimeout(OperationAggregate, databaseName, collectionName)
+	if err := m.execute(func(sc context.Context) (err error) {
+		sc, cancel := withTimeout(sc, timeout)
+		defer cancel()
+
+		aggregateOptions := options.Aggregate()
+		if timeout > 0 {
+			aggregateOptions.SetMaxTime(timeout)
+		}
+		if comment := m.getComment(); comment != "" {
+			aggregateOptions.SetComment(comment)
+		}
+
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+		cur, err := collection.Aggregate(sc, pipeline, aggregateOptions)
 		if err != nil {
-			log.Println("Unable to decode cursor: ", err)
+			log.Println("Unable to aggregate: ", m.RedactError(err))
 			return err
 		}
+		defer cur.Close(sc)
 
-		return nil
+		return decodeCursorInto(sc, cur, results)
 	}); err != nil {
-		log.Println("Unable to execute mongo session: ", err)
-		return nil, err
+		log.Println("Unable to execute MongoDB operation: ", err)
+		return err
 	}
 
-	return results, nil
+	return nil
 }
 
 // Update document with new value based on filter condition
 func (m *MongoClient) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	if err := checkNotNil("filter", filter); err != nil {
+		return nil, err
+	}
+	if err := checkNotNil("update", update); err != nil {
+		return nil, err
+	}
 
-	var result interface{}
-	session := m.createSession()
-	defer session.EndSession(ctx)
+	release, err := m.admit(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-	if err := mongo.WithSession(ctx, session, func(sc mongo.SessionContext) (err error) {
+	timeout := m.getTimeout(OperationUpdate, databaseName, collectionName)
+	var result interface{}
+	if err := m.execute(func(sc context.Context) (err error) {
+		sc, cancel := withTimeout(sc, timeout)
+		defer cancel()
 
-		collection := m.Client.Database(databaseName).Collection(collectionName)
-		result, err = collection.UpdateMany(ctx, filter, update)
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+		result, err = collection.UpdateMany(sc, withFilterComment(m.getComment(), filter), update)
 		if err != nil {
-			log.Println("Unable to update: ", err)
+			log.Println("Unable to update: ", m.RedactError(err))
 			return err
 		}
 
 		return nil
 	}); err != nil {
-		log.Println("Unable to execute mongo session: ", err)
+		log.Println("Unable to execute MongoDB operation: ", err)
 		return nil, err
 	}
 
@@ -184,25 +646,48 @@ func (m *MongoClient) Update(databaseName, collectionName string, filter, update
 
 // Delete document based on filter condition
 func (m *MongoClient) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	if err := checkNotNil("filter", filter); err != nil {
+		return nil, err
+	}
 
-	var result interface{}
-	session := m.createSession()
-	defer session.EndSession(ctx)
+	release, err := m.admit(databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-	if err := mongo.WithSession(ctx, session, func(sc mongo.SessionContext) (err error) {
+	timeout := m.getTimeout(OperationDelete, databaseName, collectionName)
+	var result interface{}
+	if err := m.execute(func(sc context.Context) (err error) {
+		sc, cancel := withTimeout(sc, timeout)
+		defer cancel()
 
-		collection := m.Client.Database(databaseName).Collection(collectionName)
-		result, err = collection.DeleteMany(ctx, filter)
+		collection := m.getClient().Database(databaseName).Collection(collectionName)
+		result, err = collection.DeleteMany(sc, withFilterComment(m.getComment(), filter))
 		if err != nil {
-			log.Println("Unable to delete: ", err)
+			log.Println("Unable to delete: ", m.RedactError(err))
 			return err
 		}
 
 		return nil
 	}); err != nil {
-		log.Println("Unable to execute mongo session: ", err)
+		log.Println("Unable to execute MongoDB operation: ", err)
 		return nil, err
 	}
 
 	return result, nil
 }
+
+// Capabilities implement ICapabilities, change streams need a replica set or a sharded cluster like transactions
+func (m *MongoClient) Capabilities() Capabilities {
+	transactional, _ := m.getTransaction()
+
+	return Capabilities{
+		Transactions:    transactional,
+		ChangeStreams:   transactional || isTransactionSupported(m.Context(), m.getClient()),
+		TextSearch:      m.getConfig().Compatibility != CosmosDBCompatibility,
+		Aggregation:     true,
+		Pagination:      true,
+		MaxDocumentSize: maxBSONDocumentSize,
+	}
+}
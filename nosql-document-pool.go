@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// defaultBufferSize is the capacity of the new buffers of bufferPool
+	defaultBufferSize = 1024
+	// maxPooledBufferSize is the capacity above which buffers are left to the garbage collector, so one
+	// large document does not hold memory forever
+	maxPooledBufferSize = 64 * 1024
+	// maxPooledValues is the capacity above which value slices are left to the garbage collector
+	maxPooledValues = 1024
+)
+
+var (
+	// bufferPool reuse the buffers of the documents marshaled on the hot paths, like cache keys and entries
+	bufferPool = sync.Pool{New: func() interface{} {
+		buffer := make([]byte, 0, defaultBufferSize)
+		return &buffer
+	}}
+	// valuesPool reuse the intermediate slices of values, like the IDs of the documents to invalidate
+	valuesPool = sync.Pool{New: func() interface{} {
+		values := make([]interface{}, 0, 16)
+		return &values
+	}}
+)
+
+// getBuffer return an empty buffer of bufferPool, give it back with putBuffer once its content has been copied
+func getBuffer() *[]byte {
+	buffer := bufferPool.Get().(*[]byte)
+	*buffer = (*buffer)[:0]
+
+	return buffer
+}
+
+// putBuffer give the buffer back to bufferPool, the buffer must not be used anymore
+func putBuffer(buffer *[]byte) {
+	if cap(*buffer) > maxPooledBufferSize {
+		return
+	}
+
+	bufferPool.Put(buffer)
+}
+
+// getValues return an empty slice of valuesPool, give it back with putValues once it is not used anymore
+func getValues() *[]interface{} {
+	values := valuesPool.Get().(*[]interface{})
+	*values = (*values)[:0]
+
+	return values
+}
+
+// putValues clear the slice and give it back to valuesPool, so the pool does not keep the values alive
+func putValues(values *[]interface{}) {
+	if cap(*values) > maxPooledValues {
+		return
+	}
+
+	for i := range *values {
+		(*values)[i] = nil
+	}
+	*values = (*values)[:0]
+	valuesPool.Put(values)
+}
+
+// marshalExtJSON return value as Extended JSON like bson.MarshalExtJSON, marshaled in a pooled buffer
+func marshalExtJSON(value interface{}, canonical bool) (string, error) {
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+
+	data, err := bson.MarshalExtJSONAppend(*buffer, value, canonical, false)
+	if err != nil {
+		return "", err
+	}
+	// The buffer grows in the pool when the document did not fit
+	*buffer = data[:0]
+
+	return string(data), nil
+}
@@ -0,0 +1,573 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// HashField replace the values of the field with their salted SHA-256 token, the values can not be read back
+	HashField = "hash"
+	// TokenizeField replace the values of the field with their token and keep the values in the token vault
+	TokenizeField = "tokenize"
+	// defaultTokenVault is the default collection of the token vault
+	defaultTokenVault = "tokens"
+)
+
+var (
+	// ErrProtectedPipeline is returned when an update pipeline is applied to a collection with protected fields
+	ErrProtectedPipeline = errors.New("Update pipelines are not supported on protected collections")
+)
+
+// ProtectedField model for a field of the collection model hashed or tokenized on write
+type ProtectedField struct {
+	Database   string
+	Collection string
+	Field      string // dotted path, like email or contact.phone, arrays protect each of their values
+	Mode       string // hash (default) or tokenize
+}
+
+// tokenVaultEntry private model for a tokenized value
+type tokenVaultEntry struct {
+	Token string      `bson:"_id"`
+	Value interface{} `bson:"value"`
+}
+
+// ProtectedDatabase decorate INoSQLDocument with the hashing and tokenization of fields. Create and Update write the
+// deterministic tokens of the values instead of the values, and filters on the fields are rewritten with the tokens of
+// their values, so documents stay searchable by equality like Read(db, "users", bson.M{"email": email}, ...).
+// Read replace the tokens of tokenized fields with the values of the vault, the values of hashed fields are never stored.
+type ProtectedDatabase struct {
+	db     INoSQLDocument
+	salt   []byte
+	vault  string
+	fields []ProtectedField
+}
+
+// NewProtectedDatabase init new protected database, tokens are the SHA-256 of salt and the BSON values.
+// Tokenized values are written to vaultCollection of the database before the documents, default tokens.
+func NewProtectedDatabase(db INoSQLDocument, salt []byte, vaultCollection string, fields ...ProtectedField) (*ProtectedDatabase, error) {
+	var errs *multierror.Error
+	if len(salt) == 0 {
+		errs = multierror.Append(errs, errors.New("protection: salt is required"))
+	}
+	for i := range fields {
+		field := &fields[i]
+		if field.Database == "" || field.Collection == "" || field.Field == "" {
+			errs = multierror.Append(errs, errors.New("protection: database, collection and field are required"))
+		}
+		switch field.Mode {
+		case "":
+			field.Mode = HashField
+		case HashField, TokenizeField:
+		default:
+			errs = multierror.Append(errs, fmt.Errorf("protection: unknown mode %q", field.Mode))
+		}
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+
+	if vaultCollection == "" {
+		vaultCollection = defaultTokenVault
+	}
+
+	return &ProtectedDatabase{db: db, salt: salt, vault: vaultCollection, fields: fields}, nil
+}
+
+// Token return the token stored for value in field, to search protected fields outside of this decorator like in aggregation pipelines
+func (p *ProtectedDatabase) Token(databaseName, collectionName, field string, value interface{}) (string, error) {
+	for _, protectedField := range p.getFields(databaseName, collectionName) {
+		if protectedField.Field == field {
+			return p.getToken(value, protectedField.Mode, nil)
+		}
+	}
+
+	return "", fmt.Errorf("Field %q of %s.%s is not protected", field, databaseName, collectionName)
+}
+
+// Create the documents with the tokens of their protected fields
+func (p *ProtectedDatabase) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	fields := p.getFields(databaseName, collectionName)
+	if len(fields) == 0 {
+		return p.db.Create(databaseName, collectionName, documents)
+	}
+
+	vault := map[string]interface{}{}
+	protected := make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		value, err := p.protectDocument(document, fields, vault)
+		if err != nil {
+			return nil, err
+		}
+		protected = append(protected, value)
+	}
+
+	if err := p.store(databaseName, vault); err != nil {
+		return nil, err
+	}
+
+	return p.db.Create(databaseName, collectionName, protected)
+}
+
+// Read documents based on filter and replace the tokens of their tokenized fields with the values
+func (p *ProtectedDatabase) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	fields := p.getFields(databaseName, collectionName)
+	if len(fields) == 0 {
+		return p.db.Read(databaseName, collectionName, filter, limit, dataModel)
+	}
+
+	filter, err := p.protectFilter(filter, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := p.db.Read(databaseName, collectionName, filter, limit, dataModel)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.detokenize(databaseName, results, fields); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Update the documents matching filter, the values of protected fields set by $set, $setOnInsert, $push, $addToSet
+// or the replacement are replaced with their tokens
+func (p *ProtectedDatabase) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	fields := p.getFields(databaseName, collectionName)
+	if len(fields) == 0 {
+		return p.db.Update(databaseName, collectionName, filter, update)
+	}
+
+	switch update.(type) {
+	case mongo.Pipeline, bson.A, []bson.D, []bson.M, []interface{}:
+		return nil, ErrProtectedPipeline
+	}
+
+	filter, err := p.protectFilter(filter, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	vault := map[string]interface{}{}
+	update, err = p.protectUpdate(update, fields, vault)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.store(databaseName, vault); err != nil {
+		return nil, err
+	}
+
+	return p.db.Update(databaseName, collectionName, filter, update)
+}
+
+// Delete the documents matching filter
+func (p *ProtectedDatabase) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	fields := p.getFields(databaseName, collectionName)
+	if len(fields) == 0 {
+		return p.db.Delete(databaseName, collectionName, filter)
+	}
+
+	filter, err := p.protectFilter(filter, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.db.Delete(databaseName, collectionName, filter)
+}
+
+// getFields return the protected fields of the collection
+func (p *ProtectedDatabase) getFields(databaseName, collectionName string) []ProtectedField {
+	var fields []ProtectedField
+	for _, field := range p.fields {
+		if field.Database == databaseName && field.Collection == collectionName {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// getToken return the hex SHA-256 of the salt and the BSON value, integers hash the same whatever their size.
+// The values of tokenized fields are added to vault when it is not nil.
+func (p *ProtectedDatabase) getToken(value interface{}, mode string, vault map[string]interface{}) (string, error) {
+	kind, data, err := bson.MarshalValue(value)
+	if err != nil {
+		return "", err
+	}
+	if kind == bsontype.Int32 {
+		kind, data, _ = bson.MarshalValue(int64(bson.RawValue{Type: kind, Value: data}.Int32()))
+	}
+
+	hash := sha256.New()
+	hash.Write(p.salt)
+	hash.Write([]byte{byte(kind)})
+	hash.Write(data)
+	token := hex.EncodeToString(hash.Sum(nil))
+
+	if mode == TokenizeField && vault != nil {
+		vault[token] = value
+	}
+
+	return token, nil
+}
+
+// protectValue return value with the tokens of its values, arrays are protected element by element and null is kept
+func (p *ProtectedDatabase) protectValue(value interface{}, mode string, vault map[string]interface{}) (interface{}, error) {
+	return mapPath(value, nil, func(value interface{}) (interface{}, error) {
+		return p.getToken(value, mode, vault)
+	})
+}
+
+// protectDocument return document as bson.D with the tokens of the protected fields
+func (p *ProtectedDatabase) protectDocument(document interface{}, fields []ProtectedField, vault map[string]interface{}) (bson.D, error) {
+	value, err := toDocument(document)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range fields {
+		mode := field.Mode
+		if _, err := mapPath(value, strings.Split(field.Field, "."), func(value interface{}) (interface{}, error) {
+			return p.getToken(value, mode, vault)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return value, nil
+}
+
+// protectFilter return filter with the tokens of the values compared to protected fields by equality, $eq, $ne, $in or $nin,
+// through $and, $or and $nor. Other operators can not match tokens and are refused.
+func (p *ProtectedDatabase) protectFilter(filter interface{}, fields []ProtectedField) (interface{}, error) {
+	if filter == nil {
+		return nil, nil
+	}
+
+	document, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.protectClauses(document, fields); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// protectClauses replace the values compared to protected fields by the clauses of filter
+func (p *ProtectedDatabase) protectClauses(filter bson.D, fields []ProtectedField) error {
+	for i, element := range filter {
+		switch element.Key {
+		case "$and", "$or", "$nor":
+			clauses, _ := element.Value.(bson.A)
+			for _, clause := range clauses {
+				if document, ok := clause.(bson.D); ok {
+					if err := p.protectClauses(document, fields); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		}
+
+		for _, field := range fields {
+			if field.Field != element.Key {
+				continue
+			}
+
+			value, err := p.protectCondition(element.Value, field)
+			if err != nil {
+				return err
+			}
+			filter[i].Value = value
+		}
+	}
+
+	return nil
+}
+
+// protectCondition return the condition on field with the tokens of its values
+func (p *ProtectedDatabase) protectCondition(condition interface{}, field ProtectedField) (interface{}, error) {
+	operators, ok := condition.(bson.D)
+	if !ok || len(operators) == 0 || !strings.HasPrefix(operators[0].Key, "$") {
+		return p.protectValue(condition, field.Mode, nil)
+	}
+
+	for i, operator := range operators {
+		switch operator.Key {
+		case "$eq", "$ne", "$in", "$nin":
+			value, err := p.protectValue(operator.Value, field.Mode, nil)
+			if err != nil {
+				return nil, err
+			}
+			operators[i].Value = value
+		case "$exists", "$type":
+		default:
+			return nil, fmt.Errorf("Unsupported operator %s on protected field %q", operator.Key, field.Field)
+		}
+	}
+
+	return operators, nil
+}
+
+// protectUpdate return update with the tokens of the values written to protected fields
+func (p *ProtectedDatabase) protectUpdate(update interface{}, fields []ProtectedField, vault map[string]interface{}) (interface{}, error) {
+	document, err := toDocument(update)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(document) == 0 || !strings.HasPrefix(document[0].Key, "$") {
+		return p.protectDocument(document, fields, vault)
+	}
+
+	for _, operator := range document {
+		values, _ := operator.Value.(bson.D)
+		for i, element := range values {
+			for _, field := range fields {
+				segments, ok := getRelativePath(element.Key, field.Field)
+				if !ok {
+					continue
+				}
+
+				mode := field.Mode
+				protect := func(value interface{}) (interface{}, error) {
+					return p.getToken(value, mode, vault)
+				}
+
+				switch operator.Key {
+				case "$set", "$setOnInsert":
+					value, err := mapPath(values[i].Value, segments, protect)
+					if err != nil {
+						return nil, err
+					}
+					values[i].Value = value
+				case "$push", "$addToSet":
+					if modifiers, ok := values[i].Value.(bson.D); ok && len(modifiers) > 0 && modifiers[0].Key == "$each" {
+						if _, err := mapPath(modifiers[0].Value, segments, protect); err != nil {
+							return nil, err
+						}
+						continue
+					}
+					value, err := mapPath(values[i].Value, segments, protect)
+					if err != nil {
+						return nil, err
+					}
+					values[i].Value = value
+				case "$pull":
+					if len(segments) > 0 {
+						return nil, fmt.Errorf("Unsupported operator %s on protected field %q", operator.Key, field.Field)
+					}
+					value, err := p.protectCondition(values[i].Value, field)
+					if err != nil {
+						return nil, err
+					}
+					values[i].Value = value
+				case "$unset":
+				default:
+					return nil, fmt.Errorf("Unsupported operator %s on protected field %q", operator.Key, field.Field)
+				}
+			}
+		}
+	}
+
+	return document, nil
+}
+
+// store write the tokenized values missing from the vault of the database
+func (p *ProtectedDatabase) store(databaseName string, vault map[string]interface{}) error {
+	if len(vault) == 0 {
+		return nil
+	}
+
+	tokens := make(bson.A, 0, len(vault))
+	for token := range vault {
+		tokens = append(tokens, token)
+	}
+
+	results, err := p.db.Read(databaseName, p.vault, bson.M{"_id": bson.M{"$in": tokens}}, 0, reflect.TypeOf(tokenVaultEntry{}))
+	if err != nil {
+		return err
+	}
+	if entries, ok := results.(*[]tokenVaultEntry); ok {
+		for _, entry := range *entries {
+			delete(vault, entry.Token)
+		}
+	}
+
+	// One entry per write, so the entries written at once by another client do not stop the others
+	for token, value := range vault {
+		if _, err := p.db.Create(databaseName, p.vault, []interface{}{tokenVaultEntry{Token: token, Value: value}}); err != nil && !isDuplicateKeyOnly(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// detokenize replace the tokens of the tokenized fields of results, a pointer to a slice of documents, with the values of the vault
+func (p *ProtectedDatabase) detokenize(databaseName string, results interface{}, fields []ProtectedField) error {
+	var tokenized []ProtectedField
+	for _, field := range fields {
+		if field.Mode == TokenizeField {
+			tokenized = append(tokenized, field)
+		}
+	}
+
+	slice := reflect.ValueOf(results)
+	if len(tokenized) == 0 || slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return nil
+	}
+	slice = slice.Elem()
+
+	documents := make([]bson.D, slice.Len())
+	tokens := bson.A{}
+	collect := func(value interface{}) (interface{}, error) {
+		if token, ok := value.(string); ok {
+			tokens = append(tokens, token)
+		}
+		return value, nil
+	}
+	for i := range documents {
+		document, err := toDocument(slice.Index(i).Interface())
+		if err != nil {
+			return err
+		}
+		for _, field := range tokenized {
+			mapPath(document, strings.Split(field.Field, "."), collect)
+		}
+		documents[i] = document
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	entries, err := p.db.Read(databaseName, p.vault, bson.M{"_id": bson.M{"$in": tokens}}, 0, reflect.TypeOf(tokenVaultEntry{}))
+	if err != nil {
+		return err
+	}
+	values := map[string]interface{}{}
+	if entries, ok := entries.(*[]tokenVaultEntry); ok {
+		for _, entry := range *entries {
+			values[entry.Token] = entry.Value
+		}
+	}
+
+	replace := func(value interface{}) (interface{}, error) {
+		if token, ok := value.(string); ok {
+			if original, ok := values[token]; ok {
+				return original, nil
+			}
+		}
+		return value, nil
+	}
+	for i, document := range documents {
+		for _, field := range tokenized {
+			mapPath(document, strings.Split(field.Field, "."), replace)
+		}
+
+		raw, err := bson.Marshal(document)
+		if err != nil {
+			return err
+		}
+		element := reflect.New(slice.Index(i).Type())
+		if err := bson.Unmarshal(raw, element.Interface()); err != nil {
+			return err
+		}
+		slice.Index(i).Set(element.Elem())
+	}
+
+	return nil
+}
+
+// toDocument return a bson.D copy of document, nested documents are bson.D and arrays bson.A
+func toDocument(document interface{}) (bson.D, error) {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var value bson.D
+	if err := bson.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// mapPath apply fn to the values at the dotted path segments of value, in place for documents and arrays, and return
+// the new value. Arrays apply fn to each of their elements, null and missing fields are skipped.
+func mapPath(value interface{}, segments []string, fn func(interface{}) (interface{}, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case bson.A:
+		for i := range v {
+			element, err := mapPath(v[i], segments, fn)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = element
+		}
+		return v, nil
+	}
+
+	if len(segments) == 0 {
+		return fn(value)
+	}
+
+	if document, ok := value.(bson.D); ok {
+		for i := range document {
+			if document[i].Key != segments[0] {
+				continue
+			}
+
+			element, err := mapPath(document[i].Value, segments[1:], fn)
+			if err != nil {
+				return nil, err
+			}
+			document[i].Value = element
+		}
+	}
+
+	return value, nil
+}
+
+// getRelativePath return the segments of field below the update key, array indexes and positional operators of key
+// are skipped, like items.$[item].sku for the field items.sku. It returns false when key does not set field.
+func getRelativePath(key, field string) ([]string, bool) {
+	var segments []string
+	for _, segment := range strings.Split(key, ".") {
+		if _, err := strconv.Atoi(segment); err == nil || strings.HasPrefix(segment, "$") {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+
+	path := strings.Join(segments, ".")
+	switch {
+	case path == field:
+		return nil, true
+	case strings.HasPrefix(field, path+"."):
+		return strings.Split(strings.TrimPrefix(field, path+"."), "."), true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/golang-common-packages/hash"
+)
+
+const (
+	// queryCacheKeyPrefix is the prefix of the keys written by QueryCache
+	queryCacheKeyPrefix = "storage:query:"
+)
+
+// QueryCache decorate INoSQLDocument with a cache of Read and Aggregate results keyed by the hash of the normalized query,
+// stored in a key-value backend (REDIS, BIGCACHE or CUSTOM). Each write through QueryCache invalidate all the cached
+// queries of its collection, writes from other clients are only seen when the entries expire.
+type QueryCache struct {
+	db     INoSQLDocument
+	cache  INoSQLKeyValue
+	ttl    time.Duration
+	hasher hash.IHash
+	*queryCacheStats
+}
+
+// queryCacheStats private model for the counters of a query cache, shared by its transaction handles
+type queryCacheStats struct {
+	hits   uint64
+	misses uint64
+	writes uint64
+}
+
+// NewQueryCache init new query cache, cached results expire after ttl
+func NewQueryCache(db INoSQLDocument, cache INoSQLKeyValue, ttl time.Duration) *QueryCache {
+	return &QueryCache{db: db, cache: cache, ttl: ttl, hasher: &hash.Client{}, queryCacheStats: &queryCacheStats{}}
+}
+
+// Stats return the cache metrics
+func (q *QueryCache) Stats() QueryCacheStats {
+	return QueryCacheStats{
+		Hits:          atomic.LoadUint64(&q.hits),
+		Misses:        atomic.LoadUint64(&q.misses),
+		Invalidations: atomic.LoadUint64(&q.writes),
+	}
+}
+
+// Create the documents and invalidate the cached queries of the collection
+func (q *QueryCache) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	defer q.invalidate(databaseName, collectionName)
+	return q.db.Create(databaseName, collectionName, documents)
+}
+
+// Read documents from the cache or from the database
+func (q *QueryCache) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	return q.cached(databaseName, collectionName, bson.D{
+		primitive.E{Key: "filter", Value: normalizeQuery(filter)},
+		primitive.E{Key: "limit", Value: limit},
+	}, dataModel, func() (interface{}, error) {
+		return q.db.Read(databaseName, collectionName, filter, limit, dataModel)
+	})
+}
+
+// Aggregate results from the cache or from the database, see IAggregate
+func (q *QueryCache) Aggregate(databaseName, collectionName string, pipeline interface{}, dataModel reflect.Type) (interface{}, error) {
+	aggregator, ok := q.db.(IAggregate)
+	if !ok {
+		return nil, errors.New("Database does not support aggregation")
+	}
+
+	return q.cached(databaseName, collectionName, bson.D{
+		primitive.E{Key: "pipeline", Value: normalizeQuery(pipeline)},
+	}, dataModel, func() (interface{}, error) {
+		return aggregator.Aggregate(databaseName, collectionName, pipeline, dataModel)
+	})
+}
+
+// Update the documents and invalidate the cached queries of the collection
+func (q *QueryCache) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	defer q.invalidate(databaseName, collectionName)
+	return q.db.Update(databaseName, collectionName, filter, update)
+}
+
+// Delete the documents and invalidate the cached queries of the collection
+func (q *QueryCache) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	defer q.invalidate(databaseName, collectionName)
+	return q.db.Delete(databaseName, collectionName, filter)
+}
+
+// cached return the cached results of query or the results of read, cached for the next calls
+func (q *QueryCache) cached(databaseName, collectionName string, query bson.D, dataModel reflect.Type, read func() (interface{}, error)) (interface{}, error) {
+	key, err := q.getKey(databaseName, collectionName, query)
+	if err != nil {
+		atomic.AddUint64(&q.misses, 1)
+		return read()
+	}
+
+	if value, err := q.cache.Get(key); err == nil {
+		if results, ok := decodeCacheEntry(value, dataModel); ok {
+			atomic.AddUint64(&q.hits, 1)
+			return results, nil
+		}
+	}
+	atomic.AddUint64(&q.misses, 1)
+
+	results, err := read()
+	if err != nil {
+		return nil, err
+	}
+
+	if data, ok := encodeCacheEntry(results); ok {
+		if err := q.cache.Set(key, data, q.ttl); err != nil {
+			log.Println("Unable to set cache entry: ", err)
+		}
+	}
+
+	return results, nil
+}
+
+// getKey return the cache key of query, it includes the version of the collection so writes invalidate it
+func (q *QueryCache) getKey(databaseName, collectionName string, query bson.D) (string, error) {
+	data, err := marshalExtJSON(query, true)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%s.%s:%s:%s", queryCacheKeyPrefix, databaseName, collectionName,
+		q.getVersion(databaseName, collectionName), q.hasher.SHA1(data)), nil
+}
+
+// getVersion return the version of the collection, it changes on each write
+func (q *QueryCache) getVersion(databaseName, collectionName string) string {
+	value, err := q.cache.Get(getVersionKey(databaseName, collectionName))
+	if err != nil {
+		return "0"
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+
+	return "0"
+}
+
+// invalidate change the version of the collection, the previous entries expire by TTL
+func (q *QueryCache) invalidate(databaseName, collectionName string) {
+	atomic.AddUint64(&q.writes, 1)
+
+	// The version outlive the entries it invalidates
+	version := strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := q.cache.Set(getVersionKey(databaseName, collectionName), version, q.ttl*2); err != nil {
+		log.Println("Unable to invalidate query cache: ", err)
+	}
+}
+
+// getVersionKey return the key of the version of the collection
+func getVersionKey(databaseName, collectionName string) string {
+	return fmt.Sprintf("%sversion:%s.%s", queryCacheKeyPrefix, databaseName, collectionName)
+}
+
+// normalizeQuery return query with map keys sorted so equal queries have the same hash,
+// the order of ordered documents (bson.D) and arrays is kept
+func normalizeQuery(query interface{}) interface{} {
+	switch q := query.(type) {
+	case bson.M:
+		return normalizeMap(q)
+	case map[string]interface{}:
+		return normalizeMap(q)
+	case bson.D:
+		normalized := make(bson.D, 0, len(q))
+		for _, element := range q {
+			normalized = append(normalized, primitive.E{Key: element.Key, Value: normalizeQuery(element.Value)})
+		}
+		return normalized
+	case bson.A:
+		normalized := make(bson.A, 0, len(q))
+		for _, value := range q {
+			normalized = append(normalized, normalizeQuery(value))
+		}
+		return normalized
+	case []interface{}:
+		return normalizeQuery(bson.A(q))
+	case mongo.Pipeline:
+		return normalizeQuery([]bson.D(q))
+	case []bson.D:
+		normalized := make(bson.A, 0, len(q))
+		for _, value := range q {
+			normalized = append(normalized, normalizeQuery(value))
+		}
+		return normalized
+	case []bson.M:
+		normalized := make(bson.A, 0, len(q))
+		for _, value := range q {
+			normalized = append(normalized, normalizeQuery(value))
+		}
+		return normalized
+	}
+
+	return query
+}
+
+// normalizeMap return the map as a bson.D sorted by key
+func normalizeMap(m map[string]interface{}) bson.D {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	normalized := make(bson.D, 0, len(keys))
+	for _, key := range keys {
+		normalized = append(normalized, primitive.E{Key: key, Value: normalizeQuery(m[key])})
+	}
+
+	return normalized
+}
+
+// Capabilities implement ICapabilities
+func (q *QueryCache) Capabilities() Capabilities {
+	return getDecoratedCapabilities(q.db)
+}
+
+// RunInTransaction implement ITransactional with the transactions of the database, tx is a *QueryCache sharing the
+// cache and the stats of q
+func (q *QueryCache) RunInTransaction(fn func(tx interface{}) error) error {
+	return WithTransaction(q.db, func(tx interface{}) error {
+		db, ok := tx.(INoSQLDocument)
+		if !ok {
+			return ErrTransactionsUnsupported
+		}
+
+		return fn(&QueryCache{db: db, cache: q.cache, ttl: q.ttl, hasher: q.hasher, queryCacheStats: q.queryCacheStats})
+	})
+}
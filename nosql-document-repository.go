@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrDocumentNotFound is returned when no document match the ID provided
+	ErrDocumentNotFound = errors.New("Document not found")
+)
+
+// ElemMatch is the value of a GetByFields array field matching documents with at least one element
+// matching all the conditions, like ElemMatch{"product": "book", "quantity": bson.M{"$gte": 2}}
+type ElemMatch map[string]interface{}
+
+// Repository provide typed CRUD actions for the documents of one collection
+type Repository[T any] struct {
+	db             INoSQLDocument
+	databaseName   string
+	collectionName string
+	dataModel      reflect.Type
+	fields         map[string]schemaField // bson paths of the model, nil when the model is not a struct
+}
+
+// NewRepository init new repository of T documents bound to databaseName and collectionName
+func NewRepository[T any](db INoSQLDocument, databaseName, collectionName string) *Repository[T] {
+	var model T
+
+	repository := &Repository[T]{
+		db:             db,
+		databaseName:   databaseName,
+		collectionName: collectionName,
+		dataModel:      reflect.TypeOf(model),
+	}
+
+	modelType := repository.dataModel
+	for modelType != nil && modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	if modelType != nil && modelType.Kind() == reflect.Struct {
+		repository.fields = map[string]schemaField{}
+		getSchemaFields(modelType, "", repository.fields)
+	}
+
+	return repository
+}
+
+// IDFilter return the filter on _id, id is converted to ObjectID when it is a valid hex string
+func IDFilter(id string) bson.M {
+	if objectID, err := primitive.ObjectIDFromHex(id); err == nil {
+		return bson.M{"_id": objectID}
+	}
+
+	return bson.M{"_id": id}
+}
+
+// GetDocumentID return the _id value of document
+func GetDocumentID(document interface{}) (interface{}, error) {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := bson.Raw(raw).LookupErr("_id")
+	if err != nil {
+		return nil, err
+	}
+
+	var id interface{}
+	if err := value.Unmarshal(&id); err != nil {
+		return nil, err
+	}
+
+	return id, nil
+}
+
+// List return documents of the collection, limit 0 means no limit
+func (r *Repository[T]) List(limit int64) ([]T, error) {
+	return r.FindWhere(bson.M{}, limit)
+}
+
+// GetByID return the document based on its ID
+func (r *Repository[T]) GetByID(id string) (*T, error) {
+	documents, err := r.FindWhere(IDFilter(id), 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(documents) == 0 {
+		return nil, ErrDocumentNotFound
+	}
+
+	return &documents[0], nil
+}
+
+// GetByIDs return the documents of ids in one query, in the order of ids, with the ids which are not found
+func (r *Repository[T]) GetByIDs(ids []string) ([]T, map[string]bool, error) {
+	values := make(bson.A, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, IDFilter(id)["_id"])
+	}
+
+	found, err := r.FindWhere(bson.M{"_id": bson.M{"$in": values}}, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byID := make(map[string]int, len(found))
+	for i, document := range found {
+		id, err := GetDocumentID(document)
+		if err != nil {
+			return nil, nil, err
+		}
+		byID[getIDString(id)] = i
+	}
+
+	documents := make([]T, 0, len(found))
+	missing := map[string]bool{}
+	for _, id := range ids {
+		if i, ok := byID[id]; ok {
+			documents = append(documents, found[i])
+		} else {
+			missing[id] = true
+		}
+	}
+
+	return documents, missing, nil
+}
+
+// getIDString return id as string, ObjectID as hex
+func getIDString(id interface{}) string {
+	if objectID, ok := id.(primitive.ObjectID); ok {
+		return objectID.Hex()
+	}
+
+	return fmt.Sprint(id)
+}
+
+// FindWhere return documents based on filter, limit 0 means no limit
+func (r *Repository[T]) FindWhere(filter interface{}, limit int64) ([]T, error) {
+	if reader, ok := r.db.(IReadInto); ok {
+		var documents []T
+		if err := reader.ReadInto(r.databaseName, r.collectionName, filter, limit, &documents); err != nil {
+			return nil, err
+		}
+		return documents, nil
+	}
+
+	results, err := r.db.Read(r.databaseName, r.collectionName, filter, limit, r.dataModel)
+	if err != nil {
+		return nil, err
+	}
+
+	documents, ok := results.(*[]T)
+	if !ok {
+		return nil, errors.New("Unable to map results to the repository model")
+	}
+
+	return *documents, nil
+}
+
+// Create insert the documents provided
+func (r *Repository[T]) Create(documents ...T) (interface{}, error) {
+	values := make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		values = append(values, document)
+	}
+
+	return r.db.Create(r.databaseName, r.collectionName, values)
+}
+
+// Update set the fields of document on the document based on its ID
+func (r *Repository[T]) Update(id string, document T) (interface{}, error) {
+	return r.db.Update(r.databaseName, r.collectionName, IDFilter(id), bson.M{"$set": document})
+}
+
+// Delete remove the document based on its ID
+func (r *Repository[T]) Delete(id string) (interface{}, error) {
+	return r.db.Delete(r.databaseName, r.collectionName, IDFilter(id))
+}
+
+// UpdateWhere apply update to the documents matching filter and return the number of matched and modified documents.
+// The filter is the precondition of the write, like bson.M{"_id": id, "status": "packed"} with
+// bson.M{"$set": bson.M{"status": "shipped"}}, a document not matching it anymore is not modified.
+func (r *Repository[T]) UpdateWhere(filter, update interface{}) (int64, int64, error) {
+	result, err := r.db.Update(r.databaseName, r.collectionName, filter, update)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	updateResult, ok := result.(*mongo.UpdateResult)
+	if !ok {
+		return 0, 0, errors.New("Unable to map result to UpdateResult model")
+	}
+
+	return updateResult.MatchedCount, updateResult.ModifiedCount, nil
+}
+
+// DeleteWhere remove the documents matching filter and return the number of deleted documents
+func (r *Repository[T]) DeleteWhere(filter interface{}) (int64, error) {
+	result, err := r.db.Delete(r.databaseName, r.collectionName, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	deleteResult, ok := result.(*mongo.DeleteResult)
+	if !ok {
+		return 0, errors.New("Unable to map result to DeleteResult model")
+	}
+
+	return deleteResult.DeletedCount, nil
+}
+
+// GetByFields return documents whose fields equal the values provided, limit 0 means no limit.
+// Fields are bson paths of the model, like "address.city", ElemMatch values match array elements.
+// Values are compared with $eq so user input can not inject operators, only ElemMatch conditions can hold operators.
+func (r *Repository[T]) GetByFields(fields map[string]interface{}, limit int64) ([]T, error) {
+	filter := bson.M{}
+	for path, value := range fields {
+		if err := checkFieldPath(path); err != nil {
+			return nil, err
+		}
+		if err := r.ValidatePath(path); err != nil {
+			return nil, err
+		}
+
+		if conditions, ok := value.(ElemMatch); ok {
+			for elementPath := range conditions {
+				if err := r.ValidatePath(path + "." + elementPath); err != nil {
+					return nil, err
+				}
+			}
+			value = bson.M{"$elemMatch": bson.M(conditions)}
+		} else {
+			value = bson.M{"$eq": value}
+		}
+		filter[path] = value
+	}
+
+	return r.FindWhere(filter, limit)
+}
+
+// UpdateFields set the fields provided on the document based on its ID, fields are bson paths of the model
+// like "address.city" or "items.0.quantity"
+func (r *Repository[T]) UpdateFields(id string, fields map[string]interface{}) (interface{}, error) {
+	for path := range fields {
+		if err := r.ValidatePath(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.db.Update(r.databaseName, r.collectionName, IDFilter(id), bson.M{"$set": fields})
+}
+
+// ValidatePath return an error when the dotted path is not a field of the model, array indexes and positional
+// operators are skipped and fields below maps and interfaces are not checked
+func (r *Repository[T]) ValidatePath(path string) error {
+	if r.fields == nil || strings.HasPrefix(path, "$") {
+		return nil
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(path, ".") {
+		if _, err := strconv.Atoi(segment); err == nil || strings.HasPrefix(segment, "$") {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+
+	fieldPath := strings.Join(segments, ".")
+	if _, ok := r.fields[fieldPath]; ok || isOpenSchemaField(fieldPath, r.fields) {
+		return nil
+	}
+
+	return fmt.Errorf("Unknown field %q of %v", path, r.dataModel)
+}
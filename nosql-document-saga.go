@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// SagaRunning is the status of a saga running its steps
+	SagaRunning = "running"
+	// SagaCompensating is the status of a saga undoing its completed steps after a failed step
+	SagaCompensating = "compensating"
+	// SagaCompleted is the status of a saga which ran every step
+	SagaCompleted = "completed"
+	// SagaCompensated is the status of a saga whose completed steps were undone
+	SagaCompensated = "compensated"
+	// SagaFailed is the status of a saga whose compensation failed, it needs a manual repair
+	SagaFailed = "failed"
+)
+
+var (
+	// ErrUnknownSaga is returned when the saga of an execution is not registered
+	ErrUnknownSaga = errors.New("Saga is not registered")
+)
+
+// SagaStep model for a step of a saga, like a write to one database. Action and Compensation receive the payload
+// of the execution and may change it, like to keep the ID of a created document for the compensation. They may be run
+// again after a restart, so they must be idempotent.
+type SagaStep struct {
+	Name         string
+	Action       func(payload bson.M) error
+	Compensation func(payload bson.M) error // undo Action, nil when there is nothing to undo
+}
+
+// SagaCoordinator run sagas, sequences of steps spanning several databases, like MongoDB and a SQL database. When a
+// step fails, the compensations of the completed steps run in reverse order. The state of every execution is saved in
+// a collection before each step, so Recover resume the executions interrupted by a restart. Recover must run on one
+// instance only since executions are not locked.
+type SagaCoordinator struct {
+	store          INoSQLDocument
+	databaseName   string
+	collectionName string
+	mu             sync.RWMutex
+	sagas          map[string][]SagaStep
+}
+
+// NewSagaCoordinator init new coordinator saving the executions in databaseName.collectionName of store
+func NewSagaCoordinator(store INoSQLDocument, databaseName, collectionName string) (*SagaCoordinator, error) {
+	if err := checkNamespace(databaseName, collectionName); err != nil {
+		return nil, err
+	}
+
+	return &SagaCoordinator{store: store, databaseName: databaseName, collectionName: collectionName, sagas: make(map[string][]SagaStep)}, nil
+}
+
+// Register the steps of the saga name, the sagas must be registered before Run and Recover
+func (sc *SagaCoordinator) Register(name string, steps ...SagaStep) error {
+	if name == "" {
+		return &InvalidArgumentError{Argument: "name", Reason: "cannot be empty"}
+	}
+	if len(steps) == 0 {
+		return &InvalidArgumentError{Argument: "steps", Reason: "cannot be empty"}
+	}
+	for _, step := range steps {
+		if step.Action == nil {
+			return &InvalidArgumentError{Argument: "steps", Reason: fmt.Sprintf("step %q has no action", step.Name)}
+		}
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.sagas[name] = steps
+
+	return nil
+}
+
+// Run a new execution of the saga name with payload and return it once completed or compensated. The error is the
+// error of the failed step, with the error of the compensation when it failed too.
+func (sc *SagaCoordinator) Run(name string, payload bson.M) (*SagaExecution, error) {
+	if _, err := sc.getSteps(name); err != nil {
+		return nil, err
+	}
+	if payload == nil {
+		payload = bson.M{}
+	}
+
+	now := time.Now().UTC()
+	execution := &SagaExecution{
+		ID:        primitive.NewObjectID().Hex(),
+		Saga:      name,
+		Status:    SagaRunning,
+		Payload:   payload,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := sc.store.Create(sc.databaseName, sc.collectionName, []interface{}{execution}); err != nil {
+		log.Println("Unable to save saga execution: ", err)
+		return nil, err
+	}
+
+	return execution, sc.resume(execution)
+}
+
+// Recover resume the running and compensating executions, like after a restart, and return them. The error is the
+// first error of the executions, the other executions are resumed anyway.
+func (sc *SagaCoordinator) Recover() ([]SagaExecution, error) {
+	results, err := sc.store.Read(sc.databaseName, sc.collectionName, bson.M{"status": bson.M{"$in": []string{SagaRunning, SagaCompensating}}}, 0, reflect.TypeOf(SagaExecution{}))
+	if err != nil {
+		log.Println("Unable to read saga executions: ", err)
+		return nil, err
+	}
+
+	executions, ok := results.(*[]SagaExecution)
+	if !ok {
+		return nil, errors.New("Unable to map results to SagaExecution model")
+	}
+
+	var firstErr error
+	for i := range *executions {
+		if err := sc.resume(&(*executions)[i]); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return *executions, firstErr
+}
+
+// Get return the execution of id, or ErrDocumentNotFound
+func (sc *SagaCoordinator) Get(id string) (*SagaExecution, error) {
+	results, err := sc.store.Read(sc.databaseName, sc.collectionName, bson.M{"_id": id}, 1, reflect.TypeOf(SagaExecution{}))
+	if err != nil {
+		return nil, err
+	}
+
+	executions, ok := results.(*[]SagaExecution)
+	if !ok {
+		return nil, errors.New("Unable to map results to SagaExecution model")
+	}
+	if len(*executions) == 0 {
+		return nil, ErrDocumentNotFound
+	}
+
+	return &(*executions)[0], nil
+}
+
+// resume run the execution from its step, forward when it is running and backward when it is compensating
+func (sc *SagaCoordinator) resume(execution *SagaExecution) error {
+	steps, err := sc.getSteps(execution.Saga)
+	if err != nil {
+		return err
+	}
+	if execution.Payload == nil {
+		execution.Payload = bson.M{}
+	}
+
+	var stepErr error
+	if execution.Status == SagaRunning {
+		for ; execution.Step < len(steps); execution.Step++ {
+			if err := sc.save(execution); err != nil {
+				return err
+			}
+			if stepErr = steps[execution.Step].Action(execution.Payload); stepErr != nil {
+				log.Printf("Saga %s step %s failed: %v", execution.Saga, steps[execution.Step].Name, stepErr)
+				execution.Error = fmt.Sprintf("step %s: %v", steps[execution.Step].Name, stepErr)
+				break
+			}
+		}
+
+		if stepErr == nil {
+			execution.Status = SagaCompleted
+			return sc.save(execution)
+		}
+
+		// The failed step is assumed not applied, the compensation start from the previous step
+		execution.Status = SagaCompensating
+		execution.Step--
+	} else if execution.Error != "" {
+		stepErr = errors.New(execution.Error)
+	}
+
+	for ; execution.Step >= 0; execution.Step-- {
+		if err := sc.save(execution); err != nil {
+			return err
+		}
+
+		step := steps[execution.Step]
+		if step.Compensation == nil {
+			continue
+		}
+		if err := step.Compensation(execution.Payload); err != nil {
+			log.Printf("Saga %s compensation of step %s failed: %v", execution.Saga, step.Name, err)
+			execution.Status = SagaFailed
+			execution.Error += fmt.Sprintf("; compensation of step %s: %v", step.Name, err)
+			if saveErr := sc.save(execution); saveErr != nil {
+				log.Println("Unable to save saga execution: ", saveErr)
+			}
+			return multierror.Append(stepErr, err)
+		}
+	}
+
+	execution.Step = 0
+	execution.Status = SagaCompensated
+	if err := sc.save(execution); err != nil {
+		return err
+	}
+
+	return stepErr
+}
+
+// save the status, the step, the payload and the error of the execution
+func (sc *SagaCoordinator) save(execution *SagaExecution) error {
+	execution.UpdatedAt = time.Now().UTC()
+	update := bson.M{"$set": bson.M{
+		"status":    execution.Status,
+		"step":      execution.Step,
+		"payload":   execution.Payload,
+		"error":     execution.Error,
+		"updatedAt": execution.UpdatedAt,
+	}}
+
+	if _, err := sc.store.Update(sc.databaseName, sc.collectionName, bson.M{"_id": execution.ID}, update); err != nil {
+		log.Println("Unable to save saga execution: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// getSteps return the steps of the saga name, or ErrUnknownSaga
+func (sc *SagaCoordinator) getSteps(name string) ([]SagaStep, error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	steps, ok := sc.sagas[name]
+	if !ok {
+		return nil, ErrUnknownSaga
+	}
+
+	return steps, nil
+}
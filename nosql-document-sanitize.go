@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// RejectOperators return ErrOperatorInjection when a user supplied value hold operator keys, regular expressions or JavaScript
+	RejectOperators = "reject"
+	// EscapeOperators compare user supplied values with $eq, so operator keys are matched as literal documents
+	EscapeOperators = "escape"
+)
+
+var (
+	// ErrOperatorInjection is returned when a user supplied filter value or field path hold a query operator
+	ErrOperatorInjection = errors.New("Query operator in user supplied value")
+)
+
+// SafeFilter is a filter of field paths and values supplied by users, like the query parameters of a request, matched by
+// equality. It is marshaled with the values escaped by $eq, so {"$ne": null} match the literal document instead of every
+// document, and paths with $ segments like $where are refused. It can be used as filter of every operation.
+type SafeFilter map[string]interface{}
+
+// MarshalBSON return the filter escaped by EscapeOperators, see Filter
+func (f SafeFilter) MarshalBSON() ([]byte, error) {
+	filter, err := f.Filter(EscapeOperators)
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.Marshal(filter)
+}
+
+// Filter return the filter of the fields sorted by path with the values sanitized by mode, or ErrOperatorInjection
+// when a path has a $ segment
+func (f SafeFilter) Filter(mode string) (bson.D, error) {
+	paths := make([]string, 0, len(f))
+	for path := range f {
+		if err := checkFieldPath(path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	filter := make(bson.D, 0, len(paths))
+	for _, path := range paths {
+		value, err := SanitizeValue(f[path], mode)
+		if err != nil {
+			return nil, err
+		}
+		filter = append(filter, primitive.E{Key: path, Value: value})
+	}
+
+	return filter, nil
+}
+
+// SanitizeValue return value safe to compare by equality to a field in a filter, wrapped in $eq by EscapeOperators
+// or unchanged by RejectOperators when it does not hold operator keys, regular expressions or JavaScript
+func SanitizeValue(value interface{}, mode string) (interface{}, error) {
+	switch mode {
+	case EscapeOperators:
+		return bson.D{primitive.E{Key: "$eq", Value: value}}, nil
+	case RejectOperators:
+		document, err := toDocument(bson.D{primitive.E{Key: "value", Value: value}})
+		if err != nil {
+			return nil, err
+		}
+		if hasOperator(document[0].Value) {
+			return nil, ErrOperatorInjection
+		}
+		return value, nil
+	}
+
+	return nil, &InvalidArgumentError{Argument: "mode", Reason: "must be reject or escape"}
+}
+
+// checkFieldPath return ErrOperatorInjection when a segment of the dotted path is empty or start with $
+func checkFieldPath(path string) error {
+	for _, segment := range strings.Split(path, ".") {
+		if segment == "" || strings.HasPrefix(segment, "$") {
+			return ErrOperatorInjection
+		}
+	}
+
+	return nil
+}
+
+// hasOperator return true when value hold $ keys, regular expressions or JavaScript at any depth
+func hasOperator(value interface{}) bool {
+	switch v := value.(type) {
+	case bson.D:
+		for _, element := range v {
+			if strings.HasPrefix(element.Key, "$") || hasOperator(element.Value) {
+				return true
+			}
+		}
+	case bson.A:
+		for _, item := range v {
+			if hasOperator(item) {
+				return true
+			}
+		}
+	case primitive.Regex, primitive.JavaScript, primitive.CodeWithScope:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSafeFilterFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter SafeFilter
+		mode   string
+		want   bson.D
+		err    error
+	}{
+		{
+			name:   "escape wraps values in $eq sorted by path",
+			filter: SafeFilter{"name": "alice", "age": 30},
+			mode:   EscapeOperators,
+			want: bson.D{
+				{Key: "age", Value: bson.D{{Key: "$eq", Value: 30}}},
+				{Key: "name", Value: bson.D{{Key: "$eq", Value: "alice"}}},
+			},
+		},
+		{
+			name:   "escape keeps operator documents as literals",
+			filter: SafeFilter{"password": bson.M{"$ne": nil}},
+			mode:   EscapeOperators,
+			want:   bson.D{{Key: "password", Value: bson.D{{Key: "$eq", Value: bson.M{"$ne": nil}}}}},
+		},
+		{
+			name:   "reject keeps plain values",
+			filter: SafeFilter{"address.city": "Paris"},
+			mode:   RejectOperators,
+			want:   bson.D{{Key: "address.city", Value: "Paris"}},
+		},
+		{
+			name:   "reject refuses operator documents",
+			filter: SafeFilter{"password": bson.M{"$ne": nil}},
+			mode:   RejectOperators,
+			err:    ErrOperatorInjection,
+		},
+		{
+			name:   "reject refuses nested operators",
+			filter: SafeFilter{"roles": bson.A{bson.M{"$gt": ""}}},
+			mode:   RejectOperators,
+			err:    ErrOperatorInjection,
+		},
+		{
+			name:   "reject refuses regular expressions",
+			filter: SafeFilter{"name": primitive.Regex{Pattern: ".*"}},
+			mode:   RejectOperators,
+			err:    ErrOperatorInjection,
+		},
+		{
+			name:   "reject refuses JavaScript",
+			filter: SafeFilter{"name": primitive.JavaScript("sleep(1000)")},
+			mode:   RejectOperators,
+			err:    ErrOperatorInjection,
+		},
+		{
+			name:   "operator path is refused",
+			filter: SafeFilter{"$where": "sleep(1000)"},
+			mode:   EscapeOperators,
+			err:    ErrOperatorInjection,
+		},
+		{
+			name:   "operator segment is refused",
+			filter: SafeFilter{"profile.$where": "x"},
+			mode:   EscapeOperators,
+			err:    ErrOperatorInjection,
+		},
+		{
+			name:   "empty segment is refused",
+			filter: SafeFilter{"profile..name": "x"},
+			mode:   EscapeOperators,
+			err:    ErrOperatorInjection,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.filter.Filter(test.mode)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Filter() error = %v, want %v", err, test.err)
+			}
+			if err == nil && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Filter() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeValueInvalidMode(t *testing.T) {
+	var invalidArgument *InvalidArgumentError
+	if _, err := SanitizeValue("alice", "drop"); !errors.As(err, &invalidArgument) {
+		t.Errorf("SanitizeValue() error = %v, want InvalidArgumentError", err)
+	}
+}
+
+func TestSafeFilterMarshalBSON(t *testing.T) {
+	raw, err := bson.Marshal(SafeFilter{"password": bson.M{"$ne": nil}})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var got bson.D
+	if err := bson.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := bson.D{{Key: "password", Value: bson.D{{Key: "$eq", Value: bson.D{{Key: "$ne", Value: nil}}}}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Marshal() = %v, want %v", got, want)
+	}
+
+	if _, err := bson.Marshal(SafeFilter{"$where": "sleep(1000)"}); err == nil {
+		t.Error("Marshal() of an operator path error = nil, want an error")
+	}
+}
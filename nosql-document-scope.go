@@ -0,0 +1,332 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrOutOfScope is returned when a created document or an update set a scoped field outside of the scope
+	ErrOutOfScope = errors.New("Document is out of scope")
+	// ErrMissingScope is returned by the providers of ScopeFromContext when the context does not carry the scope
+	ErrMissingScope = errors.New("Scope is missing from the context")
+	// ErrUnscopedStage is returned when a scoped aggregation has a stage reading or writing another collection
+	ErrUnscopedStage = errors.New("Aggregation stage can not be scoped")
+)
+
+// unscopedStages are the aggregation stages reading or writing other collections without the scope
+var unscopedStages = []string{"$lookup", "$graphLookup", "$unionWith", "$out", "$merge"}
+
+// ScopeProvider return the mandatory equality clauses of the operations on the collection for the request context
+// bound with ScopedDatabase.For, like bson.M{"orgId": orgID}. Nil or empty clauses mean the collection is not scoped.
+type ScopeProvider func(ctx context.Context, databaseName, collectionName string) (bson.M, error)
+
+// ScopeFromContext return the provider scoping the collections by field to the value of the context key,
+// collections is the list of scoped collections, every collection is scoped when it is empty
+func ScopeFromContext(key interface{}, field string, collections ...string) ScopeProvider {
+	return func(ctx context.Context, databaseName, collectionName string) (bson.M, error) {
+		if len(collections) > 0 && !containsString(collections, collectionName) {
+			return nil, nil
+		}
+
+		value := ctx.Value(key)
+		if value == nil {
+			return nil, ErrMissingScope
+		}
+
+		return bson.M{field: value}, nil
+	}
+}
+
+// ScopedDatabase decorate INoSQLDocument with the scope of every operation, the filters of Read, Aggregate, Update
+// and Delete are combined with the scope, Create set the scoped fields of the documents and Update can not move
+// documents out of the scope. Scoped aggregations can not read or write other collections, ErrUnscopedStage is
+// returned for the $lookup, $graphLookup, $unionWith, $out and $merge stages.
+// The scope is bound per request with For, the providers get an empty context on the database returned by
+// NewScopedDatabase, so the operations on the collections scoped by ScopeFromContext return ErrMissingScope.
+type ScopedDatabase struct {
+	db       INoSQLDocument
+	provider ScopeProvider
+	context  context.Context
+}
+
+// NewScopedDatabase init new scoped database, provider is called before every operation
+func NewScopedDatabase(db INoSQLDocument, provider ScopeProvider) *ScopedDatabase {
+	return &ScopedDatabase{db: db, provider: provider, context: context.Background()}
+}
+
+// For return a handle scoping the operations to reqCtx, the database is bound to reqCtx too when it implements
+// IContextBinder
+func (s *ScopedDatabase) For(reqCtx context.Context) *ScopedDatabase {
+	db := s.db
+	if binder, ok := db.(IContextBinder); ok {
+		db = binder.BindContext(reqCtx)
+	}
+
+	return &ScopedDatabase{db: db, provider: s.provider, context: reqCtx}
+}
+
+// BindContext implement IContextBinder with For
+func (s *ScopedDatabase) BindContext(c context.Context) INoSQLDocument {
+	return s.For(c)
+}
+
+// Create the documents with the scoped fields, ErrOutOfScope is returned when a document has another value
+func (s *ScopedDatabase) Create(databaseName, collectionName string, documents []interface{}) (interface{}, error) {
+	scope, err := s.provider(s.context, databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	if len(scope) == 0 {
+		return s.db.Create(databaseName, collectionName, documents)
+	}
+
+	scoped := make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		value, err := toDocument(document)
+		if err != nil {
+			return nil, err
+		}
+		if value, err = setScope(value, scope); err != nil {
+			return nil, err
+		}
+		scoped = append(scoped, value)
+	}
+
+	return s.db.Create(databaseName, collectionName, scoped)
+}
+
+// Read the documents of the scope matching filter
+func (s *ScopedDatabase) Read(databaseName, collectionName string, filter interface{}, limit int64, dataModel reflect.Type) (interface{}, error) {
+	filter, err := s.getFilter(databaseName, collectionName, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.db.Read(databaseName, collectionName, filter, limit, dataModel)
+}
+
+// Aggregate run the pipeline on the documents of the scope, see IAggregate, ErrUnscopedStage is returned when a
+// stage, or a stage of a $facet, read or write another collection
+func (s *ScopedDatabase) Aggregate(databaseName, collectionName string, pipeline interface{}, dataModel reflect.Type) (interface{}, error) {
+	aggregator, ok := s.db.(IAggregate)
+	if !ok {
+		return nil, errors.New("Database does not support aggregation")
+	}
+
+	scope, err := s.provider(s.context, databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	if len(scope) == 0 {
+		return aggregator.Aggregate(databaseName, collectionName, pipeline, dataModel)
+	}
+
+	stages, err := getStages(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkScopeStages(stages); err != nil {
+		return nil, err
+	}
+
+	// $geoNear and $search must be the first stage of the pipeline
+	position := 0
+	if len(stages) > 0 {
+		if stage, ok := stages[0].(bson.D); ok && len(stage) > 0 && (stage[0].Key == "$geoNear" || stage[0].Key == "$search") {
+			position = 1
+		}
+	}
+
+	scoped := make(bson.A, 0, len(stages)+1)
+	scoped = append(scoped, stages[:position]...)
+	scoped = append(scoped, bson.M{"$match": scope})
+	scoped = append(scoped, stages[position:]...)
+
+	return aggregator.Aggregate(databaseName, collectionName, scoped, dataModel)
+}
+
+// Update the documents of the scope matching filter, ErrOutOfScope is returned when update change a scoped field.
+// Update pipelines get a last stage setting the scoped fields.
+func (s *ScopedDatabase) Update(databaseName, collectionName string, filter, update interface{}) (interface{}, error) {
+	scope, err := s.provider(s.context, databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	if len(scope) == 0 {
+		return s.db.Update(databaseName, collectionName, filter, update)
+	}
+
+	switch update.(type) {
+	case mongo.Pipeline, bson.A, []bson.D, []bson.M, []interface{}:
+		stages, err := getStages(update)
+		if err != nil {
+			return nil, err
+		}
+		set := bson.M{}
+		for field, value := range scope {
+			set[field] = bson.M{"$literal": value}
+		}
+		update = append(stages, bson.M{"$set": set})
+	default:
+		if update, err = checkScopeUpdate(update, scope); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.db.Update(databaseName, collectionName, scopeFilter(filter, scope), update)
+}
+
+// Delete the documents of the scope matching filter
+func (s *ScopedDatabase) Delete(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	filter, err := s.getFilter(databaseName, collectionName, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.db.Delete(databaseName, collectionName, filter)
+}
+
+// getFilter return filter combined with the scope of the collection
+func (s *ScopedDatabase) getFilter(databaseName, collectionName string, filter interface{}) (interface{}, error) {
+	scope, err := s.provider(s.context, databaseName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	return scopeFilter(filter, scope), nil
+}
+
+// scopeFilter return filter combined with scope, a nil filter stays nil to be refused by the database
+func scopeFilter(filter interface{}, scope bson.M) interface{} {
+	if len(scope) == 0 || filter == nil {
+		return filter
+	}
+
+	return bson.M{"$and": bson.A{filter, scope}}
+}
+
+// setScope set the scoped fields missing from document and return ErrOutOfScope when one has another value
+func setScope(document bson.D, scope bson.M) (bson.D, error) {
+	for field, value := range scope {
+		current, ok := getElement(document, field)
+		if !ok {
+			document = append(document, bson.E{Key: field, Value: value})
+			continue
+		}
+		if getValueKey(current) != getValueKey(value) {
+			return nil, ErrOutOfScope
+		}
+	}
+
+	return document, nil
+}
+
+// checkScopeUpdate return update with the scoped fields of the replacement, or ErrOutOfScope when an operator
+// change a scoped field, setting a scoped field to its scope value is allowed
+func checkScopeUpdate(update interface{}, scope bson.M) (interface{}, error) {
+	document, err := toDocument(update)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(document) == 0 || !strings.HasPrefix(document[0].Key, "$") {
+		return setScope(document, scope)
+	}
+
+	for _, operator := range document {
+		isSet := operator.Key == "$set" || operator.Key == "$setOnInsert"
+		values, _ := operator.Value.(bson.D)
+		for _, element := range values {
+			for field, value := range scope {
+				// $rename moves the value of the key to the field of its value
+				if target, ok := element.Value.(string); ok && operator.Key == "$rename" && isScopePath(target, field) {
+					return nil, ErrOutOfScope
+				}
+				if !isScopePath(element.Key, field) {
+					continue
+				}
+				if !isSet || element.Key != field || getValueKey(element.Value) != getValueKey(value) {
+					return nil, ErrOutOfScope
+				}
+			}
+		}
+	}
+
+	return document, nil
+}
+
+// isScopePath return true when path is the scoped field, one of its sub fields or one of its parents
+func isScopePath(path, field string) bool {
+	return path == field || strings.HasPrefix(path, field+".") || strings.HasPrefix(field, path+".")
+}
+
+// checkScopeStages return ErrUnscopedStage when a stage of stages, or of their $facet, read or write another
+// collection
+func checkScopeStages(stages bson.A) error {
+	for _, stage := range stages {
+		document, ok := stage.(bson.D)
+		if !ok {
+			continue
+		}
+
+		for _, element := range document {
+			if containsString(unscopedStages, element.Key) {
+				return ErrUnscopedStage
+			}
+			if element.Key != "$facet" {
+				continue
+			}
+
+			facets, _ := element.Value.(bson.D)
+			for _, facet := range facets {
+				pipeline, _ := facet.Value.(bson.A)
+				if err := checkScopeStages(pipeline); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// getStages return the stages of pipeline whatever its type (mongo.Pipeline, []bson.M, bson.A...), documents are bson.D
+func getStages(pipeline interface{}) (bson.A, error) {
+	raw, err := bson.Marshal(bson.M{"pipeline": pipeline})
+	if err != nil {
+		return nil, err
+	}
+
+	var stages struct {
+		Pipeline bson.A `bson:"pipeline"`
+	}
+	if err := bson.Unmarshal(raw, &stages); err != nil {
+		return nil, err
+	}
+
+	return stages.Pipeline, nil
+}
+
+// Capabilities implement ICapabilities
+func (s *ScopedDatabase) Capabilities() Capabilities {
+	return getDecoratedCapabilities(s.db)
+}
+
+// RunInTransaction implement ITransactional with the transactions of the database, tx is a *ScopedDatabase with the
+// scope of s
+func (s *ScopedDatabase) RunInTransaction(fn func(tx interface{}) error) error {
+	return WithTransaction(s.db, func(tx interface{}) error {
+		db, ok := tx.(INoSQLDocument)
+		if !ok {
+			return ErrTransactionsUnsupported
+		}
+
+		return fn(&ScopedDatabase{db: db, provider: s.provider, context: s.context})
+	})
+}
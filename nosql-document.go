@@ -1,6 +1,9 @@
 package storage
 
-import "reflect"
+import (
+	"context"
+	"reflect"
+)
 
 // INoSQLDocument factory pattern CRUD interface
 type INoSQLDocument interface {
@@ -10,9 +13,50 @@ type INoSQLDocument interface {
 	Delete(databaseName, collectionName string, filter interface{}) (interface{}, error)
 }
 
+// IContextBinder interface for databases able to return a handle running its operations with a request context,
+// like the cancellation, the deadline and the values read by the decorators
+type IContextBinder interface {
+	BindContext(c context.Context) INoSQLDocument
+}
+
+// IAggregate interface for databases able to run aggregation pipelines
+type IAggregate interface {
+	Aggregate(databaseName, collectionName string, pipeline interface{}, dataModel reflect.Type) (interface{}, error)
+}
+
+// IReadInto interface for databases able to decode the results into a slice provided by the caller, like &[]User{},
+// without reflection on the data model nor type assertion of the results
+type IReadInto interface {
+	ReadInto(databaseName, collectionName string, filter interface{}, limit int64, results interface{}) error
+	AggregateInto(databaseName, collectionName string, pipeline interface{}, results interface{}) error
+}
+
+// IPatch interface for databases able to apply JSON Patch and JSON Merge Patch documents
+type IPatch interface {
+	ApplyPatch(databaseName, collectionName string, id interface{}, patch []byte) error
+}
+
+// IPage interface for databases able to read pages by keyset pagination in both directions
+type IPage interface {
+	ReadPage(databaseName, collectionName string, filter interface{}, pageOptions *PageOptions, dataModel reflect.Type) (*Page, error)
+}
+
+// ISearch interface for databases able to run paginated searches on the full text index of a collection
+type ISearch interface {
+	Search(databaseName, collectionName string, query interface{}, searchOptions *SearchOptions, dataModel reflect.Type) (*SearchResult, error)
+}
+
 const (
 	// MONGODB database
 	MONGODB = iota
+	// ELASTICSEARCH database
+	ELASTICSEARCH
+	// DYNAMODB database
+	DYNAMODB
+	// COUCHBASE database
+	COUCHBASE
+	// EMBEDDED database stored in local files
+	EMBEDDED
 )
 
 // newNoSQLDocument init instance by factory pattern
@@ -21,6 +65,14 @@ func newNoSQLDocument(databaseCompany int, config *Config) interface{} {
 	switch databaseCompany {
 	case MONGODB:
 		return newMongoDB(&config.MongoDB)
+	case ELASTICSEARCH:
+		return newElasticsearch(&config.Elasticsearch)
+	case DYNAMODB:
+		return newDynamoDB(&config.DynamoDB)
+	case COUCHBASE:
+		return newCouchbase(&config.Couchbase)
+	case EMBEDDED:
+		return newEmbedded(&config.Embedded)
 	}
 
 	return nil
@@ -91,7 +91,7 @@ func (bc *BigCacheClient) Get(key string) (interface{}, error) {
 	}
 
 	var value interface{}
-	json.Unmarshal(b, value)
+	json.Unmarshal(b, &value)
 
 	return value, nil
 }
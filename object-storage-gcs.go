@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"io"
+
+	"google.golang.org/api/option"
+	gcs "google.golang.org/api/storage/v1"
+)
+
+// GCSObjectStorage store objects in a Google Cloud Storage bucket, uploads are streamed as resumable uploads so the
+// archives and backups are never held in memory
+type GCSObjectStorage struct {
+	Service *gcs.Service
+	Bucket  string
+}
+
+// NewGCSObjectStorage init new object storage in the bucket of config
+func NewGCSObjectStorage(config *GCS) (*GCSObjectStorage, error) {
+	if config.Bucket == "" {
+		return nil, &InvalidArgumentError{Argument: "bucket", Reason: "cannot be empty"}
+	}
+
+	var clientOptions []option.ClientOption
+	if config.Credential != "" {
+		clientOptions = append(clientOptions, option.WithCredentialsFile(config.Credential))
+	}
+
+	service, err := gcs.NewService(ctx, clientOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GCSObjectStorage{Service: service, Bucket: config.Bucket}, nil
+}
+
+// Upload write content to the object name, the object is only visible once the upload is complete
+func (g *GCSObjectStorage) Upload(name string, content io.Reader) error {
+	_, err := g.Service.Objects.Insert(g.Bucket, &gcs.Object{Name: name}).Media(content).Context(ctx).Do()
+	return err
+}
+
+// Download return the content of the object name
+func (g *GCSObjectStorage) Download(name string) (io.ReadCloser, error) {
+	response, err := g.Service.Objects.Get(g.Bucket, name).Context(ctx).Download()
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Body, nil
+}
+
+// List return the names of the objects starting with prefix
+func (g *GCSObjectStorage) List(prefix string) ([]string, error) {
+	var names []string
+	err := g.Service.Objects.List(g.Bucket).Prefix(prefix).Pages(ctx, func(objects *gcs.Objects) error {
+		for _, object := range objects.Items {
+			names = append(names, object.Name)
+		}
+		return nil
+	})
+
+	return names, err
+}
+
+// Delete remove the object name
+func (g *GCSObjectStorage) Delete(name string) error {
+	return g.Service.Objects.Delete(g.Bucket, name).Context(ctx).Do()
+}
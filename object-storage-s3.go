@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// S3ObjectStorage store objects in an S3 bucket, uploads are streamed as multipart uploads so the archives and
+// backups are never held in memory
+type S3ObjectStorage struct {
+	Client s3iface.S3API
+	Bucket string
+}
+
+// NewS3ObjectStorage init new object storage in the bucket of config
+func NewS3ObjectStorage(config *S3) (*S3ObjectStorage, error) {
+	if config.Bucket == "" {
+		return nil, &InvalidArgumentError{Argument: "bucket", Reason: "cannot be empty"}
+	}
+
+	awsConfig := aws.NewConfig().WithRegion(config.Region).WithS3ForcePathStyle(config.ForcePathStyle)
+	if config.Endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(config.Endpoint)
+	}
+	if config.AccessKeyID != "" {
+		awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(config.AccessKeyID, config.SecretAccessKey, config.SessionToken))
+	}
+
+	awsSession, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3ObjectStorage{Client: s3.New(awsSession), Bucket: config.Bucket}, nil
+}
+
+// Upload write content to the object name, the object is only visible once the upload is complete
+func (s *S3ObjectStorage) Upload(name string, content io.Reader) error {
+	uploader := s3manager.NewUploaderWithClient(s.Client)
+	_, err := uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(name),
+		Body:   content,
+	})
+
+	return err
+}
+
+// Download return the content of the object name
+func (s *S3ObjectStorage) Download(name string) (io.ReadCloser, error) {
+	output, err := s.Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return output.Body, nil
+}
+
+// List return the names of the objects starting with prefix
+func (s *S3ObjectStorage) List(prefix string) ([]string, error) {
+	var names []string
+	err := s.Client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			names = append(names, aws.StringValue(object.Key))
+		}
+		return true
+	})
+
+	return names, err
+}
+
+// Delete remove the object name
+func (s *S3ObjectStorage) Delete(name string) error {
+	_, err := s.Client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(name),
+	})
+
+	return err
+}
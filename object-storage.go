@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IObjectStorage interface of the backend storing backups and archives, like S3, GCS or the filesystem
+type IObjectStorage interface {
+	Upload(name string, content io.Reader) error
+	Download(name string) (io.ReadCloser, error)
+	List(prefix string) ([]string, error)
+	Delete(name string) error
+}
+
+// FileObjectStorage store objects in a directory of the filesystem
+type FileObjectStorage struct {
+	Directory string
+}
+
+// Upload write content to the file name, the file is only visible once it is complete
+func (f *FileObjectStorage) Upload(name string, content io.Reader) error {
+	target := filepath.Join(f.Directory, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), target)
+}
+
+// List return the names of the files starting with prefix
+func (f *FileObjectStorage) List(prefix string) ([]string, error) {
+	directory := filepath.Join(f.Directory, filepath.FromSlash(prefix))
+	if !strings.HasSuffix(prefix, "/") {
+		directory = filepath.Dir(directory)
+	}
+
+	files, err := ioutil.ReadDir(directory)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		name, err := filepath.Rel(f.Directory, filepath.Join(directory, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		name = filepath.ToSlash(name)
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// Download return the content of the file name
+func (f *FileObjectStorage) Download(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(f.Directory, filepath.FromSlash(name)))
+}
+
+// Delete remove the file name
+func (f *FileObjectStorage) Delete(name string) error {
+	return os.Remove(filepath.Join(f.Directory, filepath.FromSlash(name)))
+}
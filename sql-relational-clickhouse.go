@@ -0,0 +1,504 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/golang-common-packages/hash"
+)
+
+var (
+	// clickHouseClientSessionMapping singleton pattern
+	clickHouseClientSessionMapping = make(map[string]*ClickHouseClient)
+)
+
+const (
+	// defaultClickHouseBatchSize is the default number of rows of each insert request
+	defaultClickHouseBatchSize = 10000
+	// clickHouseBufferedBatches is the number of batches of rows a ClickHouseBatch keep while the inserts fail
+	clickHouseBufferedBatches = 10
+)
+
+// Window model for ReadWindow, rows with From <= Field < To ordered by Field
+type Window struct {
+	Field  string                 // DateTime or DateTime64 column of the window
+	From   time.Time              // inclusive, zero time means no lower bound
+	To     time.Time              // exclusive, zero time means no upper bound
+	Filter map[string]interface{} // equality conditions on other columns
+	Limit  int64                  // 0 means no limit
+	Final  bool                   // read with FINAL to merge the rows of ReplacingMergeTree tables, like the ClickHouseBridge tables
+}
+
+// ClickHouseError is returned when ClickHouse answer with an error status
+type ClickHouseError struct {
+	Status  int
+	Code    int // ClickHouse exception code, like 60 for an unknown table
+	Message string
+}
+
+// Error return the message of the ClickHouse error
+func (e *ClickHouseError) Error() string {
+	return fmt.Sprintf("ClickHouse error %d code %d: %s", e.Status, e.Code, e.Message)
+}
+
+// ClickHouseClient manage all ClickHouse actions through its HTTP interface. Rows are inserted and read in the
+// JSONEachRow format, the fields of the rows are the columns of the table by their bson tag.
+type ClickHouseClient struct {
+	Client *http.Client
+	Config *ClickHouse
+	mu     sync.Mutex
+	next   int // address tried first by the next request
+}
+
+// newClickHouse init new instance
+func newClickHouse(config *ClickHouse) *ClickHouseClient {
+	hasher := &hash.Client{}
+	configAsJSON, err := json.Marshal(config)
+	if err != nil {
+		log.Fatalln("Unable to marshal ClickHouse configuration: ", err)
+	}
+	configAsString := hasher.SHA1(string(configAsJSON))
+
+	currentClickHouseSession := clickHouseClientSessionMapping[configAsString]
+	if currentClickHouseSession == nil {
+		currentClickHouseSession = NewClickHouseClient(config)
+
+		// Check the connection status
+		if err := currentClickHouseSession.do("SELECT 1", nil, nil, nil); err != nil {
+			log.Fatalln("Unable to connect to ClickHouse: ", err)
+		}
+		clickHouseClientSessionMapping[configAsString] = currentClickHouseSession
+		log.Println("Connected to ClickHouse")
+	}
+
+	return currentClickHouseSession
+}
+
+// NewClickHouseClient init new client of config without checking the connection, New check it
+func NewClickHouseClient(config *ClickHouse) *ClickHouseClient {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+
+	return &ClickHouseClient{Client: &http.Client{Timeout: timeout}, Config: config}
+}
+
+// Execute run query and return its rows decoded to a slice of dataModel, a reflect.Type, or of bson.M when
+// dataModel is nil. Statements without result like DDL return an empty slice.
+func (c *ClickHouseClient) Execute(query string, dataModel interface{}) (interface{}, error) {
+	modelType := reflect.TypeOf(bson.M{})
+	if dataModel != nil {
+		t, ok := dataModel.(reflect.Type)
+		if !ok {
+			return nil, &InvalidArgumentError{Argument: "dataModel", Reason: "must be a reflect.Type"}
+		}
+		modelType = t
+	}
+
+	results, err := c.query(query, url.Values{"default_format": {"JSONEachRow"}}, modelType)
+	if err != nil {
+		log.Println("Unable to execute query: ", err)
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Insert the rows into databaseName.tableName by requests of BatchSize rows, a failed request return its error and
+// the rows of the previous requests stay inserted. Dates are sent in RFC 3339 and ObjectID as hex strings.
+func (c *ClickHouseClient) Insert(databaseName, tableName string, rows []interface{}) error {
+	if err := checkNamespace(databaseName, tableName); err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return &InvalidArgumentError{Argument: "rows", Reason: "cannot be empty"}
+	}
+
+	batchSize := c.getBatchSize()
+	query := "INSERT INTO " + getClickHouseTable(databaseName, tableName) + " FORMAT JSONEachRow"
+	params := url.Values{"date_time_input_format": {"best_effort"}}
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		var payload bytes.Buffer
+		for _, row := range rows[start:end] {
+			document, err := toDocument(row)
+			if err != nil {
+				return err
+			}
+			line, err := json.Marshal(toJSONValue(document))
+			if err != nil {
+				return err
+			}
+			payload.Write(line)
+			payload.WriteByte('\n')
+		}
+
+		if err := c.do(query, params, payload.Bytes(), nil); err != nil {
+			log.Println("Unable to insert rows: ", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadWindow return the rows of databaseName.tableName in window decoded to a slice of dataModel,
+// the bounds and the filter are sent as typed query parameters
+func (c *ClickHouseClient) ReadWindow(databaseName, tableName string, window *Window, dataModel reflect.Type) (interface{}, error) {
+	if err := checkNamespace(databaseName, tableName); err != nil {
+		return nil, err
+	}
+	if err := checkDataModel(dataModel); err != nil {
+		return nil, err
+	}
+	if window == nil || window.Field == "" {
+		return nil, &InvalidArgumentError{Argument: "window", Reason: "field is required"}
+	}
+	if err := checkLimit(window.Limit); err != nil {
+		return nil, err
+	}
+	if !window.From.IsZero() && !window.To.IsZero() && !window.From.Before(window.To) {
+		return nil, &InvalidArgumentError{Argument: "window", Reason: "from must be before to"}
+	}
+
+	params := url.Values{
+		"date_time_output_format":                 {"iso"},
+		"output_format_json_quote_64bit_integers": {"0"},
+		"wait_end_of_query":                       {"1"},
+	}
+	var conditions []string
+	addParam := func(column, operator string, value interface{}) error {
+		name := "p" + strconv.Itoa(len(conditions))
+		valueType, text, err := getClickHouseParam(value)
+		if err != nil {
+			return err
+		}
+		params.Set("param_"+name, text)
+		conditions = append(conditions, quoteClickHouseIdentifier(column)+" "+operator+" {"+name+":"+valueType+"}")
+		return nil
+	}
+
+	if !window.From.IsZero() {
+		if err := addParam(window.Field, ">=", window.From); err != nil {
+			return nil, err
+		}
+	}
+	if !window.To.IsZero() {
+		if err := addParam(window.Field, "<", window.To); err != nil {
+			return nil, err
+		}
+	}
+
+	columns := make([]string, 0, len(window.Filter))
+	for column := range window.Filter {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		if err := addParam(column, "=", window.Filter[column]); err != nil {
+			return nil, err
+		}
+	}
+
+	query := "SELECT * FROM " + getClickHouseTable(databaseName, tableName)
+	if window.Final {
+		query += " FINAL"
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY " + quoteClickHouseIdentifier(window.Field)
+	if window.Limit > 0 {
+		query += " LIMIT " + strconv.FormatInt(window.Limit, 10)
+	}
+	query += " FORMAT JSONEachRow"
+
+	results, err := c.query(query, params, dataModel)
+	if err != nil {
+		log.Println("Unable to read window: ", err)
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// query run query and decode its JSONEachRow rows to a pointer to a slice of dataModel
+func (c *ClickHouseClient) query(query string, params url.Values, dataModel reflect.Type) (interface{}, error) {
+	results := reflect.New(reflect.SliceOf(dataModel))
+	results.Elem().Set(reflect.MakeSlice(results.Elem().Type(), 0, 0))
+
+	err := c.do(query, params, nil, func(body io.Reader) error {
+		decoder := json.NewDecoder(bufio.NewReader(body))
+		decoder.UseNumber()
+		for {
+			var row map[string]interface{}
+			if err := decoder.Decode(&row); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			document := reflect.New(dataModel)
+			if err := decodeJSONDocument(row, document.Interface()); err != nil {
+				return err
+			}
+			results.Elem().Set(reflect.Append(results.Elem(), document.Elem()))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results.Interface(), nil
+}
+
+// do send the query to the addresses in turn until one answers and pass the response body to handle,
+// query is sent as body or, when data is not nil, as query parameter with data as body
+func (c *ClickHouseClient) do(query string, params url.Values, data []byte, handle func(io.Reader) error) error {
+	values := url.Values{}
+	for key, value := range params {
+		values[key] = value
+	}
+	payload := []byte(query)
+	if data != nil {
+		values.Set("query", query)
+		payload = data
+	}
+
+	c.mu.Lock()
+	first := c.next
+	c.mu.Unlock()
+
+	var lastErr error
+	for i := range c.Config.Addresses {
+		address := c.Config.Addresses[(first+i)%len(c.Config.Addresses)]
+		response, err := c.send(strings.TrimRight(address, "/")+"/?"+values.Encode(), payload)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		c.mu.Lock()
+		c.next = (first + i) % len(c.Config.Addresses)
+		c.mu.Unlock()
+
+		return decodeClickHouseResponse(response, handle)
+	}
+	if lastErr == nil {
+		lastErr = errors.New("No ClickHouse address")
+	}
+
+	return lastErr
+}
+
+// send the request with the credentials of the config
+func (c *ClickHouseClient) send(address string, payload []byte) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, address, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	if c.Config.Username != "" {
+		request.Header.Set("X-ClickHouse-User", c.Config.Username)
+		request.Header.Set("X-ClickHouse-Key", c.Config.Password)
+	}
+
+	return c.Client.Do(request)
+}
+
+// decodeClickHouseResponse close the response and pass its body to handle, or return the ClickHouseError of the status
+func decodeClickHouseResponse(response *http.Response, handle func(io.Reader) error) error {
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusMultipleChoices {
+		data, _ := io.ReadAll(response.Body)
+		code, _ := strconv.Atoi(response.Header.Get("X-ClickHouse-Exception-Code"))
+		return &ClickHouseError{Status: response.StatusCode, Code: code, Message: strings.TrimSpace(string(data))}
+	}
+
+	if handle == nil {
+		_, err := io.Copy(io.Discard, response.Body)
+		return err
+	}
+
+	return handle(response.Body)
+}
+
+// getClickHouseTable return the quoted table name of databaseName.tableName
+func getClickHouseTable(databaseName, tableName string) string {
+	return quoteClickHouseIdentifier(databaseName) + "." + quoteClickHouseIdentifier(tableName)
+}
+
+// quoteClickHouseIdentifier return the identifier between backquotes
+func quoteClickHouseIdentifier(identifier string) string {
+	return "`" + strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(identifier) + "`"
+}
+
+// getClickHouseParam return the ClickHouse type and the text of a query parameter value
+func getClickHouseParam(value interface{}) (string, string, error) {
+	switch v := value.(type) {
+	case string:
+		return "String", v, nil
+	case bool:
+		return "Bool", strconv.FormatBool(v), nil
+	case int, int8, int16, int32, int64:
+		return "Int64", fmt.Sprint(v), nil
+	case uint, uint8, uint16, uint32, uint64:
+		return "UInt64", fmt.Sprint(v), nil
+	case float32, float64:
+		return "Float64", fmt.Sprint(v), nil
+	case time.Time:
+		return "DateTime64(3, 'UTC')", v.UTC().Format("2006-01-02 15:04:05.000"), nil
+	}
+
+	return "", "", &InvalidArgumentError{Argument: "window", Reason: fmt.Sprintf("unsupported value type %T", value)}
+}
+
+// getBatchSize return the rows of each insert request, the config or the default
+func (c *ClickHouseClient) getBatchSize() int {
+	if c.Config.BatchSize > 0 {
+		return c.Config.BatchSize
+	}
+
+	return defaultClickHouseBatchSize
+}
+
+// ClickHouseBatch buffer rows of a table and insert them when BatchSize rows are buffered, every interval
+// and on Close. Rows of a failed insert are put back in the buffer and inserted by the next flush, the error is logged
+// and returned by the next Flush. Until then the buffer is only flushed every interval, and it keeps the last
+// clickHouseBufferedBatches batches of rows, the older rows are dropped.
+type ClickHouseBatch struct {
+	client       *ClickHouseClient
+	databaseName string
+	tableName    string
+	mu           sync.Mutex
+	rows         []interface{}
+	lastError    error
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+// NewClickHouseBatch init new batch of databaseName.tableName flushed every interval, 0 means only by size and Close
+func NewClickHouseBatch(client *ClickHouseClient, databaseName, tableName string, interval time.Duration) (*ClickHouseBatch, error) {
+	if err := checkNamespace(databaseName, tableName); err != nil {
+		return nil, err
+	}
+
+	b := &ClickHouseBatch{client: client, databaseName: databaseName, tableName: tableName, stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(b.done)
+		if interval <= 0 {
+			<-b.stop
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				b.flush()
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+
+	return b, nil
+}
+
+// Add buffer the rows and insert the buffer once it reaches BatchSize rows
+func (b *ClickHouseBatch) Add(rows ...interface{}) {
+	b.mu.Lock()
+	b.rows = append(b.rows, rows...)
+	full := len(b.rows) >= b.client.getBatchSize() && b.lastError == nil
+	b.mu.Unlock()
+
+	if full {
+		b.flush()
+	}
+}
+
+// Flush insert the buffered rows and return the error of the last failed insert
+func (b *ClickHouseBatch) Flush() error {
+	b.flush()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	err := b.lastError
+	b.lastError = nil
+
+	return err
+}
+
+// Close stop the interval flushes and insert the buffered rows
+func (b *ClickHouseBatch) Close() error {
+	close(b.stop)
+	<-b.done
+
+	return b.Flush()
+}
+
+// flush insert the buffered rows
+func (b *ClickHouseBatch) flush() {
+	b.mu.Lock()
+	rows := b.rows
+	b.rows = nil
+	b.mu.Unlock()
+
+	if len(rows) == 0 {
+		return
+	}
+
+	batchSize := b.client.getBatchSize()
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		if err := b.client.Insert(b.databaseName, b.tableName, rows[start:end]); err != nil {
+			log.Println("Unable to flush ClickHouse batch: ", err)
+			b.putBack(rows[start:], err)
+			return
+		}
+	}
+}
+
+// putBack buffer again the rows of a failed insert before the rows added since, dropping the oldest rows over
+// clickHouseBufferedBatches batches
+func (b *ClickHouseBatch) putBack(rows []interface{}, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastError = err
+	b.rows = append(append(make([]interface{}, 0, len(rows)+len(b.rows)), rows...), b.rows...)
+	if limit := b.client.getBatchSize() * clickHouseBufferedBatches; len(b.rows) > limit {
+		log.Println("Unable to buffer ClickHouse rows, dropped: ", len(b.rows)-limit)
+		b.rows = b.rows[len(b.rows)-limit:]
+	}
+}
+
+// Capabilities implement ICapabilities
+func (c *ClickHouseClient) Capabilities() Capabilities {
+	return Capabilities{}
+}
@@ -0,0 +1,423 @@
+package storage
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// sqlIdentifierPattern match the table and column names accepted in the generated statements, optionally schema qualified
+	sqlIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// ISQLTable interface for the models naming their table, used by SQLMapper when the table name is empty
+type ISQLTable interface {
+	TableName() string
+}
+
+// SQLMapper generate the statements of struct models for the SQL client, so the models used with MongoDB work with
+// the relational databases. Fields are mapped to columns like Query, see getSQLColumn, the primary key is the field
+// tagged db:",pk" or the bson _id. ObjectID are stored as hex strings and the nested structs, maps and slices as JSON.
+type SQLMapper struct {
+	queryer sqlQueryer
+	dialect string
+}
+
+// Mapper return the mapper of the models, the statements run inside tx when it is not nil,
+// or inside the transaction of the handle passed by RunInTransaction
+func (c *SQLLikeClient) Mapper(tx *sql.Tx) *SQLMapper {
+	if tx != nil {
+		return &SQLMapper{queryer: tx, dialect: c.Config.Dialect}
+	}
+
+	return &SQLMapper{queryer: c.getQueryer(), dialect: c.Config.Dialect}
+}
+
+// Insert the models, of the same struct type, in one statement. The omitempty columns are left to their default
+// value when they are zero in every model, like an auto increment primary key.
+func (sm *SQLMapper) Insert(tableName string, models ...interface{}) (sql.Result, error) {
+	if len(models) == 0 {
+		return nil, &InvalidArgumentError{Argument: "models", Reason: "cannot be empty"}
+	}
+
+	tableName, fields, err := sm.getMapping(tableName, models[0])
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]reflect.Value, len(models))
+	for i, model := range models {
+		value := reflect.ValueOf(model)
+		for value.Kind() == reflect.Ptr {
+			value = value.Elem()
+		}
+		if !value.IsValid() || (i > 0 && value.Type() != values[0].Type()) {
+			return nil, &InvalidArgumentError{Argument: "models", Reason: "must be non nil and have the same type"}
+		}
+		values[i] = value
+	}
+
+	var columns []sqlField
+	for _, field := range fields {
+		if field.omitEmpty && allZero(values, field.index) {
+			continue
+		}
+		columns = append(columns, field)
+	}
+	if len(columns) == 0 {
+		return nil, &InvalidArgumentError{Argument: "models", Reason: "have no column to insert"}
+	}
+
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.column
+	}
+
+	args := make([]interface{}, 0, len(columns)*len(values))
+	rows := make([]string, len(values))
+	for i, value := range values {
+		placeholders := make([]string, len(columns))
+		for j, column := range columns {
+			arg, err := getSQLValue(getSQLFieldValue(value, column.index))
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			placeholders[j] = sm.placeholder(len(args))
+		}
+		rows[i] = "(" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	query := "INSERT INTO " + tableName + " (" + strings.Join(names, ", ") + ") VALUES " + strings.Join(rows, ", ")
+
+	return sm.queryer.ExecContext(ctx, query, args...)
+}
+
+// Update set every column of the row of the model primary key to the model values
+func (sm *SQLMapper) Update(tableName string, model interface{}) (sql.Result, error) {
+	tableName, fields, err := sm.getMapping(tableName, model)
+	if err != nil {
+		return nil, err
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(model))
+	var assignments []string
+	var args []interface{}
+	var primaryKey *sqlField
+	for i, field := range fields {
+		if field.primaryKey {
+			primaryKey = &fields[i]
+			continue
+		}
+
+		arg, err := getSQLValue(getSQLFieldValue(value, field.index))
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		assignments = append(assignments, field.column+" = "+sm.placeholder(len(args)))
+	}
+	if len(assignments) == 0 {
+		return nil, &InvalidArgumentError{Argument: "model", Reason: "has no column to update"}
+	}
+
+	where, whereArgs, err := sm.getPrimaryKeyCondition(primaryKey, value, len(args))
+	if err != nil {
+		return nil, err
+	}
+
+	query := "UPDATE " + tableName + " SET " + strings.Join(assignments, ", ") + " WHERE " + where
+
+	return sm.queryer.ExecContext(ctx, query, append(args, whereArgs...)...)
+}
+
+// Delete the row of the model primary key
+func (sm *SQLMapper) Delete(tableName string, model interface{}) (sql.Result, error) {
+	tableName, fields, err := sm.getMapping(tableName, model)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args, err := sm.getPrimaryKeyCondition(getSQLPrimaryKey(fields), reflect.Indirect(reflect.ValueOf(model)), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return sm.queryer.ExecContext(ctx, "DELETE FROM "+tableName+" WHERE "+where, args...)
+}
+
+// Get scan the row of primary key id into model, a pointer to a struct, or return ErrDocumentNotFound
+func (sm *SQLMapper) Get(tableName string, id interface{}, model interface{}) error {
+	value := reflect.ValueOf(model)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return &InvalidArgumentError{Argument: "model", Reason: "must be a non nil pointer to a struct"}
+	}
+
+	tableName, fields, err := sm.getMapping(tableName, model)
+	if err != nil {
+		return err
+	}
+	primaryKey := getSQLPrimaryKey(fields)
+	if primaryKey == nil {
+		return &InvalidArgumentError{Argument: "model", Reason: "has no primary key"}
+	}
+	arg, err := getSQLValue(reflect.ValueOf(id))
+	if err != nil {
+		return err
+	}
+
+	query := "SELECT " + getSQLColumnList(fields) + " FROM " + tableName + " WHERE " + primaryKey.column + " = " + sm.placeholder(1)
+	results := reflect.New(reflect.SliceOf(value.Elem().Type()))
+	if err := queryInto(sm.queryer, results.Interface(), query, arg); err != nil {
+		return err
+	}
+	if results.Elem().Len() == 0 {
+		return ErrDocumentNotFound
+	}
+	value.Elem().Set(results.Elem().Index(0))
+
+	return nil
+}
+
+// Select scan the rows matching where, a condition with placeholders for args or empty for every row,
+// into results, a pointer to a slice of structs or of pointers to structs
+func (sm *SQLMapper) Select(tableName string, results interface{}, where string, args ...interface{}) error {
+	if err := checkResults(results); err != nil {
+		return err
+	}
+
+	model := reflect.New(reflect.TypeOf(results).Elem().Elem()).Interface()
+	tableName, fields, err := sm.getMapping(tableName, model)
+	if err != nil {
+		return err
+	}
+
+	query := "SELECT " + getSQLColumnList(fields) + " FROM " + tableName
+	if where != "" {
+		query += " WHERE " + where
+	}
+
+	return queryInto(sm.queryer, results, query, args...)
+}
+
+// getMapping return the table name, from the model when it is empty, and the fields of the model
+func (sm *SQLMapper) getMapping(tableName string, model interface{}) (string, []sqlField, error) {
+	modelType := reflect.TypeOf(model)
+	for modelType != nil && modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	if modelType == nil || modelType.Kind() != reflect.Struct {
+		return "", nil, &InvalidArgumentError{Argument: "model", Reason: "must be a struct"}
+	}
+
+	if tableName == "" {
+		if table, ok := reflect.New(modelType).Interface().(ISQLTable); ok {
+			tableName = table.TableName()
+		} else {
+			tableName = strings.ToLower(modelType.Name())
+		}
+	}
+	if !sqlIdentifierPattern.MatchString(tableName) {
+		return "", nil, &InvalidArgumentError{Argument: "tableName", Reason: fmt.Sprintf("%q is not a valid identifier", tableName)}
+	}
+
+	fields := getSQLFieldList(modelType)
+	for _, field := range fields {
+		if !sqlIdentifierPattern.MatchString(field.column) {
+			return "", nil, &InvalidArgumentError{Argument: "model", Reason: fmt.Sprintf("column %q is not a valid identifier", field.column)}
+		}
+	}
+
+	return tableName, fields, nil
+}
+
+// getPrimaryKeyCondition return the condition on the primary key value, its placeholder follow the previous arguments
+func (sm *SQLMapper) getPrimaryKeyCondition(primaryKey *sqlField, value reflect.Value, previous int) (string, []interface{}, error) {
+	if primaryKey == nil {
+		return "", nil, &InvalidArgumentError{Argument: "model", Reason: "has no primary key"}
+	}
+
+	field := getSQLFieldValue(value, primaryKey.index)
+	if !field.IsValid() || field.IsZero() {
+		return "", nil, &InvalidArgumentError{Argument: "model", Reason: "primary key cannot be empty"}
+	}
+	arg, err := getSQLValue(field)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return primaryKey.column + " = " + sm.placeholder(previous+1), []interface{}{arg}, nil
+}
+
+// placeholder return the placeholder of the nth argument of the dialect
+func (sm *SQLMapper) placeholder(n int) string {
+	switch sm.dialect {
+	case PostgresDialect, CockroachDBDialect:
+		return "$" + strconv.Itoa(n)
+	}
+
+	return "?"
+}
+
+// getSQLPrimaryKey return the primary key field, nil when there is none
+func getSQLPrimaryKey(fields []sqlField) *sqlField {
+	for i := range fields {
+		if fields[i].primaryKey {
+			return &fields[i]
+		}
+	}
+
+	return nil
+}
+
+// getSQLColumnList return the columns of the fields separated by comma
+func getSQLColumnList(fields []sqlField) string {
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = field.column
+	}
+
+	return strings.Join(columns, ", ")
+}
+
+// allZero return true when the field at index is zero in every value
+func allZero(values []reflect.Value, index []int) bool {
+	for _, value := range values {
+		if field := getSQLFieldValue(value, index); field.IsValid() && !field.IsZero() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// getSQLFieldValue return the field of value at index, the invalid value when it is in a nil embedded struct
+func getSQLFieldValue(value reflect.Value, index []int) reflect.Value {
+	field, err := value.FieldByIndexErr(index)
+	if err != nil {
+		return reflect.Value{}
+	}
+
+	return field
+}
+
+// isSQLJSON return true when the values of fieldType are stored as JSON
+func isSQLJSON(fieldType reflect.Type) bool {
+	for fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	if fieldType == timeType || fieldType == bytesType || fieldType.Implements(valuerType) || reflect.PtrTo(fieldType).Implements(scannerType) {
+		return false
+	}
+
+	switch fieldType.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice:
+		return true
+	case reflect.Array:
+		return fieldType != objectIDType
+	}
+
+	return false
+}
+
+// getSQLValue return the argument of the field value, ObjectID as hex string and JSON for nested values
+func getSQLValue(value reflect.Value) (interface{}, error) {
+	if !value.IsValid() {
+		return nil, nil
+	}
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil, nil
+		}
+		if value.Type().Implements(valuerType) {
+			return value.Interface(), nil
+		}
+		value = value.Elem()
+	}
+
+	if value.Type() == objectIDType {
+		return value.Interface().(primitive.ObjectID).Hex(), nil
+	}
+	if isSQLJSON(value.Type()) {
+		data, err := json.Marshal(value.Interface())
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+
+	return value.Interface(), nil
+}
+
+// getSQLDestination return the scan destination of the field, decoding ObjectID and JSON columns
+func getSQLDestination(field reflect.Value) interface{} {
+	fieldType := field.Type()
+	if fieldType == objectIDType {
+		return &sqlObjectID{target: field.Addr().Interface().(*primitive.ObjectID)}
+	}
+	if isSQLJSON(fieldType) {
+		return &sqlJSON{target: field.Addr().Interface()}
+	}
+
+	return field.Addr().Interface()
+}
+
+// sqlObjectID scan a hex string column into an ObjectID
+type sqlObjectID struct {
+	target *primitive.ObjectID
+}
+
+// Scan implement sql.Scanner
+func (s *sqlObjectID) Scan(src interface{}) error {
+	var hex string
+	switch v := src.(type) {
+	case nil:
+		*s.target = primitive.NilObjectID
+		return nil
+	case string:
+		hex = v
+	case []byte:
+		hex = string(v)
+	default:
+		return fmt.Errorf("Unable to scan %T into ObjectID", src)
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return err
+	}
+	*s.target = objectID
+
+	return nil
+}
+
+// sqlJSON scan a JSON column into a nested struct, map or slice
+type sqlJSON struct {
+	target interface{}
+}
+
+// Scan implement sql.Scanner
+func (s *sqlJSON) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), s.target)
+	case []byte:
+		return json.Unmarshal(v, s.target)
+	}
+
+	return errors.New("Unable to scan a non JSON column into " + reflect.TypeOf(s.target).Elem().String())
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+// sqlMappingTestUser is a model with valid columns
+type sqlMappingTestUser struct {
+	ID   int    `db:"id,pk"`
+	Name string `db:"name"`
+}
+
+// sqlMappingTestTable is a model naming its table
+type sqlMappingTestTable struct {
+	ID int `db:"id,pk"`
+}
+
+// TableName implement ISQLTable
+func (sqlMappingTestTable) TableName() string {
+	return "accounts; DROP TABLE accounts"
+}
+
+// sqlMappingTestColumn is a model with a column injecting SQL
+type sqlMappingTestColumn struct {
+	ID   int    `db:"id,pk"`
+	Name string `db:"name = name; DROP TABLE users; --"`
+}
+
+func TestSQLMapperGetMapping(t *testing.T) {
+	tests := []struct {
+		name      string
+		tableName string
+		model     interface{}
+		want      string
+		invalid   bool
+	}{
+		{name: "table", tableName: "users", model: sqlMappingTestUser{}, want: "users"},
+		{name: "schema qualified table", tableName: "public.users", model: &sqlMappingTestUser{}, want: "public.users"},
+		{name: "table of the type name", model: sqlMappingTestUser{}, want: "sqlmappingtestuser"},
+		{name: "statement in table", tableName: "users; DROP TABLE users", model: sqlMappingTestUser{}, invalid: true},
+		{name: "comment in table", tableName: "users--", model: sqlMappingTestUser{}, invalid: true},
+		{name: "quoted table", tableName: `"users"`, model: sqlMappingTestUser{}, invalid: true},
+		{name: "table starting with a digit", tableName: "1users", model: sqlMappingTestUser{}, invalid: true},
+		{name: "table with two qualifiers", tableName: "db.public.users", model: sqlMappingTestUser{}, invalid: true},
+		{name: "table with a space", tableName: "users u", model: sqlMappingTestUser{}, invalid: true},
+		{name: "statement in TableName", model: sqlMappingTestTable{}, invalid: true},
+		{name: "statement in column", tableName: "users", model: sqlMappingTestColumn{}, invalid: true},
+		{name: "model is not a struct", tableName: "users", model: map[string]interface{}{"id": 1}, invalid: true},
+		{name: "nil model", tableName: "users", model: nil, invalid: true},
+	}
+
+	mapper := &SQLMapper{}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, fields, err := mapper.getMapping(test.tableName, test.model)
+			if test.invalid {
+				var invalidArgument *InvalidArgumentError
+				if !errors.As(err, &invalidArgument) {
+					t.Fatalf("getMapping() error = %v, want InvalidArgumentError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getMapping() error = %v", err)
+			}
+			if got != test.want {
+				t.Errorf("getMapping() table = %q, want %q", got, test.want)
+			}
+			if columns := getSQLColumnList(fields); columns != "id, name" {
+				t.Errorf("getMapping() columns = %q, want %q", columns, "id, name")
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"reflect"
+	"strings"
+)
+
+// sqlQueryer is the part of sql.DB and sql.Tx used by the queries, so they run inside WithTransaction too
+type sqlQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// sqlField private model for a struct field mapped to a column
+type sqlField struct {
+	column     string
+	index      []int
+	fieldType  reflect.Type
+	primaryKey bool
+	omitEmpty  bool
+	inline     bool
+}
+
+// Query run the parameterized query and scan its rows into results, a non nil pointer to a slice of structs, of
+// pointers to structs, of maps like bson.M, or of single column values. The placeholders of args depend on the driver,
+// like $1 for Postgres and ? for MySQL. Columns are mapped to the fields by their db tag, their bson tag, or their name
+// in lower case like the MongoDB models, see getSQLColumn, and the columns without field are ignored.
+func (c *SQLLikeClient) Query(results interface{}, query string, args ...interface{}) error {
+	return queryInto(c.getQueryer(), results, query, args...)
+}
+
+// QueryTx run Query inside the transaction tx, like the one of WithTransaction
+func (c *SQLLikeClient) QueryTx(tx *sql.Tx, results interface{}, query string, args ...interface{}) error {
+	return queryInto(tx, results, query, args...)
+}
+
+// Exec run the parameterized statement and return its result, see Query for the placeholders.
+// Query and Exec run inside the transaction of the handle passed by RunInTransaction.
+func (c *SQLLikeClient) Exec(query string, args ...interface{}) (sql.Result, error) {
+	result, err := c.getQueryer().ExecContext(ctx, query, args...)
+	if err != nil {
+		log.Println("Unable to execute statement: ", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ExecTx run Exec inside the transaction tx, like the one of WithTransaction
+func (c *SQLLikeClient) ExecTx(tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		log.Println("Unable to execute statement: ", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// queryInto run the query with queryer and scan its rows into results
+func queryInto(queryer sqlQueryer, results interface{}, query string, args ...interface{}) error {
+	if err := checkResults(results); err != nil {
+		return err
+	}
+
+	rows, err := queryer.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Println("Unable to execute query: ", err)
+		return err
+	}
+	defer rows.Close()
+
+	if err := scanRows(rows, reflect.ValueOf(results).Elem()); err != nil {
+		log.Println("Unable to scan rows data: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// scanRows append the rows to slice, a slice value
+func scanRows(rows *sql.Rows, slice reflect.Value) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	elementType := slice.Type().Elem()
+	modelType := elementType
+	if modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+
+	var fields map[string]sqlField
+	isStruct := modelType.Kind() == reflect.Struct && modelType != timeType
+	if isStruct {
+		fields = getSQLFields(modelType)
+	}
+	isMap := modelType.Kind() == reflect.Map && modelType.Key().Kind() == reflect.String && modelType.Elem().Kind() == reflect.Interface
+	if !isStruct && !isMap && len(columns) != 1 {
+		return &InvalidArgumentError{Argument: "results", Reason: "must be a slice of structs or maps for queries of several columns"}
+	}
+
+	items := reflect.MakeSlice(slice.Type(), 0, 0)
+	for rows.Next() {
+		item := reflect.New(modelType)
+		destinations := make([]interface{}, len(columns))
+
+		switch {
+		case isStruct:
+			for i, column := range columns {
+				if field, ok := fields[strings.ToLower(column)]; ok {
+					destinations[i] = getSQLDestination(getFieldByIndex(item.Elem(), field.index))
+				} else {
+					destinations[i] = new(interface{})
+				}
+			}
+		case isMap:
+			for i := range columns {
+				destinations[i] = new(interface{})
+			}
+		default:
+			destinations[0] = item.Interface()
+		}
+
+		if err := rows.Scan(destinations...); err != nil {
+			return err
+		}
+
+		if isMap {
+			document := reflect.MakeMapWithSize(modelType, len(columns))
+			for i, column := range columns {
+				value := *(destinations[i].(*interface{}))
+				// Drivers return text columns as bytes
+				if data, ok := value.([]byte); ok {
+					value = string(data)
+				}
+				if value == nil {
+					document.SetMapIndex(reflect.ValueOf(column), reflect.Zero(modelType.Elem()))
+				} else {
+					document.SetMapIndex(reflect.ValueOf(column), reflect.ValueOf(value))
+				}
+			}
+			item.Elem().Set(document)
+		}
+
+		if elementType.Kind() == reflect.Ptr {
+			items = reflect.Append(items, item)
+		} else {
+			items = reflect.Append(items, item.Elem())
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	slice.Set(items)
+
+	return nil
+}
+
+// getSQLFields return the fields of the struct type by lower case column name, the inline and anonymous struct
+// fields are flattened
+func getSQLFields(structType reflect.Type) map[string]sqlField {
+	fields := make(map[string]sqlField)
+	for _, field := range getSQLFieldList(structType) {
+		fields[strings.ToLower(field.column)] = field
+	}
+
+	return fields
+}
+
+// getSQLFieldList return the fields of the struct type in declaration order, the first field of a column wins
+func getSQLFieldList(structType reflect.Type) []sqlField {
+	var fields []sqlField
+	collectSQLFields(structType, nil, map[string]bool{}, &fields)
+
+	return fields
+}
+
+// collectSQLFields add the fields of structType under the index path to fields
+func collectSQLFields(structType reflect.Type, index []int, seen map[string]bool, fields *[]sqlField) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		fieldIndex := append(append([]int(nil), index...), i)
+		column := getSQLColumn(field)
+		if column.column == "-" {
+			continue
+		}
+
+		fieldType := field.Type
+		if field.Anonymous && fieldType.Kind() == reflect.Ptr {
+			if field.PkgPath != "" {
+				// The pointer of an unexported embedded struct can not be allocated
+				continue
+			}
+			fieldType = fieldType.Elem()
+		}
+		if (column.inline || field.Anonymous) && fieldType.Kind() == reflect.Struct && fieldType != timeType {
+			collectSQLFields(fieldType, fieldIndex, seen, fields)
+			continue
+		}
+		if field.PkgPath != "" || seen[strings.ToLower(column.column)] {
+			continue
+		}
+
+		seen[strings.ToLower(column.column)] = true
+		column.index = fieldIndex
+		column.fieldType = field.Type
+		*fields = append(*fields, column)
+	}
+}
+
+// getSQLColumn return the column of the field from its db tag, its bson tag or its name in lower case. The bson _id
+// is mapped to the id column and is the primary key like the db tag option pk, the omitempty option of both tags
+// omit the zero values from the inserts.
+func getSQLColumn(field reflect.StructField) sqlField {
+	var column sqlField
+
+	bsonTag := strings.Split(field.Tag.Get("bson"), ",")
+	for _, flag := range bsonTag[1:] {
+		column.inline = column.inline || flag == "inline"
+		column.omitEmpty = column.omitEmpty || flag == "omitempty"
+	}
+	dbTag := strings.Split(field.Tag.Get("db"), ",")
+	for _, flag := range dbTag[1:] {
+		column.primaryKey = column.primaryKey || flag == "pk"
+		column.omitEmpty = column.omitEmpty || flag == "omitempty"
+	}
+
+	switch {
+	case dbTag[0] != "":
+		column.column = dbTag[0]
+	case bsonTag[0] == "_id":
+		column.column = "id"
+	case bsonTag[0] != "":
+		column.column = bsonTag[0]
+	default:
+		column.column = strings.ToLower(field.Name)
+	}
+	column.primaryKey = column.primaryKey || bsonTag[0] == "_id"
+
+	return column
+}
+
+// getFieldByIndex return the field of value at index, the nil pointers of embedded structs are allocated
+func getFieldByIndex(value reflect.Value, index []int) reflect.Value {
+	for i, position := range index {
+		if i > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		value = value.Field(position)
+	}
+
+	return value
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const (
+	// PostgresDialect retry the whole transaction on serialization failures of SERIALIZABLE transactions
+	PostgresDialect = "postgres"
+	// CockroachDBDialect retry the transaction with the CockroachDB client-side protocol: the statements are rerun
+	// after ROLLBACK TO SAVEPOINT cockroach_restart, which keeps the priority of the transaction across attempts
+	CockroachDBDialect = "cockroachdb"
+
+	// serializationFailureCode is the SQLSTATE of the transactions aborted by a conflict and safe to retry
+	serializationFailureCode = "40001"
+	// cockroachDBRestartSavepoint is the savepoint name recognized by CockroachDB for transaction retries
+	cockroachDBRestartSavepoint = "cockroach_restart"
+	// maxTransactionRetryDelay is the maximum delay between two attempts of a transaction
+	maxTransactionRetryDelay = time.Second
+)
+
+// WithTransaction run fn inside one transaction and commit it, or roll it back when fn or the commit fail.
+// Serialization failures (SQLSTATE 40001) are retried up to MaxTransactionRetries times with backoff, fn is run again
+// on every attempt so it must not have side effects outside the transaction. The database errors of the statements
+// run by fn must be returned as is, or wrapped with %w, to be detected.
+func (c *SQLLikeClient) WithTransaction(fn func(tx *sql.Tx) error, opts *sql.TxOptions) error {
+	if c.Config.Dialect == CockroachDBDialect {
+		return c.withSavepointRetry(fn, opts)
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = c.runTransaction(fn, opts); err == nil || !c.shouldRetry(err, attempt) {
+			return err
+		}
+	}
+}
+
+// RunInTransaction implement ITransactional with WithTransaction and the default transaction options, tx is a
+// *SQLLikeClient whose Query, Exec and Mapper run inside the transaction. Called from tx, it run fn in the current
+// transaction.
+func (c *SQLLikeClient) RunInTransaction(fn func(tx interface{}) error) error {
+	if c.tx != nil {
+		return fn(c)
+	}
+
+	return c.WithTransaction(func(tx *sql.Tx) error {
+		return fn(&SQLLikeClient{Client: c.Client, Config: c.Config, tx: tx})
+	}, nil)
+}
+
+// getQueryer return the transaction of the handle, or the database for the client
+func (c *SQLLikeClient) getQueryer() sqlQueryer {
+	if c.tx != nil {
+		return c.tx
+	}
+
+	return c.Client
+}
+
+// runTransaction run fn inside one transaction
+func (c *SQLLikeClient) runTransaction(fn func(tx *sql.Tx) error, opts *sql.TxOptions) error {
+	tx, err := c.Client.BeginTx(ctx, opts)
+	if err != nil {
+		log.Println("Unable to begin transaction: ", err)
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// withSavepointRetry run fn inside one transaction and retry it from the cockroach_restart savepoint
+func (c *SQLLikeClient) withSavepointRetry(fn func(tx *sql.Tx) error, opts *sql.TxOptions) error {
+	tx, err := c.Client.BeginTx(ctx, opts)
+	if err != nil {
+		log.Println("Unable to begin transaction: ", err)
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+cockroachDBRestartSavepoint); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = fn(tx)
+		if err == nil {
+			// The release commit the transaction, a serialization failure can still happen here
+			if _, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT "+cockroachDBRestartSavepoint); err == nil {
+				return tx.Commit()
+			}
+		}
+
+		if !c.shouldRetry(err, attempt) {
+			tx.Rollback()
+			return err
+		}
+		if _, rollbackErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+cockroachDBRestartSavepoint); rollbackErr != nil {
+			log.Println("Unable to rollback to savepoint: ", rollbackErr)
+			tx.Rollback()
+			return err
+		}
+	}
+}
+
+// shouldRetry return true after the backoff delay when err is a serialization failure and attempts remain
+func (c *SQLLikeClient) shouldRetry(err error, attempt int) bool {
+	if !IsSerializationFailure(err) || attempt >= c.Config.MaxTransactionRetries || ctx.Err() != nil {
+		return false
+	}
+
+	// Exponential backoff from 10ms with full jitter, so the conflicting transactions do not retry together
+	delay := 10 * time.Millisecond << uint(attempt)
+	if delay <= 0 || delay > maxTransactionRetryDelay {
+		delay = maxTransactionRetryDelay
+	}
+	delay = time.Duration(rand.Int63n(int64(delay)) + 1)
+
+	log.Printf("Transaction serialization failure, retry %d in %v", attempt+1, delay)
+	time.Sleep(delay)
+
+	return true
+}
+
+// IsSerializationFailure return true when err is a transaction aborted by a conflict (SQLSTATE 40001), based on the
+// SQLState method of the pgx and lib/pq errors or on the message for the other drivers
+func IsSerializationFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState() == serializationFailureCode
+	}
+
+	message := err.Error()
+	return strings.Contains(message, "SQLSTATE "+serializationFailureCode) || strings.Contains(message, "restart transaction")
+}
@@ -14,6 +14,7 @@ import (
 type SQLLikeClient struct {
 	Client *sql.DB
 	Config *LIKE
+	tx     *sql.Tx // transaction of a handle passed by RunInTransaction, nil for the client
 }
 
 var (
@@ -33,7 +34,7 @@ func newSQLLike(config *LIKE) *SQLLikeClient {
 
 	currentSQLLikeSession := sqlLikeClientSessionMapping[configAsString]
 	if currentSQLLikeSession == nil {
-		currentSQLLikeSession = &SQLLikeClient{nil, nil}
+		currentSQLLikeSession = &SQLLikeClient{}
 
 		client, err := sql.Open(config.DriverName, config.DataSourceName)
 		if err != nil {
@@ -97,3 +98,8 @@ func (c *SQLLikeClient) Execute(
 
 	return results, nil
 }
+
+// Capabilities implement ICapabilities
+func (c *SQLLikeClient) Capabilities() Capabilities {
+	return Capabilities{Transactions: true}
+}
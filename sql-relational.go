@@ -1,13 +1,23 @@
 package storage
 
+import "reflect"
+
 // ISQLRelational factory pattern interface
 type ISQLRelational interface {
 	Execute(query string, dataModel interface{}) (interface{}, error)
 }
 
+// IAnalytics interface for analytical databases, rows are appended by batches and read by time window
+type IAnalytics interface {
+	Insert(databaseName, tableName string, rows []interface{}) error
+	ReadWindow(databaseName, tableName string, window *Window, dataModel reflect.Type) (interface{}, error)
+}
+
 const (
 	// SQLLike database (common relational database)
 	SQLLike = iota
+	// CLICKHOUSE analytical database
+	CLICKHOUSE
 )
 
 // newSQLRelational factory pattern
@@ -16,6 +26,8 @@ func newSQLRelational(databaseCompany int, config *Config) interface{} {
 	switch databaseCompany {
 	case SQLLike:
 		return newSQLLike(&config.LIKE)
+	case CLICKHOUSE:
+		return newClickHouse(&config.ClickHouse)
 	}
 
 	return nil
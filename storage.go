@@ -18,6 +18,44 @@ var (
 	ctx = context.Background()
 )
 
+// ICapabilities interface for databases describing their features, so generic code can feature-detect
+// instead of type-switching on the client, see GetCapabilities
+type ICapabilities interface {
+	Capabilities() Capabilities
+}
+
+// GetCapabilities return the capabilities of db, a client returned by New or a decorator. They are inferred from
+// the optional interfaces implemented by db when it does not implement ICapabilities.
+func GetCapabilities(db interface{}) Capabilities {
+	if describer, ok := db.(ICapabilities); ok {
+		return describer.Capabilities()
+	}
+
+	_, transactions := db.(ITransactional)
+	_, aggregation := db.(IAggregate)
+	_, textSearch := db.(ISearch)
+	_, pagination := db.(IPage)
+
+	return Capabilities{Transactions: transactions, Aggregation: aggregation, TextSearch: textSearch, Pagination: pagination}
+}
+
+// ITransactional interface for databases able to run a function inside one transaction, see WithTransaction
+type ITransactional interface {
+	RunInTransaction(fn func(tx interface{}) error) error
+}
+
+// WithTransaction run fn inside one transaction of db, MongoDB sessions or SQL BEGIN and COMMIT, and return
+// ErrTransactionsUnsupported when db can not run transactions. tx is a handle of db bound to the transaction, of the
+// same type as db, like *SQLLikeClient or *MongoClient. The operations of tx join the transaction, which is committed
+// when fn return nil and aborted otherwise, while the operations of db do not.
+func WithTransaction(db interface{}, fn func(tx interface{}) error) error {
+	if transactional, ok := db.(ITransactional); ok {
+		return transactional.RunInTransaction(fn)
+	}
+
+	return ErrTransactionsUnsupported
+}
+
 // New database by abstract factory pattern
 func New(context context.Context, databaseType int) func(databaseCompany int, config *Config) interface{} {
 	SetContext(context)
@@ -35,3 +73,12 @@ func New(context context.Context, databaseType int) func(databaseCompany int, co
 		return nil
 	}
 }
+
+// getDecoratedCapabilities return the capabilities of db for a decorator passing through Aggregate only
+func getDecoratedCapabilities(db interface{}) Capabilities {
+	capabilities := GetCapabilities(db)
+	capabilities.TextSearch = false
+	capabilities.Pagination = false
+
+	return capabilities
+}
@@ -36,3 +36,13 @@ func streamToString(stream io.Reader) string {
 	buf.ReadFrom(stream)
 	return buf.String()
 }
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
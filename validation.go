@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InvalidArgumentError is returned when an argument of an operation is invalid, before anything is sent to the database
+type InvalidArgumentError struct {
+	Argument string // name of the offending parameter, like collectionName
+	Reason   string
+}
+
+// Error return the message of the invalid argument
+func (e *InvalidArgumentError) Error() string {
+	return fmt.Sprintf("Invalid argument %s: %s", e.Argument, e.Reason)
+}
+
+// checkNamespace return an InvalidArgumentError when the database or collection name can not be used
+func checkNamespace(databaseName, collectionName string) error {
+	if databaseName == "" {
+		return &InvalidArgumentError{Argument: "databaseName", Reason: "cannot be empty"}
+	}
+	if strings.ContainsAny(databaseName, "/\\. \"$\x00") {
+		return &InvalidArgumentError{Argument: "databaseName", Reason: "cannot contain /\\. \"$ or null characters"}
+	}
+	if collectionName == "" {
+		return &InvalidArgumentError{Argument: "collectionName", Reason: "cannot be empty"}
+	}
+	if strings.ContainsAny(collectionName, "$\x00") {
+		return &InvalidArgumentError{Argument: "collectionName", Reason: "cannot contain $ or null characters"}
+	}
+
+	return nil
+}
+
+// checkLimit return an InvalidArgumentError when limit is negative
+func checkLimit(limit int64) error {
+	if limit < 0 {
+		return &InvalidArgumentError{Argument: "limit", Reason: "cannot be negative"}
+	}
+
+	return nil
+}
+
+// checkDataModel return an InvalidArgumentError when dataModel is nil
+func checkDataModel(dataModel reflect.Type) error {
+	if dataModel == nil {
+		return &InvalidArgumentError{Argument: "dataModel", Reason: "cannot be nil"}
+	}
+
+	return nil
+}
+
+// checkResults return an InvalidArgumentError when results is not a non nil pointer to a slice
+func checkResults(results interface{}) error {
+	value := reflect.ValueOf(results)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Slice {
+		return &InvalidArgumentError{Argument: "results", Reason: "must be a non nil pointer to a slice"}
+	}
+
+	return nil
+}
+
+// checkNotNil return an InvalidArgumentError naming argument when value is nil
+func checkNotNil(argument string, value interface{}) error {
+	if value == nil {
+		return &InvalidArgumentError{Argument: argument, Reason: "cannot be nil"}
+	}
+
+	return nil
+}
+
+// ParseObjectID return the ObjectID of the hex string, an InvalidArgumentError naming argument when it is not valid
+func ParseObjectID(argument, id string) (primitive.ObjectID, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, &InvalidArgumentError{Argument: argument, Reason: "must be a 24 characters hex ObjectID"}
+	}
+
+	return objectID, nil
+}